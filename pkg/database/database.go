@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"go-audio-stream/pkg/database/search"
 	"go-audio-stream/pkg/models"
 	"log"
 	"os"
@@ -59,12 +60,25 @@ func New() Service {
 
 	gorm_db.Exec("CREATE EXTENSION IF NOT EXISTS vector")
 
+	if err := search.RegisterCallbacks(gorm_db); err != nil {
+		log.Fatal(err)
+	}
+
+	// Artist credits carry a role and position on the many2many join
+	gorm_db.SetupJoinTable(&models.Song{}, "Artists", &models.SongCredit{})
+	gorm_db.SetupJoinTable(&models.Artist{}, "Songs", &models.SongCredit{})
+	// Follows record when they were made
+	gorm_db.SetupJoinTable(&models.User{}, "Follows", &models.UserFollowsArtist{})
+
 	gorm_db.AutoMigrate(
 		&models.User{},
 		&models.Artist{},
+		&models.Album{},
 		&models.Song{},
 		&models.Playlist{},
 		&models.PlaylistSong{},
+		&models.PlaylistMember{},
+		&models.PlaylistInvite{},
 		&models.Device{},
 		&models.DevicePairing{},
 		&models.UserListenHistory{},
@@ -75,6 +89,8 @@ func New() Service {
 		&models.SongTag{},
 		&models.UserLocalSong{},
 		&models.SchemaMigration{},
+		&models.Upload{},
+		&models.SongAsset{},
 	)
 
 	dbInstance = &service{
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// cursor is the position after the last row of a page. It records the sort
+// it was made for, since a keyset only makes sense under the same ordering.
+type cursor struct {
+	Sort string `json:"s"`
+	// Kind tells how Value was encoded: t (time), n (number), b (bool) or
+	// s (string)
+	Kind  string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+// encodeCursor renders an opaque cursor for the row with the given sort
+// value and id
+func encodeCursor(sort string, value interface{}, id string) (string, error) {
+	c := cursor{Sort: sort, ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		c.Kind, value = "t", v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "", fmt.Errorf("cannot page by a null time")
+		}
+		c.Kind, value = "t", v.UTC().Format(time.RFC3339Nano)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		c.Kind = "n"
+	case bool:
+		c.Kind = "b"
+	case string:
+		c.Kind = "s"
+	default:
+		c.Kind, value = "s", fmt.Sprint(v)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	c.Value = raw
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a cursor made by encodeCursor
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" || c.value() == nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	return &c, nil
+}
+
+// value returns the sort value typed for the database driver, or nil when it
+// does not match its kind
+func (c *cursor) value() interface{} {
+	switch c.Kind {
+	case "t":
+		var s string
+		if json.Unmarshal(c.Value, &s) != nil {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil
+		}
+		return t
+	case "n":
+		var n json.Number
+		if json.Unmarshal(c.Value, &n) != nil {
+			return nil
+		}
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil
+		}
+		return f
+	case "b":
+		var b bool
+		if json.Unmarshal(c.Value, &b) != nil {
+			return nil
+		}
+		return b
+	case "s":
+		var s string
+		if json.Unmarshal(c.Value, &s) != nil {
+			return nil
+		}
+		return s
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Relation is an association loaded for an include
+type Relation struct {
+	// Path is the association path, e.g. "PlaylistSongs.Song"
+	Path string
+	// Order optionally sorts the loaded rows, e.g. "position"
+	Order string
+}
+
+// Includes maps the values accepted by the include parameter to the
+// relations each one loads
+type Includes map[string][]Relation
+
+// Preload loads the relations named in include, a comma-separated list of
+// allow-listed names. Errors wrap ErrInvalid.
+func Preload(tx *gorm.DB, includes Includes, include string) (*gorm.DB, error) {
+	loaded := map[string]bool{}
+	for _, name := range strings.Split(include, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		relations, ok := includes[name]
+		if !ok {
+			names := make([]string, 0, len(includes))
+			for name := range includes {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return nil, fmt.Errorf("%w: include must be a list of %s", ErrInvalid, strings.Join(names, ", "))
+		}
+
+		for _, relation := range relations {
+			// Includes share paths, e.g. songs and songs.artists
+			if loaded[relation.Path] {
+				continue
+			}
+			loaded[relation.Path] = true
+			if relation.Order == "" {
+				tx = tx.Preload(relation.Path)
+				continue
+			}
+			order := relation.Order
+			tx = tx.Preload(relation.Path, func(db *gorm.DB) *gorm.DB {
+				return db.Order(order)
+			})
+		}
+	}
+	return tx, nil
+}
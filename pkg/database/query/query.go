@@ -0,0 +1,153 @@
+// Package query applies the limit, cursor, sort and filter parameters of
+// list endpoints to GORM queries. Only the sorts and filters allow-listed in
+// a Spec are accepted, and results are paged by keyset so deep pages stay
+// cheap and stable while rows are inserted.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultLimit is the page size when the limit parameter is absent
+	DefaultLimit = 20
+	// MaxLimit caps the limit parameter
+	MaxLimit = 100
+)
+
+// ErrInvalid is wrapped by the errors for malformed parameters
+var ErrInvalid = errors.New("invalid query")
+
+// Filter narrows a query by the value of one query parameter
+type Filter func(tx *gorm.DB, value string) (*gorm.DB, error)
+
+// Equal filters on a text column
+func Equal(column string) Filter {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		return tx.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value}), nil
+	}
+}
+
+// Bool filters on a boolean column
+func Bool(column string) Filter {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be true or false", ErrInvalid, column)
+		}
+		return tx.Where(clause.Eq{Column: clause.Column{Name: column}, Value: b}), nil
+	}
+}
+
+// Where filters with a condition taking the value as its only argument,
+// e.g. "id IN (SELECT song_id FROM artist_song WHERE artist_id = ?)"
+func Where(condition string) Filter {
+	return func(tx *gorm.DB, value string) (*gorm.DB, error) {
+		return tx.Where(condition, value), nil
+	}
+}
+
+// Spec is the allow-list of a list endpoint
+type Spec struct {
+	// Sorts are the columns that may be sorted on. Rows with equal values
+	// are ordered by id, so the columns should not be nullable.
+	Sorts []string
+	// DefaultSort is used without a sort parameter; prefix with - for
+	// descending order
+	DefaultSort string
+	// Filters maps query parameters to the filter they apply
+	Filters map[string]Filter
+}
+
+// Page describes where a list response sits in the full result
+type Page struct {
+	NextCursor string
+	HasMore    bool
+	Limit      int
+}
+
+// Find loads one page of the rows matching params into dest, a pointer to a
+// slice of models. Parameter errors wrap ErrInvalid.
+func Find(tx *gorm.DB, dest interface{}, spec Spec, params url.Values) (*Page, error) {
+	limit := DefaultLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > MaxLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalid, MaxLimit)
+		}
+	}
+
+	sort := params.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	column, desc := strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	if !slices.Contains(spec.Sorts, column) {
+		return nil, fmt.Errorf("%w: sort must be one of %s, optionally prefixed with -", ErrInvalid, strings.Join(spec.Sorts, ", "))
+	}
+
+	for name, filter := range spec.Filters {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if tx, err = filter(tx, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := params.Get("cursor"); value != "" {
+		c, err := decodeCursor(value)
+		if err != nil || c.Sort != sort {
+			return nil, fmt.Errorf("%w: cursor does not belong to this query", ErrInvalid)
+		}
+		operator := ">"
+		if desc {
+			operator = "<"
+		}
+		tx = tx.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, operator), c.value(), c.ID)
+	}
+
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	result := tx.Order(column + direction).Order("id" + direction).Limit(limit + 1).Find(dest)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	page := &Page{Limit: limit}
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= limit {
+		return page, nil
+	}
+	rows.SetLen(limit)
+	page.HasMore = true
+
+	// The cursor points after the last row of this page
+	schema := result.Statement.Schema
+	sortField, idField := schema.LookUpField(column), schema.LookUpField("id")
+	if sortField == nil || idField == nil {
+		return nil, fmt.Errorf("cannot page %s by %s", schema.Table, column)
+	}
+	last := rows.Index(limit - 1)
+	sortValue, _ := sortField.ValueOf(tx.Statement.Context, last)
+	idValue, _ := idField.ValueOf(tx.Statement.Context, last)
+	cursor, err := encodeCursor(sort, sortValue, fmt.Sprint(idValue))
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
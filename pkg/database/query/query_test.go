@@ -0,0 +1,93 @@
+package query
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 18, 9, 30, 0, 123456789, time.FixedZone("IST", 19800))
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"time", created, created.UTC()},
+		{"time pointer", &created, created.UTC()},
+		{"int", int64(9000), int64(9000)},
+		{"int32", int32(215), int64(215)},
+		{"float", 0.5, 0.5},
+		{"bool", true, true},
+		{"string", "Showkali", "Showkali"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeCursor("-created_at", tt.value, "b9f1c1d0-0000-4000-8000-000000000001")
+			if err != nil {
+				t.Fatalf("encodeCursor: %v", err)
+			}
+			c, err := decodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if c.Sort != "-created_at" || c.ID != "b9f1c1d0-0000-4000-8000-000000000001" {
+				t.Errorf("cursor = %+v", c)
+			}
+			got := c.value()
+			if gotTime, ok := got.(time.Time); ok {
+				if !gotTime.Equal(tt.want.(time.Time)) {
+					t.Errorf("value = %v, want %v", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "not base64!", "e30", "eyJzIjoibmFtZSIsImsiOiJ0IiwidiI6MSwiaWQiOiJ4In0"} {
+		if _, err := decodeCursor(value); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", value)
+		}
+	}
+}
+
+func TestFindRejectsInvalidParams(t *testing.T) {
+	spec := Spec{
+		Sorts:       []string{"name"},
+		DefaultSort: "name",
+		Filters:     map[string]Filter{"explicit": Bool("explicit")},
+	}
+	tests := []string{
+		"limit=0",
+		"limit=101",
+		"limit=ten",
+		"sort=password",
+		"sort=--name",
+		"explicit=maybe",
+	}
+
+	for _, raw := range tests {
+		params, _ := url.ParseQuery(raw)
+		// Parameters are validated before the query is touched
+		if _, err := Find(nil, nil, spec, params); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Find(%q) error = %v, want ErrInvalid", raw, err)
+		}
+	}
+}
+
+func TestPreloadRejectsUnknownIncludes(t *testing.T) {
+	includes := Includes{"artists": {{Path: "Artists"}}}
+	// Unknown names are rejected before anything is preloaded
+	for _, include := range []string{"secrets", " ,secrets", "Artists"} {
+		if _, err := Preload(nil, includes, include); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Preload(%q) error = %v, want ErrInvalid", include, err)
+		}
+	}
+}
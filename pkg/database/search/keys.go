@@ -0,0 +1,80 @@
+package search
+
+import (
+	"context"
+	"reflect"
+
+	"go-audio-stream/pkg/database/search/translit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// KeysColumn holds the transliterated search keys of songs, artists and
+// albums
+const KeysColumn = "search_keys"
+
+// keySources names the field the search keys of each table are made from
+var keySources = map[string]string{
+	"songs":   "Name",
+	"artists": "Name",
+	"albums":  "Title",
+}
+
+// RegisterCallbacks keeps the search keys of songs, artists and albums in
+// step with their names whenever rows are created or updated through GORM
+func RegisterCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("search:keys", setKeys); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("search:keys", setKeys)
+}
+
+func setKeys(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	nameField := stmt.Schema.LookUpField(keySources[stmt.Schema.Table])
+	keysField := stmt.Schema.LookUpField(KeysColumn)
+	if nameField == nil || keysField == nil {
+		return
+	}
+
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		for _, key := range []string{nameField.DBName, nameField.Name} {
+			if name, ok := dest[key].(string); ok {
+				stmt.SetColumn(keysField.DBName, translit.Keys(name))
+			}
+		}
+		return
+	}
+
+	// Updates with a struct leave ReflectValue pointing at the model, so
+	// read the values being written from Dest
+	value := reflect.ValueOf(stmt.Dest)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			stmt.AddError(setStructKeys(stmt.Context, reflect.Indirect(value.Index(i)), stmt.Schema, nameField, keysField))
+		}
+	case reflect.Struct:
+		stmt.AddError(setStructKeys(stmt.Context, value, stmt.Schema, nameField, keysField))
+	}
+}
+
+// setStructKeys sets the keys of one model. Zero names are skipped since
+// updates with structs leave zero fields unchanged.
+func setStructKeys(ctx context.Context, value reflect.Value, s *schema.Schema, nameField, keysField *schema.Field) error {
+	if value.Type() != s.ModelType || !value.CanAddr() {
+		return nil
+	}
+	name, zero := nameField.ValueOf(ctx, value)
+	if zero {
+		return nil
+	}
+	return keysField.Set(ctx, value, translit.Keys(name.(string)))
+}
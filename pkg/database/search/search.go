@@ -0,0 +1,205 @@
+// Package search ranks catalog entities against a free-text query using the
+// search_vector columns and trigram indexes created by the migration service.
+//
+// A query matches rows whose tsvector contains every word, the last one as a
+// prefix so results follow the user while typing, or whose name is
+// trigram-similar to the query to tolerate typos. Songs, artists and albums
+// also match on the phonetic keys of their names (see package translit), so
+// a romanized query finds a Tamil or Hindi title and the other way round.
+// Matches are ranked by text relevance and boosted by popularity.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go-audio-stream/pkg/database/search/translit"
+
+	"gorm.io/gorm"
+)
+
+// Type is a kind of catalog entity that can be searched
+type Type string
+
+const (
+	Song     Type = "song"
+	Artist   Type = "artist"
+	Album    Type = "album"
+	Playlist Type = "playlist"
+)
+
+// Types lists every searchable type in response order
+var Types = []Type{Song, Artist, Album, Playlist}
+
+var (
+	// ErrEmptyQuery is returned for queries without any letters or digits
+	ErrEmptyQuery = errors.New("query has no searchable words")
+	// ErrUnknownType is returned by ParseTypes for unsupported types
+	ErrUnknownType = errors.New("unknown search type")
+)
+
+// ParseTypes parses a comma-separated list of types. An empty list selects
+// every type.
+func ParseTypes(value string) ([]Type, error) {
+	if strings.TrimSpace(value) == "" {
+		return Types, nil
+	}
+	var types []Type
+	for _, name := range strings.Split(value, ",") {
+		t := Type(strings.TrimSpace(name))
+		if !slices.Contains(Types, t) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownType, t)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// Query is a parsed search query
+type Query struct {
+	// Text is the normalized query compared by trigram similarity
+	Text string
+	// TSQuery is the to_tsquery input matching every word, the last one as
+	// a prefix
+	TSQuery string
+	// Keys and KeysTSQuery are the same for the phonetic key of the query
+	Keys        string
+	KeysTSQuery string
+}
+
+// Parse normalizes a user's query. Only letters, digits and combining marks
+// are kept, so the result is always valid tsquery syntax.
+func Parse(q string) (Query, error) {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	if len(words) == 0 {
+		return Query{}, ErrEmptyQuery
+	}
+
+	keys := strings.Fields(translit.Phonetic(q))
+	return Query{
+		Text:        strings.Join(words, " "),
+		TSQuery:     prefixQuery(words),
+		Keys:        strings.Join(keys, " "),
+		KeysTSQuery: prefixQuery(keys),
+	}, nil
+}
+
+// prefixQuery renders a tsquery matching every word, the last one as a
+// prefix
+func prefixQuery(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	terms := slices.Clone(words)
+	terms[len(terms)-1] += ":*"
+	return strings.Join(terms, " & ")
+}
+
+// target describes how a type is matched and ranked
+type target struct {
+	table string
+	// name is the column compared by trigram similarity
+	name string
+	// popularity is an expression of the row's popularity
+	popularity string
+	// related optionally matches rows through other entities and gives
+	// them a smaller rank bonus
+	related string
+	// visible restricts the rows that may be returned
+	visible string
+	// keyed tables have search keys in their search_vector and a trigram
+	// index on them
+	keyed bool
+}
+
+var targets = map[Type]target{
+	Song: {
+		table:      "songs",
+		name:       "name",
+		popularity: "songs.play_count",
+		// Songs are found by the name of their credited artists as well
+		related: `songs.id IN (SELECT artist_song.song_id FROM artist_song
+			JOIN artists ON artists.id = artist_song.artist_id
+			WHERE artists.search_vector @@ ` + keyedTSQuery + `)`,
+		keyed: true,
+	},
+	Artist: {
+		table:      "artists",
+		name:       "name",
+		popularity: "artists.followers",
+		keyed:      true,
+	},
+	Album: {
+		table:      "albums",
+		name:       "title",
+		popularity: "(SELECT coalesce(sum(songs.play_count), 0) FROM songs WHERE songs.album_id = albums.id)",
+		related: `albums.id IN (SELECT album_artist.album_id FROM album_artist
+			JOIN artists ON artists.id = album_artist.artist_id
+			WHERE artists.search_vector @@ ` + keyedTSQuery + `)`,
+		keyed: true,
+	},
+	Playlist: {
+		table:      "playlists",
+		name:       "name",
+		popularity: "0",
+		visible:    "NOT playlists.private",
+	},
+}
+
+const (
+	// textTSQuery matches the words of the query
+	textTSQuery = "to_tsquery('simple', @tsquery)"
+	// keyedTSQuery matches the words or their phonetic keys
+	keyedTSQuery = "(to_tsquery('simple', @tsquery) || to_tsquery('simple', @keystsquery))"
+)
+
+// relatedBonus is the rank added for matches through related entities
+const relatedBonus = 0.2
+
+// Find loads up to limit rows of type t matching q into dest, a pointer to a
+// slice of the type's model, best match first
+func Find(tx *gorm.DB, t Type, q Query, limit int, dest interface{}) error {
+	target, ok := targets[t]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownType, t)
+	}
+	args := map[string]interface{}{
+		"tsquery":     q.TSQuery,
+		"text":        q.Text,
+		"keystsquery": q.KeysTSQuery,
+		"keys":        q.Keys,
+	}
+
+	tsquery := textTSQuery
+	if target.keyed && q.KeysTSQuery != "" {
+		tsquery = keyedTSQuery
+	}
+	match := fmt.Sprintf("%[1]s.search_vector @@ %[3]s OR @text <%% lower(%[1]s.%[2]s)", target.table, target.name, tsquery)
+	similarity := fmt.Sprintf("word_similarity(@text, lower(%s.%s))", target.table, target.name)
+	if target.keyed && q.Keys != "" {
+		match += fmt.Sprintf(" OR @keys <%% %s.%s", target.table, KeysColumn)
+		similarity = fmt.Sprintf("greatest(%s, word_similarity(@keys, %s.%s))", similarity, target.table, KeysColumn)
+	}
+	relevance := fmt.Sprintf("ts_rank(%s.search_vector, %s) + %s", target.table, tsquery, similarity)
+	if target.related != "" {
+		match += " OR " + target.related
+		relevance += fmt.Sprintf(" + CASE WHEN %s THEN %g ELSE 0 END", target.related, relatedBonus)
+	}
+	// The boost grows logarithmically so that popularity orders similar
+	// matches without burying exact ones
+	rank := fmt.Sprintf("(%s) * (1 + ln(1 + greatest(%s, 0)) / 10)", relevance, target.popularity)
+
+	tx = tx.Select(fmt.Sprintf("%s.*, %s AS search_rank", target.table, rank), args).
+		Where("("+match+")", args)
+	if target.visible != "" {
+		tx = tx.Where(target.visible)
+	}
+	return tx.Order("search_rank DESC").Order(target.table + ".id").Limit(limit).Find(dest).Error
+}
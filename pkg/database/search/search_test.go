@@ -0,0 +1,83 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		q       string
+		text    string
+		tsquery string
+	}{
+		{"showkali", "showkali", "showkali:*"},
+		{"  A.R. Rahman ", "a r rahman", "a & r & rahman:*"},
+		{"Acham Yenbadhu Madam", "acham yenbadhu madam", "acham & yenbadhu & madam:*"},
+		{"rahman':* | !x", "rahman x", "rahman & x:*"},
+		{"அச்சம் என்பது", "அச்சம் என்பது", "அச்சம் & என்பது:*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			got, err := Parse(tt.q)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.Text != tt.text || got.TSQuery != tt.tsquery {
+				t.Errorf("Parse(%q) = %+v, want text %q, tsquery %q", tt.q, got, tt.text, tt.tsquery)
+			}
+		})
+	}
+
+	for _, q := range []string{"", "   ", "&|!:*()'"} {
+		if _, err := Parse(q); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Parse(%q) error = %v, want ErrEmptyQuery", q, err)
+		}
+	}
+}
+
+func TestParsePhoneticKeys(t *testing.T) {
+	tests := []struct {
+		q    string
+		keys string
+	}{
+		{"Showkali", "saukali:*"},
+		{"ஷௌக்காளி", "saukali:*"},
+		{"acham yenbadhu", "asam & enpatu:*"},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.q)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.q, err)
+		}
+		if got.KeysTSQuery != tt.keys {
+			t.Errorf("Parse(%q).KeysTSQuery = %q, want %q", tt.q, got.KeysTSQuery, tt.keys)
+		}
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Type
+		wantErr bool
+	}{
+		{"", Types, false},
+		{"song", []Type{Song}, false},
+		{"artist, album,artist", []Type{Artist, Album}, false},
+		{"song,user", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTypes(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseTypes(%q) error = %v", tt.value, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTypes(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
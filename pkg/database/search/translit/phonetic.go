@@ -0,0 +1,136 @@
+package translit
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// romanSounds spells ISO 15919 letters the way they are usually typed
+// before diacritics are dropped
+var romanSounds = strings.NewReplacer(
+	// Tamil ṟṟ and ṉṟ are pronounced "tr" and "ndr", as in kāṟṟu (kaatru)
+	"ṟṟ", "tr", "ṉṟ", "ndr",
+	"r̥̄", "ri", "r̥", "ri", "l̥̄", "li", "l̥", "li",
+	"ś", "sh", "ṣ", "sh", "m̐", "n",
+)
+
+// rewrites merge spellings of the same sound, longest match first. Voiced
+// and aspirated stops are merged with the plain ones since Tamil script does
+// not tell them apart.
+var rewrites = []struct{ from, to string }{
+	{"zh", "l"}, {"sh", "s"}, {"ch", "s"}, {"kh", "k"}, {"gh", "k"},
+	{"th", "t"}, {"dh", "t"}, {"ph", "p"}, {"bh", "p"}, {"jh", "j"}, {"ck", "k"},
+	{"ow", "au"}, {"ou", "au"}, {"aw", "au"},
+	{"ee", "i"}, {"ii", "i"}, {"oo", "u"}, {"uu", "u"}, {"aa", "a"},
+	{"c", "s"}, {"z", "j"}, {"x", "ks"}, {"q", "k"}, {"w", "v"}, {"f", "p"},
+	{"g", "k"}, {"d", "t"}, {"b", "p"},
+}
+
+// Phonetic returns the phonetic key of s: the words of its romanization with
+// diacritics dropped and similar sounds merged
+func Phonetic(s string) string {
+	words := Words(fold(nasalize(romanSounds.Replace(Romanize(s)))))
+	for i, word := range words {
+		words[i] = phoneticWord(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// Keys returns the search keys of a name: its words in their own script, in
+// ISO 15919 romanization without diacritics and as phonetic keys, each once
+func Keys(s string) string {
+	var keys []string
+	for _, words := range [][]string{
+		Words(strings.ToLower(norm.NFC.String(s))),
+		Words(fold(Romanize(s))),
+		strings.Fields(Phonetic(s)),
+	} {
+		for _, word := range words {
+			if !slices.Contains(keys, word) {
+				keys = append(keys, word)
+			}
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// Words splits s into runs of letters, combining marks and digits
+func Words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// nasalize spells the anusvara ṁ as the nasal of the following consonant
+func nasalize(s string) string {
+	if !strings.Contains(s, "ṁ") {
+		return s
+	}
+	runes := []rune(s)
+	var out strings.Builder
+	for i, r := range runes {
+		if r != 'ṁ' {
+			out.WriteRune(r)
+			continue
+		}
+		if i+1 < len(runes) && strings.ContainsRune("pbm", runes[i+1]) {
+			out.WriteRune('m')
+		} else {
+			out.WriteRune('n')
+		}
+	}
+	return out.String()
+}
+
+// fold lowercases s and drops its diacritics. Letters that only differ by a
+// combining mark from a Latin letter become that letter; other scripts keep
+// their marks.
+func fold(s string) string {
+	var out strings.Builder
+	var base rune
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		if unicode.Is(unicode.Mn, r) && base < unicode.MaxLatin1 {
+			continue
+		}
+		base = r
+		out.WriteRune(r)
+	}
+	return norm.NFC.String(out.String())
+}
+
+// phoneticWord applies the rewrites to a folded word and merges repeated
+// letters, so gemination (kk) and vowel length are ignored
+func phoneticWord(word string) string {
+	// A leading "ye" is how Tamil initial e is often typed (yenbadhu)
+	if strings.HasPrefix(word, "ye") {
+		word = word[1:]
+	}
+	if strings.HasSuffix(word, "y") && len(word) > 1 {
+		word = strings.TrimSuffix(word, "y") + "i"
+	}
+
+	var out []rune
+	for i := 0; i < len(word); {
+		from, to := word[i:i+1], ""
+		for _, rw := range rewrites {
+			if strings.HasPrefix(word[i:], rw.from) {
+				from, to = rw.from, rw.to
+				break
+			}
+		}
+		if to == "" {
+			r := []rune(word[i:])[0]
+			from, to = string(r), string(r)
+		}
+		for _, r := range to {
+			if len(out) == 0 || out[len(out)-1] != r {
+				out = append(out, r)
+			}
+		}
+		i += len(from)
+	}
+	return string(out)
+}
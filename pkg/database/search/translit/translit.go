@@ -0,0 +1,191 @@
+// Package translit romanizes Indic scripts per ISO 15919 and reduces text in
+// any script to phonetic keys, so that spellings such as "showkali",
+// "shaukali" and the Tamil original compare equal.
+//
+// The Devanagari, Bengali, Gurmukhi, Gujarati, Oriya, Tamil, Telugu, Kannada
+// and Malayalam blocks share the ISCII layout, so one table indexed by the
+// offset within the block covers all of them.
+package translit
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// script is a Unicode block of an ISCII-derived script
+type script struct {
+	base rune
+	// schwaDeletion drops the inherent vowel of word-final consonants, as
+	// in Hindi "दिल" (dil) rather than "dila"
+	schwaDeletion bool
+}
+
+var scripts = []script{
+	{0x0900, true},  // Devanagari
+	{0x0980, true},  // Bengali
+	{0x0A00, true},  // Gurmukhi
+	{0x0A80, true},  // Gujarati
+	{0x0B00, false}, // Oriya
+	{0x0B80, false}, // Tamil
+	{0x0C00, false}, // Telugu
+	{0x0C80, false}, // Kannada
+	{0x0D00, false}, // Malayalam
+}
+
+// Offsets within a block
+const (
+	candrabindu = 0x01
+	anusvara    = 0x02
+	visarga     = 0x03
+	nukta       = 0x3C
+	avagraha    = 0x3D
+	virama      = 0x4D
+)
+
+var vowels = map[rune]string{
+	0x05: "a", 0x06: "ā", 0x07: "i", 0x08: "ī", 0x09: "u", 0x0A: "ū",
+	0x0B: "r̥", 0x0C: "l̥", 0x0D: "ê", 0x0E: "e", 0x0F: "ē", 0x10: "ai",
+	0x11: "ô", 0x12: "o", 0x13: "ō", 0x14: "au", 0x60: "r̥̄", 0x61: "l̥̄",
+}
+
+var vowelSigns = map[rune]string{
+	0x3E: "ā", 0x3F: "i", 0x40: "ī", 0x41: "u", 0x42: "ū", 0x43: "r̥",
+	0x44: "r̥̄", 0x45: "ê", 0x46: "e", 0x47: "ē", 0x48: "ai", 0x49: "ô",
+	0x4A: "o", 0x4B: "ō", 0x4C: "au", 0x57: "au", 0x62: "l̥", 0x63: "l̥̄",
+}
+
+var consonants = map[rune]string{
+	0x15: "k", 0x16: "kh", 0x17: "g", 0x18: "gh", 0x19: "ṅ",
+	0x1A: "c", 0x1B: "ch", 0x1C: "j", 0x1D: "jh", 0x1E: "ñ",
+	0x1F: "ṭ", 0x20: "ṭh", 0x21: "ḍ", 0x22: "ḍh", 0x23: "ṇ",
+	0x24: "t", 0x25: "th", 0x26: "d", 0x27: "dh", 0x28: "n", 0x29: "ṉ",
+	0x2A: "p", 0x2B: "ph", 0x2C: "b", 0x2D: "bh", 0x2E: "m",
+	0x2F: "y", 0x30: "r", 0x31: "ṟ", 0x32: "l", 0x33: "ḷ", 0x34: "ḻ", 0x35: "v",
+	0x36: "ś", 0x37: "ṣ", 0x38: "s", 0x39: "h",
+	// Precomposed nukta letters
+	0x58: "q", 0x59: "k͟h", 0x5A: "ġ", 0x5B: "z", 0x5C: "ṛ", 0x5D: "ṛh", 0x5E: "f", 0x5F: "ẏ",
+}
+
+// chillus are Malayalam consonants without an inherent vowel
+var chillus = map[rune]string{
+	0x7A: "ṇ", 0x7B: "n", 0x7C: "r", 0x7D: "l", 0x7E: "ḷ", 0x7F: "k",
+}
+
+// nuktaForms maps consonants to their form with a following nukta
+var nuktaForms = map[string]string{
+	"k": "q", "kh": "k͟h", "g": "ġ", "j": "z", "ḍ": "ṛ", "ḍh": "ṛh", "ph": "f", "y": "ẏ",
+}
+
+// lookup finds the script of r and its offset within the block
+func lookup(r rune) (script, rune, bool) {
+	for _, s := range scripts {
+		if r >= s.base && r < s.base+0x80 {
+			return s, r - s.base, true
+		}
+	}
+	return script{}, 0, false
+}
+
+// Romanize transliterates the Indic scripts in s to ISO 15919 and lowercases
+// the result. Other scripts are kept as they are.
+func Romanize(s string) string {
+	var out strings.Builder
+	var (
+		// pending is the script of a consonant still waiting for its vowel
+		pending     *script
+		conjunct    bool   // the pending consonant follows a virama
+		afterVirama bool   // the previous rune was a virama
+		syllables   int    // vowels written in the current word
+		lastRoman   string // the consonant written last
+	)
+
+	// resolve writes the inherent vowel of a pending consonant. wordEnd
+	// applies schwa deletion.
+	resolve := func(wordEnd bool) {
+		if pending == nil {
+			return
+		}
+		if !(wordEnd && pending.schwaDeletion && !conjunct && syllables > 0) {
+			out.WriteString("a")
+		}
+		pending = nil
+		syllables++
+	}
+
+	for _, r := range norm.NFC.String(s) {
+		sc, offset, ok := lookup(r)
+		if !ok {
+			separator := !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r)
+			resolve(separator)
+			if separator {
+				syllables = 0
+			}
+			afterVirama = false
+			out.WriteString(strings.ToLower(string(r)))
+			continue
+		}
+
+		switch {
+		case consonants[offset] != "":
+			resolve(false)
+			roman := consonants[offset]
+			out.WriteString(roman)
+			lastRoman = roman
+			conjunct = afterVirama
+			pending = &sc
+		case chillus[offset] != "" && sc.base == 0x0D00:
+			resolve(false)
+			out.WriteString(chillus[offset])
+			lastRoman = ""
+		case offset == nukta:
+			if form, ok := nuktaForms[lastRoman]; ok && pending != nil {
+				// Replace the consonant just written
+				text := strings.TrimSuffix(out.String(), lastRoman)
+				out.Reset()
+				out.WriteString(text + form)
+				lastRoman = form
+			}
+		case vowelSigns[offset] != "":
+			if pending != nil {
+				pending = nil
+				syllables++
+			}
+			out.WriteString(vowelSigns[offset])
+		case offset == virama:
+			pending = nil
+			afterVirama = true
+			continue
+		case vowels[offset] != "":
+			resolve(false)
+			out.WriteString(vowels[offset])
+			syllables++
+		case offset == candrabindu:
+			resolve(false)
+			out.WriteString("m̐")
+		case offset == anusvara:
+			resolve(false)
+			out.WriteString("ṁ")
+		case offset == visarga:
+			resolve(false)
+			out.WriteString("ḥ")
+		case offset == avagraha:
+			resolve(false)
+			out.WriteString("'")
+		case offset >= 0x66 && offset <= 0x6F:
+			resolve(true)
+			out.WriteRune('0' + offset - 0x66)
+			syllables = 0
+		default:
+			// Dandas and other signs separate words
+			resolve(true)
+			out.WriteString(" ")
+			syllables = 0
+		}
+		afterVirama = false
+	}
+	resolve(true)
+
+	return norm.NFC.String(out.String())
+}
@@ -0,0 +1,69 @@
+package translit
+
+import "testing"
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"அச்சம் என்பது மடமையடா", "accam eṉpatu maṭamaiyaṭā"},
+		{"ஷௌக்காளி", "ṣaukkāḷi"},
+		{"காற்று", "kāṟṟu"},
+		{"तमिऴ्", "tamiḻ"},
+		{"दिल", "dil"},
+		{"कमल", "kamal"},
+		{"कृष्ण", "kr̥ṣṇa"},
+		{"ज़िन्दगी", "zindagī"},
+		{"हैं", "haiṁ"},
+		{"ರಹಮಾನ್", "rahamān"},
+		{"A.R. Rahman", "a.r. rahman"},
+		{"२०२६", "2026"},
+	}
+
+	for _, tt := range tests {
+		if got := Romanize(tt.in); got != tt.want {
+			t.Errorf("Romanize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPhoneticMatchesAcrossScripts(t *testing.T) {
+	tests := []struct {
+		key      string
+		variants []string
+	}{
+		{"saukali", []string{"Showkali", "shaukali", "Shoukkali", "ஷௌக்காளி", "சௌகாளி"}},
+		{"asam enpatu matamaiyata", []string{"Acham Yenbadhu Madamaiyada", "Achcham Enbathu Madamaiyada", "அச்சம் என்பது மடமையடா"}},
+		{"katru", []string{"Kaatru", "காற்று"}},
+		{"talapati", []string{"Thalapathy", "தளபதி"}},
+		{"tamil", []string{"Tamizh", "Tamil", "தமிழ்"}},
+		{"til", []string{"Dil", "दिल"}},
+		{"hain", []string{"hain", "हैं"}},
+		{"rahman", []string{"Rahman", "ரஹ்மான்"}},
+	}
+
+	for _, tt := range tests {
+		for _, v := range tt.variants {
+			if got := Phonetic(v); got != tt.key {
+				t.Errorf("Phonetic(%q) = %q, want %q", v, got, tt.key)
+			}
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Showkali", "showkali saukali"},
+		{"ஷௌக்காளி", "ஷௌக்காளி saukkali saukali"},
+		{"Ilaiyaraaja", "ilaiyaraaja ilaiyaraja"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Keys(tt.in); got != tt.want {
+			t.Errorf("Keys(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package artwork turns uploaded cover art and artist photos into square
+// JPEG and WebP thumbnails with a placeholder colour and BlurHash.
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"slices"
+
+	// Decoders for the formats accepted by sniff.ValidateImage
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/chai2010/webp"
+	"golang.org/x/image/draw"
+)
+
+// DefaultSizes are the square edge lengths in pixels produced for every image
+var DefaultSizes = []int{64, 300, 640, 1280}
+
+// Format is an output encoding
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+}
+
+// Output formats, in the order variants are produced
+var (
+	JPEG = Format{Name: "jpeg", ContentType: "image/jpeg", Extension: ".jpg"}
+	WebP = Format{Name: "webp", ContentType: "image/webp", Extension: ".webp"}
+)
+
+const (
+	jpegQuality = 85
+	webpQuality = 80
+
+	// BlurHash components along each axis of the square thumbnail
+	blurHashComponents = 4
+)
+
+// ErrDecode is returned for images that cannot be decoded
+var ErrDecode = errors.New("failed to decode image")
+
+// Variant is one encoded size
+type Variant struct {
+	Size   int
+	Format Format
+	Data   []byte
+}
+
+// Result holds the encoded variants of an image
+type Result struct {
+	Variants []Variant
+
+	// DominantColor is a #rrggbb colour to show while loading
+	DominantColor string
+	// BlurHash is a compact blurred placeholder, see blurha.sh
+	BlurHash string
+}
+
+// Process decodes data, applies its EXIF orientation and encodes a centre
+// square crop at each size in both formats. Sizes larger than the source are
+// skipped, except the smallest, so small images are never blown up. The
+// output carries no metadata.
+func Process(data []byte, sizes []int) (*Result, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	src = orient(src, exifOrientation(data))
+	square := flatten(cropSquare(src))
+
+	sizes = slices.Sorted(slices.Values(sizes))
+	result := &Result{}
+	var smallest *image.RGBA
+	for i, size := range sizes {
+		if i > 0 && size > square.Bounds().Dx() {
+			break
+		}
+		scaled := resize(square, size)
+		if smallest == nil {
+			smallest = scaled
+		}
+
+		var jpegData, webpData bytes.Buffer
+		if err := jpeg.Encode(&jpegData, scaled, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+		if err := webp.Encode(&webpData, scaled, &webp.Options{Quality: webpQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode WebP: %w", err)
+		}
+		result.Variants = append(result.Variants,
+			Variant{Size: size, Format: JPEG, Data: jpegData.Bytes()},
+			Variant{Size: size, Format: WebP, Data: webpData.Bytes()},
+		)
+	}
+	if smallest == nil {
+		return nil, errors.New("no sizes requested")
+	}
+
+	result.DominantColor = dominantColor(smallest)
+	result.BlurHash, err = blurhash.Encode(blurHashComponents, blurHashComponents, smallest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute BlurHash: %w", err)
+	}
+	return result, nil
+}
+
+// cropSquare returns the centred square of img with the length of its
+// shorter side
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	rect := image.Rect(x, y, x+side, y+side)
+
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// flatten composites img over white, since JPEG has no transparency
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+func resize(img *image.RGBA, size int) *image.RGBA {
+	if img.Bounds().Dx() == size {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// dominantColor buckets the pixels of img into 4 bits per channel and
+// returns the average colour of the fullest bucket
+func dominantColor(img *image.RGBA) string {
+	type bucket struct{ r, g, b, n int }
+	var buckets [4096]bucket
+
+	best := 0
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+		index := r>>4<<8 | g>>4<<4 | b>>4
+		bk := &buckets[index]
+		bk.r += r
+		bk.g += g
+		bk.b += b
+		bk.n++
+		if bk.n > buckets[best].n {
+			best = index
+		}
+	}
+
+	bk := buckets[best]
+	if bk.n == 0 {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", bk.r/bk.n, bk.g/bk.n, bk.b/bk.n)
+}
@@ -0,0 +1,111 @@
+package artwork
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/chai2010/webp"
+)
+
+func TestProcess(t *testing.T) {
+	// 800x600, mostly blue with a red strip on the left
+	src := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			c := color.RGBA{B: 200, A: 255}
+			if x < 200 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var data bytes.Buffer
+	png.Encode(&data, src)
+
+	result, err := Process(data.Bytes(), DefaultSizes)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// 640 and 1280 exceed the 600px square and are skipped
+	if len(result.Variants) != 4 {
+		t.Fatalf("Process() variants = %d, want 4", len(result.Variants))
+	}
+	for _, v := range result.Variants {
+		var img image.Image
+		if v.Format == WebP {
+			img, err = webp.Decode(bytes.NewReader(v.Data))
+		} else {
+			img, err = jpeg.Decode(bytes.NewReader(v.Data))
+		}
+		if err != nil {
+			t.Fatalf("%s %d: decode error = %v", v.Format.Name, v.Size, err)
+		}
+		if b := img.Bounds(); b.Dx() != v.Size || b.Dy() != v.Size {
+			t.Errorf("%s %d: size = %v", v.Format.Name, v.Size, b)
+		}
+	}
+
+	if result.DominantColor != "#0000c8" {
+		t.Errorf("DominantColor = %s, want #0000c8", result.DominantColor)
+	}
+	// 1 size flag + 1 max AC + 4 DC + 15 AC components * 2
+	if len(result.BlurHash) != 36 {
+		t.Errorf("BlurHash = %q, want 36 characters", result.BlurHash)
+	}
+}
+
+func TestProcessSmallImage(t *testing.T) {
+	var data bytes.Buffer
+	png.Encode(&data, image.NewGray(image.Rect(0, 0, 10, 20)))
+
+	result, err := Process(data.Bytes(), DefaultSizes)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(result.Variants) != 2 || result.Variants[0].Size != 64 {
+		t.Errorf("Process() variants = %d, want only the 64px size", len(result.Variants))
+	}
+}
+
+func TestProcessInvalid(t *testing.T) {
+	if _, err := Process([]byte("not an image"), DefaultSizes); err == nil {
+		t.Error("Process() error = nil, want decode error")
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil)
+
+	// Big-endian TIFF with one IFD entry: Orientation = 6
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	var data []byte
+	data = append(data, encoded.Bytes()[:2]...)
+	data = append(data, 0xFF, 0xE1, byte((len(app1)+2)>>8), byte(len(app1)+2))
+	data = append(data, app1...)
+	data = append(data, encoded.Bytes()[2:]...)
+
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("exifOrientation() = %d, want 6", got)
+	}
+	if got := exifOrientation(encoded.Bytes()); got != 1 {
+		t.Errorf("exifOrientation() without EXIF = %d, want 1", got)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.White)
+	rotated := orient(src, 6)
+	if b := rotated.Bounds(); b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("orient() bounds = %v, want 1x2", b)
+	}
+	// The top-left pixel ends up top-right, which is the only column
+	if r, _, _, _ := rotated.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("orient() moved the top-left pixel to the wrong corner")
+	}
+}
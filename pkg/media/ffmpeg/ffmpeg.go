@@ -0,0 +1,72 @@
+// Package ffmpeg runs the ffmpeg command line tool as a subprocess.
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DefaultBinary is used when no explicit ffmpeg path is configured
+const DefaultBinary = "ffmpeg"
+
+// ErrNotInstalled is returned when the ffmpeg binary cannot be found
+var ErrNotInstalled = errors.New("ffmpeg binary not found")
+
+// Runner executes ffmpeg with a fixed binary path
+type Runner struct {
+	Binary string
+}
+
+// NewRunner creates a runner for binary, falling back to DefaultBinary
+func NewRunner(binary string) *Runner {
+	if binary == "" {
+		binary = DefaultBinary
+	}
+	return &Runner{Binary: binary}
+}
+
+// Available reports whether the configured binary can be executed
+func (r *Runner) Available() error {
+	if _, err := exec.LookPath(r.Binary); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotInstalled, err)
+	}
+	return nil
+}
+
+// Run executes ffmpeg with args. The last lines of stderr are included in the
+// returned error when the process fails.
+func (r *Runner) Run(ctx context.Context, args ...string) error {
+	return r.Stream(ctx, nil, args...)
+}
+
+// Stream executes ffmpeg like Run with its standard output written to w, for
+// commands that write to pipe:1
+func (r *Runner) Stream(ctx context.Context, w io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.Binary, append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}, args...)...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%w: %v", ErrNotInstalled, err)
+		}
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, tail(stderr.String(), 5))
+	}
+
+	return nil
+}
+
+// tail returns the last n non-empty lines of s
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,9 @@
+module go-audio-stream/pkg/media
+
+go 1.25.3
+
+require (
+	github.com/buckket/go-blurhash v1.1.0
+	github.com/chai2010/webp v1.4.0
+	golang.org/x/image v0.38.0
+)
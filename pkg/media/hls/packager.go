@@ -0,0 +1,97 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+)
+
+// Segment container formats supported by FFmpegPackager
+const (
+	SegmentFMP4   = "fmp4"
+	SegmentMPEGTS = "mpegts"
+)
+
+// Packager segments an audio file into HLS variants under outputDir. Each
+// variant is written to outputDir/{variant.Name}/ with a MediaPlaylistName
+// playlist, and the master playlist to outputDir/MasterPlaylistName.
+type Packager interface {
+	Package(ctx context.Context, input string, outputDir string, variants []Variant) error
+}
+
+// FFmpegPackager packages audio by running ffmpeg once per variant
+type FFmpegPackager struct {
+	runner          *ffmpeg.Runner
+	segmentDuration time.Duration
+	segmentType     string
+}
+
+// NewFFmpegPackager creates a packager writing segments of segmentDuration in
+// the given container (SegmentFMP4 or SegmentMPEGTS)
+func NewFFmpegPackager(runner *ffmpeg.Runner, segmentDuration time.Duration, segmentType string) *FFmpegPackager {
+	if segmentDuration <= 0 {
+		segmentDuration = 6 * time.Second
+	}
+	if segmentType != SegmentMPEGTS {
+		segmentType = SegmentFMP4
+	}
+	return &FFmpegPackager{
+		runner:          runner,
+		segmentDuration: segmentDuration,
+		segmentType:     segmentType,
+	}
+}
+
+// Package transcodes input to AAC at each variant bitrate and segments it
+func (p *FFmpegPackager) Package(ctx context.Context, input string, outputDir string, variants []Variant) error {
+	for _, v := range variants {
+		dir := filepath.Join(outputDir, v.Name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create variant directory: %w", err)
+		}
+
+		if err := p.runner.Run(ctx, p.args(input, dir, v)...); err != nil {
+			return fmt.Errorf("failed to package variant %s: %w", v.Name, err)
+		}
+	}
+
+	master := filepath.Join(outputDir, MasterPlaylistName)
+	if err := os.WriteFile(master, MasterPlaylist(variants), 0o644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	return nil
+}
+
+func (p *FFmpegPackager) args(input, dir string, v Variant) []string {
+	segmentExt := ".m4s"
+	if p.segmentType == SegmentMPEGTS {
+		segmentExt = ".ts"
+	}
+
+	args := []string{
+		"-i", input,
+		"-map", "0:a:0",
+		"-vn",
+		"-c:a", "aac",
+		"-b:a", strconv.Itoa(v.Bitrate),
+		"-ac", "2",
+		"-ar", "44100",
+		"-f", "hls",
+		"-hls_time", strconv.FormatFloat(p.segmentDuration.Seconds(), 'f', -1, 64),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_type", p.segmentType,
+		"-hls_segment_filename", filepath.Join(dir, "segment_%04d"+segmentExt),
+	}
+	if p.segmentType == SegmentFMP4 {
+		args = append(args, "-hls_fmp4_init_filename", "init.mp4")
+	}
+
+	return append(args, filepath.Join(dir, MediaPlaylistName))
+}
@@ -0,0 +1,113 @@
+// Package hls packages audio into HTTP Live Streaming renditions and edits
+// the resulting playlists.
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MasterPlaylistName is the file name of the multi-bitrate playlist
+	MasterPlaylistName = "master.m3u8"
+	// MediaPlaylistName is the file name of each variant's media playlist
+	MediaPlaylistName = "playlist.m3u8"
+	// PlaylistContentType is the MIME type of m3u8 playlists
+	PlaylistContentType = "application/vnd.apple.mpegurl"
+)
+
+// Variant is one bitrate rendition of a track
+type Variant struct {
+	// Name is the directory holding the variant, e.g. "128k"
+	Name string
+	// Bitrate is the target audio bitrate in bits per second
+	Bitrate int
+	// Codecs is the RFC 6381 codec string advertised in the master playlist
+	Codecs string
+}
+
+// DefaultVariants are the AAC-LC renditions produced for every track
+var DefaultVariants = []Variant{
+	{Name: "64k", Bitrate: 64000, Codecs: "mp4a.40.2"},
+	{Name: "128k", Bitrate: 128000, Codecs: "mp4a.40.2"},
+	{Name: "256k", Bitrate: 256000, Codecs: "mp4a.40.2"},
+}
+
+// MasterPlaylist renders a master playlist referencing each variant's media
+// playlist by relative URI
+func MasterPlaylist(variants []Variant) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:7\n")
+	buf.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, v := range variants {
+		// BANDWIDTH is the peak rate including container overhead
+		fmt.Fprintf(&buf, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=\"%s\"\n", v.Bitrate*11/10, v.Bitrate, v.Codecs)
+		fmt.Fprintf(&buf, "%s/%s\n", v.Name, MediaPlaylistName)
+	}
+	return buf.Bytes()
+}
+
+// SessionDataTag renders an EXT-X-SESSION-DATA tag carrying value under the
+// reverse-DNS id, for master playlists
+func SessionDataTag(id, value string) string {
+	return fmt.Sprintf("#EXT-X-SESSION-DATA:DATA-ID=\"%s\",VALUE=\"%s\"", id, strings.ReplaceAll(value, `"`, "'"))
+}
+
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// RewriteURIs returns playlist with every URI line and URI="..." tag
+// attribute replaced by the result of rewrite
+func RewriteURIs(playlist []byte, rewrite func(uri string) (string, error)) ([]byte, error) {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	first := true
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if first {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("not an m3u8 playlist")
+			}
+			first = false
+		}
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			var rewriteErr error
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := uriAttribute.FindStringSubmatch(attr)[1]
+				rewritten, err := rewrite(uri)
+				if err != nil {
+					rewriteErr = err
+					return attr
+				}
+				return `URI="` + rewritten + `"`
+			})
+			if rewriteErr != nil {
+				return nil, rewriteErr
+			}
+		default:
+			rewritten, err := rewrite(line)
+			if err != nil {
+				return nil, err
+			}
+			line = rewritten
+		}
+
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, fmt.Errorf("not an m3u8 playlist")
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMasterPlaylist(t *testing.T) {
+	got := string(MasterPlaylist([]Variant{
+		{Name: "64k", Bitrate: 64000, Codecs: "mp4a.40.2"},
+		{Name: "128k", Bitrate: 128000, Codecs: "mp4a.40.2"},
+	}))
+
+	want := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=70400,AVERAGE-BANDWIDTH=64000,CODECS="mp4a.40.2"
+64k/playlist.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=140800,AVERAGE-BANDWIDTH=128000,CODECS="mp4a.40.2"
+128k/playlist.m3u8
+`
+	if got != want {
+		t.Errorf("MasterPlaylist() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRewriteURIs(t *testing.T) {
+	playlist := "#EXTM3U\r\n" +
+		"#EXT-X-VERSION:7\r\n" +
+		"#EXT-X-TARGETDURATION:6\r\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\r\n" +
+		"#EXTINF:6.0,\r\n" +
+		"segment_0000.m4s\r\n" +
+		"#EXTINF:4.2,\r\n" +
+		"segment_0001.m4s\r\n" +
+		"#EXT-X-ENDLIST\r\n"
+
+	got, err := RewriteURIs([]byte(playlist), func(uri string) (string, error) {
+		return "https://cdn.example/" + uri + "?sig=1", nil
+	})
+	if err != nil {
+		t.Fatalf("RewriteURIs() error = %v", err)
+	}
+
+	want := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MAP:URI="https://cdn.example/init.mp4?sig=1"
+#EXTINF:6.0,
+https://cdn.example/segment_0000.m4s?sig=1
+#EXTINF:4.2,
+https://cdn.example/segment_0001.m4s?sig=1
+#EXT-X-ENDLIST
+`
+	if string(got) != want {
+		t.Errorf("RewriteURIs() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := RewriteURIs([]byte("segment.ts\n"), func(uri string) (string, error) { return uri, nil }); err == nil || !strings.Contains(err.Error(), "m3u8") {
+		t.Errorf("RewriteURIs() on non-playlist error = %v", err)
+	}
+}
+
+func TestSessionDataTag(t *testing.T) {
+	got := SessionDataTag("org.hydrogenaudio.replaygain.track_gain", `-3.21 "dB"`)
+	want := `#EXT-X-SESSION-DATA:DATA-ID="org.hydrogenaudio.replaygain.track_gain",VALUE="-3.21 'dB'"`
+	if got != want {
+		t.Errorf("SessionDataTag() = %s, want %s", got, want)
+	}
+}
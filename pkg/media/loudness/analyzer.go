@@ -0,0 +1,56 @@
+package loudness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+	"go-audio-stream/pkg/media/pcm"
+)
+
+// Analyzer measures the loudness of an audio file
+type Analyzer interface {
+	Analyze(ctx context.Context, input string) (Result, error)
+}
+
+// FFmpegAnalyzer decodes any format ffmpeg reads and measures it with a
+// Meter
+type FFmpegAnalyzer struct {
+	runner *ffmpeg.Runner
+}
+
+// NewFFmpegAnalyzer creates an analyzer decoding with runner
+func NewFFmpegAnalyzer(runner *ffmpeg.Runner) *FFmpegAnalyzer {
+	return &FFmpegAnalyzer{runner: runner}
+}
+
+func (a *FFmpegAnalyzer) Analyze(ctx context.Context, input string) (Result, error) {
+	var result Result
+	err := pcm.Decode(ctx, a.runner, input, func(r *pcm.Reader) error {
+		var err error
+		result, err = Measure(r)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrSilent) {
+		return Result{}, fmt.Errorf("failed to measure loudness: %w", err)
+	}
+	return result, err
+}
+
+// Measure reads r to the end and measures it
+func Measure(r *pcm.Reader) (Result, error) {
+	meter := NewMeter(r.SampleRate, r.Channels)
+	samples := make([]float64, 4096*r.Channels)
+	for {
+		n, err := r.Read(samples)
+		meter.Write(samples[:n])
+		if errors.Is(err, io.EOF) {
+			return meter.Result()
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+}
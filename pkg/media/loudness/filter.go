@@ -0,0 +1,99 @@
+package loudness
+
+import "math"
+
+// biquad is a direct form II transposed second-order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kFilter is the BS.1770 K-weighting: a high-shelf modelling the head
+// followed by the RLB high-pass
+type kFilter struct {
+	shelf, highPass biquad
+}
+
+// newKFilter derives the K-weighting coefficients for any sample rate from
+// the analogue prototypes, matching the published 48 kHz coefficients
+func newKFilter(rate float64) kFilter {
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return kFilter{shelf: shelf, highPass: highPass}
+}
+
+func (f *kFilter) process(x float64) float64 {
+	return f.highPass.process(f.shelf.process(x))
+}
+
+// interpolation holds the polyphase coefficients of the true-peak
+// oversampling filter: a Hann-windowed sinc with its cutoff at the original
+// Nyquist frequency, as BS.1770 Annex 2 suggests
+var interpolation = func() [oversampling][tapsPerPhase]float64 {
+	var phases [oversampling][tapsPerPhase]float64
+	n := oversampling * tapsPerPhase
+	center := float64(n-1) / 2
+	for i := 0; i < n; i++ {
+		t := (float64(i) - center) / oversampling
+		sinc := 1.0
+		if t != 0 {
+			sinc = math.Sin(math.Pi*t) / (math.Pi * t)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(i)+0.5)/float64(n))
+		phases[i%oversampling][i/oversampling] = sinc * window
+	}
+	return phases
+}()
+
+// truePeakFilter tracks the highest absolute value of one channel
+// oversampled by the interpolation filter
+type truePeakFilter struct {
+	history [tapsPerPhase]float64
+	pos     int
+}
+
+func (f *truePeakFilter) process(x float64) float64 {
+	f.pos = (f.pos + tapsPerPhase - 1) % tapsPerPhase
+	f.history[f.pos] = x
+
+	peak := math.Abs(x)
+	for _, taps := range interpolation {
+		var y float64
+		for k, h := range taps {
+			y += h * f.history[(f.pos+k)%tapsPerPhase]
+		}
+		if y = math.Abs(y); y > peak {
+			peak = y
+		}
+	}
+	return peak
+}
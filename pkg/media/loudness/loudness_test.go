@@ -0,0 +1,125 @@
+package loudness
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/media/pcm"
+)
+
+const rate = 48000
+
+// sine returns interleaved stereo frames of a sine at dbfs peak level
+func sine(freq, dbfs, seconds, phase float64) []float64 {
+	amp := math.Pow(10, dbfs/20)
+	frames := int(seconds * rate)
+	samples := make([]float64, 0, 2*frames)
+	for i := 0; i < frames; i++ {
+		v := amp * math.Sin(2*math.Pi*freq*float64(i)/rate+phase)
+		samples = append(samples, v, v)
+	}
+	return samples
+}
+
+func measure(t *testing.T, samples []float64) Result {
+	t.Helper()
+	m := NewMeter(rate, 2)
+	m.Write(samples)
+	result, err := m.Result()
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	return result
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	// EBU Tech 3341 case 1: stereo 1 kHz at -23 dBFS reads -23 LUFS
+	result := measure(t, sine(1000, -23, 20, 0))
+	if math.Abs(result.Integrated+23) > 0.1 {
+		t.Errorf("Integrated = %.2f LUFS, want -23", result.Integrated)
+	}
+	if math.Abs(result.TrackGain()-5) > 0.1 {
+		t.Errorf("TrackGain() = %.2f dB, want 5", result.TrackGain())
+	}
+
+	// EBU Tech 3341 case 3: the quiet part falls below the relative gate
+	samples := append(sine(1000, -36, 10, 0), sine(1000, -23, 60, 0)...)
+	samples = append(samples, sine(1000, -36, 10, 0)...)
+	if result := measure(t, samples); math.Abs(result.Integrated+23) > 0.1 {
+		t.Errorf("gated Integrated = %.2f LUFS, want -23", result.Integrated)
+	}
+}
+
+func TestLoudnessRange(t *testing.T) {
+	// EBU Tech 3342 case 1: 20 s at -20 dBFS then 20 s at -30 dBFS
+	samples := append(sine(1000, -20, 20, 0), sine(1000, -30, 20, 0)...)
+	if result := measure(t, samples); math.Abs(result.Range-10) > 1 {
+		t.Errorf("Range = %.2f LU, want 10", result.Range)
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	// A quarter-rate sine sampled 45 degrees off its peaks: the samples
+	// reach only -9 dBFS but the waveform peaks at -6 dBFS
+	result := measure(t, sine(rate/4, -6, 5, math.Pi/4))
+	if math.Abs(result.TruePeak+6) > 0.5 {
+		t.Errorf("TruePeak = %.2f dBTP, want -6", result.TruePeak)
+	}
+	if math.Abs(result.TrackPeak()-0.5) > 0.03 {
+		t.Errorf("TrackPeak() = %.3f, want 0.5", result.TrackPeak())
+	}
+}
+
+func TestSilence(t *testing.T) {
+	m := NewMeter(rate, 2)
+	m.Write(make([]float64, 2*rate))
+	if _, err := m.Result(); !errors.Is(err, ErrSilent) {
+		t.Errorf("Result() error = %v, want ErrSilent", err)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	samples := sine(1000, -23, 10, 0)
+
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVE")
+	b.WriteString("LIST\x04\x00\x00\x00INFO")
+	b.WriteString("fmt \x10\x00\x00\x00")
+	for _, v := range []interface{}{uint16(1), uint16(2), uint32(rate), uint32(rate * 4), uint16(4), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data\xff\xff\xff\xff") // unknown size, as ffmpeg writes to a pipe
+	for _, v := range samples {
+		binary.Write(&b, binary.LittleEndian, int16(math.Round(v*(1<<15))))
+	}
+
+	reader, err := pcm.NewWAVReader(&b)
+	if err != nil {
+		t.Fatalf("NewWAVReader() error = %v", err)
+	}
+	result, err := Measure(reader)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if math.Abs(result.Integrated+23) > 0.1 {
+		t.Errorf("Integrated = %.2f LUFS, want -23", result.Integrated)
+	}
+}
+
+func TestLoudestWindow(t *testing.T) {
+	samples := append(sine(1000, -30, 40, 0), sine(1000, -10, 30, 0)...)
+	samples = append(samples, sine(1000, -30, 20, 0)...)
+
+	m := NewMeter(rate, 2)
+	m.Write(samples)
+	if got := m.LoudestWindow(30 * time.Second); got != 40*time.Second {
+		t.Errorf("LoudestWindow() = %v, want 40s", got)
+	}
+	if got := m.LoudestWindow(5 * time.Minute); got != 0 {
+		t.Errorf("LoudestWindow() longer than the track = %v, want 0", got)
+	}
+}
@@ -0,0 +1,232 @@
+// Package loudness measures audio loudness per EBU R128 (ITU-R BS.1770-4):
+// integrated loudness, loudness range and true peak.
+package loudness
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// ReferenceLUFS is the ReplayGain 2.0 target loudness track gains are
+// computed against
+const ReferenceLUFS = -18.0
+
+const (
+	absoluteGate   = -70.0 // LUFS
+	relativeGate   = -10.0 // LU below the ungated integrated loudness
+	rangeGate      = -20.0 // LU below the ungated short-term loudness
+	subBlocksMom   = 4     // 400 ms momentary blocks of 100 ms sub-blocks
+	subBlocksST    = 30    // 3 s short-term blocks
+	oversampling   = 4     // true-peak oversampling factor
+	tapsPerPhase   = 12
+	lowPercentile  = 0.10
+	highPercentile = 0.95
+)
+
+// subBlockDuration is the resolution of the gating blocks
+const subBlockDuration = 100 * time.Millisecond
+
+// ErrSilent is returned when no part of the audio is above the absolute
+// gate, so the integrated loudness is undefined
+var ErrSilent = errors.New("audio is silent or too short to measure")
+
+// Result holds the loudness of a track
+type Result struct {
+	// Integrated is the gated integrated loudness in LUFS
+	Integrated float64 `json:"integrated"`
+	// Range is the loudness range in LU
+	Range float64 `json:"range"`
+	// TruePeak is the highest inter-sample peak in dBTP
+	TruePeak float64 `json:"true_peak"`
+}
+
+// TrackGain is the ReplayGain 2.0 gain in dB that brings the track to
+// ReferenceLUFS
+func (r Result) TrackGain() float64 {
+	return ReferenceLUFS - r.Integrated
+}
+
+// TrackPeak is the true peak as a linear amplitude, as ReplayGain stores it
+func (r Result) TrackPeak() float64 {
+	return math.Pow(10, r.TruePeak/20)
+}
+
+// Meter accumulates interleaved samples and measures their loudness
+type Meter struct {
+	channels int
+	weights  []float64
+	filters  []kFilter
+	peaks    []truePeakFilter
+
+	subBlockSize int       // frames per 100 ms
+	frames       int       // frames in the current sub-block
+	sum          float64   // weighted sum of squares in the current sub-block
+	subBlocks    []float64 // weighted mean square of each finished sub-block
+	truePeak     float64
+}
+
+// NewMeter creates a meter for audio with the given sample rate and number
+// of channels. Six channels are taken as 5.1 (L R C LFE Ls Rs) and five as
+// L R C Ls Rs, with the LFE excluded and surrounds weighted per BS.1770.
+func NewMeter(sampleRate, channels int) *Meter {
+	m := &Meter{
+		channels:     channels,
+		weights:      channelWeights(channels),
+		filters:      make([]kFilter, channels),
+		peaks:        make([]truePeakFilter, channels),
+		subBlockSize: sampleRate / 10,
+	}
+	for i := range m.filters {
+		m.filters[i] = newKFilter(float64(sampleRate))
+	}
+	return m
+}
+
+func channelWeights(channels int) []float64 {
+	weights := make([]float64, channels)
+	for i := range weights {
+		weights[i] = 1
+	}
+	switch channels {
+	case 5:
+		weights[3], weights[4] = 1.41, 1.41
+	case 6:
+		weights[3], weights[4], weights[5] = 0, 1.41, 1.41
+	}
+	return weights
+}
+
+// Write adds interleaved samples in the range [-1, 1]. A trailing partial
+// frame is ignored.
+func (m *Meter) Write(samples []float64) {
+	for i := 0; i+m.channels <= len(samples); i += m.channels {
+		for ch := 0; ch < m.channels; ch++ {
+			x := samples[i+ch]
+			if peak := m.peaks[ch].process(x); peak > m.truePeak {
+				m.truePeak = peak
+			}
+			if m.weights[ch] != 0 {
+				y := m.filters[ch].process(x)
+				m.sum += m.weights[ch] * y * y
+			}
+		}
+
+		m.frames++
+		if m.frames == m.subBlockSize {
+			m.subBlocks = append(m.subBlocks, m.sum/float64(m.subBlockSize))
+			m.frames, m.sum = 0, 0
+		}
+	}
+}
+
+// Result measures everything written so far
+func (m *Meter) Result() (Result, error) {
+	integrated, ok := integratedLoudness(blockPowers(m.subBlocks, subBlocksMom))
+	if !ok {
+		return Result{}, ErrSilent
+	}
+	return Result{
+		Integrated: integrated,
+		Range:      loudnessRange(blockPowers(m.subBlocks, subBlocksST)),
+		TruePeak:   20 * math.Log10(m.truePeak),
+	}, nil
+}
+
+// LoudestWindow returns the start of the window of the given length with
+// the highest K-weighted energy, to the nearest 100 ms. Audio shorter than
+// the window starts at 0.
+func (m *Meter) LoudestWindow(window time.Duration) time.Duration {
+	n := int(window / subBlockDuration)
+	if n < 1 || len(m.subBlocks) <= n {
+		return 0
+	}
+
+	var sum, best float64
+	start := 0
+	for i, p := range m.subBlocks {
+		sum += p
+		if i >= n {
+			sum -= m.subBlocks[i-n]
+		}
+		if i >= n-1 && sum > best {
+			best, start = sum, i-n+1
+		}
+	}
+	return time.Duration(start) * subBlockDuration
+}
+
+// blockPowers averages n consecutive sub-blocks, sliding by one sub-block
+func blockPowers(subBlocks []float64, n int) []float64 {
+	if len(subBlocks) < n {
+		return nil
+	}
+	powers := make([]float64, 0, len(subBlocks)-n+1)
+	var sum float64
+	for i, p := range subBlocks {
+		sum += p
+		if i >= n {
+			sum -= subBlocks[i-n]
+		}
+		if i >= n-1 {
+			powers = append(powers, math.Max(sum/float64(n), 0))
+		}
+	}
+	return powers
+}
+
+func integratedLoudness(powers []float64) (float64, bool) {
+	gated := gate(powers, power(absoluteGate))
+	if len(gated) == 0 {
+		return 0, false
+	}
+	gated = gate(gated, mean(gated)*power(relativeGate))
+	return lufs(mean(gated)), true
+}
+
+func loudnessRange(powers []float64) float64 {
+	gated := gate(powers, power(absoluteGate))
+	if len(gated) == 0 {
+		return 0
+	}
+	gated = gate(gated, mean(gated)*power(rangeGate))
+
+	values := make([]float64, len(gated))
+	for i, p := range gated {
+		values[i] = lufs(p)
+	}
+	sort.Float64s(values)
+	low := values[int(math.Round(float64(len(values)-1)*lowPercentile))]
+	high := values[int(math.Round(float64(len(values)-1)*highPercentile))]
+	return high - low
+}
+
+// gate keeps the powers above threshold
+func gate(powers []float64, threshold float64) []float64 {
+	var kept []float64
+	for _, p := range powers {
+		if p > threshold {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// lufs converts a weighted mean square to loudness
+func lufs(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// power converts loudness, or a loudness difference, to a mean square factor
+func power(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
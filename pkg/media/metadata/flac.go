@@ -0,0 +1,118 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// FLAC metadata block types
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+// readFLAC walks the FLAC metadata blocks. The exact duration comes from the
+// total sample count in STREAMINFO.
+func readFLAC(r io.ReadSeeker, size int64, m *Metadata) error {
+	offset := int64(4)
+	for {
+		header, err := readAt(r, offset, 4)
+		if err != nil {
+			return err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4
+
+		if offset+length > size {
+			return ErrMalformed
+		}
+
+		switch blockType {
+		case flacStreamInfo:
+			if length < 34 {
+				return ErrMalformed
+			}
+			block, err := readAt(r, offset, int(length))
+			if err != nil {
+				return err
+			}
+			m.SampleRate = int(block[10])<<12 | int(block[11])<<4 | int(block[12])>>4
+			m.Channels = int(block[12]>>1&0x07) + 1
+			totalSamples := int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+			m.setDuration(totalSamples, m.SampleRate)
+		case flacVorbisComment:
+			block, err := readAt(r, offset, int(length))
+			if err != nil {
+				return err
+			}
+			if err := parseVorbisComment(block, m); err != nil {
+				return err
+			}
+		case flacPicture:
+			if length > maxPictureSize {
+				break
+			}
+			block, err := readAt(r, offset, int(length))
+			if err != nil {
+				return err
+			}
+			if p, front := parseFLACPicture(block); p != nil {
+				m.setPicture(p, front)
+			}
+		}
+
+		offset += length
+		if last {
+			break
+		}
+	}
+
+	if m.SampleRate == 0 {
+		return ErrMalformed
+	}
+	m.setBitrate(size - offset)
+
+	return nil
+}
+
+// parseFLACPicture parses a FLAC PICTURE block, also used base64-encoded
+// in Ogg METADATA_BLOCK_PICTURE comments
+func parseFLACPicture(block []byte) (*Picture, bool) {
+	field := func(b []byte) ([]byte, []byte, bool) {
+		if len(b) < 4 {
+			return nil, nil, false
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, nil, false
+		}
+		return b[4 : 4+n], b[4+n:], true
+	}
+
+	if len(block) < 4 {
+		return nil, false
+	}
+	pictureType := binary.BigEndian.Uint32(block)
+
+	mimeType, rest, ok := field(block[4:])
+	if !ok {
+		return nil, false
+	}
+	_, rest, ok = field(rest) // description
+	if !ok || len(rest) < 16 {
+		return nil, false
+	}
+	data, _, ok := field(rest[16:]) // skip width, height, depth and colors
+	if !ok {
+		return nil, false
+	}
+
+	mime := pictureMIMEType(data)
+	if mime == "" {
+		mime = string(mimeType)
+	}
+	return &Picture{MIMEType: mime, Data: data}, pictureType == 3
+}
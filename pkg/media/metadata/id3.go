@@ -0,0 +1,362 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// id3Fields maps ID3v2.3/2.4 text frames to canonical field names
+var id3Fields = map[string]string{
+	"TIT2": "title",
+	"TPE1": "artist",
+	"TPE2": "albumartist",
+	"TALB": "album",
+	"TCON": "genre",
+	"TYER": "date",
+	"TDRC": "date",
+	"TORY": "date",
+	"TRCK": "tracknumber",
+	"TPOS": "discnumber",
+	"TCOM": "composer",
+	"TEXT": "lyricist",
+	"TLAN": "language",
+}
+
+// id3v22Frames maps ID3v2.2 three-character frame IDs to their v2.3 names
+var id3v22Frames = map[string]string{
+	"TT2": "TIT2",
+	"TP1": "TPE1",
+	"TP2": "TPE2",
+	"TAL": "TALB",
+	"TCO": "TCON",
+	"TYE": "TYER",
+	"TOR": "TORY",
+	"TRK": "TRCK",
+	"TPA": "TPOS",
+	"TCM": "TCOM",
+	"TXT": "TEXT",
+	"TLA": "TLAN",
+	"TLE": "TLEN",
+	"PIC": "PIC",
+}
+
+// id3Tag is the result of parsing an ID3v2 tag
+type id3Tag struct {
+	size     int64
+	lengthMS int64
+}
+
+// readID3v2 parses an ID3v2 tag at the start of r, a file of fileSize
+// bytes. It returns a zero size when there is no tag.
+func readID3v2(r io.ReadSeeker, fileSize int64, m *Metadata) (id3Tag, error) {
+	header, err := readAt(r, 0, 10)
+	if err != nil || string(header[:3]) != "ID3" {
+		return id3Tag{}, nil
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := int64(syncsafe(header[6:10]))
+	tag := id3Tag{size: 10 + size}
+	if version == 4 && flags&0x10 != 0 {
+		tag.size += 10 // footer
+	}
+	if version < 2 || version > 4 {
+		return tag, nil
+	}
+
+	// The size comes from the file, so check it before allocating
+	if 10+size > fileSize {
+		return tag, ErrMalformed
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return tag, ErrMalformed
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+
+	if flags&0x40 != 0 && version > 2 && len(body) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(body[:4])) + 4
+		if version == 4 {
+			extSize = syncsafe(body[:4])
+		}
+		if extSize > len(body) {
+			return tag, ErrMalformed
+		}
+		body = body[extSize:]
+	}
+
+	for len(body) > 0 {
+		var id string
+		var frame []byte
+
+		if version == 2 {
+			if len(body) < 6 || body[0] == 0 {
+				break
+			}
+			id = id3v22Frames[string(body[:3])]
+			frameSize := int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+			if 6+frameSize > len(body) {
+				break
+			}
+			frame = body[6 : 6+frameSize]
+			body = body[6+frameSize:]
+		} else {
+			if len(body) < 10 || body[0] == 0 {
+				break
+			}
+			id = string(body[:4])
+			frameSize := int(binary.BigEndian.Uint32(body[4:8]))
+			if version == 4 {
+				frameSize = syncsafe(body[4:8])
+			}
+			if 10+frameSize > len(body) {
+				break
+			}
+			formatFlags := body[9]
+			frame = body[10 : 10+frameSize]
+			body = body[10+frameSize:]
+
+			if version == 4 {
+				if formatFlags&0x02 != 0 {
+					frame = removeUnsync(frame)
+				}
+				if formatFlags&0x01 != 0 && len(frame) >= 4 {
+					frame = frame[4:] // data length indicator
+				}
+			}
+			if formatFlags&0x0C != 0 {
+				continue // compressed or encrypted
+			}
+		}
+
+		if len(frame) == 0 {
+			continue
+		}
+
+		switch {
+		case id == "APIC":
+			if p, front := parseAPIC(frame); p != nil {
+				m.setPicture(p, front)
+			}
+		case id == "PIC":
+			if p, front := parsePIC(frame); p != nil {
+				m.setPicture(p, front)
+			}
+		case id == "TLEN":
+			tag.lengthMS, _ = strconv.ParseInt(strings.Trim(decodeText(frame[0], frame[1:]), "\x00 "), 10, 64)
+		case id == "TCON":
+			m.set("genre", resolveGenre(decodeText(frame[0], frame[1:])))
+		case id3Fields[id] != "":
+			m.set(id3Fields[id], decodeText(frame[0], frame[1:]))
+		}
+	}
+
+	return tag, nil
+}
+
+// readID3v1 parses the 128-byte ID3v1 tag at the end of the file, filling
+// only fields the ID3v2 tag did not set. It reports whether a tag exists.
+func readID3v1(r io.ReadSeeker, size int64, m *Metadata) bool {
+	if size < 128 {
+		return false
+	}
+	tag, err := readAt(r, size-128, 128)
+	if err != nil || string(tag[:3]) != "TAG" {
+		return false
+	}
+
+	m.set("title", latin1(tag[3:33]))
+	if len(m.Artists) == 0 {
+		m.set("artist", latin1(tag[33:63]))
+	}
+	m.set("album", latin1(tag[63:93]))
+	m.set("date", latin1(tag[93:97]))
+	if tag[125] == 0 && tag[126] != 0 {
+		m.set("tracknumber", strconv.Itoa(int(tag[126])))
+	}
+	if int(tag[127]) < len(id3Genres) {
+		m.set("genre", id3Genres[tag[127]])
+	}
+
+	return true
+}
+
+// parseAPIC parses an ID3v2.3/2.4 attached picture frame
+func parseAPIC(frame []byte) (*Picture, bool) {
+	encoding := frame[0]
+	mimeType, rest, ok := cutTerminated(0, frame[1:])
+	if !ok || len(rest) < 1 {
+		return nil, false
+	}
+	pictureType := rest[0]
+	_, data, ok := cutTerminated(encoding, rest[1:])
+	if !ok || len(data) > maxPictureSize {
+		return nil, false
+	}
+
+	if detected := pictureMIMEType(data); detected != "" {
+		mimeType = detected
+	} else if !strings.Contains(mimeType, "/") {
+		mimeType = "image/" + strings.ToLower(mimeType)
+	}
+	return &Picture{MIMEType: mimeType, Data: data}, pictureType == 3
+}
+
+// parsePIC parses an ID3v2.2 picture frame, which has a three-letter image
+// format instead of a MIME type
+func parsePIC(frame []byte) (*Picture, bool) {
+	if len(frame) < 5 {
+		return nil, false
+	}
+	encoding := frame[0]
+	pictureType := frame[4]
+	_, data, ok := cutTerminated(encoding, frame[5:])
+	if !ok || len(data) > maxPictureSize {
+		return nil, false
+	}
+
+	mimeType := pictureMIMEType(data)
+	if mimeType == "" {
+		mimeType = "image/" + strings.ToLower(string(frame[1:4]))
+	}
+	return &Picture{MIMEType: mimeType, Data: data}, pictureType == 3
+}
+
+// resolveGenre expands ID3v1 genre references such as "(13)" or "13"
+func resolveGenre(value string) string {
+	value = strings.Trim(value, "\x00 ")
+	ref := value
+	if strings.HasPrefix(ref, "(") {
+		if end := strings.IndexByte(ref, ')'); end > 0 {
+			if rest := strings.TrimSpace(ref[end+1:]); rest != "" {
+				return rest
+			}
+			ref = ref[1:end]
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < len(id3Genres) {
+		return id3Genres[n]
+	}
+	return value
+}
+
+// decodeText decodes an ID3v2 text payload. Multiple values (ID3v2.4) are
+// returned separated by NUL characters.
+func decodeText(encoding byte, data []byte) string {
+	var values []string
+	for len(data) > 0 {
+		value, rest, _ := cutTerminated(encoding, data)
+		values = append(values, value)
+		data = rest
+	}
+	return strings.Join(values, "\x00")
+}
+
+// cutTerminated decodes one NUL-terminated string in the given ID3 text
+// encoding and returns the remaining bytes. A missing terminator consumes
+// the whole input.
+func cutTerminated(encoding byte, data []byte) (string, []byte, bool) {
+	switch encoding {
+	case 1, 2:
+		end := -1
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return decodeUTF16(data, encoding == 2), nil, true
+		}
+		return decodeUTF16(data[:end], encoding == 2), data[end+2:], true
+	default:
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			end = len(data)
+		}
+		value := data[:end]
+		rest := data[end:]
+		if len(rest) > 0 {
+			rest = rest[1:]
+		}
+		if encoding == 3 {
+			return string(value), rest, true
+		}
+		return latin1(value), rest, true
+	}
+}
+
+// decodeUTF16 decodes UTF-16 honouring a byte order mark when present
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian, data = false, data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian, data = true, data[2:]
+		}
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(data[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 decodes ISO-8859-1, trimming NUL padding
+func latin1(data []byte) string {
+	runes := make([]rune, 0, len(data))
+	for _, b := range data {
+		if b == 0 {
+			break
+		}
+		runes = append(runes, rune(b))
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// removeUnsync reverses the ID3 unsynchronisation scheme (0xFF 0x00 -> 0xFF)
+func removeUnsync(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+// id3Genres is the ID3v1 genre list
+var id3Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
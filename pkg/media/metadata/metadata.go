@@ -0,0 +1,251 @@
+// Package metadata reads tags, duration and embedded cover art from MP3,
+// FLAC, Ogg Vorbis/Opus and MP4/M4A files without external tools.
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats recognised by Read
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+	FormatOpus = "opus"
+	FormatMP4  = "mp4"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrMalformed         = errors.New("malformed audio file")
+)
+
+// maxPictureSize bounds embedded cover art read into memory
+const maxPictureSize = 16 << 20
+
+// Picture is embedded cover art
+type Picture struct {
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"-"`
+}
+
+// Metadata holds the tags and stream properties of an audio file. Fields
+// missing from the file are left empty.
+type Metadata struct {
+	Format      string   `json:"format"`
+	Title       string   `json:"title,omitempty"`
+	Artists     []string `json:"artists,omitempty"`
+	AlbumArtist string   `json:"album_artist,omitempty"`
+	Album       string   `json:"album,omitempty"`
+	Genre       string   `json:"genre,omitempty"`
+	Composer    string   `json:"composer,omitempty"`
+	Lyricist    string   `json:"lyricist,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Year        int      `json:"year,omitempty"`
+	TrackNumber int      `json:"track_number,omitempty"`
+	TrackTotal  int      `json:"track_total,omitempty"`
+	DiscNumber  int      `json:"disc_number,omitempty"`
+	DiscTotal   int      `json:"disc_total,omitempty"`
+
+	DurationMS int64 `json:"duration_ms"`
+	SampleRate int   `json:"sample_rate,omitempty"`
+	Channels   int   `json:"channels,omitempty"`
+	Bitrate    int   `json:"bitrate,omitempty"`
+
+	Picture *Picture `json:"picture,omitempty"`
+}
+
+// Read detects the container format of r and parses its metadata
+func Read(r io.ReadSeeker) (*Metadata, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		m.Format = FormatFLAC
+		err = readFLAC(r, size, m)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		err = readOgg(r, size, m)
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		m.Format = FormatMP4
+		err = readMP4(r, size, m)
+	case bytes.HasPrefix(header, []byte("ID3")) || isMPEGSync(header):
+		m.Format = FormatMP3
+		err = readMP3(r, size, m)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// set assigns a tag value by its canonical (Vorbis comment style) field
+// name. The first value seen for a field wins, except artists which
+// accumulate.
+func (m *Metadata) set(field, value string) {
+	value = strings.TrimSpace(strings.Trim(value, "\x00"))
+	if value == "" {
+		return
+	}
+
+	first := value
+	if i := strings.IndexByte(value, 0); i >= 0 {
+		first = strings.TrimSpace(value[:i])
+	}
+
+	switch field {
+	case "title":
+		setString(&m.Title, first)
+	case "artist":
+		for _, artist := range SplitArtists(value) {
+			if !containsFold(m.Artists, artist) {
+				m.Artists = append(m.Artists, artist)
+			}
+		}
+	case "albumartist":
+		setString(&m.AlbumArtist, first)
+	case "album":
+		setString(&m.Album, first)
+	case "genre":
+		setString(&m.Genre, first)
+	case "composer":
+		setString(&m.Composer, first)
+	case "lyricist":
+		setString(&m.Lyricist, first)
+	case "language":
+		setString(&m.Language, first)
+	case "date":
+		if m.Year == 0 && len(first) >= 4 {
+			m.Year, _ = strconv.Atoi(first[:4])
+		}
+	case "tracknumber":
+		setNumberPair(&m.TrackNumber, &m.TrackTotal, first)
+	case "tracktotal":
+		setNumberPair(&m.TrackTotal, nil, first)
+	case "discnumber":
+		setNumberPair(&m.DiscNumber, &m.DiscTotal, first)
+	case "disctotal":
+		setNumberPair(&m.DiscTotal, nil, first)
+	}
+}
+
+// setPicture keeps the first picture, or replaces it with a front cover
+func (m *Metadata) setPicture(p *Picture, frontCover bool) {
+	if p == nil || len(p.Data) == 0 {
+		return
+	}
+	if m.Picture == nil || frontCover {
+		m.Picture = p
+	}
+}
+
+// setDuration derives DurationMS from a sample count
+func (m *Metadata) setDuration(samples int64, sampleRate int) {
+	if samples <= 0 || sampleRate <= 0 {
+		return
+	}
+	m.DurationMS = samples * 1000 / int64(sampleRate)
+}
+
+// setBitrate derives the average bitrate from the audio payload size
+func (m *Metadata) setBitrate(audioBytes int64) {
+	if m.Bitrate == 0 && m.DurationMS > 0 && audioBytes > 0 {
+		m.Bitrate = int(audioBytes * 8 * 1000 / m.DurationMS)
+	}
+}
+
+// SplitArtists splits a multi-artist tag value such as
+// "A.R. Rahman feat. Shakthisree Gopalan" into individual names
+func SplitArtists(value string) []string {
+	for _, sep := range []string{"\x00", ";", ",", " & ", " feat. ", " ft. ", " featuring ", " Feat. ", " Ft. "} {
+		value = strings.ReplaceAll(value, sep, "\x00")
+	}
+
+	var artists []string
+	for _, artist := range strings.Split(value, "\x00") {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	return artists
+}
+
+func setString(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// setNumberPair parses "n" or "n/total"
+func setNumberPair(number, total *int, value string) {
+	n, t, _ := strings.Cut(value, "/")
+	if v, err := strconv.Atoi(strings.TrimSpace(n)); err == nil && *number == 0 {
+		*number = v
+	}
+	if total != nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(t)); err == nil && *total == 0 {
+			*total = v
+		}
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// readAt reads exactly n bytes at offset
+func readAt(r io.ReadSeeker, offset int64, n int) ([]byte, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return buf, nil
+}
+
+// pictureMIMEType sniffs the image format of cover art
+func pictureMIMEType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "image/gif"
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	default:
+		return ""
+	}
+}
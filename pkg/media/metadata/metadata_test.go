@@ -0,0 +1,178 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		file string
+		want Metadata
+		mime string
+	}{
+		{
+			file: "id3v23.mp3",
+			want: Metadata{
+				Format:      FormatMP3,
+				Title:       "Vande Mataram",
+				Artists:     []string{"A.R. Rahman", "Shakthisree Gopalan"},
+				Album:       "Maa Tujhe Salaam",
+				Genre:       "Pop",
+				Language:    "hin",
+				Year:        1997,
+				TrackNumber: 3,
+				TrackTotal:  10,
+				DurationMS:  2612,
+				SampleRate:  44100,
+				Channels:    2,
+				Bitrate:     127718,
+			},
+			mime: "image/jpeg",
+		},
+		{
+			file: "id3v24_xing.mp3",
+			want: Metadata{
+				Format:     FormatMP3,
+				Title:      "Jai Ho",
+				Artists:    []string{"A.R. Rahman", "Sukhwinder Singh"},
+				Year:       2008,
+				DiscNumber: 1,
+				DiscTotal:  2,
+				DurationMS: 26122,
+				SampleRate: 44100,
+				Channels:   2,
+				Bitrate:    1277,
+			},
+		},
+		{
+			file: "test.flac",
+			want: Metadata{
+				Format:      FormatFLAC,
+				Title:       "Kun Faya Kun",
+				Artists:     []string{"A.R. Rahman", "Javed Ali"},
+				Album:       "Rockstar",
+				Genre:       "Sufi",
+				Year:        2011,
+				TrackNumber: 5,
+				TrackTotal:  14,
+				DurationMS:  3000,
+				SampleRate:  44100,
+				Channels:    2,
+				Bitrate:     2666,
+			},
+			mime: "image/png",
+		},
+		{
+			file: "test.ogg",
+			want: Metadata{
+				Format:     FormatOgg,
+				Title:      "Dil Se Re",
+				Artists:    []string{"A.R. Rahman"},
+				Album:      "Dil Se",
+				DurationMS: 2000,
+				SampleRate: 48000,
+				Channels:   2,
+				Bitrate:    3880,
+			},
+			mime: "image/jpeg",
+		},
+		{
+			file: "test.opus",
+			want: Metadata{
+				Format:     FormatOpus,
+				Title:      "Roja",
+				Artists:    []string{"S.P. Balasubrahmanyam", "Sujatha"},
+				Language:   "tam",
+				DurationMS: 3000,
+				SampleRate: 44100,
+				Channels:   2,
+				Bitrate:    2266,
+			},
+		},
+		{
+			file: "test.m4a",
+			want: Metadata{
+				Format:      FormatMP4,
+				Title:       "Chaiyya Chaiyya",
+				Artists:     []string{"Sukhwinder Singh", "Sapna Awasthi"},
+				Album:       "Dil Se",
+				Genre:       "Pop",
+				Lyricist:    "Gulzar",
+				Language:    "eng",
+				Year:        1998,
+				TrackNumber: 1,
+				TrackTotal:  6,
+				DiscNumber:  1,
+				DiscTotal:   1,
+				DurationMS:  4000,
+				SampleRate:  44100,
+				Channels:    2,
+				Bitrate:     8000,
+			},
+			mime: "image/jpeg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open("testdata/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := Read(f)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			picture := got.Picture
+			got.Picture = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Read() =\n%+v\nwant\n%+v", *got, tt.want)
+			}
+
+			switch {
+			case tt.mime == "" && picture != nil:
+				t.Errorf("Picture = %s, want none", picture.MIMEType)
+			case tt.mime != "" && (picture == nil || picture.MIMEType != tt.mime || len(picture.Data) == 0):
+				t.Errorf("Picture = %+v, want %s", picture, tt.mime)
+			}
+		})
+	}
+}
+
+func TestReadUnsupported(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVEfmt ")))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Read() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestReadOversizedID3(t *testing.T) {
+	// A tag claiming 256 MB in a file of a few bytes
+	data := append([]byte("ID3\x03\x00\x00\x7f\x7f\x7f\x7f"), make([]byte, 32)...)
+	_, err := Read(bytes.NewReader(data))
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("Read() error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestSplitArtists(t *testing.T) {
+	tests := map[string][]string{
+		"A.R. Rahman":                       {"A.R. Rahman"},
+		"Shankar, Ehsaan & Loy":             {"Shankar", "Ehsaan", "Loy"},
+		"Pritam feat. Arijit Singh; Shreya": {"Pritam", "Arijit Singh", "Shreya"},
+		"Ilaiyaraaja ft. S. Janaki":         {"Ilaiyaraaja", "S. Janaki"},
+		"  ":                                nil,
+	}
+	for value, want := range tests {
+		if got := SplitArtists(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitArtists(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
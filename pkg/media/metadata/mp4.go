@@ -0,0 +1,300 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// mp4Fields maps iTunes-style ilst atoms to canonical field names
+var mp4Fields = map[string]string{
+	"\xa9nam": "title",
+	"\xa9ART": "artist",
+	"aART":    "albumartist",
+	"\xa9alb": "album",
+	"\xa9gen": "genre",
+	"\xa9day": "date",
+	"\xa9wrt": "composer",
+	"\xa9lyr": "lyricist",
+}
+
+// mp4Containers are atoms whose payload is a list of child atoms
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"udta": true,
+	"meta": true,
+	"ilst": true,
+}
+
+// maxMP4Atom bounds the leaf atoms read into memory
+const maxMP4Atom = maxPictureSize + 1024
+
+// mp4Reader accumulates state while walking the atom tree
+type mp4Reader struct {
+	r io.ReadSeeker
+	m *Metadata
+
+	movieDuration  int64
+	movieTimescale int64
+	trackDuration  int64
+	trackTimescale int64
+	trackLanguage  string
+	mdatSize       int64
+
+	// mdhd values of the current trak, kept if its handler is audio
+	pendingDuration  int64
+	pendingTimescale int64
+	pendingLanguage  string
+}
+
+// readMP4 walks the MP4/M4A atom tree for the movie header, the audio
+// track and the iTunes metadata list
+func readMP4(r io.ReadSeeker, size int64, m *Metadata) error {
+	p := &mp4Reader{r: r, m: m}
+	if err := p.walk(0, size, ""); err != nil {
+		return err
+	}
+
+	switch {
+	case p.trackTimescale > 0 && p.trackDuration > 0:
+		m.setDuration(p.trackDuration, int(p.trackTimescale))
+	case p.movieTimescale > 0:
+		m.setDuration(p.movieDuration, int(p.movieTimescale))
+	}
+	if p.trackLanguage != "" && p.trackLanguage != "und" {
+		m.set("language", p.trackLanguage)
+	}
+	m.setBitrate(p.mdatSize)
+
+	return nil
+}
+
+// walk iterates the atoms between start and end
+func (p *mp4Reader) walk(start, end int64, parent string) error {
+	for offset := start; offset+8 <= end; {
+		header, err := readAt(p.r, offset, 8)
+		if err != nil {
+			return err
+		}
+		atomSize := int64(binary.BigEndian.Uint32(header))
+		name := string(header[4:8])
+		headerSize := int64(8)
+
+		switch atomSize {
+		case 0:
+			atomSize = end - offset // extends to the end of the file
+		case 1:
+			ext, err := readAt(p.r, offset+8, 8)
+			if err != nil {
+				return err
+			}
+			atomSize = int64(binary.BigEndian.Uint64(ext))
+			headerSize = 16
+		}
+		if atomSize < headerSize || offset+atomSize > end {
+			return ErrMalformed
+		}
+
+		bodyStart := offset + headerSize
+		bodyEnd := offset + atomSize
+		if err := p.atom(name, parent, bodyStart, bodyEnd); err != nil {
+			return err
+		}
+		offset = bodyEnd
+	}
+	return nil
+}
+
+func (p *mp4Reader) atom(name, parent string, start, end int64) error {
+	size := end - start
+
+	switch {
+	case name == "mdat":
+		p.mdatSize += size
+		return nil
+	case name == "trak":
+		p.pendingTimescale, p.pendingDuration, p.pendingLanguage = 0, 0, ""
+		return p.walk(start, end, name)
+	case name == "meta":
+		// meta is a full box in MP4 but a plain container in QuickTime
+		header, err := readAt(p.r, start, 8)
+		if err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint32(header) == 0 && string(header[4:8]) != "hdlr" {
+			start += 4
+		}
+		return p.walk(start, end, name)
+	case mp4Containers[name]:
+		return p.walk(start, end, name)
+	case parent == "ilst":
+		return p.ilstItem(name, start, end)
+	}
+
+	switch name {
+	case "mvhd", "mdhd", "hdlr", "stsd":
+	default:
+		return nil
+	}
+	if size > maxMP4Atom {
+		return nil
+	}
+	body, err := readAt(p.r, start, int(size))
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "mvhd":
+		p.movieTimescale, p.movieDuration = mp4Duration(body)
+	case "mdhd":
+		p.pendingTimescale, p.pendingDuration = mp4Duration(body)
+		p.pendingLanguage = mp4Language(body)
+	case "hdlr":
+		// mdhd precedes hdlr inside mdia; keep the first audio track
+		if parent == "mdia" && len(body) >= 12 && string(body[8:12]) == "soun" && p.trackTimescale == 0 {
+			p.trackTimescale, p.trackDuration, p.trackLanguage = p.pendingTimescale, p.pendingDuration, p.pendingLanguage
+		}
+	case "stsd":
+		// Full box header, entry count, then the first sample entry:
+		// size, format, 6 reserved, data ref index, 8 reserved,
+		// channel count, sample size, 4 reserved, sample rate (16.16)
+		if len(body) >= 8+36 {
+			entry := body[8:]
+			format := string(entry[4:8])
+			if format == "mp4a" || format == "alac" || format == "fLaC" || format == "Opus" {
+				p.m.Channels = int(binary.BigEndian.Uint16(entry[24:26]))
+				p.m.SampleRate = int(binary.BigEndian.Uint32(entry[32:36]) >> 16)
+			}
+		}
+	}
+	return nil
+}
+
+// ilstItem reads the data atom of an iTunes metadata item
+func (p *mp4Reader) ilstItem(name string, start, end int64) error {
+	if end-start > maxMP4Atom {
+		return nil
+	}
+	body, err := readAt(p.r, start, int(end-start))
+	if err != nil {
+		return err
+	}
+
+	var freeformName string
+	for len(body) >= 8 {
+		atomSize := int(binary.BigEndian.Uint32(body))
+		if atomSize < 8 || atomSize > len(body) {
+			return nil
+		}
+		child := string(body[4:8])
+		payload := body[8:atomSize]
+		body = body[atomSize:]
+
+		switch child {
+		case "name":
+			if len(payload) > 4 {
+				freeformName = strings.ToUpper(string(payload[4:]))
+			}
+		case "data":
+			if len(payload) < 8 {
+				continue
+			}
+			dataType := binary.BigEndian.Uint32(payload) & 0x00FFFFFF
+			value := payload[8:]
+			p.ilstData(name, freeformName, dataType, value)
+		}
+	}
+	return nil
+}
+
+func (p *mp4Reader) ilstData(name, freeformName string, dataType uint32, value []byte) {
+	switch name {
+	case "trkn", "disk":
+		// Binary: 2 reserved bytes, number, total
+		if len(value) >= 6 {
+			number := int(binary.BigEndian.Uint16(value[2:4]))
+			total := int(binary.BigEndian.Uint16(value[4:6]))
+			field := "tracknumber"
+			if name == "disk" {
+				field = "discnumber"
+			}
+			p.m.set(field, strconv.Itoa(number)+"/"+strconv.Itoa(total))
+		}
+	case "gnre":
+		// ID3v1 genre index plus one
+		if len(value) >= 2 {
+			if n := int(binary.BigEndian.Uint16(value)) - 1; n >= 0 && n < len(id3Genres) {
+				p.m.set("genre", id3Genres[n])
+			}
+		}
+	case "covr":
+		if len(value) > maxPictureSize {
+			return
+		}
+		mimeType := pictureMIMEType(value)
+		if mimeType == "" {
+			switch dataType {
+			case 13:
+				mimeType = "image/jpeg"
+			case 14:
+				mimeType = "image/png"
+			default:
+				return
+			}
+		}
+		data := make([]byte, len(value))
+		copy(data, value)
+		p.m.setPicture(&Picture{MIMEType: mimeType, Data: data}, true)
+	case "----":
+		if field := vorbisFields[freeformName]; field != "" && dataType == 1 {
+			p.m.set(field, string(value))
+		}
+	default:
+		if field := mp4Fields[name]; field != "" && dataType == 1 {
+			p.m.set(field, string(value))
+		}
+	}
+}
+
+// mp4Duration reads the timescale and duration of an mvhd or mdhd box
+func mp4Duration(body []byte) (int64, int64) {
+	if len(body) < 4 {
+		return 0, 0
+	}
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0, 0
+		}
+		return int64(binary.BigEndian.Uint32(body[20:24])), int64(binary.BigEndian.Uint64(body[24:32]))
+	}
+	if len(body) < 20 {
+		return 0, 0
+	}
+	return int64(binary.BigEndian.Uint32(body[12:16])), int64(binary.BigEndian.Uint32(body[16:20]))
+}
+
+// mp4Language decodes the packed ISO-639-2 language code of an mdhd box
+func mp4Language(body []byte) string {
+	offset := 20
+	if len(body) > 0 && body[0] == 1 {
+		offset = 32
+	}
+	if len(body) < offset+2 {
+		return ""
+	}
+	packed := binary.BigEndian.Uint16(body[offset:])
+	if packed == 0 {
+		return ""
+	}
+	return string([]byte{
+		byte(packed>>10&0x1F) + 0x60,
+		byte(packed>>5&0x1F) + 0x60,
+		byte(packed&0x1F) + 0x60,
+	})
+}
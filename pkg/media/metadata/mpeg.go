@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// MPEG audio bitrates in kbps indexed by [table][bitrate index]
+var mpegBitrates = [5][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0}, // V1 L1
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},    // V1 L2
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},     // V1 L3
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},    // V2 L1
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},         // V2 L2/L3
+}
+
+// MPEG audio sample rates indexed by [version][sample rate index]
+var mpegSampleRates = map[int][3]int{
+	10: {44100, 48000, 32000},
+	20: {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+// mpegFrame is a decoded MPEG audio frame header
+type mpegFrame struct {
+	version    int // 10, 20 or 25 for MPEG 1, 2 and 2.5
+	layer      int
+	bitrate    int
+	sampleRate int
+	channels   int
+	samples    int
+	size       int
+}
+
+// parseMPEGHeader decodes a 4-byte MPEG audio frame header
+func parseMPEGHeader(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || !isMPEGSync(b) {
+		return mpegFrame{}, false
+	}
+
+	var f mpegFrame
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		f.version = 25
+	case 2:
+		f.version = 20
+	case 3:
+		f.version = 10
+	default:
+		return mpegFrame{}, false
+	}
+
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		f.layer = 3
+	case 2:
+		f.layer = 2
+	case 3:
+		f.layer = 1
+	default:
+		return mpegFrame{}, false
+	}
+
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 0x03
+	if bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mpegFrame{}, false // free format is not supported
+	}
+
+	table := 4
+	switch {
+	case f.version == 10:
+		table = f.layer - 1
+	case f.layer == 1:
+		table = 3
+	}
+	f.bitrate = mpegBitrates[table][bitrateIndex] * 1000
+	f.sampleRate = mpegSampleRates[f.version][sampleRateIndex]
+
+	f.channels = 2
+	if b[3]>>6 == 3 {
+		f.channels = 1
+	}
+
+	padding := int(b[2]>>1) & 0x01
+	switch {
+	case f.layer == 1:
+		f.samples = 384
+		f.size = (12*f.bitrate/f.sampleRate + padding) * 4
+	case f.layer == 3 && f.version != 10:
+		f.samples = 576
+		f.size = 72*f.bitrate/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		f.size = 144*f.bitrate/f.sampleRate + padding
+	}
+
+	return f, f.size > 4
+}
+
+func isMPEGSync(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xE0 == 0xE0
+}
+
+// sameStream reports whether two frame headers belong to the same stream
+func (f mpegFrame) sameStream(o mpegFrame) bool {
+	return f.version == o.version && f.layer == o.layer && f.sampleRate == o.sampleRate
+}
+
+// xingFrames returns the frame count from a Xing/Info or VBRI header in the
+// first frame, which encoders write for VBR files
+func xingFrames(frame []byte, f mpegFrame) (int64, bool) {
+	offset := 4 + 17
+	switch {
+	case f.version == 10 && f.channels == 2:
+		offset = 4 + 32
+	case f.version != 10 && f.channels == 1:
+		offset = 4 + 9
+	}
+
+	if len(frame) >= offset+12 {
+		tag := string(frame[offset : offset+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[offset+4:])
+			if flags&0x01 != 0 {
+				return int64(binary.BigEndian.Uint32(frame[offset+8:])), true
+			}
+		}
+	}
+
+	if len(frame) >= 4+32+18 && string(frame[36:40]) == "VBRI" {
+		return int64(binary.BigEndian.Uint32(frame[36+14:])), true
+	}
+
+	return 0, false
+}
+
+// readMP3 parses ID3 tags and measures the exact duration of an MPEG audio
+// stream, from the Xing/VBRI frame count when present and otherwise by
+// walking every frame header
+func readMP3(r io.ReadSeeker, size int64, m *Metadata) error {
+	tag, err := readID3v2(r, size, m)
+	if err != nil {
+		return err
+	}
+
+	audioStart := tag.size
+	audioEnd := size
+	if readID3v1(r, size, m) {
+		audioEnd -= 128
+	}
+	if audioStart >= audioEnd {
+		return ErrMalformed
+	}
+
+	if _, err := r.Seek(audioStart, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(io.LimitReader(r, audioEnd-audioStart), 64*1024)
+
+	var (
+		first       mpegFrame
+		found       bool
+		totalFrames int64
+		samples     int64
+		audioBytes  int64
+		pos         int64
+	)
+
+	for {
+		header, err := reader.Peek(4)
+		if err != nil {
+			break
+		}
+
+		f, ok := parseMPEGHeader(header)
+		if !ok || (found && !f.sameStream(first)) {
+			// Lost sync: skip a byte and search for the next frame
+			reader.Discard(1)
+			pos++
+			if !found && pos > 64*1024 {
+				break
+			}
+			continue
+		}
+
+		if !found {
+			found = true
+			first = f
+			m.SampleRate = f.sampleRate
+			m.Channels = f.channels
+
+			frame, _ := reader.Peek(f.size)
+			if frames, ok := xingFrames(frame, f); ok {
+				totalFrames = frames
+				m.setDuration(frames*int64(f.samples), f.sampleRate)
+				m.setBitrate(audioEnd - audioStart - pos - int64(f.size))
+				break
+			}
+		}
+
+		discarded, _ := reader.Discard(f.size)
+		pos += int64(discarded)
+		if discarded < f.size {
+			break // truncated last frame
+		}
+		samples += int64(f.samples)
+		audioBytes += int64(f.size)
+	}
+
+	if !found {
+		return ErrMalformed
+	}
+
+	if totalFrames == 0 {
+		m.setDuration(samples, first.sampleRate)
+		m.setBitrate(audioBytes)
+	}
+	if m.DurationMS == 0 && tag.lengthMS > 0 {
+		m.DurationMS = tag.lengthMS
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// maxOggPacket bounds the header packets assembled in memory; comment
+// packets carry base64 cover art, so they can be large
+const maxOggPacket = maxPictureSize * 2
+
+// oggPage is one Ogg page with its lacing table and body
+type oggPage struct {
+	granule int64
+	serial  uint32
+	lacing  []byte
+	body    []byte
+}
+
+func readOggPage(r io.Reader) (*oggPage, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" || header[4] != 0 {
+		return nil, ErrMalformed
+	}
+
+	page := &oggPage{
+		granule: int64(binary.LittleEndian.Uint64(header[6:14])),
+		serial:  binary.LittleEndian.Uint32(header[14:18]),
+		lacing:  make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, page.lacing); err != nil {
+		return nil, ErrMalformed
+	}
+
+	bodySize := 0
+	for _, l := range page.lacing {
+		bodySize += int(l)
+	}
+	page.body = make([]byte, bodySize)
+	if _, err := io.ReadFull(r, page.body); err != nil {
+		return nil, ErrMalformed
+	}
+
+	return page, nil
+}
+
+// readOggHeaderPackets returns the first n packets of the first logical
+// stream, reassembling packets that span pages
+func readOggHeaderPackets(r io.Reader, n int) ([][]byte, uint32, error) {
+	var (
+		packets [][]byte
+		current []byte
+		serial  uint32
+	)
+
+	for first := true; len(packets) < n; first = false {
+		page, err := readOggPage(r)
+		if err != nil {
+			return nil, 0, ErrMalformed
+		}
+		if first {
+			serial = page.serial
+		} else if page.serial != serial {
+			continue
+		}
+
+		offset := 0
+		for _, l := range page.lacing {
+			current = append(current, page.body[offset:offset+int(l)]...)
+			offset += int(l)
+			if len(current) > maxOggPacket {
+				return nil, 0, ErrMalformed
+			}
+			if l < 255 {
+				packets = append(packets, current)
+				current = nil
+				if len(packets) == n {
+					break
+				}
+			}
+		}
+	}
+
+	return packets, serial, nil
+}
+
+// readOgg parses Ogg Vorbis and Ogg Opus files. The duration comes from the
+// granule position of the last page.
+func readOgg(r io.ReadSeeker, size int64, m *Metadata) error {
+	packets, serial, err := readOggHeaderPackets(bufio.NewReader(r), 2)
+	if err != nil {
+		return err
+	}
+	ident, comment := packets[0], packets[1]
+
+	var granuleRate int
+	var preSkip int64
+	switch {
+	case bytes.HasPrefix(ident, []byte("\x01vorbis")) && len(ident) >= 16:
+		m.Format = FormatOgg
+		m.Channels = int(ident[11])
+		m.SampleRate = int(binary.LittleEndian.Uint32(ident[12:16]))
+		granuleRate = m.SampleRate
+		if !bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			return ErrMalformed
+		}
+		comment = comment[7:]
+	case bytes.HasPrefix(ident, []byte("OpusHead")) && len(ident) >= 16:
+		m.Format = FormatOpus
+		m.Channels = int(ident[9])
+		preSkip = int64(binary.LittleEndian.Uint16(ident[10:12]))
+		m.SampleRate = int(binary.LittleEndian.Uint32(ident[12:16]))
+		// Opus granule positions always count 48 kHz samples
+		granuleRate = 48000
+		if m.SampleRate == 0 {
+			m.SampleRate = granuleRate
+		}
+		if !bytes.HasPrefix(comment, []byte("OpusTags")) {
+			return ErrMalformed
+		}
+		comment = comment[8:]
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	if err := parseVorbisComment(comment, m); err != nil {
+		return err
+	}
+
+	granule, err := lastOggGranule(r, size, serial)
+	if err != nil {
+		return err
+	}
+	m.setDuration(granule-preSkip, granuleRate)
+	m.setBitrate(size)
+
+	return nil
+}
+
+// lastOggGranule finds the granule position of the last page of the stream
+// by scanning the tail of the file
+func lastOggGranule(r io.ReadSeeker, size int64, serial uint32) (int64, error) {
+	const window = 64 * 1024
+	start := size - window
+	if start < 0 {
+		start = 0
+	}
+
+	tail, err := readAt(r, start, int(size-start))
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(tail) - 27; i >= 0; i-- {
+		if tail[i] != 'O' || !bytes.HasPrefix(tail[i:], []byte("OggS")) {
+			continue
+		}
+		page, err := readOggPage(bytes.NewReader(tail[i:]))
+		if err != nil || page.serial != serial || page.granule < 0 {
+			continue
+		}
+		return page.granule, nil
+	}
+
+	return 0, ErrMalformed
+}
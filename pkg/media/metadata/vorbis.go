@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// vorbisFields maps Vorbis comment keys to canonical field names
+var vorbisFields = map[string]string{
+	"TITLE":        "title",
+	"ARTIST":       "artist",
+	"PERFORMER":    "artist",
+	"ALBUMARTIST":  "albumartist",
+	"ALBUM ARTIST": "albumartist",
+	"ALBUM":        "album",
+	"GENRE":        "genre",
+	"DATE":         "date",
+	"YEAR":         "date",
+	"TRACKNUMBER":  "tracknumber",
+	"TRACKTOTAL":   "tracktotal",
+	"TOTALTRACKS":  "tracktotal",
+	"DISCNUMBER":   "discnumber",
+	"DISCTOTAL":    "disctotal",
+	"TOTALDISCS":   "disctotal",
+	"COMPOSER":     "composer",
+	"LYRICIST":     "lyricist",
+	"LANGUAGE":     "language",
+}
+
+// parseVorbisComment parses a Vorbis comment structure (as used by FLAC,
+// Ogg Vorbis and Opus): a vendor string followed by KEY=value pairs, all
+// length-prefixed little-endian
+func parseVorbisComment(data []byte, m *Metadata) error {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return "", false
+		}
+		value := string(data[4 : 4+n])
+		data = data[4+n:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return ErrMalformed
+	}
+	if len(data) < 4 {
+		return ErrMalformed
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	var coverArtMIME string
+	var coverArt []byte
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return ErrMalformed
+		}
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToUpper(key)
+
+		switch key {
+		case "METADATA_BLOCK_PICTURE":
+			if block, err := base64.StdEncoding.DecodeString(value); err == nil {
+				if p, front := parseFLACPicture(block); p != nil {
+					m.setPicture(p, front)
+				}
+			}
+		case "COVERART":
+			coverArt, _ = base64.StdEncoding.DecodeString(value)
+		case "COVERARTMIME":
+			coverArtMIME = value
+		default:
+			if field := vorbisFields[key]; field != "" {
+				m.set(field, value)
+			}
+		}
+	}
+
+	if len(coverArt) > 0 && m.Picture == nil {
+		if mime := pictureMIMEType(coverArt); mime != "" {
+			coverArtMIME = mime
+		}
+		m.setPicture(&Picture{MIMEType: coverArtMIME, Data: coverArt}, false)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package pcm reads decoded audio as interleaved float samples, from WAV
+// streams or any file ffmpeg can decode.
+package pcm
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+)
+
+// ErrUnsupportedWAV is returned for WAV data that is not 16/24/32-bit
+// integer or 32/64-bit float PCM
+var ErrUnsupportedWAV = errors.New("unsupported WAV format")
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// Reader reads interleaved samples in the range [-1, 1] from the data chunk
+// of a WAV stream
+type Reader struct {
+	SampleRate int
+	Channels   int
+
+	r          *bufio.Reader
+	decode     func([]byte) float64
+	sampleSize int
+	buf        []byte
+}
+
+// NewWAVReader parses the WAV header of r. The data chunk is read until EOF
+// rather than trusting its size, so streams written to a pipe (where the
+// size is unknown) work too.
+func NewWAVReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if (string(riff[0:4]) != "RIFF" && string(riff[0:4]) != "RF64") || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a WAV stream", ErrUnsupportedWAV)
+	}
+
+	var format, channels, bits uint16
+	var sampleRate uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(br, chunk[:]); err != nil {
+			return nil, fmt.Errorf("failed to find WAV data: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, fmt.Errorf("%w: invalid fmt chunk", ErrUnsupportedWAV)
+			}
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(br, body); err != nil {
+				return nil, fmt.Errorf("%w: truncated fmt chunk", ErrUnsupportedWAV)
+			}
+			format = binary.LittleEndian.Uint16(body[0:])
+			channels = binary.LittleEndian.Uint16(body[2:])
+			sampleRate = binary.LittleEndian.Uint32(body[4:])
+			bits = binary.LittleEndian.Uint16(body[14:])
+			if format == wavFormatExtensible && size >= 26 {
+				format = binary.LittleEndian.Uint16(body[24:])
+			}
+		case "data":
+			if channels == 0 || sampleRate < 10 {
+				return nil, fmt.Errorf("%w: data before fmt chunk", ErrUnsupportedWAV)
+			}
+			decode, err := sampleDecoder(format, bits)
+			if err != nil {
+				return nil, err
+			}
+			return &Reader{
+				SampleRate: int(sampleRate),
+				Channels:   int(channels),
+				r:          br,
+				decode:     decode,
+				sampleSize: int(bits / 8),
+			}, nil
+		default:
+			if _, err := br.Discard(int(size + size%2)); err != nil {
+				return nil, fmt.Errorf("failed to find WAV data: %w", err)
+			}
+		}
+	}
+}
+
+// Read fills samples with whole frames and returns the number of samples
+// read. It returns io.EOF once the data is exhausted; a trailing partial
+// frame is dropped.
+func (r *Reader) Read(samples []float64) (int, error) {
+	frames := len(samples) / r.Channels
+	if frames == 0 {
+		return 0, nil
+	}
+	size := frames * r.Channels * r.sampleSize
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	buf := r.buf[:size]
+
+	n, err := io.ReadFull(r.r, buf)
+	n -= n % (r.Channels * r.sampleSize)
+	for i := 0; i < n; i += r.sampleSize {
+		samples[i/r.sampleSize] = r.decode(buf[i : i+r.sampleSize])
+	}
+
+	count := n / r.sampleSize
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		if count == 0 {
+			return 0, io.EOF
+		}
+	default:
+		return count, fmt.Errorf("failed to read WAV data: %w", err)
+	}
+	return count, nil
+}
+
+// Decode runs ffmpeg to decode the first audio stream of input to float PCM
+// and passes the result to consume. Decoding stops early if consume returns
+// before reaching the end.
+func Decode(ctx context.Context, runner *ffmpeg.Runner, input string, consume func(*Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pipeReader, pipeWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := runner.Stream(ctx, pipeWriter, "-i", input, "-map", "0:a:0", "-vn", "-c:a", "pcm_f32le", "-f", "wav", "pipe:1")
+		pipeWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	err := func() error {
+		reader, err := NewWAVReader(pipeReader)
+		if err != nil {
+			return err
+		}
+		return consume(reader)
+	}()
+
+	// Stop ffmpeg if consume gave up early
+	pipeReader.CloseWithError(io.ErrClosedPipe)
+	cancel()
+	if decodeErr := <-done; decodeErr != nil && err == nil {
+		return decodeErr
+	}
+	return err
+}
+
+func sampleDecoder(format, bits uint16) (func([]byte) float64, error) {
+	switch {
+	case format == wavFormatPCM && bits == 16:
+		return func(b []byte) float64 {
+			return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		}, nil
+	case format == wavFormatPCM && bits == 24:
+		return func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}, nil
+	case format == wavFormatPCM && bits == 32:
+		return func(b []byte) float64 {
+			return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}, nil
+	case format == wavFormatFloat && bits == 32:
+		return func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}, nil
+	case format == wavFormatFloat && bits == 64:
+		return func(b []byte) float64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: format %d with %d bits", ErrUnsupportedWAV, format, bits)
+}
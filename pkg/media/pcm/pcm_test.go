@@ -0,0 +1,57 @@
+package pcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func wav(format, channels, bits uint16, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVE")
+	b.WriteString("LIST\x03\x00\x00\x00abc\x00") // odd-sized chunk with padding
+	b.WriteString("fmt \x10\x00\x00\x00")
+	blockAlign := channels * bits / 8
+	for _, v := range []interface{}{format, channels, uint32(8000), uint32(8000) * uint32(blockAlign), blockAlign, bits} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data\xff\xff\xff\xff")
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestWAVReader(t *testing.T) {
+	// Stereo 24-bit: full-scale negative, half positive, then a partial frame
+	data := []byte{0x00, 0x00, 0x80, 0x00, 0x00, 0x40, 0x01, 0x02}
+	r, err := NewWAVReader(bytes.NewReader(wav(1, 2, 24, data)))
+	if err != nil {
+		t.Fatalf("NewWAVReader() error = %v", err)
+	}
+	if r.SampleRate != 8000 || r.Channels != 2 {
+		t.Errorf("format = %d Hz %d ch", r.SampleRate, r.Channels)
+	}
+
+	samples := make([]float64, 8)
+	n, err := r.Read(samples)
+	if err != nil || n != 2 || samples[0] != -1 || samples[1] != 0.5 {
+		t.Errorf("Read() = %d %v, %v", n, samples[:n], err)
+	}
+	if n, err := r.Read(samples); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("Read() at end = %d, %v, want io.EOF", n, err)
+	}
+}
+
+func TestWAVReaderUnsupported(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"not wav":  []byte("not a wav file at all"),
+		"8-bit":    wav(1, 1, 8, nil),
+		"a-law":    wav(6, 1, 8, nil),
+		"no chans": wav(1, 0, 16, nil),
+	} {
+		if _, err := NewWAVReader(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedWAV) {
+			t.Errorf("%s: NewWAVReader() error = %v, want ErrUnsupportedWAV", name, err)
+		}
+	}
+}
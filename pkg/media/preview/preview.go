@@ -0,0 +1,151 @@
+// Package preview cuts short preview clips out of full tracks.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+	"go-audio-stream/pkg/media/loudness"
+	"go-audio-stream/pkg/media/pcm"
+)
+
+// Preview clips are MP3 so every browser can play them
+const (
+	ContentType = "audio/mpeg"
+	Extension   = ".mp3"
+)
+
+// Options configure the clips
+type Options struct {
+	Duration time.Duration
+	FadeIn   time.Duration
+	FadeOut  time.Duration
+	Bitrate  int // bits per second
+}
+
+// DefaultOptions are 30 second clips at 128 kbps with short fades
+var DefaultOptions = Options{
+	Duration: 30 * time.Second,
+	FadeIn:   time.Second,
+	FadeOut:  2 * time.Second,
+	Bitrate:  128000,
+}
+
+// Clipper encodes the part of input starting at start as a preview clip of
+// Duration
+type Clipper interface {
+	Duration() time.Duration
+	Clip(ctx context.Context, input, output string, start time.Duration) error
+}
+
+// StartFinder picks where a preview of length should start
+type StartFinder interface {
+	FindStart(ctx context.Context, input string, length time.Duration) (time.Duration, error)
+}
+
+// FFmpegClipper cuts and encodes clips with ffmpeg
+type FFmpegClipper struct {
+	runner  *ffmpeg.Runner
+	options Options
+}
+
+// NewFFmpegClipper creates a clipper; zero fields of options take their
+// DefaultOptions values
+func NewFFmpegClipper(runner *ffmpeg.Runner, options Options) *FFmpegClipper {
+	if options.Duration <= 0 {
+		options.Duration = DefaultOptions.Duration
+	}
+	if options.FadeIn <= 0 {
+		options.FadeIn = DefaultOptions.FadeIn
+	}
+	if options.FadeOut <= 0 {
+		options.FadeOut = DefaultOptions.FadeOut
+	}
+	if options.Bitrate <= 0 {
+		options.Bitrate = DefaultOptions.Bitrate
+	}
+	return &FFmpegClipper{runner: runner, options: options}
+}
+
+// Duration is the length of the clips
+func (c *FFmpegClipper) Duration() time.Duration {
+	return c.options.Duration
+}
+
+func (c *FFmpegClipper) Clip(ctx context.Context, input, output string, start time.Duration) error {
+	if err := c.runner.Run(ctx, c.args(input, output, start)...); err != nil {
+		return fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return nil
+}
+
+func (c *FFmpegClipper) args(input, output string, start time.Duration) []string {
+	o := c.options
+	fadeOutStart := o.Duration - o.FadeOut
+	filter := fmt.Sprintf("afade=t=in:st=0:d=%s,afade=t=out:st=%s:d=%s", seconds(o.FadeIn), seconds(fadeOutStart), seconds(o.FadeOut))
+
+	return []string{
+		// Seeking before -i is fast and, for audio, sample accurate
+		"-ss", seconds(start),
+		"-t", seconds(o.Duration),
+		"-i", input,
+		"-map", "0:a:0",
+		"-vn",
+		"-map_metadata", "-1",
+		"-af", filter,
+		"-c:a", "libmp3lame",
+		"-b:a", strconv.Itoa(o.Bitrate),
+		"-f", "mp3",
+		output,
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// LoudestFinder starts previews at the loudest stretch of the track, which
+// is usually the chorus
+type LoudestFinder struct {
+	runner *ffmpeg.Runner
+}
+
+// NewLoudestFinder creates a finder decoding with runner
+func NewLoudestFinder(runner *ffmpeg.Runner) *LoudestFinder {
+	return &LoudestFinder{runner: runner}
+}
+
+func (f *LoudestFinder) FindStart(ctx context.Context, input string, length time.Duration) (time.Duration, error) {
+	var start time.Duration
+	err := pcm.Decode(ctx, f.runner, input, func(r *pcm.Reader) error {
+		var err error
+		start, err = LoudestStart(r, length)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find preview start: %w", err)
+	}
+	return start, nil
+}
+
+// LoudestStart reads r to the end and returns the start of its loudest
+// window of length
+func LoudestStart(r *pcm.Reader, length time.Duration) (time.Duration, error) {
+	meter := loudness.NewMeter(r.SampleRate, r.Channels)
+	samples := make([]float64, 4096*r.Channels)
+	for {
+		n, err := r.Read(samples)
+		meter.Write(samples[:n])
+		if errors.Is(err, io.EOF) {
+			return meter.LoudestWindow(length), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
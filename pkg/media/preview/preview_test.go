@@ -0,0 +1,66 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/media/pcm"
+)
+
+func TestClipArgs(t *testing.T) {
+	clipper := NewFFmpegClipper(nil, Options{Duration: 20 * time.Second})
+	args := clipper.args("in.flac", "out.mp3", 75500*time.Millisecond)
+
+	if i := slices.Index(args, "-ss"); i < 0 || args[i+1] != "75.500" || slices.Index(args, "-i") < i {
+		t.Errorf("args = %v, want -ss 75.500 before -i", args)
+	}
+	if i := slices.Index(args, "-t"); i < 0 || args[i+1] != "20.000" {
+		t.Errorf("args = %v, want -t 20.000", args)
+	}
+	want := "afade=t=in:st=0:d=1.000,afade=t=out:st=18.000:d=2.000"
+	if i := slices.Index(args, "-af"); i < 0 || args[i+1] != want {
+		t.Errorf("args = %v, want -af %s", args, want)
+	}
+	if args[len(args)-1] != "out.mp3" || !strings.Contains(strings.Join(args, " "), "-b:a 128000") {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestLoudestStart(t *testing.T) {
+	// 40 s quiet, 30 s loud, 20 s quiet at 8 kHz mono
+	const rate = 8000
+	var data bytes.Buffer
+	for i := 0; i < 90*rate; i++ {
+		amplitude := 0.01
+		if i >= 40*rate && i < 70*rate {
+			amplitude = 0.5
+		}
+		v := amplitude * math.Sin(2*math.Pi*1000*float64(i)/rate)
+		binary.Write(&data, binary.LittleEndian, int16(v*math.MaxInt16))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVEfmt \x10\x00\x00\x00")
+	for _, v := range []interface{}{uint16(1), uint16(1), uint32(rate), uint32(rate * 2), uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data\xff\xff\xff\xff")
+	b.Write(data.Bytes())
+
+	r, err := pcm.NewWAVReader(&b)
+	if err != nil {
+		t.Fatalf("NewWAVReader() error = %v", err)
+	}
+	start, err := LoudestStart(r, 30*time.Second)
+	if err != nil {
+		t.Fatalf("LoudestStart() error = %v", err)
+	}
+	if start < 39500*time.Millisecond || start > 40500*time.Millisecond {
+		t.Errorf("LoudestStart() = %s, want about 40s", start)
+	}
+}
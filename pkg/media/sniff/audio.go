@@ -0,0 +1,648 @@
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// minFrames is how many consecutive frames MP3 and ADTS streams must
+	// decode to count as playable
+	minFrames = 4
+
+	// syncSearch bounds the scan for the first frame after any tags
+	syncSearch = 64 * 1024
+
+	// webmProbe is how much of a WebM file is searched for its track list
+	webmProbe = 1 << 20
+
+	// maxMoovSize bounds the MP4 movie box read into memory
+	maxMoovSize = 64 << 20
+)
+
+// ValidateAudio detects the format of r and decodes its headers and first
+// frames to confirm that the stream is playable. Failures are returned as a
+// *Rejection.
+func ValidateAudio(r io.ReadSeeker) (Type, error) {
+	t, size, err := detect(r)
+	if err != nil {
+		return t, err
+	}
+	if t.Kind != KindAudio {
+		return t, reject(ReasonWrongKind, t, "Expected an audio file, got %s", t.MIMEType)
+	}
+
+	var check func(io.ReadSeeker, int64) error
+	switch t {
+	case MP3:
+		check = checkMP3
+	case AAC:
+		check = checkADTS
+	case WAV:
+		check = checkWAV
+	case FLAC:
+		check = checkFLAC
+	case Ogg:
+		check = checkOgg
+	case WebM:
+		check = checkWebM
+	case MP4:
+		check = checkMP4
+	}
+	if err := check(r, size); err != nil {
+		return t, reject(ReasonCorrupt, t, "Invalid %s stream: %v", t.Name, err)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// detect reads the file header and identifies its format
+func detect(r io.ReadSeeker) (Type, int64, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Type{}, 0, err
+	}
+	if size == 0 {
+		return Type{}, 0, reject(ReasonEmpty, Type{}, "File is empty")
+	}
+
+	header, err := readAt(r, 0, HeaderSize, size)
+	if err != nil {
+		return Type{}, 0, err
+	}
+	t, ok := Detect(header)
+	if !ok {
+		return Type{}, size, reject(ReasonUnrecognized, Type{}, "Unrecognized file format")
+	}
+	return t, size, nil
+}
+
+var (
+	errNoFrames  = errors.New("no decodable frames")
+	errTruncated = errors.New("file is truncated")
+)
+
+// readAt reads up to n bytes at offset, stopping at the end of the file
+func readAt(r io.ReadSeeker, offset int64, n int, size int64) ([]byte, error) {
+	if offset >= size {
+		return nil, errTruncated
+	}
+	if remaining := size - offset; int64(n) > remaining {
+		n = int(remaining)
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// MPEG audio bitrates in kbps indexed by [table][bitrate index]
+var mpegBitrates = [5][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mpegSampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG 2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG 2
+	{44100, 48000, 32000}, // MPEG 1
+}
+
+func isMPEGSync(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xE0 == 0xE0 && b[1]&0x06 != 0
+}
+
+// mpegFrameSize returns the length of the MPEG audio frame starting at b
+// and a key identifying its stream parameters
+func mpegFrameSize(b []byte) (int, uint32, bool) {
+	if len(b) < 4 || !isMPEGSync(b) {
+		return 0, 0, false
+	}
+	version := int(b[1]>>3) & 0x03
+	layer := 4 - int(b[1]>>1)&0x03
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 0x03
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return 0, 0, false
+	}
+
+	table := 4
+	switch {
+	case version == 3:
+		table = layer - 1
+	case layer == 1:
+		table = 3
+	}
+	bitrate := mpegBitrates[table][bitrateIndex] * 1000
+	sampleRate := mpegSampleRates[version][rateIndex]
+	padding := int(b[2]>>1) & 0x01
+
+	var size int
+	switch {
+	case layer == 1:
+		size = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && version != 3:
+		size = 72*bitrate/sampleRate + padding
+	default:
+		size = 144*bitrate/sampleRate + padding
+	}
+	key := uint32(b[1])<<8 | uint32(b[2]&0x0C)
+	return size, key, size > 4
+}
+
+func isADTSSync(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xF6 == 0xF0
+}
+
+// adtsFrameSize returns the length of the ADTS frame starting at b
+func adtsFrameSize(b []byte) (int, uint32, bool) {
+	if len(b) < 7 || !isADTSSync(b) {
+		return 0, 0, false
+	}
+	if (b[2]>>2)&0x0F > 12 {
+		return 0, 0, false // reserved sampling frequency index
+	}
+	size := int(b[3]&0x03)<<11 | int(b[4])<<3 | int(b[5]>>5)
+	key := uint32(b[1])<<8 | uint32(b[2]&0xFC)
+	return size, key, size > 7
+}
+
+// checkFrames requires minFrames consecutive frames of the same stream,
+// starting at the first sync word after offset
+func checkFrames(r io.ReadSeeker, offset, size int64, frameSize func([]byte) (int, uint32, bool)) error {
+	buf, err := readAt(r, offset, syncSearch+minFrames*8192, size)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(buf) && start < syncSearch; start++ {
+		pos, frames := start, 0
+		var stream uint32
+		for frames < minFrames {
+			n, key, ok := frameSize(buf[pos:])
+			if !ok || (frames > 0 && key != stream) {
+				break
+			}
+			stream = key
+			frames++
+			pos += n
+			if pos >= len(buf) {
+				// Short files may end after fewer frames
+				if int64(pos) >= size-offset {
+					return nil
+				}
+				break
+			}
+		}
+		if frames == minFrames {
+			return nil
+		}
+	}
+	return errNoFrames
+}
+
+func checkMP3(r io.ReadSeeker, size int64) error {
+	offset := int64(0)
+	header, err := readAt(r, 0, 10, size)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		if len(header) < 10 {
+			return errTruncated
+		}
+		tagSize := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+		offset = 10 + tagSize
+		if header[5]&0x10 != 0 {
+			offset += 10
+		}
+	}
+	return checkFrames(r, offset, size, mpegFrameSize)
+}
+
+func checkADTS(r io.ReadSeeker, size int64) error {
+	return checkFrames(r, 0, size, adtsFrameSize)
+}
+
+// WAVE format tags accepted in the fmt chunk
+const (
+	wavePCM        = 0x0001
+	waveFloat      = 0x0003
+	waveALaw       = 0x0006
+	waveMuLaw      = 0x0007
+	waveExtensible = 0xFFFE
+)
+
+func checkWAV(r io.ReadSeeker, size int64) error {
+	var haveFormat bool
+	for offset := int64(12); offset+8 <= size; {
+		header, err := readAt(r, offset, 8, size)
+		if err != nil {
+			return err
+		}
+		id := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			if length < 16 {
+				return errors.New("fmt chunk too short")
+			}
+			chunk, err := readAt(r, offset, 16, size)
+			if err != nil || len(chunk) < 16 {
+				return errTruncated
+			}
+			format := binary.LittleEndian.Uint16(chunk[0:])
+			channels := binary.LittleEndian.Uint16(chunk[2:])
+			sampleRate := binary.LittleEndian.Uint32(chunk[4:])
+			blockAlign := binary.LittleEndian.Uint16(chunk[12:])
+			bitsPerSample := binary.LittleEndian.Uint16(chunk[14:])
+
+			switch format {
+			case wavePCM, waveFloat, waveALaw, waveMuLaw, waveExtensible:
+			default:
+				return errors.New("unsupported WAVE encoding")
+			}
+			if channels == 0 || sampleRate == 0 || blockAlign == 0 || bitsPerSample == 0 {
+				return errors.New("invalid fmt chunk")
+			}
+			if format == wavePCM && int(blockAlign) != int(channels)*int((bitsPerSample+7)/8) {
+				return errors.New("inconsistent block alignment")
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return errors.New("data chunk before fmt chunk")
+			}
+			// Streaming writers leave the length at 0 or 0xFFFFFFFF
+			if offset >= size {
+				return errNoFrames
+			}
+			return nil
+		}
+
+		offset += length + length&1 // chunks are word aligned
+	}
+	return errors.New("missing data chunk")
+}
+
+func checkFLAC(r io.ReadSeeker, size int64) error {
+	offset := int64(4)
+	for first := true; ; first = false {
+		header, err := readAt(r, offset, 4, size)
+		if err != nil || len(header) < 4 {
+			return errTruncated
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4
+
+		if first {
+			if blockType != 0 || length != 34 {
+				return errors.New("missing STREAMINFO")
+			}
+			info, err := readAt(r, offset, 34, size)
+			if err != nil || len(info) < 34 {
+				return errTruncated
+			}
+			minBlock := binary.BigEndian.Uint16(info[0:])
+			sampleRate := int(info[10])<<12 | int(info[11])<<4 | int(info[12])>>4
+			if minBlock < 16 || sampleRate == 0 {
+				return errors.New("invalid STREAMINFO")
+			}
+		} else if blockType == 127 {
+			return errors.New("invalid metadata block")
+		}
+
+		offset += length
+		if last {
+			break
+		}
+	}
+
+	// Every FLAC frame starts with the 14-bit sync code 0x3FFE
+	sync, err := readAt(r, offset, 2, size)
+	if err != nil || len(sync) < 2 {
+		return errNoFrames
+	}
+	if sync[0] != 0xFF || sync[1]&0xFE != 0xF8 {
+		return errNoFrames
+	}
+	return nil
+}
+
+func checkOgg(r io.ReadSeeker, size int64) error {
+	buf, err := readAt(r, 0, 256*1024, size)
+	if err != nil {
+		return err
+	}
+
+	page, rest, err := parseOggPage(buf)
+	if err != nil {
+		return err
+	}
+	if page.flags&0x02 == 0 {
+		return errors.New("first page is not a stream start")
+	}
+	switch {
+	case bytes.HasPrefix(page.body, []byte("\x01vorbis")),
+		bytes.HasPrefix(page.body, []byte("OpusHead")),
+		bytes.HasPrefix(page.body, []byte("\x7fFLAC")):
+	default:
+		return errors.New("unsupported Ogg codec")
+	}
+
+	// The comment header and audio packets follow on further valid pages
+	pages := 1
+	for pages < 3 && len(rest) > 0 {
+		if _, rest, err = parseOggPage(rest); err != nil {
+			if errors.Is(err, errTruncated) && int64(len(buf)) < size {
+				return nil // page continues past the probe
+			}
+			return err
+		}
+		pages++
+	}
+	if pages < 2 {
+		return errNoFrames
+	}
+	return nil
+}
+
+type oggPage struct {
+	flags byte
+	body  []byte
+}
+
+func parseOggPage(b []byte) (oggPage, []byte, error) {
+	if len(b) < 27 {
+		return oggPage{}, nil, errTruncated
+	}
+	if string(b[:4]) != "OggS" || b[4] != 0 {
+		return oggPage{}, nil, errors.New("lost Ogg page sync")
+	}
+	segments := int(b[26])
+	if len(b) < 27+segments {
+		return oggPage{}, nil, errTruncated
+	}
+	bodySize := 0
+	for _, l := range b[27 : 27+segments] {
+		bodySize += int(l)
+	}
+	end := 27 + segments + bodySize
+	if len(b) < end {
+		return oggPage{}, nil, errTruncated
+	}
+
+	page := make([]byte, end)
+	copy(page, b[:end])
+	want := binary.LittleEndian.Uint32(page[22:])
+	page[22], page[23], page[24], page[25] = 0, 0, 0, 0
+	if oggCRC(page) != want {
+		return oggPage{}, nil, errors.New("Ogg page checksum mismatch")
+	}
+
+	return oggPage{flags: b[5], body: b[27+segments : end]}, b[end:], nil
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, v := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+	return crc
+}
+
+// EBML element IDs used to find the WebM track list
+const (
+	ebmlHeader     = 0x1A45DFA3
+	ebmlDocType    = 0x4282
+	ebmlSegment    = 0x18538067
+	ebmlTracks     = 0x1654AE6B
+	ebmlTrackEntry = 0xAE
+	ebmlTrackType  = 0x83
+	ebmlCodecID    = 0x86
+	ebmlCluster    = 0x1F43B675
+	trackTypeAudio = 2
+)
+
+func checkWebM(r io.ReadSeeker, size int64) error {
+	buf, err := readAt(r, 0, webmProbe, size)
+	if err != nil {
+		return err
+	}
+
+	id, header, rest, err := readElement(buf)
+	if err != nil || id != ebmlHeader {
+		return errors.New("invalid EBML header")
+	}
+	docType := findElement(header, ebmlDocType)
+	if string(docType) != "webm" && string(docType) != "matroska" {
+		return errors.New("unsupported EBML document type")
+	}
+
+	id, segment, _, err := readElement(rest)
+	if err != nil || id != ebmlSegment {
+		return errors.New("missing segment")
+	}
+
+	for len(segment) > 0 {
+		id, body, next, err := readElement(segment)
+		if err != nil {
+			break
+		}
+		switch id {
+		case ebmlTracks:
+			return checkWebMTracks(body)
+		case ebmlCluster:
+			return errors.New("clusters before track list")
+		}
+		segment = next
+	}
+	return errors.New("missing track list")
+}
+
+func checkWebMTracks(tracks []byte) error {
+	for len(tracks) > 0 {
+		id, entry, next, err := readElement(tracks)
+		if err != nil {
+			break
+		}
+		if id == ebmlTrackEntry {
+			trackType := findElement(entry, ebmlTrackType)
+			codec := findElement(entry, ebmlCodecID)
+			if len(trackType) == 1 && trackType[0] == trackTypeAudio && bytes.HasPrefix(codec, []byte("A_")) {
+				return nil
+			}
+		}
+		tracks = next
+	}
+	return errors.New("no audio track")
+}
+
+// readElement reads one EBML element, returning its ID, body and the
+// remaining bytes. Bodies of unknown or oversized length are cut to the
+// available data.
+func readElement(b []byte) (uint64, []byte, []byte, error) {
+	id, n, ok := readVint(b, true)
+	if !ok {
+		return 0, nil, nil, errTruncated
+	}
+	b = b[n:]
+	length, n, ok := readVint(b, false)
+	if !ok {
+		return 0, nil, nil, errTruncated
+	}
+	b = b[n:]
+	if length > uint64(len(b)) {
+		return id, b, nil, nil
+	}
+	return id, b[:length], b[length:], nil
+}
+
+// readVint decodes an EBML variable-length integer. IDs keep their length
+// marker bits; sizes do not.
+func readVint(b []byte, keepMarker bool) (uint64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(b) < n {
+		return 0, 0, false
+	}
+
+	value := uint64(b[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> n)
+	}
+	for _, v := range b[1:n] {
+		value = value<<8 | uint64(v)
+	}
+	if !keepMarker && value == 1<<(7*n)-1 {
+		value = ^uint64(0) // unknown size
+	}
+	return value, n, true
+}
+
+// findElement returns the body of the first direct child with the given ID
+func findElement(b []byte, want uint64) []byte {
+	for len(b) > 0 {
+		id, body, next, err := readElement(b)
+		if err != nil {
+			return nil
+		}
+		if id == want {
+			return body
+		}
+		b = next
+	}
+	return nil
+}
+
+func checkMP4(r io.ReadSeeker, size int64) error {
+	var moov []byte
+	var haveMdat bool
+
+	for offset := int64(0); offset+8 <= size; {
+		header, err := readAt(r, offset, 16, size)
+		if err != nil || len(header) < 8 {
+			return errTruncated
+		}
+		atomSize := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch atomSize {
+		case 0:
+			atomSize = size - offset
+		case 1:
+			if len(header) < 16 {
+				return errTruncated
+			}
+			atomSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if atomSize < headerSize || offset+atomSize > size {
+			return errors.New("invalid atom size")
+		}
+
+		switch string(header[4:8]) {
+		case "moov":
+			if atomSize > maxMoovSize {
+				return errors.New("movie box too large")
+			}
+			moov, err = readAt(r, offset+headerSize, int(atomSize-headerSize), size)
+			if err != nil {
+				return err
+			}
+		case "mdat":
+			haveMdat = atomSize > headerSize
+		}
+		offset += atomSize
+	}
+
+	if moov == nil {
+		return errors.New("missing movie box")
+	}
+	if !haveMdat {
+		return errNoFrames
+	}
+	for _, trak := range childAtoms(moov, "trak") {
+		for _, mdia := range childAtoms(trak, "mdia") {
+			for _, hdlr := range childAtoms(mdia, "hdlr") {
+				if len(hdlr) >= 12 && string(hdlr[8:12]) == "soun" {
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("no audio track")
+}
+
+// childAtoms returns the bodies of the direct children named name
+func childAtoms(b []byte, name string) [][]byte {
+	var atoms [][]byte
+	for len(b) >= 8 {
+		atomSize := int(binary.BigEndian.Uint32(b))
+		if atomSize < 8 || atomSize > len(b) {
+			break
+		}
+		if string(b[4:8]) == name {
+			atoms = append(atoms, b[8:atomSize])
+		}
+		b = b[atomSize:]
+	}
+	return atoms
+}
@@ -0,0 +1,86 @@
+package sniff
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+// MaxImagePixels bounds the decoded size of accepted images so a small file
+// cannot expand into a huge bitmap when thumbnails are generated
+const MaxImagePixels = 50_000_000
+
+// ValidateImage detects the format of r and decodes the image header to
+// confirm it is readable. Failures are returned as a *Rejection.
+func ValidateImage(r io.ReadSeeker) (Type, error) {
+	t, size, err := detect(r)
+	if err != nil {
+		return t, err
+	}
+	if t.Kind != KindImage {
+		return t, reject(ReasonWrongKind, t, "Expected an image file, got %s", t.MIMEType)
+	}
+
+	var width, height int
+	if t == WebP {
+		width, height, err = webpSize(r, size)
+	} else {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return t, err
+		}
+		var config image.Config
+		config, _, err = image.DecodeConfig(r)
+		width, height = config.Width, config.Height
+	}
+	if err == nil && (width <= 0 || height <= 0) {
+		err = errors.New("image has no pixels")
+	}
+	if err != nil {
+		return t, reject(ReasonCorrupt, t, "Invalid %s image: %v", t.Name, err)
+	}
+	if int64(width)*int64(height) > MaxImagePixels {
+		return t, reject(ReasonTooLarge, t, "Image is %dx%d, larger than %d pixels", width, height, MaxImagePixels)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// webpSize reads the canvas size from the first chunk of a WebP file, which
+// the standard library cannot decode
+func webpSize(r io.ReadSeeker, size int64) (int, int, error) {
+	// Chunk header plus the 10 bytes holding the dimensions
+	buf, err := readAt(r, 12, 18, size)
+	if err != nil || len(buf) < 18 {
+		return 0, 0, errTruncated
+	}
+
+	chunk := buf[8:]
+	switch string(buf[:4]) {
+	case "VP8 ":
+		// Key frame start code, then 14-bit width and height
+		if chunk[3] != 0x9D || chunk[4] != 0x01 || chunk[5] != 0x2A {
+			return 0, 0, errors.New("missing VP8 start code")
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3FFF)
+		height := int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3FFF)
+		return width, height, nil
+	case "VP8L":
+		if chunk[0] != 0x2F {
+			return 0, 0, errors.New("missing VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	case "VP8X":
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	}
+	return 0, 0, errors.New("unknown WebP chunk")
+}
@@ -0,0 +1,113 @@
+// Package sniff identifies audio and image files from their leading bytes
+// and checks that they decode, so uploads do not have to trust the
+// client-supplied Content-Type.
+package sniff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Kind is the broad media class of a detected type
+type Kind string
+
+const (
+	KindAudio Kind = "audio"
+	KindImage Kind = "image"
+)
+
+// Type is a detected file format
+type Type struct {
+	Name      string `json:"name"`
+	Kind      Kind   `json:"kind"`
+	MIMEType  string `json:"mime_type"`
+	Extension string `json:"extension"`
+}
+
+// Detectable formats
+var (
+	MP3  = Type{Name: "mp3", Kind: KindAudio, MIMEType: "audio/mpeg", Extension: ".mp3"}
+	WAV  = Type{Name: "wav", Kind: KindAudio, MIMEType: "audio/wav", Extension: ".wav"}
+	FLAC = Type{Name: "flac", Kind: KindAudio, MIMEType: "audio/flac", Extension: ".flac"}
+	AAC  = Type{Name: "aac", Kind: KindAudio, MIMEType: "audio/aac", Extension: ".aac"}
+	Ogg  = Type{Name: "ogg", Kind: KindAudio, MIMEType: "audio/ogg", Extension: ".ogg"}
+	WebM = Type{Name: "webm", Kind: KindAudio, MIMEType: "audio/webm", Extension: ".webm"}
+	MP4  = Type{Name: "mp4", Kind: KindAudio, MIMEType: "audio/mp4", Extension: ".m4a"}
+
+	JPEG = Type{Name: "jpeg", Kind: KindImage, MIMEType: "image/jpeg", Extension: ".jpg"}
+	PNG  = Type{Name: "png", Kind: KindImage, MIMEType: "image/png", Extension: ".png"}
+	GIF  = Type{Name: "gif", Kind: KindImage, MIMEType: "image/gif", Extension: ".gif"}
+	WebP = Type{Name: "webp", Kind: KindImage, MIMEType: "image/webp", Extension: ".webp"}
+)
+
+// HeaderSize is the number of leading bytes Detect looks at
+const HeaderSize = 64
+
+// Detect identifies a file format from its first HeaderSize bytes
+func Detect(header []byte) (Type, bool) {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return MP3, true
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FLAC, true
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return Ogg, true
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return WAV, true
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return WebP, true
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return WebM, true
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return MP4, true
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, true
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, true
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF, true
+	case isADTSSync(header):
+		return AAC, true
+	case isMPEGSync(header):
+		return MP3, true
+	}
+	return Type{}, false
+}
+
+// Reason is a machine-readable rejection code
+type Reason string
+
+const (
+	ReasonEmpty        Reason = "empty_file"
+	ReasonTooLarge     Reason = "file_too_large"
+	ReasonUnrecognized Reason = "unrecognized_format"
+	ReasonWrongKind    Reason = "wrong_media_kind"
+	ReasonCorrupt      Reason = "corrupt_stream"
+)
+
+// Rejection explains why a file failed validation
+type Rejection struct {
+	Reason   Reason `json:"reason"`
+	Message  string `json:"message"`
+	Detected string `json:"detected_type,omitempty"`
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+func reject(reason Reason, detected Type, format string, args ...interface{}) *Rejection {
+	return &Rejection{
+		Reason:   reason,
+		Message:  fmt.Sprintf(format, args...),
+		Detected: detected.MIMEType,
+	}
+}
+
+// TooLarge returns the rejection for files over the size limit
+func TooLarge(limit int64) *Rejection {
+	return &Rejection{
+		Reason:  ReasonTooLarge,
+		Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", limit),
+	}
+}
@@ -0,0 +1,176 @@
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func mp3Frames(n int) []byte {
+	// MPEG 1 Layer III, 128 kbps, 44.1 kHz: 417-byte frames
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	return bytes.Repeat(frame, n)
+}
+
+func adtsFrames(n int) []byte {
+	frame := make([]byte, 200)
+	copy(frame, []byte{0xFF, 0xF1, 0x50, 0x80, byte(200 >> 3), byte(200&0x07)<<5 | 0x1F, 0xFC})
+	return bytes.Repeat(frame, n)
+}
+
+func wavFile(data int) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+data))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []interface{}{uint32(16), uint16(1), uint16(2), uint32(44100), uint32(44100 * 4), uint16(4), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(data))
+	b.Write(make([]byte, data))
+	return b.Bytes()
+}
+
+func flacFile(frameSync bool) []byte {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint16(info[0:], 4096)
+	binary.BigEndian.PutUint16(info[2:], 4096)
+	info[10], info[11], info[12] = 0x0A, 0xC4, 0x42 // 44.1 kHz, stereo, 16-bit
+	b := append([]byte("fLaC\x80\x00\x00\x22"), info...)
+	if frameSync {
+		b = append(b, 0xFF, 0xF8, 0x69, 0x08)
+	}
+	return append(b, make([]byte, 64)...)
+}
+
+func oggPageBytes(flags byte, packet []byte) []byte {
+	page := []byte("OggS\x00")
+	page = append(page, flags)
+	page = append(page, make([]byte, 20)...)
+	page = append(page, 1, byte(len(packet)))
+	page = append(page, packet...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+	return page
+}
+
+func oggFile() []byte {
+	ident := append([]byte("OpusHead\x01\x02"), make([]byte, 9)...)
+	b := oggPageBytes(0x02, ident)
+	b = append(b, oggPageBytes(0, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	return append(b, oggPageBytes(0x04, make([]byte, 50))...)
+}
+
+func ebml(id []byte, body ...[]byte) []byte {
+	content := bytes.Join(body, nil)
+	return append(append(append([]byte{}, id...), 0x80|byte(len(content))), content...)
+}
+
+func webmFile(trackType byte) []byte {
+	header := ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebml([]byte{0x42, 0x82}, []byte("webm")))
+	entry := ebml([]byte{0xAE}, ebml([]byte{0x83}, []byte{trackType}), ebml([]byte{0x86}, []byte("A_OPUS")))
+	segment := ebml([]byte{0x18, 0x53, 0x80, 0x67}, ebml([]byte{0x16, 0x54, 0xAE, 0x6B}, entry))
+	return append(header, segment...)
+}
+
+func atom(name string, body ...[]byte) []byte {
+	content := bytes.Join(body, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	return append(append(b, name...), content...)
+}
+
+func mp4File(handler string) []byte {
+	hdlr := atom("hdlr", make([]byte, 8), []byte(handler), make([]byte, 12))
+	moov := atom("moov", atom("trak", atom("mdia", hdlr)))
+	return bytes.Join([][]byte{atom("ftyp", []byte("M4A \x00\x00\x00\x00")), moov, atom("mdat", make([]byte, 100))}, nil)
+}
+
+func pngFile(w, h int) []byte {
+	var b bytes.Buffer
+	png.Encode(&b, image.NewGray(image.Rect(0, 0, w, h)))
+	return b.Bytes()
+}
+
+func TestValidateAudio(t *testing.T) {
+	id3 := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		want   Type
+		reason Reason
+	}{
+		{"mp3", mp3Frames(10), MP3, ""},
+		{"mp3 with id3", append(id3, mp3Frames(10)...), MP3, ""},
+		{"mp3 garbage after id3", append(id3, bytes.Repeat([]byte("not audio"), 100)...), MP3, ReasonCorrupt},
+		{"aac", adtsFrames(10), AAC, ""},
+		{"wav", wavFile(1024), WAV, ""},
+		{"wav without data", wavFile(1024)[:36], WAV, ReasonCorrupt},
+		{"flac", flacFile(true), FLAC, ""},
+		{"flac without frames", flacFile(false), FLAC, ReasonCorrupt},
+		{"ogg", oggFile(), Ogg, ""},
+		{"ogg bad checksum", append(oggFile()[:30], 'x'), Ogg, ReasonCorrupt},
+		{"webm", webmFile(2), WebM, ""},
+		{"webm video only", webmFile(1), WebM, ReasonCorrupt},
+		{"mp4", mp4File("soun"), MP4, ""},
+		{"mp4 video only", mp4File("vide"), MP4, ReasonCorrupt},
+		{"png", pngFile(4, 4), PNG, ReasonWrongKind},
+		{"text", []byte("hello world, this is not audio"), Type{}, ReasonUnrecognized},
+		{"empty", nil, Type{}, ReasonEmpty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateAudio(bytes.NewReader(tt.data))
+			if got != tt.want {
+				t.Errorf("ValidateAudio() type = %v, want %v", got, tt.want)
+			}
+			checkReason(t, err, tt.reason)
+		})
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		want   Type
+		reason Reason
+	}{
+		{"png", pngFile(16, 8), PNG, ""},
+		{"truncated png", pngFile(16, 8)[:20], PNG, ReasonCorrupt},
+		{"mp3", mp3Frames(5), MP3, ReasonWrongKind},
+		{"webp", append([]byte("RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00\x2F\x07\xC0\x00\x00"), make([]byte, 16)...), WebP, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateImage(bytes.NewReader(tt.data))
+			if got != tt.want {
+				t.Errorf("ValidateImage() type = %v, want %v", got, tt.want)
+			}
+			checkReason(t, err, tt.reason)
+		})
+	}
+}
+
+func checkReason(t *testing.T, err error, want Reason) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+		return
+	}
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("error = %v, want rejection %s", err, want)
+	}
+	if rejection.Reason != want {
+		t.Errorf("rejection reason = %s (%s), want %s", rejection.Reason, rejection.Message, want)
+	}
+}
@@ -0,0 +1,103 @@
+package transcode
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is one entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	codecs    string
+	q         float64
+}
+
+// Negotiate picks the rendition from available that best matches an Accept
+// header. Renditions are scored by the quality value of the most specific
+// matching media range; ties go to the earlier rendition in available. An
+// empty header accepts everything. ok is false when nothing is acceptable.
+func Negotiate(accept string, available []Rendition) (Rendition, bool) {
+	ranges := parseAccept(accept)
+
+	best, bestQ := -1, 0.0
+	for i, r := range available {
+		if q := quality(ranges, r); q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return Rendition{}, false
+	}
+	return available[best], true
+}
+
+func parseAccept(accept string) []mediaRange {
+	if strings.TrimSpace(accept) == "" {
+		return []mediaRange{{mediaType: "*/*", q: 1}}
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, codecs: strings.ToLower(params["codecs"]), q: q})
+	}
+
+	// Most specific ranges first, so they override wildcards
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return specificity(ranges[i]) > specificity(ranges[j])
+	})
+	return ranges
+}
+
+func specificity(m mediaRange) int {
+	switch {
+	case m.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(m.mediaType, "/*"):
+		return 1
+	case m.codecs == "":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// quality returns the q value of the most specific range matching r
+func quality(ranges []mediaRange, r Rendition) float64 {
+	for _, m := range ranges {
+		if matches(m, r) {
+			return m.q
+		}
+	}
+	return 0
+}
+
+func matches(m mediaRange, r Rendition) bool {
+	switch {
+	case m.mediaType == "*/*":
+		return true
+	case strings.HasSuffix(m.mediaType, "/*"):
+		return strings.HasPrefix(r.ContentType, strings.TrimSuffix(m.mediaType, "*"))
+	case m.mediaType != r.ContentType:
+		return false
+	case m.codecs == "":
+		return true
+	}
+	for _, codec := range strings.Split(m.codecs, ",") {
+		if strings.TrimSpace(codec) == strings.ToLower(r.Codec) {
+			return true
+		}
+	}
+	return false
+}
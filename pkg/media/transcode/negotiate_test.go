@@ -0,0 +1,32 @@
+package transcode
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		available []Rendition
+		want      string
+	}{
+		{"empty header", "", DefaultRenditions, "aac_256"},
+		{"wildcard", "*/*", DefaultRenditions, "aac_256"},
+		{"ogg preferred", "audio/ogg, audio/*;q=0.5", DefaultRenditions, "opus_160"},
+		{"ogg with codecs", `audio/ogg; codecs="opus", audio/mpeg;q=0.8`, DefaultRenditions, "opus_160"},
+		{"codec mismatch", `audio/ogg; codecs="vorbis", audio/mpeg;q=0.8`, DefaultRenditions, "mp3_320"},
+		{"q ordering", "audio/mpeg;q=0.9, audio/mp4;q=0.4", DefaultRenditions, "mp3_320"},
+		{"specific range overrides wildcard", "audio/*, audio/mp4;q=0", DefaultRenditions, "opus_160"},
+		{"only available", "audio/ogg", []Rendition{Opus96, MP3320}, "opus_96"},
+		{"nothing acceptable", "video/webm", DefaultRenditions, ""},
+		{"malformed entries skipped", "audio/mpeg;q=x, ;;, audio/mp4", DefaultRenditions, "aac_256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Negotiate(tt.accept, tt.available)
+			if got.Name != tt.want || ok != (tt.want != "") {
+				t.Errorf("Negotiate(%q) = %q, %v, want %q", tt.accept, got.Name, ok, tt.want)
+			}
+		})
+	}
+}
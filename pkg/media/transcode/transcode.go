@@ -0,0 +1,96 @@
+// Package transcode encodes uploaded masters into normalized renditions and
+// picks the rendition that suits a client best.
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+)
+
+// Rendition is one normalized encoding of a track
+type Rendition struct {
+	// Name identifies the rendition, e.g. "opus_160"
+	Name string
+	// Codec is the RFC 6381 codec string, matched against the codecs
+	// parameter of Accept headers
+	Codec string
+	// Bitrate is the target audio bitrate in bits per second
+	Bitrate int
+	// ContentType is the MIME type of the encoded file
+	ContentType string
+	// Extension is the file extension including the dot
+	Extension string
+
+	// format and encoder are the ffmpeg muxer and audio encoder
+	format  string
+	encoder string
+}
+
+// Default renditions, in order of preference when a client accepts any of
+// them equally. AAC comes first as the format every player supports.
+var (
+	AAC256  = Rendition{Name: "aac_256", Codec: "mp4a.40.2", Bitrate: 256000, ContentType: "audio/mp4", Extension: ".m4a", format: "ipod", encoder: "aac"}
+	AAC128  = Rendition{Name: "aac_128", Codec: "mp4a.40.2", Bitrate: 128000, ContentType: "audio/mp4", Extension: ".m4a", format: "ipod", encoder: "aac"}
+	Opus160 = Rendition{Name: "opus_160", Codec: "opus", Bitrate: 160000, ContentType: "audio/ogg", Extension: ".opus", format: "ogg", encoder: "libopus"}
+	Opus96  = Rendition{Name: "opus_96", Codec: "opus", Bitrate: 96000, ContentType: "audio/ogg", Extension: ".opus", format: "ogg", encoder: "libopus"}
+	MP3320  = Rendition{Name: "mp3_320", Codec: "mp3", Bitrate: 320000, ContentType: "audio/mpeg", Extension: ".mp3", format: "mp3", encoder: "libmp3lame"}
+)
+
+// DefaultRenditions are produced for every uploaded track
+var DefaultRenditions = []Rendition{AAC256, AAC128, Opus160, Opus96, MP3320}
+
+// Lookup returns the default rendition called name
+func Lookup(name string) (Rendition, bool) {
+	for _, r := range DefaultRenditions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// Encoder writes input encoded as rendition r to output
+type Encoder interface {
+	Encode(ctx context.Context, input, output string, r Rendition) error
+}
+
+// FFmpegEncoder encodes renditions with an ffmpeg subprocess
+type FFmpegEncoder struct {
+	runner *ffmpeg.Runner
+}
+
+// NewFFmpegEncoder creates an encoder using runner
+func NewFFmpegEncoder(runner *ffmpeg.Runner) *FFmpegEncoder {
+	return &FFmpegEncoder{runner: runner}
+}
+
+func (e *FFmpegEncoder) Encode(ctx context.Context, input, output string, r Rendition) error {
+	if r.encoder == "" {
+		return fmt.Errorf("unknown rendition %q", r.Name)
+	}
+	if err := e.runner.Run(ctx, args(input, output, r)...); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", r.Name, err)
+	}
+	return nil
+}
+
+func args(input, output string, r Rendition) []string {
+	args := []string{
+		"-i", input,
+		"-map", "0:a:0",
+		"-vn",
+		"-map_metadata", "-1",
+		"-c:a", r.encoder,
+		"-b:a", strconv.Itoa(r.Bitrate),
+	}
+	switch r.encoder {
+	case "libopus":
+		args = append(args, "-vbr", "on")
+	case "aac":
+		args = append(args, "-movflags", "+faststart")
+	}
+	return append(args, "-f", r.format, output)
+}
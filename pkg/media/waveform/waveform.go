@@ -0,0 +1,274 @@
+// Package waveform generates min/max peak data for drawing waveforms, in
+// the JSON and binary .dat formats of BBC audiowaveform.
+package waveform
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"go-audio-stream/pkg/media/ffmpeg"
+	"go-audio-stream/pkg/media/pcm"
+)
+
+// Version is the audiowaveform data format version written
+const Version = 2
+
+// DefaultPixelsPerSecond are the resolutions generated for every track,
+// finest first
+var DefaultPixelsPerSecond = []int{100, 50, 20, 10}
+
+// ErrInvalidData is returned when reading malformed .dat files
+var ErrInvalidData = errors.New("invalid waveform data")
+
+// Waveform is the single-channel peak data of a track at one resolution.
+// Data holds a min and max value for each pixel, in the range of Bits.
+type Waveform struct {
+	SampleRate      int
+	SamplesPerPixel int
+	Bits            int
+	Data            []int16
+}
+
+// Length is the number of pixels
+func (w *Waveform) Length() int {
+	return len(w.Data) / 2
+}
+
+// SamplesPerPixel converts a zoom level in pixels per second to samples
+func SamplesPerPixel(sampleRate, pixelsPerSecond int) int {
+	if pixelsPerSecond <= 0 {
+		return sampleRate
+	}
+	return max(1, int(math.Round(float64(sampleRate)/float64(pixelsPerSecond))))
+}
+
+// Generator produces waveforms of an audio file at several resolutions
+type Generator interface {
+	Generate(ctx context.Context, input string, pixelsPerSecond []int) ([]*Waveform, error)
+}
+
+// FFmpegGenerator decodes any format ffmpeg reads and generates 16-bit
+// waveforms from it
+type FFmpegGenerator struct {
+	runner *ffmpeg.Runner
+}
+
+// NewFFmpegGenerator creates a generator decoding with runner
+func NewFFmpegGenerator(runner *ffmpeg.Runner) *FFmpegGenerator {
+	return &FFmpegGenerator{runner: runner}
+}
+
+func (g *FFmpegGenerator) Generate(ctx context.Context, input string, pixelsPerSecond []int) ([]*Waveform, error) {
+	var waveforms []*Waveform
+	err := pcm.Decode(ctx, g.runner, input, func(r *pcm.Reader) error {
+		var err error
+		waveforms, err = FromPCM(r, pixelsPerSecond)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate waveform: %w", err)
+	}
+	return waveforms, nil
+}
+
+// level accumulates one resolution
+type level struct {
+	waveform *Waveform
+	min, max float64
+	count    int
+}
+
+func (l *level) flush() {
+	l.waveform.Data = append(l.waveform.Data, toInt16(l.min), toInt16(l.max))
+	l.min, l.max, l.count = math.Inf(1), math.Inf(-1), 0
+}
+
+// FromPCM reads r to the end and returns a 16-bit waveform for each zoom
+// level in pixelsPerSecond. Channels are mixed down to mono.
+func FromPCM(r *pcm.Reader, pixelsPerSecond []int) ([]*Waveform, error) {
+	levels := make([]*level, len(pixelsPerSecond))
+	for i, pps := range pixelsPerSecond {
+		levels[i] = &level{
+			waveform: &Waveform{SampleRate: r.SampleRate, SamplesPerPixel: SamplesPerPixel(r.SampleRate, pps), Bits: 16},
+			min:      math.Inf(1),
+			max:      math.Inf(-1),
+		}
+	}
+
+	samples := make([]float64, 4096*r.Channels)
+	for {
+		n, err := r.Read(samples)
+		for i := 0; i+r.Channels <= n; i += r.Channels {
+			var mono float64
+			for _, v := range samples[i : i+r.Channels] {
+				mono += v
+			}
+			mono /= float64(r.Channels)
+
+			for _, l := range levels {
+				l.min = math.Min(l.min, mono)
+				l.max = math.Max(l.max, mono)
+				l.count++
+				if l.count == l.waveform.SamplesPerPixel {
+					l.flush()
+				}
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	waveforms := make([]*Waveform, len(levels))
+	for i, l := range levels {
+		if l.count > 0 {
+			l.flush()
+		}
+		waveforms[i] = l.waveform
+	}
+	return waveforms, nil
+}
+
+func toInt16(v float64) int16 {
+	return int16(math.Max(-32768, math.Min(32767, math.Round(v*32767))))
+}
+
+// Resample merges pixels to approach samplesPerPixel. Only whole pixels are
+// merged, so the result has a multiple of w's resolution.
+func (w *Waveform) Resample(samplesPerPixel int) *Waveform {
+	factor := max(1, int(math.Round(float64(samplesPerPixel)/float64(w.SamplesPerPixel))))
+	if factor == 1 {
+		return w
+	}
+
+	out := &Waveform{SampleRate: w.SampleRate, SamplesPerPixel: w.SamplesPerPixel * factor, Bits: w.Bits}
+	out.Data = make([]int16, 0, 2*((w.Length()+factor-1)/factor))
+	for start := 0; start < w.Length(); start += factor {
+		end := min(start+factor, w.Length())
+		lo, hi := w.Data[2*start], w.Data[2*start+1]
+		for p := start + 1; p < end; p++ {
+			lo, hi = min(lo, w.Data[2*p]), max(hi, w.Data[2*p+1])
+		}
+		out.Data = append(out.Data, lo, hi)
+	}
+	return out
+}
+
+// To8Bit returns the waveform scaled to 8-bit values
+func (w *Waveform) To8Bit() *Waveform {
+	if w.Bits == 8 {
+		return w
+	}
+	out := &Waveform{SampleRate: w.SampleRate, SamplesPerPixel: w.SamplesPerPixel, Bits: 8, Data: make([]int16, len(w.Data))}
+	for i, v := range w.Data {
+		out.Data[i] = v >> 8
+	}
+	return out
+}
+
+// MarshalJSON encodes the waveform in audiowaveform's JSON format
+func (w *Waveform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Version         int     `json:"version"`
+		Channels        int     `json:"channels"`
+		SampleRate      int     `json:"sample_rate"`
+		SamplesPerPixel int     `json:"samples_per_pixel"`
+		Bits            int     `json:"bits"`
+		Length          int     `json:"length"`
+		Data            []int16 `json:"data"`
+	}{Version, 1, w.SampleRate, w.SamplesPerPixel, w.Bits, w.Length(), w.Data})
+}
+
+// datHeader is the header of an audiowaveform version 2 .dat file
+type datHeader struct {
+	Version         int32
+	Flags           uint32 // bit 0 set for 8-bit data
+	SampleRate      int32
+	SamplesPerPixel int32
+	Length          uint32
+	Channels        int32
+}
+
+// WriteDat encodes the waveform in audiowaveform's binary format
+func (w *Waveform) WriteDat(dst io.Writer) error {
+	header := datHeader{
+		Version:         Version,
+		SampleRate:      int32(w.SampleRate),
+		SamplesPerPixel: int32(w.SamplesPerPixel),
+		Length:          uint32(w.Length()),
+		Channels:        1,
+	}
+	if w.Bits == 8 {
+		header.Flags = 1
+	}
+	if err := binary.Write(dst, binary.LittleEndian, header); err != nil {
+		return err
+	}
+
+	if w.Bits == 8 {
+		data := make([]byte, len(w.Data))
+		for i, v := range w.Data {
+			data[i] = byte(int8(v))
+		}
+		_, err := dst.Write(data)
+		return err
+	}
+	return binary.Write(dst, binary.LittleEndian, w.Data)
+}
+
+// ReadDat decodes a single-channel audiowaveform .dat file of version 1 or 2
+func ReadDat(r io.Reader) (*Waveform, error) {
+	var header datHeader
+	if err := binary.Read(r, binary.LittleEndian, &header.Version); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidData, err)
+	}
+	fields := []interface{}{&header.Flags, &header.SampleRate, &header.SamplesPerPixel, &header.Length}
+	switch header.Version {
+	case 1:
+		header.Channels = 1
+	case 2:
+		fields = append(fields, &header.Channels)
+	default:
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidData, header.Version)
+	}
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidData, err)
+		}
+	}
+	if header.Channels != 1 || header.SampleRate <= 0 || header.SamplesPerPixel <= 0 || header.Length > 1<<26 {
+		return nil, fmt.Errorf("%w: unsupported header", ErrInvalidData)
+	}
+
+	w := &Waveform{
+		SampleRate:      int(header.SampleRate),
+		SamplesPerPixel: int(header.SamplesPerPixel),
+		Bits:            16,
+		Data:            make([]int16, 2*header.Length),
+	}
+	if header.Flags&1 == 0 {
+		if err := binary.Read(r, binary.LittleEndian, w.Data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidData, err)
+		}
+		return w, nil
+	}
+
+	w.Bits = 8
+	data := make([]byte, len(w.Data))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidData, err)
+	}
+	for i, v := range data {
+		w.Data[i] = int16(int8(v))
+	}
+	return w, nil
+}
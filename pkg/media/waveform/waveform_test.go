@@ -0,0 +1,91 @@
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go-audio-stream/pkg/media/pcm"
+)
+
+// wavOf returns a 16-bit stereo WAV stream at 1 kHz
+func wavOf(frames [][2]int16) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVEfmt \x10\x00\x00\x00")
+	for _, v := range []interface{}{uint16(1), uint16(2), uint32(1000), uint32(4000), uint16(4), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data\xff\xff\xff\xff")
+	binary.Write(&b, binary.LittleEndian, frames)
+	return b.Bytes()
+}
+
+func TestFromPCM(t *testing.T) {
+	frames := [][2]int16{{1000, 1000}, {-2000, -2000}, {3000, 1000}, {0, 0}, {-32768, -32768}}
+	r, err := pcm.NewWAVReader(bytes.NewReader(wavOf(frames)))
+	if err != nil {
+		t.Fatalf("NewWAVReader() error = %v", err)
+	}
+
+	// 500 and 200 pixels per second at 1 kHz are 2 and 5 samples per pixel
+	waveforms, err := FromPCM(r, []int{500, 200})
+	if err != nil {
+		t.Fatalf("FromPCM() error = %v", err)
+	}
+
+	fine := waveforms[0]
+	if fine.SamplesPerPixel != 2 || fine.SampleRate != 1000 || fine.Bits != 16 {
+		t.Errorf("fine waveform = %+v", fine)
+	}
+	// Stereo pairs are averaged; the last pixel holds a single sample
+	if want := []int16{-2000, 1000, 0, 2000, -32767, -32767}; !reflect.DeepEqual(fine.Data, want) {
+		t.Errorf("fine data = %v, want %v", fine.Data, want)
+	}
+	if want := []int16{-32767, 2000}; !reflect.DeepEqual(waveforms[1].Data, want) {
+		t.Errorf("coarse data = %v, want %v", waveforms[1].Data, want)
+	}
+
+	resampled := fine.Resample(4)
+	if resampled.SamplesPerPixel != 4 || !reflect.DeepEqual(resampled.Data, []int16{-2000, 2000, -32767, -32767}) {
+		t.Errorf("Resample(4) = %+v", resampled)
+	}
+}
+
+func TestDatRoundTrip(t *testing.T) {
+	w := &Waveform{SampleRate: 44100, SamplesPerPixel: 441, Bits: 16, Data: []int16{-300, 400, -32768, 32767}}
+
+	for _, waveform := range []*Waveform{w, w.To8Bit()} {
+		var b bytes.Buffer
+		if err := waveform.WriteDat(&b); err != nil {
+			t.Fatalf("WriteDat() error = %v", err)
+		}
+		if want := 24 + len(waveform.Data)*waveform.Bits/8; b.Len() != want {
+			t.Errorf("%d-bit .dat is %d bytes, want %d", waveform.Bits, b.Len(), want)
+		}
+		got, err := ReadDat(&b)
+		if err != nil {
+			t.Fatalf("ReadDat() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, waveform) {
+			t.Errorf("ReadDat() = %+v, want %+v", got, waveform)
+		}
+	}
+
+	if _, err := ReadDat(bytes.NewReader([]byte{9, 0, 0, 0})); err == nil {
+		t.Error("ReadDat() accepted version 9")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	w := &Waveform{SampleRate: 48000, SamplesPerPixel: 480, Bits: 8, Data: []int16{-1, 2}}
+	got, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"version":2,"channels":1,"sample_rate":48000,"samples_per_pixel":480,"bits":8,"length":1,"data":[-1,2]}`
+	if string(got) != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
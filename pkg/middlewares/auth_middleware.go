@@ -47,3 +47,19 @@ func NewAuthMiddleware(client *clients.IdentityClient) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// TokenFromQuery copies the token in the query parameter param to the
+// Authorization header when the request has none, for clients that cannot
+// set headers, such as browser WebSockets. Use it before the auth
+// middleware.
+func TokenFromQuery(param string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if token := c.QueryParam(param); token != "" && req.Header.Get("Authorization") == "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return next(c)
+		}
+	}
+}
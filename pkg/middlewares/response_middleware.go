@@ -6,10 +6,24 @@ import (
 	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
+// PaginationContextKey is the context key under which list handlers store
+// the *Pagination of their response
+const PaginationContextKey = "pagination"
+
 type ResponseStruct struct {
-	Data interface{} `json:"data"`
+	Data       interface{} `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination tells clients how to fetch the next page of a list. Pass
+// NextCursor as the cursor parameter while HasMore is true.
+type Pagination struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Limit      int    `json:"limit"`
 }
 
 type CustomResponseWriter struct {
@@ -21,56 +35,96 @@ func (w *CustomResponseWriter) Write(b []byte) (int, error) {
 	return w.body.Write(b)
 }
 
-func CustomResponseMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// Skip wrapping for swagger documentation
-		if strings.HasPrefix(c.Request().URL.Path, "/swagger") {
-			return next(c)
-		}
+// ResponseConfig defines the config for the response wrapping middleware
+type ResponseConfig struct {
+	// Skipper defines a function to skip wrapping, e.g. for streaming routes
+	// whose body must not be buffered in memory.
+	Skipper middleware.Skipper
+}
 
-		// Create a custom response writer to capture the response
-		originalWriter := c.Response().Writer
-		buffer := &bytes.Buffer{}
-		customWriter := &CustomResponseWriter{
-			Response: *c.Response(),
-			body:     buffer,
+// DefaultResponseConfig skips only the swagger documentation
+var DefaultResponseConfig = ResponseConfig{
+	Skipper: SkipPathPrefixes("/swagger"),
+}
+
+// SkipPathPrefixes returns a skipper matching requests whose path starts with
+// any of the given prefixes
+func SkipPathPrefixes(prefixes ...string) middleware.Skipper {
+	return func(c echo.Context) bool {
+		path := c.Request().URL.Path
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
 		}
-		c.Response().Writer = customWriter
+		return false
+	}
+}
+
+func CustomResponseMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return CustomResponseMiddlewareWithConfig(DefaultResponseConfig)(next)
+}
 
-		// Execute the next handler
-		err := next(c)
+// CustomResponseMiddlewareWithConfig wraps successful JSON responses in
+// ResponseStruct, passing through the requests matched by config.Skipper
+func CustomResponseMiddlewareWithConfig(config ResponseConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultResponseConfig.Skipper
+	}
 
-		// Restore the original writer
-		c.Response().Writer = originalWriter
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
 
-		if err != nil {
-			return err
-		}
+			// Create a custom response writer to capture the response
+			originalWriter := c.Response().Writer
+			buffer := &bytes.Buffer{}
+			customWriter := &CustomResponseWriter{
+				Response: *c.Response(),
+				body:     buffer,
+			}
+			c.Response().Writer = customWriter
 
-		// Only transform successful responses (2xx status codes)
-		if c.Response().Status >= 200 && c.Response().Status < 300 {
-			// Get the original response body
-			originalBody := buffer.Bytes()
+			// Execute the next handler
+			err := next(c)
 
-			// If there's content to wrap
-			if len(originalBody) > 0 {
-				var originalData interface{}
+			// Restore the original writer
+			c.Response().Writer = originalWriter
 
-				// Try to parse the original response as JSON
-				if json.Unmarshal(originalBody, &originalData) == nil {
-					// Wrap the data in ResponseStruct
-					wrappedResponse := ResponseStruct{
-						Data: originalData,
-					}
+			if err != nil {
+				return err
+			}
 
-					// Send the wrapped response
-					return c.JSON(c.Response().Status, wrappedResponse)
+			// Only transform successful responses (2xx status codes)
+			if c.Response().Status >= 200 && c.Response().Status < 300 {
+				// Get the original response body
+				originalBody := buffer.Bytes()
+
+				// If there's content to wrap
+				if len(originalBody) > 0 {
+					var originalData interface{}
+
+					// Try to parse the original response as JSON
+					if json.Unmarshal(originalBody, &originalData) == nil {
+						// Wrap the data in ResponseStruct
+						wrappedResponse := ResponseStruct{
+							Data: originalData,
+						}
+						if pagination, ok := c.Get(PaginationContextKey).(*Pagination); ok {
+							wrappedResponse.Pagination = pagination
+						}
+
+						// Send the wrapped response
+						return c.JSON(c.Response().Status, wrappedResponse)
+					}
 				}
 			}
-		}
 
-		// For non-2xx responses or if JSON parsing fails, return the original response
-		c.Response().Writer.Write(buffer.Bytes())
-		return nil
+			// For non-2xx responses or if JSON parsing fails, return the original response
+			c.Response().Writer.Write(buffer.Bytes())
+			return nil
+		}
 	}
 }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// AlbumType is the kind of release
+type AlbumType string
+
+const (
+	AlbumTypeAlbum       AlbumType = "album"
+	AlbumTypeSingle      AlbumType = "single"
+	AlbumTypeEP          AlbumType = "ep"
+	AlbumTypeCompilation AlbumType = "compilation"
+)
+
+// Valid reports whether t is one of the known album types
+func (t AlbumType) Valid() bool {
+	switch t {
+	case AlbumTypeAlbum, AlbumTypeSingle, AlbumTypeEP, AlbumTypeCompilation:
+		return true
+	}
+	return false
+}
+
+// Album is a release grouping songs into a tracklist. Songs point at their
+// album with a disc and track number.
+type Album struct {
+	BaseModel
+	Title       string     `json:"title"`
+	ReleaseDate *time.Time `gorm:"type:date;index" json:"release_date"`
+	Type        AlbumType  `gorm:"index;default:album" json:"type"`
+	Label       string     `json:"label"`
+	UPC         string     `gorm:"index" json:"upc"`
+	Image       string     `json:"image"`
+	Images      *Images    `json:"images"`
+	DiscCount   int16      `gorm:"default:1" json:"disc_count"`
+	// SearchKeys holds the transliterated forms of Title, set on save
+	SearchKeys string `json:"-"`
+
+	Artists []Artist `gorm:"many2many:album_artist;" json:"artists"`
+	Songs   []Song   `gorm:"foreignKey:AlbumID" json:"songs,omitempty"`
+}
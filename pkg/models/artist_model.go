@@ -1,12 +1,17 @@
 package models
 
+// Artist is a performer or creator in the catalog. Followers counts the
+// users following the artist; it is kept in sync by the follow endpoints.
 type Artist struct {
 	BaseModel
 	Name      string     `json:"name" form:"name"`
 	Image     string     `json:"image" form:"image"`
-	Followers int64      `json:"followers"`
+	Images    *Images    `json:"images"`
+	Followers int64      `gorm:"->;not null;default:0" json:"followers"`
 	Songs     []Song     `gorm:"many2many:artist_song;"`
 	Playlists []Playlist `gorm:"foreignKey:CreatorArtistID"`
 	Verified  bool       `json:"verified"`
 	Bio       string     `json:"bio" form:"bio"`
+	// SearchKeys holds the transliterated forms of Name, set on save
+	SearchKeys string `json:"-"`
 }
@@ -2,6 +2,14 @@ package models
 
 import "time"
 
+// Device types
+const (
+	DeviceMobile  = "mobile"
+	DeviceBrowser = "browser"
+)
+
+// Device is a phone or browser a user signed in on. Revoked devices are
+// deleted. LastOnlineAt is updated by heartbeats and pairing.
 type Device struct {
 	BaseModel
 	UserID       string     `gorm:"index" json:"user_id"`
@@ -9,5 +17,5 @@ type Device struct {
 	DeviceName   string     `json:"device_name"`
 	LastOnlineAt *time.Time `json:"last_online_at"`
 
-	User User `gorm:"foreignKey:UserID"`
+	User User `gorm:"foreignKey:UserID" json:"-"`
 }
@@ -2,17 +2,29 @@ package models
 
 import "time"
 
+// Pairing statuses
+const (
+	PairingPending = "pending"
+	PairingPaired  = "paired"
+	PairingExpired = "expired"
+)
+
+// DevicePairing connects a user's phone to a browser. The phone starts a
+// pending pairing with a short PairCode, stored without its separator, and
+// the browser completes it by sending the code back before ExpiresAt. A
+// pairing can only be completed once.
 type DevicePairing struct {
 	BaseModel
-	UserID          string `gorm:"index" json:"user_id"`
-	MobileDeviceID  string `gorm:"index" json:"mobile_device_id"`
-	BrowserDeviceID string `gorm:"index" json:"browser_device_id"`
+	UserID          string  `gorm:"index" json:"user_id"`
+	MobileDeviceID  string  `gorm:"index" json:"mobile_device_id"`
+	BrowserDeviceID *string `gorm:"index" json:"browser_device_id"`
 
-	PairCode  string    `json:"pair_code"`
-	Status    string    `json:"status"` // pending, paired, expired
-	ExpiresAt time.Time `json:"expires_at"`
+	PairCode  string     `gorm:"index" json:"-"`
+	Status    string     `gorm:"index" json:"status"` // pending, paired, expired
+	ExpiresAt time.Time  `json:"expires_at"`
+	PairedAt  *time.Time `json:"paired_at"`
 
-	User          User   `gorm:"foreignKey:UserID"`
-	MobileDevice  Device `gorm:"foreignKey:MobileDeviceID"`
-	BrowserDevice Device `gorm:"foreignKey:BrowserDeviceID"`
+	User          User    `gorm:"foreignKey:UserID" json:"-"`
+	MobileDevice  Device  `gorm:"foreignKey:MobileDeviceID" json:"-"`
+	BrowserDevice *Device `gorm:"foreignKey:BrowserDeviceID" json:"-"`
 }
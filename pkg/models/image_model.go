@@ -0,0 +1,42 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ImageVariant is one square size of a processed image
+type ImageVariant struct {
+	Size int    `json:"size"`
+	JPEG string `json:"jpeg"`
+	WebP string `json:"webp"`
+}
+
+// Images lists the processed sizes of an uploaded cover or artist image,
+// smallest first, with placeholders to show while they load. It is stored
+// as a JSON column next to the entity's Image URL.
+type Images struct {
+	Variants      []ImageVariant `json:"variants"`
+	DominantColor string         `json:"dominant_color"`
+	BlurHash      string         `json:"blurhash"`
+}
+
+func (Images) GormDataType() string {
+	return "json"
+}
+
+func (i Images) Value() (driver.Value, error) {
+	data, err := json.Marshal(i)
+	return string(data), err
+}
+
+func (i *Images) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, i)
+	case string:
+		return json.Unmarshal([]byte(v), i)
+	}
+	return fmt.Errorf("unsupported Images value %T", value)
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// PlaylistRole is what a user may do with a playlist
+type PlaylistRole string
+
+const (
+	// PlaylistOwner is the playlist's creator, who can change and delete it
+	// and manage its members
+	PlaylistOwner PlaylistRole = "owner"
+	// PlaylistEditor members can change the songs of collaborative playlists
+	PlaylistEditor PlaylistRole = "editor"
+	// PlaylistViewer members can see private playlists
+	PlaylistViewer PlaylistRole = "viewer"
+)
+
+// Valid reports whether r is a role that can be given to members
+func (r PlaylistRole) Valid() bool {
+	return r == PlaylistEditor || r == PlaylistViewer
+}
+
+// AtLeast reports whether r grants everything other does
+func (r PlaylistRole) AtLeast(other PlaylistRole) bool {
+	return r.level() >= other.level()
+}
+
+func (r PlaylistRole) level() int {
+	switch r {
+	case PlaylistOwner:
+		return 3
+	case PlaylistEditor:
+		return 2
+	case PlaylistViewer:
+		return 1
+	}
+	return 0
+}
+
+// PlaylistMember gives a user a role on a playlist
+type PlaylistMember struct {
+	PlaylistID string       `gorm:"primaryKey" json:"playlist_id"`
+	UserID     string       `gorm:"primaryKey;index" json:"user_id"`
+	Role       PlaylistRole `gorm:"not null;default:viewer" json:"role"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// PlaylistInvite is a link that makes whoever accepts it a member with Role
+// until ExpiresAt. Only a hash of the token is stored; Token is set when the
+// invite is created.
+type PlaylistInvite struct {
+	BaseModel
+	PlaylistID      string       `gorm:"not null;index" json:"playlist_id"`
+	TokenHash       string       `gorm:"not null;uniqueIndex" json:"-"`
+	Role            PlaylistRole `gorm:"not null" json:"role"`
+	CreatedByUserID string       `json:"created_by_user_id"`
+	ExpiresAt       time.Time    `json:"expires_at"`
+
+	Token string `gorm:"-" json:"token,omitempty"`
+}
@@ -1,17 +1,41 @@
 package models
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Playlist is an ordered list of songs. SnapshotID changes with every edit of
+// the entries, so clients can send it back to detect concurrent changes.
+// The creating user owns the playlist and PlaylistMember rows give other
+// users access. Playlists with Rules are smart: their entries are computed
+// from the rules and refreshed periodically, RefreshedAt being the last
+// time.
 type Playlist struct {
 	BaseModel
-	Name            string `json:"name"`
-	Image           string `json:"image"`
-	Private         bool   `json:"private"`
-	Description     string `json:"description"`
-	IsCollaborative bool   `json:"is_collaborative"`
+	Name            string  `json:"name"`
+	Image           string  `json:"image"`
+	Images          *Images `json:"images"`
+	Private         bool    `json:"private"`
+	Description     string  `json:"description"`
+	IsCollaborative bool    `json:"is_collaborative"`
+	SnapshotID      string  `gorm:"<-:create" json:"snapshot_id"`
+
+	Rules       *SmartRules `json:"rules,omitempty"`
+	RefreshedAt *time.Time  `gorm:"->" json:"refreshed_at,omitempty"`
 
-	CreatorUserID   *string `gorm:"index" json:"creator_user_id"`
+	CreatorUserID   *string `gorm:"index;<-:create" json:"creator_user_id"`
 	CreatorUser     *User   `gorm:"foreignKey:CreatorUserID"`
 	CreatorArtistID *string `gorm:"index" json:"creator_artist_id"`
 	CreatorArtist   *Artist `gorm:"foreignKey:CreatorArtistID"`
 
 	PlaylistSongs []PlaylistSong `gorm:"foreignKey:PlaylistID" json:"playlist_songs"`
 }
+
+// BeforeCreate assigns the ID and the first snapshot ID
+func (p *Playlist) BeforeCreate(tx *gorm.DB) (err error) {
+	p.SnapshotID = uuid.New().String()
+	return p.BaseModel.BeforeCreate(tx)
+}
@@ -1,11 +1,33 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// 1. Define the Junction Model
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PlaylistSong is one entry of a playlist. A song can appear several times,
+// each as its own entry. Entries are ordered by Rank, a lexicographic key the
+// server maintains so that inserts and moves only rewrite the moved entries;
+// Position is the entry's index, filled in when the entries are loaded.
+// AddedBy is the user who added the entry.
 type PlaylistSong struct {
-	PlaylistID string    `gorm:"primaryKey" json:"playlist_id"`
-	SongID     string    `gorm:"primaryKey" json:"song_id"`
-	Position   int       `gorm:"not null" json:"position"`
+	ID         string    `gorm:"type:uuid;primaryKey" json:"id"`
+	PlaylistID string    `gorm:"not null;index:idx_playlist_songs_rank,priority:1" json:"playlist_id"`
+	SongID     string    `gorm:"not null;index" json:"song_id"`
+	Rank       string    `gorm:"not null;default:'';index:idx_playlist_songs_rank,priority:2" json:"-"`
+	Position   int       `gorm:"-" json:"position"`
+	AddedBy    *string   `gorm:"type:uuid;index" json:"added_by"`
 	CreatedAt  time.Time `json:"created_at"`
+
+	Song *Song `gorm:"foreignKey:SongID" json:"song,omitempty"`
+}
+
+// BeforeCreate assigns a new entry ID
+func (e *PlaylistSong) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return
 }
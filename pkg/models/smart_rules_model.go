@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// SmartRules make a playlist smart: instead of users adding songs, its
+// entries are the catalog songs matching the conditions, in Sort order and
+// at most Limit of them. Rules are stored as a JSON column on the playlist.
+type SmartRules struct {
+	// Match is "all" (the default) when every condition must hold or "any"
+	// when one is enough
+	Match      string           `json:"match,omitempty"`
+	Conditions []SmartCondition `json:"conditions"`
+	// Sort is a field name, prefixed with - for descending order, or
+	// "random"
+	Sort  string `json:"sort,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// SmartCondition compares a song field with a value, e.g.
+// {"field": "energy", "op": "gt", "value": 0.7}
+type SmartCondition struct {
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (SmartRules) GormDataType() string {
+	return "json"
+}
+
+func (r SmartRules) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+func (r *SmartRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("unsupported SmartRules value %T", value)
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// SongAssetStatus is the processing state of a rendition
+type SongAssetStatus string
+
+const (
+	SongAssetPending    SongAssetStatus = "pending"
+	SongAssetProcessing SongAssetStatus = "processing"
+	SongAssetReady      SongAssetStatus = "ready"
+	SongAssetFailed     SongAssetStatus = "failed"
+)
+
+// SongAsset is one encoded rendition of a song's master, e.g. Opus at
+// 160 kbps. Pending assets are the transcoder's work queue.
+type SongAsset struct {
+	BaseModel
+	SongID      string          `gorm:"index" json:"song_id"`
+	Rendition   string          `json:"rendition"`
+	Codec       string          `json:"codec"`
+	Bitrate     int             `json:"bitrate"`
+	ContentType string          `json:"content_type"`
+	Key         string          `json:"key"`
+	Size        int64           `json:"size"`
+	Status      SongAssetStatus `gorm:"index" json:"status"`
+
+	SourceKey   string     `json:"-"` // master the rendition is encoded from
+	Attempts    int        `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	LockedUntil *time.Time `json:"-"` // lease of the worker processing it
+}
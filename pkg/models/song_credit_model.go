@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreditRole is what an artist did on a song
+type CreditRole string
+
+const (
+	CreditPrimary       CreditRole = "primary"
+	CreditFeatured      CreditRole = "featured"
+	CreditComposer      CreditRole = "composer"
+	CreditLyricist      CreditRole = "lyricist"
+	CreditProducer      CreditRole = "producer"
+	CreditMusicDirector CreditRole = "music_director"
+)
+
+// Valid reports whether r is one of the known credit roles
+func (r CreditRole) Valid() bool {
+	switch r {
+	case CreditPrimary, CreditFeatured, CreditComposer, CreditLyricist, CreditProducer, CreditMusicDirector:
+		return true
+	}
+	return false
+}
+
+// SongCredit credits an artist on a song in one role. It is the join model
+// of Song.Artists and Artist.Songs, so an artist credited in several roles
+// appears once per role there. Position orders the credits for display.
+type SongCredit struct {
+	SongID    string     `gorm:"primaryKey" json:"song_id"`
+	ArtistID  string     `gorm:"primaryKey;index" json:"artist_id"`
+	Role      CreditRole `gorm:"primaryKey;default:primary" json:"role"`
+	Position  int        `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Artist *Artist `gorm:"foreignKey:ArtistID" json:"artist,omitempty"`
+}
+
+// TableName keeps the table of the former plain many2many join
+func (SongCredit) TableName() string {
+	return "artist_song"
+}
+
+// BeforeCreate credits artists added through Song.Artists as primary
+func (c *SongCredit) BeforeCreate(tx *gorm.DB) error {
+	if c.Role == "" {
+		c.Role = CreditPrimary
+	}
+	return nil
+}
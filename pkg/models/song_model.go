@@ -2,18 +2,45 @@ package models
 
 type Song struct {
 	BaseModel
-	Name        string `json:"name"`
-	Image       string `json:"image"`
-	URL         string `json:"url"`
-	VideoURL    string `json:"video_url"`
-	Duration    int32  `json:"duration"`
-	Explicit    bool   `json:"explicit"`
-	TrackNumber *int16 `json:"track_number"`
-	Language    string `json:"language"`
+	Name        string  `json:"name"`
+	Image       string  `json:"image"`
+	Images      *Images `json:"images"`
+	URL         string  `json:"url"`
+	VideoURL    string  `json:"video_url"`
+	Duration    int32   `json:"duration"`
+	Explicit    bool    `json:"explicit"`
+	TrackNumber *int16  `json:"track_number"`
+	DiscNumber  *int16  `json:"disc_number"`
+	AlbumID     *string `gorm:"index" json:"album_id"`
+	Language    string  `json:"language"`
 
+	// SearchKeys holds the name in native script, romanized and as phonetic
+	// keys so search matches across scripts; it is set whenever Name is saved
+	SearchKeys string `json:"-"`
+
+	// Loudness per EBU R128, measured after upload. TrackGain is the
+	// ReplayGain 2.0 gain in dB towards -18 LUFS and TrackPeak the linear
+	// true peak; players apply them to normalize volume.
+	IntegratedLoudness *float32 `json:"integrated_loudness"` // LUFS
+	LoudnessRange      *float32 `json:"loudness_range"`      // LU
+	TruePeak           *float32 `json:"true_peak"`           // dBTP
+	TrackGain          *float32 `json:"track_gain"`
+	TrackPeak          *float32 `json:"track_peak"`
+
+	// PreviewStartMS is an editor-chosen start of the preview clip; when nil
+	// the loudest stretch of the track is used
+	PreviewStartMS *int32 `json:"preview_start_ms"`
+
+	// PlayCount counts the song's listens; a trigger installed by the
+	// migration service increments it for every listen history row
+	PlayCount int64 `gorm:"->;not null;default:0" json:"play_count"`
+
+	Album         *Album           `gorm:"foreignKey:AlbumID" json:"album,omitempty"`
 	Artists       []Artist         `gorm:"many2many:artist_song;" json:"artists"`
+	Credits       []SongCredit     `gorm:"foreignKey:SongID" json:"credits,omitempty"`
 	PlaylistSongs []PlaylistSong   `gorm:"foreignKey:SongID" json:"playlist_songs"`
 	Features      *SongFeatures    `gorm:"foreignKey:SongID" json:"features"`
 	Tags          []SongTag        `gorm:"many2many:song_tag_map;" json:"tags"`
 	Instruments   []SongInstrument `gorm:"many2many:song_instrument_map;" json:"instruments"`
+	Assets        []SongAsset      `gorm:"foreignKey:SongID" json:"assets,omitempty"`
 }
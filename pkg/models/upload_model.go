@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// UploadPart is a part of a resumable upload already stored in the object
+// store's multipart upload
+type UploadPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// Upload tracks a resumable (tus) upload from creation until its parts are
+// assembled into the song's audio file
+type Upload struct {
+	BaseModel
+	UserID   string `gorm:"index" json:"user_id"`
+	SongID   string `gorm:"index" json:"song_id"`
+	Filename string `json:"filename"`
+	Metadata string `json:"metadata"` // raw Upload-Metadata header
+
+	Length int64 `gorm:"column:upload_length" json:"length"`
+	Offset int64 `gorm:"column:upload_offset" json:"offset"`
+
+	// Set when the first part is stored and the format is known
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+
+	MultipartID    string                          `json:"-"`
+	Parts          datatypes.JSONSlice[UploadPart] `json:"-"`
+	IncompleteSize int64                           `json:"-"` // bytes below the minimum part size, kept in a separate object
+
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// UserFollowsArtist records that a user follows an artist. It is the join
+// model of User.Follows; CreatedAt is when the user followed.
+type UserFollowsArtist struct {
+	UserID    string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	ArtistID  string    `gorm:"type:uuid;primaryKey;index" json:"artist_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the table of the former plain many2many join
+func (UserFollowsArtist) TableName() string {
+	return "user_follows_artist"
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Backend is the set of operations the services need from an object store.
+// Client (Backblaze B2) and LocalClient (local filesystem) implement it.
+type Backend interface {
+	// Upload stores the content of reader under key and returns the key
+	Upload(ctx context.Context, key string, reader io.Reader, contentType string) (string, error)
+	// UploadWithACL uploads a file with specified ACL (public-read or private)
+	UploadWithACL(ctx context.Context, key string, reader io.Reader, contentType string, acl string) (string, error)
+	// Delete removes the file stored under key
+	Delete(ctx context.Context, key string) error
+	// GetPresignedURL generates a time-limited download URL
+	GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// GetPublicURL returns the public URL for a file uploaded with public-read ACL
+	GetPublicURL(key string) string
+	// ListFiles lists files with the given prefix
+	ListFiles(ctx context.Context, prefix string) ([]FileInfo, error)
+	// Get opens the file stored under key. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
+	// GetRange opens length bytes of the file stored under key starting at
+	// offset. The caller must close the reader.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Stat returns metadata about the file stored under key
+	Stat(ctx context.Context, key string) (*FileInfo, error)
+
+	// CreateMultipartUpload starts assembling the file under key from
+	// separately uploaded parts and returns the upload ID
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error)
+	// UploadPart stores part number (1-based) of a multipart upload. Every
+	// part but the last must be at least MinPartSize bytes.
+	UploadPart(ctx context.Context, key, uploadID string, number int32, reader io.Reader, size int64) (Part, error)
+	// CompleteMultipartUpload assembles the parts, in order, into the file
+	// under key
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipartUpload discards an unfinished multipart upload and the
+	// parts stored for it
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// MinPartSize is the smallest part S3-compatible stores accept for any part
+// of a multipart upload except the last
+const MinPartSize = 5 << 20
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// Supported values for Config.Backend
+const (
+	BackendB2    = "b2"
+	BackendLocal = "local"
+)
+
+// NewBackend creates the storage backend selected by cfg.Backend
+func NewBackend(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case "", BackendB2:
+		client, err := NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case BackendLocal:
+		client, err := NewLocalClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, ErrUnknownBackend
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -13,6 +14,8 @@ import (
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+var _ Backend = (*Client)(nil)
+
 // Client wraps the S3 client for Backblaze B2 operations
 type Client struct {
 	s3Client      *s3.Client
@@ -27,6 +30,7 @@ type FileInfo struct {
 	Size         int64
 	LastModified time.Time
 	ContentType  string
+	ETag         string
 }
 
 // NewClient creates a new B2 storage client
@@ -152,7 +156,141 @@ func (c *Client) ListFiles(ctx context.Context, prefix string) ([]FileInfo, erro
 	return files, nil
 }
 
+// Get opens the object stored under key
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	output, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, wrapNotFound(err)
+	}
+
+	return output.Body, &FileInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		LastModified: aws.ToTime(output.LastModified),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         aws.ToString(output.ETag),
+	}, nil
+}
+
+// GetRange opens length bytes of the object stored under key starting at offset
+func (c *Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	output, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+
+	return output.Body, nil
+}
+
+// Stat returns metadata about the object stored under key
+func (c *Client) Stat(ctx context.Context, key string) (*FileInfo, error) {
+	output, err := c.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+
+	return &FileInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		LastModified: aws.ToTime(output.LastModified),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         aws.ToString(output.ETag),
+	}, nil
+}
+
+// CreateMultipartUpload starts an S3 multipart upload for key
+func (c *Client) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	output, err := c.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(c.bucketName),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+// UploadPart uploads one part of a multipart upload
+func (c *Client) UploadPart(ctx context.Context, key, uploadID string, number int32, reader io.Reader, size int64) (Part, error) {
+	output, err := c.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(c.bucketName),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(number),
+		Body:          reader,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return Part{}, fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	return Part{Number: number, ETag: aws.ToString(output.ETag), Size: size}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the object
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	completed := make([]s3types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = s3types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.Number),
+		}
+	}
+
+	_, err := c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (c *Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := c.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		var noSuchUpload *s3types.NoSuchUpload
+		if errors.As(err, &noSuchUpload) {
+			return fmt.Errorf("%w: %v", ErrUploadNotFound, err)
+		}
+		return fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+	}
+
+	return nil
+}
+
 // GetBucketName returns the configured bucket name
 func (c *Client) GetBucketName() string {
 	return c.bucketName
 }
+
+// wrapNotFound maps S3 missing-object errors to ErrNotFound
+func wrapNotFound(err error) error {
+	var noSuchKey *s3types.NoSuchKey
+	var notFound *s3types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return fmt.Errorf("failed to get file: %w", err)
+}
@@ -2,23 +2,34 @@ package storage
 
 import "os"
 
-// Config holds the Backblaze B2 configuration
+// Config holds the storage configuration. Backend selects the implementation;
+// the B2 fields are used by Client and the Local fields by LocalClient.
 type Config struct {
+	Backend string
+
 	KeyID          string
 	ApplicationKey string
 	BucketName     string
 	Region         string
 	Endpoint       string
+
+	LocalPath    string
+	LocalBaseURL string
+	SigningKey   string
 }
 
-// LoadConfig loads B2 configuration from environment variables
+// LoadConfig loads storage configuration from environment variables
 func LoadConfig() Config {
 	return Config{
+		Backend:        os.Getenv("STORAGE_BACKEND"),
 		KeyID:          os.Getenv("B2_KEY_ID"),
 		ApplicationKey: os.Getenv("B2_APPLICATION_KEY"),
 		BucketName:     os.Getenv("B2_BUCKET_NAME"),
 		Region:         os.Getenv("B2_REGION"),
 		Endpoint:       os.Getenv("B2_ENDPOINT"),
+		LocalPath:      os.Getenv("STORAGE_LOCAL_PATH"),
+		LocalBaseURL:   os.Getenv("STORAGE_LOCAL_BASE_URL"),
+		SigningKey:     os.Getenv("STORAGE_SIGNING_KEY"),
 	}
 }
 
@@ -41,3 +52,14 @@ func (c Config) Validate() error {
 	}
 	return nil
 }
+
+// ValidateLocal checks if the local backend configuration values are set
+func (c Config) ValidateLocal() error {
+	if c.LocalPath == "" {
+		return ErrMissingLocalPath
+	}
+	if c.LocalBaseURL == "" {
+		return ErrMissingLocalBaseURL
+	}
+	return nil
+}
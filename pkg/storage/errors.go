@@ -8,7 +8,15 @@ var (
 	ErrMissingBucketName     = errors.New("B2_BUCKET_NAME is required")
 	ErrMissingRegion         = errors.New("B2_REGION is required")
 	ErrMissingEndpoint       = errors.New("B2_ENDPOINT is required")
+	ErrMissingLocalPath      = errors.New("STORAGE_LOCAL_PATH is required")
+	ErrMissingLocalBaseURL   = errors.New("STORAGE_LOCAL_BASE_URL is required")
+	ErrUnknownBackend        = errors.New("unknown STORAGE_BACKEND, expected b2 or local")
 	ErrUploadFailed          = errors.New("failed to upload file")
 	ErrDeleteFailed          = errors.New("failed to delete file")
 	ErrPresignFailed         = errors.New("failed to generate presigned URL")
+	ErrNotFound              = errors.New("file not found")
+	ErrInvalidKey            = errors.New("invalid file key")
+	ErrInvalidSignature      = errors.New("invalid URL signature")
+	ErrSignatureExpired      = errors.New("URL signature expired")
+	ErrUploadNotFound        = errors.New("multipart upload not found")
 )
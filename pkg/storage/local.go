@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalURLPrefix is the path under which the service mounting LocalClient
+// serves files. Signed and public URLs returned by LocalClient point there.
+const LocalURLPrefix = "/storage/"
+
+// ACLPublicRead marks a file as readable without a signed URL
+const ACLPublicRead = "public-read"
+
+// metaDir holds the sidecar metadata files, relative to the storage root
+const metaDir = ".meta"
+
+// uploadsDir holds the parts of unfinished multipart uploads, relative to
+// the storage root
+const uploadsDir = ".uploads"
+
+// LocalClient stores files on the local filesystem. It is meant for local
+// development and CI where no bucket is available.
+type LocalClient struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// localMeta is persisted next to each file to remember what S3 would store
+// as object metadata
+type localMeta struct {
+	ContentType string `json:"content_type"`
+	ACL         string `json:"acl"`
+}
+
+var _ Backend = (*LocalClient)(nil)
+
+// NewLocalClient creates a storage client rooted at cfg.LocalPath. When no
+// signing key is configured a random one is generated, so signed URLs do not
+// survive a restart.
+func NewLocalClient(cfg Config) (*LocalClient, error) {
+	if err := cfg.ValidateLocal(); err != nil {
+		return nil, err
+	}
+
+	root, err := filepath.Abs(cfg.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage path: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage path: %w", err)
+	}
+
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+
+	return &LocalClient{
+		root:       root,
+		baseURL:    strings.TrimRight(cfg.LocalBaseURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// Upload writes a private file to disk and returns the object key
+func (c *LocalClient) Upload(ctx context.Context, key string, reader io.Reader, contentType string) (string, error) {
+	return c.UploadWithACL(ctx, key, reader, contentType, "private")
+}
+
+// UploadWithACL writes a file to disk with specified ACL (public-read or private)
+func (c *LocalClient) UploadWithACL(ctx context.Context, key string, reader io.Reader, contentType string, acl string) (string, error) {
+	filePath, err := c.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(filePath, reader); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType, ACL: acl})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	if err := writeFileAtomic(c.metaPath(key), strings.NewReader(string(meta))); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	return key, nil
+}
+
+// Delete removes a file and its metadata from disk
+func (c *LocalClient) Delete(ctx context.Context, key string) error {
+	filePath, err := c.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+	}
+	if err := os.Remove(c.metaPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+	}
+
+	return nil
+}
+
+// GetPresignedURL returns a URL served by the service itself, signed with an
+// HMAC over the key and expiry time
+func (c *LocalClient) GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := c.path(key); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrPresignFailed, err)
+	}
+
+	expires := time.Now().Add(expiry).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", c.sign(key, expires))
+
+	return c.GetPublicURL(key) + "?" + query.Encode(), nil
+}
+
+// GetPublicURL returns the unsigned URL for a file (only served if the file has public-read ACL)
+func (c *LocalClient) GetPublicURL(key string) string {
+	return c.baseURL + LocalURLPrefix + escapeKey(key)
+}
+
+// ListFiles lists files with the given prefix
+func (c *LocalClient) ListFiles(ctx context.Context, prefix string) ([]FileInfo, error) {
+	files := make([]FileInfo, 0)
+	err := filepath.WalkDir(c.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == filepath.Join(c.root, metaDir) || p == filepath.Join(c.root, uploadsDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, ".tmp") {
+			return nil
+		}
+
+		info, err := c.Stat(ctx, key)
+		if err != nil {
+			return err
+		}
+		files = append(files, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return files, nil
+}
+
+// Get opens the file stored under key. The returned reader is an *os.File,
+// so callers can seek it.
+func (c *LocalClient) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	info, err := c.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath, _ := c.path(key)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, info, nil
+}
+
+// GetRange opens length bytes of the file stored under key starting at offset
+func (c *LocalClient) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	file, _, err := c.Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Stat returns metadata about the file stored under key
+func (c *LocalClient) Stat(ctx context.Context, key string) (*FileInfo, error) {
+	filePath, err := c.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	meta := c.readMeta(key)
+	return &FileInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+		ContentType:  meta.ContentType,
+		ETag:         fmt.Sprintf("\"%x-%x\"", stat.ModTime().UnixNano(), stat.Size()),
+	}, nil
+}
+
+// VerifySignature checks the expires and signature query values produced by
+// GetPresignedURL for key
+func (c *LocalClient) VerifySignature(key, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected := c.sign(key, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+// IsPublic reports whether key was uploaded with public-read ACL
+func (c *LocalClient) IsPublic(key string) bool {
+	return c.readMeta(key).ACL == ACLPublicRead
+}
+
+// Open opens the file stored under key for seeking reads
+func (c *LocalClient) Open(key string) (*os.File, *FileInfo, error) {
+	reader, info, err := c.Get(context.Background(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader.(*os.File), info, nil
+}
+
+func (c *LocalClient) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, c.signingKey)
+	mac.Write([]byte(key))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path resolves key to a file under the storage root, rejecting keys that
+// would escape it
+func (c *LocalClient) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || strings.Contains(key, "..") || strings.HasPrefix(cleaned, "/"+metaDir+"/") || strings.HasPrefix(cleaned, "/"+uploadsDir+"/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(c.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (c *LocalClient) metaPath(key string) string {
+	return filepath.Join(c.root, metaDir, filepath.FromSlash(path.Clean("/"+key))+".json")
+}
+
+func (c *LocalClient) readMeta(key string) localMeta {
+	var meta localMeta
+	data, err := os.ReadFile(c.metaPath(key))
+	if err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// writeFileAtomic writes to a temporary file and renames it into place so
+// readers never observe a partially written file
+func writeFileAtomic(filePath string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// escapeKey escapes each path segment of key for use in a URL
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
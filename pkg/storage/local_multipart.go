@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// localUpload is persisted in the upload directory so a multipart upload
+// survives restarts like it would on S3
+type localUpload struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+}
+
+// CreateMultipartUpload creates a directory that collects the parts of key
+func (c *LocalClient) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	if _, err := c.path(key); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	uploadID := hex.EncodeToString(buf)
+
+	info, err := json.Marshal(localUpload{Key: key, ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	if err := writeFileAtomic(filepath.Join(c.uploadPath(uploadID), "upload.json"), strings.NewReader(string(info))); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+
+	return uploadID, nil
+}
+
+// UploadPart writes one part into the upload directory. The ETag is the
+// quoted MD5 of the part, as S3 returns it.
+func (c *LocalClient) UploadPart(ctx context.Context, key, uploadID string, number int32, reader io.Reader, size int64) (Part, error) {
+	if _, err := c.readUpload(key, uploadID); err != nil {
+		return Part{}, err
+	}
+	if number < 1 {
+		return Part{}, fmt.Errorf("%w: invalid part number %d", ErrUploadFailed, number)
+	}
+
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(reader, hash)}
+	if err := writeFileAtomic(c.partPath(uploadID, number), counter); err != nil {
+		return Part{}, fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	if counter.n != size {
+		return Part{}, fmt.Errorf("%w: part %d is %d bytes, expected %d", ErrUploadFailed, number, counter.n, size)
+	}
+
+	return Part{
+		Number: number,
+		ETag:   `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+		Size:   size,
+	}, nil
+}
+
+// CompleteMultipartUpload concatenates the parts into the file under key and
+// removes the upload directory
+func (c *LocalClient) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	upload, err := c.readUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	files := make([]*os.File, 0, len(parts))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	readers := make([]io.Reader, 0, len(parts))
+	for i, part := range parts {
+		if part.Number != int32(i+1) {
+			return fmt.Errorf("%w: parts must be numbered consecutively from 1", ErrUploadFailed)
+		}
+		f, err := os.Open(c.partPath(uploadID, part.Number))
+		if err != nil {
+			return fmt.Errorf("%w: missing part %d", ErrUploadFailed, part.Number)
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+
+	if _, err := c.UploadWithACL(ctx, key, io.MultiReader(readers...), upload.ContentType, "private"); err != nil {
+		return err
+	}
+
+	return c.removeUpload(uploadID)
+}
+
+// AbortMultipartUpload removes the upload directory and its parts
+func (c *LocalClient) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if _, err := c.readUpload(key, uploadID); err != nil {
+		return err
+	}
+	return c.removeUpload(uploadID)
+}
+
+func (c *LocalClient) uploadPath(uploadID string) string {
+	return filepath.Join(c.root, uploadsDir, uploadID)
+}
+
+func (c *LocalClient) partPath(uploadID string, number int32) string {
+	return filepath.Join(c.uploadPath(uploadID), strconv.Itoa(int(number)))
+}
+
+// readUpload loads the upload record, checking it belongs to key
+func (c *LocalClient) readUpload(key, uploadID string) (localUpload, error) {
+	var upload localUpload
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return upload, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.uploadPath(uploadID), "upload.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return upload, ErrUploadNotFound
+	}
+	if err != nil {
+		return upload, fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	if err := json.Unmarshal(data, &upload); err != nil || upload.Key != key {
+		return upload, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func (c *LocalClient) removeUpload(uploadID string) error {
+	if err := os.RemoveAll(c.uploadPath(uploadID)); err != nil {
+		return fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
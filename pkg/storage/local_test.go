@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalClient(t *testing.T) *LocalClient {
+	t.Helper()
+	client, err := NewLocalClient(Config{
+		LocalPath:    t.TempDir(),
+		LocalBaseURL: "http://localhost:4000/",
+		SigningKey:   "test-key",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	return client
+}
+
+func TestLocalClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client := newTestLocalClient(t)
+
+	if _, err := client.Upload(ctx, "songs/1/audio.mp3", strings.NewReader("ID3data"), "audio/mpeg"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	reader, info, err := client.Get(ctx, "songs/1/audio.mp3")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer reader.Close()
+	body, _ := io.ReadAll(reader)
+	if string(body) != "ID3data" {
+		t.Errorf("Get() body = %q", body)
+	}
+	if info.Size != 7 || info.ContentType != "audio/mpeg" {
+		t.Errorf("Get() info = %+v", info)
+	}
+
+	files, err := client.ListFiles(ctx, "songs/")
+	if err != nil {
+		t.Fatalf("ListFiles() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Key != "songs/1/audio.mp3" {
+		t.Errorf("ListFiles() = %+v", files)
+	}
+
+	if err := client.Delete(ctx, "songs/1/audio.mp3"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := client.Stat(ctx, "songs/1/audio.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalClientRejectsTraversal(t *testing.T) {
+	client := newTestLocalClient(t)
+
+	for _, key := range []string{"", "../etc/passwd", "songs/../../x", ".meta/songs/1.json"} {
+		if _, err := client.Upload(context.Background(), key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Upload(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalClientSignedURL(t *testing.T) {
+	client := newTestLocalClient(t)
+
+	signed, err := client.GetPresignedURL(context.Background(), "songs/1/audio.mp3", time.Minute)
+	if err != nil {
+		t.Fatalf("GetPresignedURL() error = %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if u.Path != "/storage/songs/1/audio.mp3" {
+		t.Errorf("signed URL path = %q", u.Path)
+	}
+
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if err := client.VerifySignature("songs/1/audio.mp3", expires, signature); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+	if err := client.VerifySignature("songs/2/audio.mp3", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() other key error = %v, want ErrInvalidSignature", err)
+	}
+
+	expired, _ := client.GetPresignedURL(context.Background(), "songs/1/audio.mp3", -time.Minute)
+	u, _ = url.Parse(expired)
+	if err := client.VerifySignature("songs/1/audio.mp3", u.Query().Get("expires"), u.Query().Get("signature")); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("VerifySignature() expired error = %v, want ErrSignatureExpired", err)
+	}
+}
+
+func TestLocalClientMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	client := newTestLocalClient(t)
+	key := "songs/1/audio.wav"
+
+	uploadID, err := client.CreateMultipartUpload(ctx, key, "audio/wav")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	var parts []Part
+	for i, chunk := range []string{"RIFF", "----", "WAVE"} {
+		part, err := client.UploadPart(ctx, key, uploadID, int32(i+1), strings.NewReader(chunk), int64(len(chunk)))
+		if err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", i+1, err)
+		}
+		parts = append(parts, part)
+	}
+	if _, err := client.UploadPart(ctx, "songs/2/audio.wav", uploadID, 4, strings.NewReader("x"), 1); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("UploadPart() with another key error = %v, want ErrUploadNotFound", err)
+	}
+
+	if err := client.CompleteMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+
+	reader, info, err := client.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer reader.Close()
+	body, _ := io.ReadAll(reader)
+	if string(body) != "RIFF----WAVE" || info.ContentType != "audio/wav" {
+		t.Errorf("Get() = %q %+v", body, info)
+	}
+
+	if err := client.AbortMultipartUpload(ctx, key, uploadID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("AbortMultipartUpload() after complete error = %v, want ErrUploadNotFound", err)
+	}
+	if _, err := client.Stat(ctx, ".uploads/"+uploadID+"/1"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Stat() on upload part error = %v, want ErrInvalidKey", err)
+	}
+}
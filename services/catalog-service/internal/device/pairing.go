@@ -0,0 +1,199 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// codeAlphabet leaves out 0, 1, I and O, which are easily confused.
+	// Its 32 letters divide 256, so random bytes map to them evenly.
+	codeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	codeLength   = 6
+	// codeAttempts bounds the retries when a new code is already pending
+	codeAttempts = 5
+)
+
+// Initiate starts pairing the mobile device deviceID of userID with a
+// browser. Pending pairings the device started before are expired, so only
+// the newest code works.
+func (s *Store) Initiate(ctx context.Context, userID, deviceID string) (*models.DevicePairing, error) {
+	device, err := s.Get(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.DeviceType != models.DeviceMobile {
+		return nil, fmt.Errorf("%w: pairing is started on a mobile device", ErrInvalid)
+	}
+
+	db := s.db.GetDB().WithContext(ctx)
+	err = db.Model(&models.DevicePairing{}).
+		Where("mobile_device_id = ? AND status = ?", device.ID, models.PairingPending).
+		Update("status", models.PairingExpired).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for range codeAttempts {
+		code, err := newCode()
+		if err != nil {
+			return nil, err
+		}
+		var pending int64
+		err = db.Model(&models.DevicePairing{}).Where("pair_code = ? AND status = ?", code, models.PairingPending).Count(&pending).Error
+		if err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			continue
+		}
+
+		pairing := &models.DevicePairing{
+			UserID:         userID,
+			MobileDeviceID: device.ID,
+			PairCode:       code,
+			Status:         models.PairingPending,
+			ExpiresAt:      time.Now().Add(PairingTTL),
+		}
+		if err := db.Create(pairing).Error; err != nil {
+			return nil, err
+		}
+		if _, err := s.Touch(ctx, userID, device.ID); err != nil {
+			return nil, err
+		}
+		return pairing, nil
+	}
+	return nil, fmt.Errorf("no free pair code after %d attempts", codeAttempts)
+}
+
+// Verify completes the pending pairing of userID with code by pairing it
+// with the browser device deviceID. Each code can be used once.
+func (s *Store) Verify(ctx context.Context, userID, deviceID, code string) (*models.DevicePairing, error) {
+	device, err := s.Get(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.DeviceType != models.DeviceBrowser {
+		return nil, fmt.Errorf("%w: pairing is completed on a browser", ErrInvalid)
+	}
+	code = NormalizeCode(code)
+	if len(code) != codeLength {
+		return nil, ErrCodeNotFound
+	}
+
+	db := s.db.GetDB().WithContext(ctx)
+	var pairing models.DevicePairing
+	result := db.Where("user_id = ? AND pair_code = ?", userID, code).Order("created_at DESC").Limit(1).Find(&pairing)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCodeNotFound
+	}
+
+	now := time.Now()
+	result = db.Model(&models.DevicePairing{}).
+		Where("id = ? AND status = ? AND expires_at > ?", pairing.ID, models.PairingPending, now).
+		Updates(map[string]interface{}{"status": models.PairingPaired, "browser_device_id": device.ID, "paired_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Reload, since another request may have just used the code
+		if err := db.Select("status").First(&pairing, "id = ?", pairing.ID).Error; err != nil {
+			return nil, err
+		}
+		if pairing.Status == models.PairingPaired {
+			return nil, ErrCodeUsed
+		}
+		return nil, ErrCodeExpired
+	}
+
+	if _, err := s.Touch(ctx, userID, device.ID); err != nil {
+		return nil, err
+	}
+	pairing.Status, pairing.BrowserDeviceID, pairing.PairedAt = models.PairingPaired, &device.ID, &now
+	return &pairing, nil
+}
+
+// pairedDevicesJoin restricts a query of pairings to those whose devices
+// have not been revoked
+const pairedDevicesJoin = "JOIN devices mobile ON mobile.id = device_pairings.mobile_device_id AND mobile.deleted_at IS NULL " +
+	"JOIN devices browser ON browser.id = device_pairings.browser_device_id AND browser.deleted_at IS NULL"
+
+// Paired reports whether devices a and b of userID completed a pairing
+// with each other and neither has been revoked since
+func (s *Store) Paired(ctx context.Context, userID, a, b string) (bool, error) {
+	for _, id := range []string{a, b} {
+		if _, err := uuid.Parse(id); err != nil {
+			return false, nil
+		}
+	}
+	var count int64
+	err := s.db.GetDB().WithContext(ctx).Model(&models.DevicePairing{}).Joins(pairedDevicesJoin).
+		Where("device_pairings.user_id = ? AND device_pairings.status = ?", userID, models.PairingPaired).
+		Where("(device_pairings.mobile_device_id = ? AND device_pairings.browser_device_id = ?) OR (device_pairings.mobile_device_id = ? AND device_pairings.browser_device_id = ?)",
+			a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Pairing loads a completed pairing of userID whose devices have not been
+// revoked
+func (s *Store) Pairing(ctx context.Context, userID, pairingID string) (*models.DevicePairing, error) {
+	if _, err := uuid.Parse(pairingID); err != nil {
+		return nil, ErrPairingNotFound
+	}
+	var pairing models.DevicePairing
+	result := s.db.GetDB().WithContext(ctx).Model(&models.DevicePairing{}).Joins(pairedDevicesJoin).
+		Where("device_pairings.id = ? AND device_pairings.user_id = ? AND device_pairings.status = ?", pairingID, userID, models.PairingPaired).
+		Limit(1).Find(&pairing)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPairingNotFound
+	}
+	return &pairing, nil
+}
+
+// FormatCode shows a code in two groups, e.g. 89XK-P2
+func FormatCode(code string) string {
+	if len(code) != codeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// NormalizeCode reads a code as typed by a user: case, spaces and dashes
+// are ignored
+func NormalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r == ' ' || r == '-':
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// newCode returns a random code of codeLength letters of codeAlphabet
+func newCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,43 @@
+package device
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewCode(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		code, err := newCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != codeLength || strings.Trim(code, codeAlphabet) != "" {
+			t.Fatalf("newCode() = %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 99 {
+		t.Errorf("%d distinct codes of 100", len(seen))
+	}
+}
+
+func TestCodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		typed string
+		want  string
+	}{
+		{"89XK-P2", "89XKP2"},
+		{"89xkp2", "89XKP2"},
+		{" 89xk - p2 ", "89XKP2"},
+		{"89XK-P2-X", "89XKP2X"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeCode(tt.typed); got != tt.want {
+			t.Errorf("NormalizeCode(%q) = %q, want %q", tt.typed, got, tt.want)
+		}
+	}
+	if got := FormatCode("89XKP2"); got != "89XK-P2" {
+		t.Errorf("FormatCode = %q", got)
+	}
+}
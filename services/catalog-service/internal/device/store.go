@@ -0,0 +1,180 @@
+// Package device keeps the registry of the phones and browsers users sign
+// in on and pairs a phone with a browser. The phone starts a pairing and
+// shows a short code, also as a QR payload; the browser completes it by
+// sending the code back. Codes expire after a few minutes and can only be
+// used once: completing a pairing is a single conditional update, so two
+// browsers racing with the same code cannot both succeed.
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// PairingTTL is how long a pair code can be used
+const PairingTTL = 5 * time.Minute
+
+// maxNameLength bounds device names
+const maxNameLength = 64
+
+var (
+	ErrNotFound = errors.New("device not found")
+	ErrInvalid  = errors.New("invalid device")
+	// ErrCodeNotFound is returned for codes that were never issued to the
+	// user
+	ErrCodeNotFound = errors.New("pair code not found")
+	ErrCodeExpired  = errors.New("pair code has expired")
+	ErrCodeUsed     = errors.New("pair code has already been used")
+	// ErrPairingNotFound is returned for pairings that are not paired, or
+	// whose devices were revoked
+	ErrPairingNotFound = errors.New("pairing not found")
+)
+
+// Store reads and changes devices and pairings
+type Store struct {
+	db database.Service
+}
+
+// NewStore creates a device store
+func NewStore(db database.Service) *Store {
+	return &Store{db: db}
+}
+
+// Register adds a device for userID
+func (s *Store) Register(ctx context.Context, userID, deviceType, name string) (*models.Device, error) {
+	if deviceType != models.DeviceMobile && deviceType != models.DeviceBrowser {
+		return nil, fmt.Errorf("%w: device_type must be mobile or browser", ErrInvalid)
+	}
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	device := &models.Device{UserID: userID, DeviceType: deviceType, DeviceName: name, LastOnlineAt: &now}
+	if err := s.db.GetDB().WithContext(ctx).Create(device).Error; err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// List returns the devices of userID, most recently online first
+func (s *Store) List(ctx context.Context, userID string) ([]models.Device, error) {
+	devices := []models.Device{}
+	err := s.db.GetDB().WithContext(ctx).Where("user_id = ?", userID).
+		Order("last_online_at DESC NULLS LAST").Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// Get loads a device of userID
+func (s *Store) Get(ctx context.Context, userID, deviceID string) (*models.Device, error) {
+	if _, err := uuid.Parse(deviceID); err != nil {
+		return nil, ErrNotFound
+	}
+	var device models.Device
+	result := s.db.GetDB().WithContext(ctx).Where("id = ? AND user_id = ?", deviceID, userID).Limit(1).Find(&device)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return &device, nil
+}
+
+// Rename changes the name of a device of userID
+func (s *Store) Rename(ctx context.Context, userID, deviceID, name string) (*models.Device, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	device, err := s.Get(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.GetDB().WithContext(ctx).Model(device).Update("device_name", name).Error; err != nil {
+		return nil, err
+	}
+	device.DeviceName = name
+	return device, nil
+}
+
+// Revoke removes a device of userID and expires the pairings it started
+// that are still pending
+func (s *Store) Revoke(ctx context.Context, userID, deviceID string) error {
+	device, err := s.Get(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+	tx := s.db.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	err = tx.Model(&models.DevicePairing{}).
+		Where("mobile_device_id = ? AND status = ?", device.ID, models.PairingPending).
+		Update("status", models.PairingExpired).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Delete(device).Error; err != nil {
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Touch marks a device of userID as online now
+func (s *Store) Touch(ctx context.Context, userID, deviceID string) (*models.Device, error) {
+	device, err := s.Get(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.db.GetDB().WithContext(ctx).Model(device).Update("last_online_at", now).Error; err != nil {
+		return nil, err
+	}
+	device.LastOnlineAt = &now
+	return device, nil
+}
+
+// ExpirePairings marks pending pairings past their expiry as expired and
+// returns how many there were
+func (s *Store) ExpirePairings(ctx context.Context) (int64, error) {
+	result := s.db.GetDB().WithContext(ctx).Model(&models.DevicePairing{}).
+		Where("status = ? AND expires_at <= ?", models.PairingPending, time.Now()).
+		Update("status", models.PairingExpired)
+	return result.RowsAffected, result.Error
+}
+
+// RunExpiry calls ExpirePairings every interval until ctx is cancelled
+func (s *Store) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpirePairings(ctx)
+			if err != nil {
+				log.Printf("Failed to expire device pairings: %v", err)
+			} else if expired > 0 {
+				log.Printf("Expired %d device pairings", expired)
+			}
+		}
+	}
+}
+
+func validateName(name string) error {
+	if name == "" || len(name) > maxNameLength {
+		return fmt.Errorf("%w: device_name must have 1 to %d characters", ErrInvalid, maxNameLength)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package follow records which artists users follow and keeps each artist's
+// follower count equal to its number of follows. A follow or unfollow
+// changes the count in the same transaction, and only when it actually
+// added or removed the follow, so repeated requests leave the count alone.
+// Reconcile recomputes the counts from the follows to repair drift, e.g.
+// from rows removed outside the API.
+package follow
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+)
+
+// ReconcileInterval is how often follower counts are recomputed
+const ReconcileInterval = 24 * time.Hour
+
+// ErrNotFound is returned when following an artist that does not exist
+var ErrNotFound = errors.New("artist not found")
+
+// Store records follows
+type Store struct {
+	db database.Service
+}
+
+// NewStore creates a follow store
+func NewStore(db database.Service) *Store {
+	return &Store{db: db}
+}
+
+// Follow makes userID follow artistID and returns the artist's follower
+// count. Following an artist again changes nothing.
+func (s *Store) Follow(ctx context.Context, userID, artistID string) (int64, error) {
+	return s.change(ctx, artistID, 1,
+		"INSERT INTO user_follows_artist (user_id, artist_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		userID, artistID, time.Now())
+}
+
+// Unfollow makes userID stop following artistID and returns the artist's
+// follower count. Unfollowing an artist that is not followed changes
+// nothing.
+func (s *Store) Unfollow(ctx context.Context, userID, artistID string) (int64, error) {
+	return s.change(ctx, artistID, -1,
+		"DELETE FROM user_follows_artist WHERE user_id = ? AND artist_id = ?",
+		userID, artistID)
+}
+
+// change runs the statement adding or removing a follow and, when it
+// changed a row, moves the artist's follower count by delta in the same
+// transaction. Deleted artists can be unfollowed but not followed.
+func (s *Store) change(ctx context.Context, artistID string, delta int, statement string, args ...interface{}) (int64, error) {
+	tx := s.db.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	defer tx.Rollback()
+
+	artists := tx.Model(&models.Artist{})
+	if delta < 0 {
+		artists = artists.Unscoped()
+	}
+	var followers []int64
+	if err := artists.Where("id = ?", artistID).Pluck("followers", &followers).Error; err != nil {
+		return 0, err
+	}
+	if len(followers) == 0 {
+		return 0, ErrNotFound
+	}
+
+	result := tx.Exec(statement, args...)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		// Increment in SQL so concurrent changes of the count add up
+		err := tx.Raw("UPDATE artists SET followers = followers + ? WHERE id = ? RETURNING followers", delta, artistID).
+			Scan(&followers).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return followers[0], nil
+}
+
+// Reconcile sets every artist's follower count to its number of follows
+// and returns how many counts were wrong
+func (s *Store) Reconcile(ctx context.Context) (int64, error) {
+	const count = "(SELECT count(*) FROM user_follows_artist WHERE user_follows_artist.artist_id = artists.id)"
+	result := s.db.GetDB().WithContext(ctx).Exec("UPDATE artists SET followers = " + count + " WHERE followers <> " + count)
+	return result.RowsAffected, result.Error
+}
+
+// RunReconcile calls Reconcile now and then every interval until ctx is
+// cancelled
+func (s *Store) RunReconcile(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fixed, err := s.Reconcile(ctx)
+		if err != nil {
+			log.Printf("Failed to reconcile follower counts: %v", err)
+		} else if fixed > 0 {
+			log.Printf("Corrected the follower counts of %d artists", fixed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package follow
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go-audio-stream/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// followDB answers the statements of Store.change from memory
+type followDB struct {
+	followers map[string]int64
+	deleted   map[string]bool
+	follows   map[[2]string]bool
+	// increments counts the follower count updates
+	increments int
+}
+
+func (d *followDB) Connect(context.Context) (driver.Conn, error) { return followConn{d}, nil }
+func (d *followDB) Driver() driver.Driver                        { return nil }
+
+type followConn struct {
+	db *followDB
+}
+
+func (followConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (followConn) Close() error              { return nil }
+func (followConn) Begin() (driver.Tx, error) { return followConn{}, nil }
+func (followConn) Commit() error             { return nil }
+func (followConn) Rollback() error           { return nil }
+
+func (c followConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	follow := [2]string{args[0].Value.(string), args[1].Value.(string)}
+	changed := false
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO user_follows_artist"):
+		changed = !c.db.follows[follow]
+		c.db.follows[follow] = true
+	case strings.HasPrefix(query, "DELETE FROM user_follows_artist"):
+		changed = c.db.follows[follow]
+		delete(c.db.follows, follow)
+	default:
+		return nil, fmt.Errorf("unexpected statement %s", query)
+	}
+	if changed {
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c followConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT `followers` FROM `artists`"):
+		id := args[0].Value.(string)
+		count, ok := c.db.followers[id]
+		if !ok || c.db.deleted[id] && strings.Contains(query, "deleted_at") {
+			return &followRows{}, nil
+		}
+		return &followRows{values: []int64{count}}, nil
+	case strings.HasPrefix(query, "UPDATE artists SET followers = followers + ?"):
+		id := args[1].Value.(string)
+		c.db.followers[id] += args[0].Value.(int64)
+		c.db.increments++
+		return &followRows{values: []int64{c.db.followers[id]}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query %s", query)
+}
+
+// followRows returns values as a followers column
+type followRows struct {
+	values []int64
+}
+
+func (r *followRows) Columns() []string { return []string{"followers"} }
+func (r *followRows) Close() error      { return nil }
+
+func (r *followRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+// followService serves a gorm DB over a followDB
+type followService struct {
+	database.Service
+	db *gorm.DB
+}
+
+func (s followService) GetDB() *gorm.DB {
+	return s.db
+}
+
+func newTestStore(t *testing.T, db *followDB) *Store {
+	t.Helper()
+	pool := sql.OpenDB(db)
+	t.Cleanup(func() { pool.Close() })
+	gormDB, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{ConnPool: pool})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return NewStore(followService{db: gormDB})
+}
+
+func TestFollowIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := &followDB{
+		followers: map[string]int64{"a1": 4, "gone": 1},
+		deleted:   map[string]bool{"gone": true},
+		follows:   map[[2]string]bool{{"u1", "gone"}: true},
+	}
+	store := newTestStore(t, db)
+
+	for _, step := range []struct {
+		name  string
+		call  func(ctx context.Context, userID, artistID string) (int64, error)
+		want  int64
+		count int
+	}{
+		{"Follow", store.Follow, 5, 1},
+		{"Follow again", store.Follow, 5, 1},
+		{"Unfollow", store.Unfollow, 4, 2},
+		{"Unfollow again", store.Unfollow, 4, 2},
+	} {
+		followers, err := step.call(ctx, "u1", "a1")
+		if err != nil {
+			t.Fatalf("%s() error = %v", step.name, err)
+		}
+		if followers != step.want || db.increments != step.count {
+			t.Errorf("%s() = %d followers after %d updates, want %d after %d", step.name, followers, db.increments, step.want, step.count)
+		}
+	}
+
+	// Deleted artists can be unfollowed but not followed
+	if _, err := store.Follow(ctx, "u2", "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Follow() of a deleted artist error = %v, want ErrNotFound", err)
+	}
+	if followers, err := store.Unfollow(ctx, "u1", "gone"); err != nil || followers != 0 {
+		t.Errorf("Unfollow() of a deleted artist = %d, %v", followers, err)
+	}
+	if _, err := store.Follow(ctx, "u1", "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Follow() of an unknown artist error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/query"
+	"go-audio-stream/pkg/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// releaseDateLayout is the format of album release dates in requests
+const releaseDateLayout = "2006-01-02"
+
+// upcPattern matches 12-digit UPC-A and 13-digit EAN-13 barcodes
+var upcPattern = regexp.MustCompile(`^[0-9]{12,13}$`)
+
+// AlbumRequest creates or updates an album. Omitted fields are left
+// unchanged on update; artist_ids replaces the credited artists.
+type AlbumRequest struct {
+	Title       *string  `json:"title"`
+	ReleaseDate *string  `json:"release_date" example:"2016-11-11"`
+	Type        *string  `json:"type" enums:"album,single,ep,compilation"`
+	Label       *string  `json:"label"`
+	UPC         *string  `json:"upc"`
+	Image       *string  `json:"image"`
+	DiscCount   *int16   `json:"disc_count"`
+	ArtistIDs   []string `json:"artist_ids"`
+}
+
+// apply copies the set fields onto album, validating them
+func (r *AlbumRequest) apply(album *models.Album) error {
+	if r.Title != nil {
+		album.Title = *r.Title
+	}
+	if r.ReleaseDate != nil {
+		if *r.ReleaseDate == "" {
+			album.ReleaseDate = nil
+		} else {
+			date, err := time.Parse(releaseDateLayout, *r.ReleaseDate)
+			if err != nil {
+				return fmt.Errorf("release_date must be YYYY-MM-DD")
+			}
+			album.ReleaseDate = &date
+		}
+	}
+	if r.Type != nil {
+		album.Type = models.AlbumType(*r.Type)
+	}
+	if r.Label != nil {
+		album.Label = *r.Label
+	}
+	if r.UPC != nil {
+		album.UPC = *r.UPC
+	}
+	if r.Image != nil {
+		album.Image = *r.Image
+	}
+	if r.DiscCount != nil {
+		album.DiscCount = *r.DiscCount
+	}
+
+	if album.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if album.Type == "" {
+		album.Type = models.AlbumTypeAlbum
+	}
+	if !album.Type.Valid() {
+		return fmt.Errorf("type must be album, single, ep or compilation")
+	}
+	if album.UPC != "" && !upcPattern.MatchString(album.UPC) {
+		return fmt.Errorf("upc must be 12 or 13 digits")
+	}
+	if album.DiscCount == 0 {
+		album.DiscCount = 1
+	}
+	if album.DiscCount < 1 {
+		return fmt.Errorf("disc_count must be at least 1")
+	}
+	return nil
+}
+
+// findArtists loads the artists with ids, failing when any is missing
+func findArtists(db database.Service, ids []string) ([]models.Artist, error) {
+	artists := []models.Artist{}
+	if len(ids) == 0 {
+		return artists, nil
+	}
+	if _, err := db.Find(&artists, "id IN ?", ids); err != nil {
+		return nil, err
+	}
+	if len(artists) != len(ids) {
+		return nil, fmt.Errorf("unknown artist in artist_ids")
+	}
+	return artists, nil
+}
+
+// CreateAlbumHandler creates a new album.
+// @Summary      Create a new album
+// @Description  Create an album credited to the given artists
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        album  body      AlbumRequest  true  "Album Data"
+// @Success      201    {object}  models.Album
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/albums/ [post]
+func CreateAlbumHandler(c echo.Context, db database.Service) error {
+	req := new(AlbumRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	album := new(models.Album)
+	if err := req.apply(album); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	artists, err := findArtists(db, req.ArtistIDs)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	gormDB := db.GetDB()
+	if err := gormDB.Omit("Artists", "Songs").Create(album).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(artists) > 0 {
+		if err := gormDB.Model(album).Omit("Artists.*").Association("Artists").Replace(artists); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	album.Artists = artists
+
+	return c.JSON(http.StatusCreated, album)
+}
+
+// UpdateAlbumHandler updates an existing album.
+// @Summary      Update an album
+// @Description  Update an album's details and credited artists
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string        true  "Album ID"
+// @Param        album  body      AlbumRequest  true  "Album Data"
+// @Success      200    {object}  models.Album
+// @Failure      400    {object}  map[string]string
+// @Failure      404    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/albums/{id} [put]
+func UpdateAlbumHandler(c echo.Context, db database.Service) error {
+	id := c.Param("id")
+	album := new(models.Album)
+
+	result, err := db.Find(album, "id = ?", id)
+	if err != nil || result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Album not found"})
+	}
+
+	req := new(AlbumRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if err := req.apply(album); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	gormDB := db.GetDB()
+	if err := gormDB.Omit("Artists", "Songs").Save(album).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if req.ArtistIDs != nil {
+		artists, err := findArtists(db, req.ArtistIDs)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		if err := gormDB.Model(album).Omit("Artists.*").Association("Artists").Replace(artists); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	if err := gormDB.Model(album).Association("Artists").Find(&album.Artists); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, album)
+}
+
+// DeleteAlbumHandler deletes an album. Its songs are kept and detached.
+// @Summary      Delete an album
+// @Description  Delete an album by ID; its songs are kept without an album
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Album ID"
+// @Success      200  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/albums/{id} [delete]
+func DeleteAlbumHandler(c echo.Context, db database.Service) error {
+	id := c.Param("id")
+
+	tx := db.GetDB().Begin()
+	err := tx.Model(&models.Song{}).Where("album_id = ?", id).Updates(map[string]interface{}{
+		"album_id":     nil,
+		"disc_number":  nil,
+		"track_number": nil,
+	}).Error
+	if err == nil {
+		err = tx.Exec("DELETE FROM album_artist WHERE album_id = ?", id).Error
+	}
+	if err == nil {
+		err = tx.Delete(&models.Album{}, "id = ?", id).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Album deleted successfully"})
+}
+
+// FindOneAlbumById retrieves an album with its credited artists and its
+// tracklist in disc and track order.
+// @Summary      Get an album
+// @Description  Get an album with its artists and tracklist
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string  true   "Album ID"
+// @Param        fields  query     string  false  "Comma-separated JSON keys to return"
+// @Success      200     {object}  models.Album
+// @Failure      404     {object}  map[string]string
+// @Router       /api/v1/albums/{id} [get]
+func FindOneAlbumById(c echo.Context, db database.Service) error {
+	id := c.Param("id")
+	var album models.Album
+
+	result := db.GetDB().Preload("Artists").Preload("Songs.Credits.Artist").Limit(1).Find(&album, "id = ?", id)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Album not found"})
+	}
+	sortTracklist(album.Songs)
+	for i := range album.Songs {
+		sortCredits(album.Songs[i].Credits)
+	}
+
+	return jsonFields(c, http.StatusOK, album)
+}
+
+// sortTracklist orders songs by disc and track number; unnumbered songs go
+// last, by name
+func sortTracklist(songs []models.Song) {
+	number := func(n *int16) int {
+		if n == nil {
+			return math.MaxInt16 + 1
+		}
+		return int(*n)
+	}
+	slices.SortStableFunc(songs, func(a, b models.Song) int {
+		return cmp.Or(
+			cmp.Compare(number(a.DiscNumber), number(b.DiscNumber)),
+			cmp.Compare(number(a.TrackNumber), number(b.TrackNumber)),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// FindAllAlbums retrieves a page of albums, most recently added first.
+// @Summary      Get all albums
+// @Description  Get a page of albums with their artists; the envelope's pagination holds the next cursor
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor  query     string  false  "next_cursor of the previous page"
+// @Param        sort       query     string  false  "title or created_at, prefixed with - for descending"
+// @Param        type       query     string  false  "album, single, ep or compilation"
+// @Param        label      query     string  false  "Label"
+// @Param        artist_id  query     string  false  "Credited artist ID"
+// @Param        include  query     string  false  "Comma-separated relations: songs, songs.artists"
+// @Param        fields   query     string  false  "Comma-separated JSON keys to return"
+// @Success      200  {array}   models.Album
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/albums/ [get]
+func FindAllAlbums(c echo.Context, db database.Service) error {
+	var albums []models.Album
+	tx, err := query.Preload(db.GetDB().Preload("Artists"), albumIncludes, c.QueryParam("include"))
+	if err != nil {
+		return queryError(c, err)
+	}
+	page, err := query.Find(tx, &albums, albumListSpec, c.QueryParams())
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	setPagination(c, page)
+	return jsonFields(c, http.StatusOK, albums)
+}
+
+// FindArtistAlbums retrieves the albums credited to an artist, newest
+// release first.
+// @Summary      Get an artist's albums
+// @Description  Get the albums credited to an artist sorted by release date
+// @Tags         artists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Artist ID"
+// @Success      200  {array}   models.Album
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/artists/{id}/albums [get]
+func FindArtistAlbums(c echo.Context, db database.Service) error {
+	artistID := c.Param("id")
+	var albums []models.Album
+
+	err := db.GetDB().Preload("Artists").
+		Where("id IN (SELECT album_id FROM album_artist WHERE artist_id = ?)", artistID).
+		Order("release_date DESC NULLS LAST, title").
+		Find(&albums).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, albums)
+}
+
+// AlbumTrackRequest places a song on an album
+type AlbumTrackRequest struct {
+	SongID      string `json:"song_id"`
+	DiscNumber  int16  `json:"disc_number"`
+	TrackNumber int16  `json:"track_number"`
+}
+
+// AddSongToAlbumHandler places a song on an album's tracklist.
+// @Summary      Add song to album
+// @Description  Set a song's album, disc and track number
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string             true  "Album ID"
+// @Param        req  body      AlbumTrackRequest  true  "Track Data"
+// @Success      200  {object}  models.Song
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/albums/{id}/songs [post]
+func AddSongToAlbumHandler(c echo.Context, db database.Service) error {
+	albumID := c.Param("id")
+	req := new(AlbumTrackRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if req.DiscNumber == 0 {
+		req.DiscNumber = 1
+	}
+
+	var album models.Album
+	result, err := db.Find(&album, "id = ?", albumID)
+	if err != nil || result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Album not found"})
+	}
+	if req.DiscNumber < 1 || req.DiscNumber > album.DiscCount {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": fmt.Sprintf("disc_number must be between 1 and %d", album.DiscCount)})
+	}
+	if req.TrackNumber < 1 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "track_number must be at least 1"})
+	}
+
+	var song models.Song
+	result, err = db.Find(&song, "id = ?", req.SongID)
+	if err != nil || result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Song not found"})
+	}
+
+	song.AlbumID = &album.ID
+	song.DiscNumber = &req.DiscNumber
+	song.TrackNumber = &req.TrackNumber
+	err = db.GetDB().Model(&song).Updates(map[string]interface{}{
+		"album_id":     album.ID,
+		"disc_number":  req.DiscNumber,
+		"track_number": req.TrackNumber,
+	}).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, song)
+}
+
+// RemoveSongFromAlbumHandler takes a song off an album's tracklist.
+// @Summary      Remove song from album
+// @Description  Detach a song from an album
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string  true  "Album ID"
+// @Param        song_id  path      string  true  "Song ID"
+// @Success      200      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/albums/{id}/songs/{song_id} [delete]
+func RemoveSongFromAlbumHandler(c echo.Context, db database.Service) error {
+	albumID := c.Param("id")
+	songID := c.Param("song_id")
+
+	err := db.GetDB().Model(&models.Song{}).Where("id = ? AND album_id = ?", songID, albumID).Updates(map[string]interface{}{
+		"album_id":     nil,
+		"disc_number":  nil,
+		"track_number": nil,
+	}).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Song removed from album"})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// catalogDB runs queries dry, finds rows by ID in its maps and records
+// the credits created
+type catalogDB struct {
+	database.Service
+	db      *gorm.DB
+	albums  map[string]models.Album
+	songs   map[string]models.Song
+	artists map[string]models.Artist
+	credits []models.SongCredit
+}
+
+func newCatalogDB(t *testing.T) *catalogDB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return &catalogDB{
+		db:      db,
+		albums:  map[string]models.Album{},
+		songs:   map[string]models.Song{},
+		artists: map[string]models.Artist{},
+	}
+}
+
+func (d *catalogDB) GetDB() *gorm.DB {
+	return d.db
+}
+
+// Find looks up dest by the last condition, the ID; credits are found by
+// song ID
+func (d *catalogDB) Find(dest interface{}, conditions ...interface{}) (*gorm.DB, error) {
+	id, _ := conditions[len(conditions)-1].(string)
+	found := false
+	switch dest := dest.(type) {
+	case *models.Album:
+		*dest, found = d.albums[id]
+	case *models.Song:
+		*dest, found = d.songs[id]
+	case *models.Artist:
+		*dest, found = d.artists[id]
+	case *[]models.SongCredit:
+		for _, credit := range d.credits {
+			if credit.SongID == id {
+				*dest = append(*dest, credit)
+			}
+		}
+		return &gorm.DB{RowsAffected: int64(len(*dest))}, nil
+	}
+	if !found {
+		return &gorm.DB{}, nil
+	}
+	return &gorm.DB{RowsAffected: 1}, nil
+}
+
+func (d *catalogDB) Create(value interface{}) (*gorm.DB, error) {
+	if credit, ok := value.(*models.SongCredit); ok {
+		d.credits = append(d.credits, *credit)
+	}
+	return &gorm.DB{RowsAffected: 1}, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestAlbumRequestApply(t *testing.T) {
+	released := time.Date(2016, 11, 11, 0, 0, 0, 0, time.UTC)
+	existing := models.Album{Title: "Blonde", ReleaseDate: &released, Type: models.AlbumTypeAlbum, Label: "Boys Don't Cry", DiscCount: 1}
+
+	cases := []struct {
+		name    string
+		album   models.Album
+		req     AlbumRequest
+		want    models.Album
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			req:  AlbumRequest{Title: ptr("Blonde")},
+			want: models.Album{Title: "Blonde", Type: models.AlbumTypeAlbum, DiscCount: 1},
+		},
+		{
+			name: "sets fields",
+			req:  AlbumRequest{Title: ptr("Blonde"), ReleaseDate: ptr("2016-08-20"), Type: ptr("ep"), UPC: ptr("0602547951697"), DiscCount: ptr[int16](2)},
+			want: models.Album{Title: "Blonde", ReleaseDate: ptr(time.Date(2016, 8, 20, 0, 0, 0, 0, time.UTC)), Type: models.AlbumTypeEP, UPC: "0602547951697", DiscCount: 2},
+		},
+		{
+			name:  "keeps omitted fields",
+			album: existing,
+			req:   AlbumRequest{Label: ptr("XL")},
+			want:  models.Album{Title: "Blonde", ReleaseDate: &released, Type: models.AlbumTypeAlbum, Label: "XL", DiscCount: 1},
+		},
+		{
+			name:  "clears release date",
+			album: existing,
+			req:   AlbumRequest{ReleaseDate: ptr("")},
+			want:  models.Album{Title: "Blonde", Type: models.AlbumTypeAlbum, Label: "Boys Don't Cry", DiscCount: 1},
+		},
+		{name: "requires title", req: AlbumRequest{Label: ptr("XL")}, wantErr: "title is required"},
+		{name: "clearing title", album: existing, req: AlbumRequest{Title: ptr("")}, wantErr: "title is required"},
+		{name: "bad release date", req: AlbumRequest{Title: ptr("Blonde"), ReleaseDate: ptr("11/11/2016")}, wantErr: "release_date"},
+		{name: "unknown type", req: AlbumRequest{Title: ptr("Blonde"), Type: ptr("mixtape")}, wantErr: "type must be"},
+		{name: "short upc", req: AlbumRequest{Title: ptr("Blonde"), UPC: ptr("12345")}, wantErr: "upc must be"},
+		{name: "negative disc count", req: AlbumRequest{Title: ptr("Blonde"), DiscCount: ptr[int16](-1)}, wantErr: "disc_count must be"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			album := tt.album
+			err := tt.req.apply(&album)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("apply() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if (album.ReleaseDate == nil) != (tt.want.ReleaseDate == nil) || album.ReleaseDate != nil && !album.ReleaseDate.Equal(*tt.want.ReleaseDate) {
+				t.Errorf("apply() release date = %v, want %v", album.ReleaseDate, tt.want.ReleaseDate)
+			}
+			album.ReleaseDate, tt.want.ReleaseDate = nil, nil
+			if album.Title != tt.want.Title || album.Type != tt.want.Type || album.Label != tt.want.Label || album.UPC != tt.want.UPC || album.DiscCount != tt.want.DiscCount {
+				t.Errorf("apply() = %+v, want %+v", album, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortTracklist(t *testing.T) {
+	song := func(name string, disc, track *int16) models.Song {
+		return models.Song{Name: name, DiscNumber: disc, TrackNumber: track}
+	}
+	songs := []models.Song{
+		song("Bonus B", nil, nil),
+		song("Two/1", ptr[int16](2), ptr[int16](1)),
+		song("One/10", ptr[int16](1), ptr[int16](10)),
+		song("Bonus A", nil, nil),
+		song("One/unnumbered", ptr[int16](1), nil),
+		song("One/2", ptr[int16](1), ptr[int16](2)),
+	}
+
+	sortTracklist(songs)
+
+	want := []string{"One/2", "One/10", "One/unnumbered", "Two/1", "Bonus A", "Bonus B"}
+	for i, s := range songs {
+		if s.Name != want[i] {
+			t.Fatalf("sortTracklist() position %d = %s, want order %v", i, s.Name, want)
+		}
+	}
+}
+
+func TestAddSongToAlbumValidatesPosition(t *testing.T) {
+	db := newCatalogDB(t)
+	db.albums["a1"] = models.Album{BaseModel: models.BaseModel{ID: "a1"}, Title: "Blonde", DiscCount: 2}
+	db.songs["s1"] = models.Song{BaseModel: models.BaseModel{ID: "s1"}, Name: "Nikes"}
+
+	e := echo.New()
+	e.POST("/albums/:id/songs", func(c echo.Context) error {
+		return AddSongToAlbumHandler(c, db)
+	})
+	add := func(album, body string) *httptest.ResponseRecorder {
+		return serve(e, http.MethodPost, "/albums/"+album+"/songs", map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON}, body)
+	}
+
+	for _, tt := range []struct {
+		album, body string
+		want        int
+	}{
+		{"a1", `{"song_id": "s1", "disc_number": 3, "track_number": 1}`, http.StatusBadRequest},
+		{"a1", `{"song_id": "s1", "disc_number": -1, "track_number": 1}`, http.StatusBadRequest},
+		{"a1", `{"song_id": "s1", "track_number": 0}`, http.StatusBadRequest},
+		{"a2", `{"song_id": "s1", "track_number": 1}`, http.StatusNotFound},
+		{"a1", `{"song_id": "s2", "track_number": 1}`, http.StatusNotFound},
+	} {
+		if rec := add(tt.album, tt.body); rec.Code != tt.want {
+			t.Errorf("AddSongToAlbumHandler(%s, %s) status = %d, want %d", tt.album, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	// The disc defaults to the first
+	rec := add("a1", `{"song_id": "s1", "track_number": 4}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("AddSongToAlbumHandler() status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var song models.Song
+	if err := json.Unmarshal(rec.Body.Bytes(), &song); err != nil {
+		t.Fatalf("response is not a song: %v", err)
+	}
+	if song.AlbumID == nil || *song.AlbumID != "a1" || song.DiscNumber == nil || *song.DiscNumber != 1 || song.TrackNumber == nil || *song.TrackNumber != 4 {
+		t.Errorf("AddSongToAlbumHandler() placed song at album %v disc %v track %v", song.AlbumID, song.DiscNumber, song.TrackNumber)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/query"
 	"go-audio-stream/pkg/models"
 	"net/http"
 
@@ -64,20 +65,24 @@ func FindOneArtistById(c echo.Context, db database.Service) error {
 	id := c.Param("id")
 	var artist models.Artist
 
-	_, err := db.Find(&artist, "id = ?", id)
-	if err != nil {
+	result := db.GetDB().Limit(1).Find(&artist, "id = ?", id)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "Artist not found"})
 	}
 
-	return c.JSON(http.StatusOK, artist)
+	return jsonFields(c, http.StatusOK, artist)
 }
 
 func FindAllArtists(c echo.Context, db database.Service) error {
 	var artists []models.Artist
-	_, err := db.Find(&artists)
+	page, err := query.Find(db.GetDB(), &artists, artistListSpec, c.QueryParams())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return queryError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, artists)
+	setPagination(c, page)
+	return jsonFields(c, http.StatusOK, artists)
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"go-audio-stream/pkg/media/artwork"
+	"go-audio-stream/pkg/media/sniff"
+	"go-audio-stream/pkg/models"
+)
+
+// primaryImageSize is the JPEG size stored in the plain Image URL of
+// artists, albums, songs and playlists for clients that do not read Images
+const primaryImageSize = 640
+
+// storeArtwork encodes data at every artwork size and uploads the variants
+// publicly as {prefix}/cover/{size}.{jpg,webp}. It returns the variant URLs
+// and the key of the primary JPEG: the largest one up to primaryImageSize.
+func (h *UploadHandler) storeArtwork(ctx context.Context, prefix string, data []byte) (*models.Images, string, error) {
+	result, err := artwork.Process(data, artwork.DefaultSizes)
+	if errors.Is(err, artwork.ErrDecode) {
+		return nil, "", &sniff.Rejection{Reason: sniff.ReasonCorrupt, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	images := &models.Images{
+		DominantColor: result.DominantColor,
+		BlurHash:      result.BlurHash,
+	}
+	var primaryKey string
+	for _, v := range result.Variants {
+		key := fmt.Sprintf("%s/cover/%d%s", prefix, v.Size, v.Format.Extension)
+		uploadedKey, err := h.storage.UploadWithACL(ctx, key, bytes.NewReader(v.Data), v.Format.ContentType, "public-read")
+		if err != nil {
+			return nil, "", fmt.Errorf("upload failed: %w", err)
+		}
+
+		if n := len(images.Variants); n == 0 || images.Variants[n-1].Size != v.Size {
+			images.Variants = append(images.Variants, models.ImageVariant{Size: v.Size})
+		}
+		variant := &images.Variants[len(images.Variants)-1]
+		switch v.Format {
+		case artwork.JPEG:
+			variant.JPEG = h.storage.GetPublicURL(uploadedKey)
+			if primaryKey == "" || v.Size <= primaryImageSize {
+				primaryKey = uploadedKey
+			}
+		case artwork.WebP:
+			variant.WebP = h.storage.GetPublicURL(uploadedKey)
+		}
+	}
+
+	return images, primaryKey, nil
+}
+
+// saveEntityImages stores processed images on the artist, album, song or
+// playlist
+func (h *UploadHandler) saveEntityImages(ctx context.Context, entityType, entityID, url string, images *models.Images) error {
+	var model interface{}
+	switch entityType {
+	case "artist":
+		model = &models.Artist{}
+	case "album":
+		model = &models.Album{}
+	case "song":
+		model = &models.Song{}
+	case "playlist":
+		model = &models.Playlist{}
+	default:
+		return fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	return h.db.GetDB().WithContext(ctx).Model(model).Where("id = ?", entityID).Updates(map[string]interface{}{
+		"image":  url,
+		"images": images,
+	}).Error
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/services/catalog-service/internal/device"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultPairURL is the base of the QR payloads of pair codes when none is
+// configured; the app opens it when the code is scanned
+const DefaultPairURL = "audiostream://pair"
+
+type RegisterDeviceRequest struct {
+	DeviceType string `json:"device_type"`
+	DeviceName string `json:"device_name"`
+}
+
+type RenameDeviceRequest struct {
+	DeviceName string `json:"device_name"`
+}
+
+type InitiatePairingRequest struct {
+	DeviceID string `json:"device_id"`
+}
+
+// PairingResponse is a started pairing. The phone shows PairCode, or
+// QRPayload as a QR code, until it expires.
+type PairingResponse struct {
+	PairingID      string    `json:"pairing_id"`
+	PairCode       string    `json:"pair_code"`
+	QRPayload      string    `json:"qr_payload"`
+	ExpiresIn      int       `json:"expires_in"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	MobileDeviceID string    `json:"mobile_device_id"`
+}
+
+type VerifyPairingRequest struct {
+	PairCode string `json:"pair_code"`
+	DeviceID string `json:"device_id"`
+}
+
+type VerifyPairingResponse struct {
+	Status          string `json:"status"`
+	MobileDeviceID  string `json:"mobile_device_id"`
+	BrowserDeviceID string `json:"browser_device_id"`
+}
+
+// DeviceHandler serves the device registry and pairing
+type DeviceHandler struct {
+	db      database.Service
+	pairURL string
+}
+
+// NewDeviceHandler creates a device handler whose QR payloads open pairURL,
+// or DefaultPairURL when it is empty
+func NewDeviceHandler(db database.Service, pairURL string) *DeviceHandler {
+	if pairURL == "" {
+		pairURL = DefaultPairURL
+	}
+	return &DeviceHandler{
+		db:      db,
+		pairURL: pairURL,
+	}
+}
+
+// Register adds a device for the caller.
+// @Summary      Register a device
+// @Description  Register a phone or browser of the caller
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        device  body      RegisterDeviceRequest  true  "mobile or browser, and a name"
+// @Success      201     {object}  models.Device
+// @Failure      400     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /api/v1/devices [post]
+func (h *DeviceHandler) Register(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	var req RegisterDeviceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	registered, err := device.NewStore(h.db).Register(c.Request().Context(), user.ID, req.DeviceType, req.DeviceName)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusCreated, registered)
+}
+
+// List returns the caller's devices, most recently online first.
+// @Summary      Get devices
+// @Description  Get the caller's devices, most recently online first
+// @Tags         devices
+// @Produce      json
+// @Success      200  {array}   models.Device
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/devices [get]
+func (h *DeviceHandler) List(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	devices, err := device.NewStore(h.db).List(c.Request().Context(), user.ID)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, devices)
+}
+
+// Rename changes the name of one of the caller's devices.
+// @Summary      Rename a device
+// @Description  Change the name of one of the caller's devices
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string               true  "Device ID"
+// @Param        device  body      RenameDeviceRequest  true  "New name"
+// @Success      200     {object}  models.Device
+// @Failure      400     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /api/v1/devices/{id} [put]
+func (h *DeviceHandler) Rename(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	var req RenameDeviceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	renamed, err := device.NewStore(h.db).Rename(c.Request().Context(), user.ID, c.Param("id"), req.DeviceName)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, renamed)
+}
+
+// Revoke removes one of the caller's devices; codes it is showing stop
+// working.
+// @Summary      Revoke a device
+// @Description  Remove one of the caller's devices and expire its pending pair codes
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/devices/{id} [delete]
+func (h *DeviceHandler) Revoke(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	if err := device.NewStore(h.db).Revoke(c.Request().Context(), user.ID, c.Param("id")); err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Device revoked successfully"})
+}
+
+// Heartbeat marks one of the caller's devices as online now.
+// @Summary      Device heartbeat
+// @Description  Set a device's last_online_at to now
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  models.Device
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/devices/{id}/heartbeat [post]
+func (h *DeviceHandler) Heartbeat(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	touched, err := device.NewStore(h.db).Touch(c.Request().Context(), user.ID, c.Param("id"))
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, touched)
+}
+
+// InitiatePairing starts pairing the caller's phone with a browser.
+// @Summary      Start device pairing
+// @Description  Get a pair code for a registered mobile device, valid for 5 minutes; earlier codes of the device stop working
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        pairing  body      InitiatePairingRequest  true  "The phone's device ID"
+// @Success      201      {object}  PairingResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/devices/pair/initiate [post]
+func (h *DeviceHandler) InitiatePairing(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	var req InitiatePairingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	pairing, err := device.NewStore(h.db).Initiate(c.Request().Context(), user.ID, req.DeviceID)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	code := device.FormatCode(pairing.PairCode)
+	return c.JSON(http.StatusCreated, PairingResponse{
+		PairingID:      pairing.ID,
+		PairCode:       code,
+		QRPayload:      h.pairURL + "?code=" + url.QueryEscape(code),
+		ExpiresIn:      int(device.PairingTTL.Seconds()),
+		ExpiresAt:      pairing.ExpiresAt,
+		MobileDeviceID: pairing.MobileDeviceID,
+	})
+}
+
+// VerifyPairing completes a pairing from the browser with the code shown
+// on the phone. Attempts are rate limited per user.
+// @Summary      Complete device pairing
+// @Description  Pair a registered browser with the phone showing the code; each code works once
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        pairing  body      VerifyPairingRequest  true  "Pair code and the browser's device ID"
+// @Success      200      {object}  VerifyPairingResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      410      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/devices/pair/verify [post]
+func (h *DeviceHandler) VerifyPairing(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	var req VerifyPairingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	pairing, err := device.NewStore(h.db).Verify(c.Request().Context(), user.ID, req.DeviceID, req.PairCode)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, VerifyPairingResponse{
+		Status:          pairing.Status,
+		MobileDeviceID:  pairing.MobileDeviceID,
+		BrowserDeviceID: *pairing.BrowserDeviceID,
+	})
+}
+
+// deviceError responds to an error from the device store
+func deviceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, device.ErrNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Device not found"})
+	case errors.Is(err, device.ErrInvalid):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	case errors.Is(err, device.ErrCodeNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Invalid pair code"})
+	case errors.Is(err, device.ErrCodeUsed):
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Pair code has already been used"})
+	case errors.Is(err, device.ErrCodeExpired):
+		return c.JSON(http.StatusGone, echo.Map{"error": "Pair code has expired"})
+	case errors.Is(err, device.ErrPairingNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Pairing not found"})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jsonFields responds with v as JSON. When the request has a fields
+// parameter, a comma-separated list of JSON keys, objects are reduced to
+// those keys plus id; for lists every element is reduced. Relations loaded
+// with include must be listed as well to be kept.
+func jsonFields(c echo.Context, status int, v interface{}) error {
+	fields := c.QueryParam("fields")
+	if fields == "" {
+		return c.JSON(status, v)
+	}
+	keep := map[string]bool{"id": true}
+	for _, field := range strings.Split(fields, ",") {
+		keep[strings.TrimSpace(field)] = true
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// Numbers stay json.Number so large integers keep their precision
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+
+	prune := func(value interface{}) {
+		if object, ok := value.(map[string]interface{}); ok {
+			for key := range object {
+				if !keep[key] {
+					delete(object, key)
+				}
+			}
+		}
+	}
+	if list, ok := decoded.([]interface{}); ok {
+		for _, element := range list {
+			prune(element)
+		}
+	} else {
+		prune(decoded)
+	}
+
+	return c.JSON(status, decoded)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/query"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/follow"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// FollowResponse tells whether the caller follows an artist and how many
+// users do
+type FollowResponse struct {
+	Following bool  `json:"following"`
+	Followers int64 `json:"followers"`
+}
+
+// FollowArtistHandler makes the caller follow an artist. Following an
+// artist again changes nothing.
+// @Summary      Follow an artist
+// @Description  Follow an artist; repeated requests are ignored
+// @Tags         artists
+// @Produce      json
+// @Param        id   path      string  true  "Artist ID"
+// @Success      200  {object}  FollowResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/artists/{id}/follow [post]
+func FollowArtistHandler(c echo.Context, db database.Service) error {
+	return changeFollow(c, db, true)
+}
+
+// UnfollowArtistHandler makes the caller stop following an artist.
+// Unfollowing an artist that is not followed changes nothing.
+// @Summary      Unfollow an artist
+// @Description  Stop following an artist; repeated requests are ignored
+// @Tags         artists
+// @Produce      json
+// @Param        id   path      string  true  "Artist ID"
+// @Success      200  {object}  FollowResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/artists/{id}/follow [delete]
+func UnfollowArtistHandler(c echo.Context, db database.Service) error {
+	return changeFollow(c, db, false)
+}
+
+// FindFollowedArtists retrieves a page of the artists the caller follows.
+// @Summary      Get followed artists
+// @Description  Get a page of the artists the caller follows; the envelope's pagination holds the next cursor
+// @Tags         artists
+// @Produce      json
+// @Param        limit     query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor    query     string  false  "next_cursor of the previous page"
+// @Param        sort      query     string  false  "name, followers or created_at, prefixed with - for descending"
+// @Param        verified  query     bool    false  "Verified artists only, or none"
+// @Param        fields    query     string  false  "Comma-separated JSON keys to return"
+// @Success      200  {array}   models.Artist
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/me/following [get]
+func FindFollowedArtists(c echo.Context, db database.Service) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	var artists []models.Artist
+	followed := db.GetDB().Where("id IN (SELECT artist_id FROM user_follows_artist WHERE user_id = ?)", user.ID)
+	page, err := query.Find(followed, &artists, artistListSpec, c.QueryParams())
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	setPagination(c, page)
+	return jsonFields(c, http.StatusOK, artists)
+}
+
+// changeFollow follows or unfollows the artist in the path for the caller
+func changeFollow(c echo.Context, db database.Service, following bool) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	artistID := c.Param("id")
+	if _, err := uuid.Parse(artistID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid artist ID"})
+	}
+
+	store := follow.NewStore(db)
+	change := store.Unfollow
+	if following {
+		change = store.Follow
+	}
+	followers, err := change(c.Request().Context(), user.ID, artistID)
+	if errors.Is(err, follow.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Artist not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, FollowResponse{Following: following, Followers: followers})
+}
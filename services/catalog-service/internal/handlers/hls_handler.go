@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/hls"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// hlsURLExpiry is how long signed segment URLs in a media playlist stay valid
+const hlsURLExpiry = 6 * time.Hour
+
+// maxPlaylistSize bounds the playlists read back from storage
+const maxPlaylistSize = 1 << 20
+
+var variantName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// HLSHandler serves the HLS playlists produced by the packaging pipeline
+type HLSHandler struct {
+	storage storage.Backend
+	db      database.Service
+}
+
+// NewHLSHandler creates a new HLS handler
+func NewHLSHandler(storageClient storage.Backend, db database.Service) *HLSHandler {
+	return &HLSHandler{
+		storage: storageClient,
+		db:      db,
+	}
+}
+
+// MasterPlaylist serves the multi-bitrate playlist of a song. Variant URIs are
+// relative, so players fetch them through MediaPlaylist. Measured ReplayGain
+// values are added as session data and response headers.
+// @Summary      Get HLS master playlist
+// @Description  Get the adaptive streaming master playlist of a song
+// @Tags         songs
+// @Produce      application/vnd.apple.mpegurl
+// @Param        id   path      string  true  "Song ID"
+// @Success      200  {string}  string
+// @Failure      404  {object}  map[string]string
+// @Router       /api/v1/songs/{id}/stream.m3u8 [get]
+func (h *HLSHandler) MasterPlaylist(c echo.Context) error {
+	songID := c.Param("id")
+	if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID"})
+	}
+
+	setReplayGainHeaders(c.Response().Header(), h.db, songID)
+	sessionData := replayGainSessionData(h.db, songID)
+
+	return h.servePlaylist(c, pipeline.HLSKey(songID, hls.MasterPlaylistName), sessionData, func(uri string) (string, error) {
+		if !variantName.MatchString(strings.TrimSuffix(uri, "/"+hls.MediaPlaylistName)) {
+			return "", fmt.Errorf("unexpected variant URI %q", uri)
+		}
+		return "hls/" + uri, nil
+	})
+}
+
+// MediaPlaylist serves a variant playlist of a song with every segment URI
+// replaced by a signed storage URL.
+// @Summary      Get HLS media playlist
+// @Description  Get one bitrate variant of a song with signed segment URLs
+// @Tags         songs
+// @Produce      application/vnd.apple.mpegurl
+// @Param        id       path      string  true  "Song ID"
+// @Param        variant  path      string  true  "Variant name, e.g. 128k"
+// @Success      200      {string}  string
+// @Failure      404      {object}  map[string]string
+// @Router       /api/v1/songs/{id}/hls/{variant}/playlist.m3u8 [get]
+func (h *HLSHandler) MediaPlaylist(c echo.Context) error {
+	songID := c.Param("id")
+	if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID"})
+	}
+	variant := c.Param("variant")
+	if !variantName.MatchString(variant) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid variant"})
+	}
+
+	ctx := c.Request().Context()
+	return h.servePlaylist(c, pipeline.HLSKey(songID, variant+"/"+hls.MediaPlaylistName), nil, func(uri string) (string, error) {
+		if strings.Contains(uri, "/") || strings.Contains(uri, "..") {
+			return "", fmt.Errorf("unexpected segment URI %q", uri)
+		}
+		return h.storage.GetPresignedURL(ctx, pipeline.HLSKey(songID, variant+"/"+uri), hlsURLExpiry)
+	})
+}
+
+// servePlaylist serves the playlist at key with its URIs rewritten and the
+// extra tag lines appended
+func (h *HLSHandler) servePlaylist(c echo.Context, key string, extra []string, rewrite func(uri string) (string, error)) error {
+	reader, _, err := h.storage.Get(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Stream not available yet"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to read playlist: " + err.Error()})
+	}
+	defer reader.Close()
+
+	playlist, err := io.ReadAll(io.LimitReader(reader, maxPlaylistSize))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to read playlist: " + err.Error()})
+	}
+
+	rewritten, err := hls.RewriteURIs(playlist, rewrite)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to rewrite playlist: " + err.Error()})
+	}
+	for _, line := range extra {
+		rewritten = append(rewritten, line+"\n"...)
+	}
+
+	// Signed URLs expire, so playlists must not be cached
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, hls.PlaylistContentType, rewritten)
+}
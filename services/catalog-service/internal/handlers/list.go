@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-audio-stream/pkg/database/query"
+	"go-audio-stream/pkg/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+// List endpoints accept limit, cursor and sort (a column, prefixed with -
+// for descending order) plus the filters allow-listed here. Unknown
+// parameters are ignored.
+var (
+	songListSpec = query.Spec{
+		Sorts:       []string{"name", "created_at", "duration"},
+		DefaultSort: "name",
+		Filters: map[string]query.Filter{
+			"language":  query.Equal("language"),
+			"explicit":  query.Bool("explicit"),
+			"album_id":  query.Equal("album_id"),
+			"artist_id": query.Where("id IN (SELECT song_id FROM artist_song WHERE artist_id = ?)"),
+		},
+	}
+	artistListSpec = query.Spec{
+		Sorts:       []string{"name", "followers", "created_at"},
+		DefaultSort: "name",
+		Filters: map[string]query.Filter{
+			"verified": query.Bool("verified"),
+		},
+	}
+	playlistListSpec = query.Spec{
+		Sorts:       []string{"name", "created_at"},
+		DefaultSort: "name",
+		Filters: map[string]query.Filter{
+			"private":           query.Bool("private"),
+			"is_collaborative":  query.Bool("is_collaborative"),
+			"creator_user_id":   query.Equal("creator_user_id"),
+			"creator_artist_id": query.Equal("creator_artist_id"),
+		},
+	}
+	albumListSpec = query.Spec{
+		Sorts:       []string{"title", "created_at"},
+		DefaultSort: "-created_at",
+		Filters: map[string]query.Filter{
+			"type":      query.Equal("type"),
+			"label":     query.Equal("label"),
+			"artist_id": query.Where("id IN (SELECT album_id FROM album_artist WHERE artist_id = ?)"),
+		},
+	}
+)
+
+// Relations that reads accept in the include parameter
+var (
+	songIncludes = query.Includes{
+		"artists":     {{Path: "Artists"}},
+		"credits":     {{Path: "Credits", Order: "position"}, {Path: "Credits.Artist"}},
+		"album":       {{Path: "Album"}},
+		"features":    {{Path: "Features"}},
+		"tags":        {{Path: "Tags"}},
+		"instruments": {{Path: "Instruments"}},
+	}
+	playlistIncludes = query.Includes{
+		"songs": {{Path: "PlaylistSongs", Order: "rank, id"}, {Path: "PlaylistSongs.Song"}},
+		"songs.artists": {
+			{Path: "PlaylistSongs", Order: "rank, id"},
+			{Path: "PlaylistSongs.Song"},
+			{Path: "PlaylistSongs.Song.Artists"},
+		},
+	}
+	albumIncludes = query.Includes{
+		"songs":         {{Path: "Songs", Order: "disc_number, track_number, name"}},
+		"songs.artists": {{Path: "Songs", Order: "disc_number, track_number, name"}, {Path: "Songs.Artists"}},
+	}
+)
+
+// queryError responds to an error from the query package
+func queryError(c echo.Context, err error) error {
+	if errors.Is(err, query.ErrInvalid) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+}
+
+// setPagination hands the page metadata to the response middleware, which
+// adds it next to the data
+func setPagination(c echo.Context, page *query.Page) {
+	c.Set(middlewares.PaginationContextKey, &middlewares.Pagination{
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+		Limit:      page.Limit,
+	})
+}
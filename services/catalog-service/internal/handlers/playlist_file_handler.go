@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/transcode"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/playlist"
+	"go-audio-stream/services/catalog-service/internal/playlistfile"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxImportSize bounds uploaded playlist files
+	maxImportSize = 5 << 20
+	// maxImportTracks is the most tracks imported from one file
+	maxImportTracks = 1000
+	// exportURLExpiry is how long the stream URLs of exported playlists stay
+	// valid
+	exportURLExpiry = 24 * time.Hour
+)
+
+// PlaylistImportResponse is the imported playlist with the tracks that
+// became entries, those only found among the user's local songs and those
+// not found at all
+type PlaylistImportResponse struct {
+	Playlist  *models.Playlist `json:"playlist"`
+	Matched   []playlist.Match `json:"matched"`
+	Local     []playlist.Match `json:"local"`
+	Unmatched []playlist.Match `json:"unmatched"`
+}
+
+// PlaylistFileHandler imports and exports playlists as M3U8, XSPF and JSPF
+// files
+type PlaylistFileHandler struct {
+	storage storage.Backend
+	db      database.Service
+}
+
+// NewPlaylistFileHandler creates a playlist file handler. Without storage,
+// exported tracks point to the songs' url.
+func NewPlaylistFileHandler(storageClient storage.Backend, db database.Service) *PlaylistFileHandler {
+	return &PlaylistFileHandler{
+		storage: storageClient,
+		db:      db,
+	}
+}
+
+// Import creates a playlist owned by the caller from an uploaded file.
+// Tracks are matched to catalog songs by title, artist and duration; tracks
+// only matching one of the caller's local songs are reported as local.
+// @Summary      Import playlist
+// @Description  Create a playlist from an M3U8, XSPF or JSPF file, matching its tracks to catalog songs
+// @Tags         playlists
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file     formData  file    true   "Playlist file"
+// @Param        format   formData  string  false  "m3u8, xspf or jspf; detected from the file by default"
+// @Param        name     formData  string  false  "Playlist name; the file's title by default"
+// @Param        private  formData  bool    false  "Create a private playlist"
+// @Success      201      {object}  PlaylistImportResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      413      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/playlists/import [post]
+func (h *PlaylistFileHandler) Import(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "File is required"})
+	}
+	if fileHeader.Size > maxImportSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": fmt.Sprintf("Playlist files are limited to %d MB", maxImportSize>>20)})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	format, err := playlistfile.Detect(fileHeader.Filename, data)
+	if value := c.FormValue("format"); value != "" {
+		format, err = playlistfile.ParseFormat(value)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format must be m3u8, xspf or jspf"})
+	}
+	parsed, err := playlistfile.Parse(data, format)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if len(parsed.Tracks) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Playlist has no tracks"})
+	}
+	if len(parsed.Tracks) > maxImportTracks {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": fmt.Sprintf("Playlists are limited to %d tracks", maxImportTracks)})
+	}
+
+	ctx := c.Request().Context()
+	store := playlist.NewStore(h.db)
+	matches, err := store.MatchTracks(ctx, user.ID, parsed.Tracks)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to match tracks: " + err.Error()})
+	}
+
+	name := c.FormValue("name")
+	if name == "" {
+		name = parsed.Title
+	}
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(fileHeader.Filename), path.Ext(fileHeader.Filename))
+	}
+	imported := &models.Playlist{
+		Name:          name,
+		Description:   parsed.Description,
+		Private:       c.FormValue("private") == "true",
+		CreatorUserID: &user.ID,
+	}
+	if _, err := h.db.Create(imported); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	response := PlaylistImportResponse{Playlist: imported, Matched: []playlist.Match{}, Local: []playlist.Match{}, Unmatched: []playlist.Match{}}
+	var songIDs []string
+	for _, match := range matches {
+		switch {
+		case match.SongID != "":
+			songIDs = append(songIDs, match.SongID)
+			response.Matched = append(response.Matched, match)
+		case match.LocalSongID != "":
+			response.Local = append(response.Local, match)
+		default:
+			response.Unmatched = append(response.Unmatched, match)
+		}
+	}
+	for start := 0; start < len(songIDs); start += playlist.MaxInsert {
+		chunk := songIDs[start:min(start+playlist.MaxInsert, len(songIDs))]
+		imported.SnapshotID, _, err = store.Insert(ctx, imported.ID, "", user.ID, chunk, nil)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to add songs: " + err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+// Export serves a playlist as a file whose tracks point to signed stream
+// URLs, valid for 24 hours.
+// @Summary      Export playlist
+// @Description  Download a playlist as M3U8, XSPF or JSPF with signed stream URLs
+// @Tags         playlists
+// @Produce      audio/x-mpegurl
+// @Produce      application/xspf+xml
+// @Produce      application/jspf+json
+// @Param        id      path      string  true   "Playlist ID"
+// @Param        format  query     string  false  "m3u8 (default), xspf or jspf"
+// @Success      200     {string}  string
+// @Failure      400     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/export [get]
+func (h *PlaylistFileHandler) Export(c echo.Context) error {
+	format := playlistfile.M3U8
+	if value := c.QueryParam("format"); value != "" {
+		var err error
+		if format, err = playlistfile.ParseFormat(value); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "format must be m3u8, xspf or jspf"})
+		}
+	}
+
+	ctx := c.Request().Context()
+	store := playlist.NewStore(h.db)
+	p, _, err := authorizePlaylist(c, store, playlist.View)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	refreshStalePlaylist(c, store, p)
+	_, entries, err := store.Entries(ctx, p.ID)
+	if err != nil {
+		return playlistError(c, err)
+	}
+
+	songIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		songIDs = append(songIDs, entry.SongID)
+	}
+	keys, err := streamKeys(h.db, songIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	file := &playlistfile.Playlist{Title: p.Name, Description: p.Description}
+	for _, entry := range entries {
+		song := entry.Song
+		if song == nil {
+			continue // deleted from the catalog
+		}
+		artists := make([]string, len(song.Artists))
+		for i, artist := range song.Artists {
+			artists[i] = artist.Name
+		}
+		location, err := h.streamURL(ctx, song, keys[song.ID])
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to sign stream URL: " + err.Error()})
+		}
+		file.Tracks = append(file.Tracks, playlistfile.Track{
+			Title:      song.Name,
+			Artist:     strings.Join(artists, ", "),
+			DurationMS: int(song.Duration) * 1000,
+			Location:   location,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := playlistfile.Write(&buf, file, format); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", exportFileName(p.Name)+"."+string(format)))
+	// Signed URLs expire, so exports must not be cached
+	header.Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, playlistfile.ContentType(format), buf.Bytes())
+}
+
+// streamURL returns a signed URL for the stored audio under key, or the
+// song's url when it has no stored audio
+func (h *PlaylistFileHandler) streamURL(ctx context.Context, song *models.Song, key string) (string, error) {
+	if key == "" || h.storage == nil {
+		return song.URL, nil
+	}
+	url, err := h.storage.GetPresignedURL(ctx, key, exportURLExpiry)
+	if errors.Is(err, storage.ErrNotFound) {
+		return song.URL, nil
+	}
+	return url, err
+}
+
+// streamKeys returns the storage key to stream for each song: its first
+// ready rendition in order of preference, or the master until one is ready
+func streamKeys(db database.Service, songIDs []string) (map[string]string, error) {
+	keys := make(map[string]string, len(songIDs))
+	if len(songIDs) == 0 {
+		return keys, nil
+	}
+	var assets []models.SongAsset
+	if err := db.GetDB().Where("song_id IN ?", songIDs).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+
+	preference := make(map[string]int, len(transcode.DefaultRenditions))
+	for i, r := range transcode.DefaultRenditions {
+		preference[r.Name] = i
+	}
+	best := make(map[string]int, len(songIDs))
+	for _, asset := range assets {
+		rank, known := preference[asset.Rendition]
+		key := asset.Key
+		if asset.Status != models.SongAssetReady || !known {
+			rank, key = len(transcode.DefaultRenditions), asset.SourceKey
+		}
+		if current, ok := best[asset.SongID]; key != "" && (!ok || rank < current) {
+			best[asset.SongID] = rank
+			keys[asset.SongID] = key
+		}
+	}
+	return keys, nil
+}
+
+// exportFileName makes a playlist name safe for Content-Disposition
+func exportFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`"\/:*?<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "playlist"
+	}
+	return name
+}
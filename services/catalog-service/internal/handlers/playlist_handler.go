@@ -2,15 +2,21 @@ package handlers
 
 import (
 	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/query"
 	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/playlist"
+	"go-audio-stream/services/catalog-service/internal/smart"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
-// CreatePlaylistHandler creates a new playlist.
+// CreatePlaylistHandler creates a new playlist owned by the caller. With
+// rules it creates a smart playlist and computes its entries.
 // @Summary      Create a new playlist
-// @Description  Create a new playlist with the provided details
+// @Description  Create a new playlist with the provided details; the caller becomes its owner
 // @Tags         playlists
 // @Accept       json
 // @Produce      json
@@ -20,21 +26,34 @@ import (
 // @Failure      500       {object}  map[string]string
 // @Router       /api/v1/playlists/ [post]
 func CreatePlaylistHandler(c echo.Context, db database.Service) error {
-	playlist := new(models.Playlist)
+	p := new(models.Playlist)
 
-	if err := c.Bind(playlist); err != nil {
+	if err := c.Bind(p); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	// Entries are added through the songs endpoints
+	p.PlaylistSongs = nil
+	p.CreatorUserID = nil
+	if user, ok := currentUser(c); ok {
+		p.CreatorUserID = &user.ID
+	}
+	if err := validateRules(p); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
-	_, err := db.Create(playlist)
+	_, err := db.Create(p)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
+	if err := refreshSmartPlaylist(c, db, p); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to compute smart playlist: " + err.Error()})
+	}
 
-	return c.JSON(http.StatusCreated, playlist)
+	return c.JSON(http.StatusCreated, p)
 }
 
-// UpdatePlaylistHandler updates an existing playlist.
+// UpdatePlaylistHandler updates an existing playlist. Only the owner can
+// change it. Smart playlists are computed again with the new rules.
 // @Summary      Update a playlist
 // @Description  Update a playlist's details
 // @Tags         playlists
@@ -44,31 +63,39 @@ func CreatePlaylistHandler(c echo.Context, db database.Service) error {
 // @Param        playlist  body      models.Playlist  true  "Playlist Data"
 // @Success      200       {object}  models.Playlist
 // @Failure      400       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
 // @Failure      404       {object}  map[string]string
 // @Failure      500       {object}  map[string]string
 // @Router       /api/v1/playlists/{id} [put]
 func UpdatePlaylistHandler(c echo.Context, db database.Service) error {
 	id := c.Param("id")
-	playlist := new(models.Playlist)
 
-	_, err := db.Find(playlist, "id = ?", id)
+	playlistStore := playlist.NewStore(db)
+	p, _, err := authorizePlaylist(c, playlistStore, playlist.Manage)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "Playlist not found"})
+		return playlistError(c, err)
 	}
 
-	if err := c.Bind(playlist); err != nil {
+	if err := c.Bind(p); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	p.ID = id
+	p.PlaylistSongs = nil
+	if err := validateRules(p); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
-	_, err = db.Update(&models.Playlist{}, playlist, "id = ?", id)
-	if err != nil {
+	if err := playlistStore.Update(c.Request().Context(), id, p); err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
+	if err := refreshSmartPlaylist(c, db, p); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to compute smart playlist: " + err.Error()})
+	}
 
-	return c.JSON(http.StatusOK, playlist)
+	return c.JSON(http.StatusOK, p)
 }
 
-// DeletePlaylistHandler deletes a playlist.
+// DeletePlaylistHandler deletes a playlist. Only the owner can delete it.
 // @Summary      Delete a playlist
 // @Description  Delete a playlist by ID
 // @Tags         playlists
@@ -76,11 +103,17 @@ func UpdatePlaylistHandler(c echo.Context, db database.Service) error {
 // @Produce      json
 // @Param        id   path      string  true  "Playlist ID"
 // @Success      200  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /api/v1/playlists/{id} [delete]
 func DeletePlaylistHandler(c echo.Context, db database.Service) error {
 	id := c.Param("id")
 
+	if _, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.Manage); err != nil {
+		return playlistError(c, err)
+	}
+
 	_, err := db.Delete(&models.Playlist{}, "id = ?", id)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
@@ -89,106 +122,111 @@ func DeletePlaylistHandler(c echo.Context, db database.Service) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "Playlist deleted successfully"})
 }
 
-// FindOnePlaylistById retrieves a playlist by ID.
+// FindOnePlaylistById retrieves a playlist by ID. With include=songs its
+// playlist_songs are returned in order with each song, and with
+// include=songs.artists with the songs' artists too. Private playlists are
+// only found by their owner and members. The entries of smart playlists are
+// computed again when they are older than an hour.
 // @Summary      Get a playlist
 // @Description  Get a playlist by ID
 // @Tags         playlists
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Playlist ID"
-// @Success      200  {object}  models.Playlist
-// @Failure      404  {object}  map[string]string
+// @Param        id       path      string  true   "Playlist ID"
+// @Param        include  query     string  false  "Comma-separated relations: songs, songs.artists"
+// @Param        fields   query     string  false  "Comma-separated JSON keys to return"
+// @Success      200      {object}  models.Playlist
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
 // @Router       /api/v1/playlists/{id} [get]
 func FindOnePlaylistById(c echo.Context, db database.Service) error {
 	id := c.Param("id")
-	var playlist models.Playlist
+	store := playlist.NewStore(db)
+	p, _, err := authorizePlaylist(c, store, playlist.View)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	refreshStalePlaylist(c, store, p)
 
-	// Preload songs if needed, but for now just basic info
-	_, err := db.Find(&playlist, "id = ?", id)
+	var playlist models.Playlist
+	tx, err := query.Preload(db.GetDB(), playlistIncludes, c.QueryParam("include"))
 	if err != nil {
+		return queryError(c, err)
+	}
+	result := tx.Limit(1).Find(&playlist, "id = ?", id)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "Playlist not found"})
 	}
+	numberPlaylistSongs([]models.Playlist{playlist})
 
-	return c.JSON(http.StatusOK, playlist)
+	return jsonFields(c, http.StatusOK, playlist)
 }
 
-// FindAllPlaylists retrieves all playlists.
+// FindAllPlaylists retrieves a page of playlists. Private playlists are
+// only listed for their owner and members.
 // @Summary      Get all playlists
-// @Description  Get a list of all playlists
+// @Description  Get a page of playlists; the envelope's pagination holds the next cursor
 // @Tags         playlists
 // @Accept       json
 // @Produce      json
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor  query     string  false  "next_cursor of the previous page"
+// @Param        sort               query     string  false  "name or created_at, prefixed with - for descending"
+// @Param        private            query     bool    false  "Private playlists only, or none"
+// @Param        is_collaborative   query     bool    false  "Collaborative playlists only, or none"
+// @Param        creator_user_id    query     string  false  "Creating user ID"
+// @Param        creator_artist_id  query     string  false  "Creating artist ID"
+// @Param        include  query     string  false  "Comma-separated relations: songs, songs.artists"
+// @Param        fields   query     string  false  "Comma-separated JSON keys to return"
 // @Success      200  {array}   models.Playlist
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /api/v1/playlists/ [get]
 func FindAllPlaylists(c echo.Context, db database.Service) error {
 	var playlists []models.Playlist
-	_, err := db.Find(&playlists)
+	user, _ := currentUser(c)
+	visible := db.GetDB().Where("private = ? OR creator_user_id = ? OR id IN (?)", false, user.ID,
+		db.GetDB().Model(&models.PlaylistMember{}).Select("playlist_id").Where("user_id = ?", user.ID))
+	tx, err := query.Preload(visible, playlistIncludes, c.QueryParam("include"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return queryError(c, err)
+	}
+	page, err := query.Find(tx, &playlists, playlistListSpec, c.QueryParams())
+	if err != nil {
+		return queryError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, playlists)
-}
+	numberPlaylistSongs(playlists)
 
-type AddSongRequest struct {
-	SongID   string `json:"song_id"`
-	Position int    `json:"position"`
+	setPagination(c, page)
+	return jsonFields(c, http.StatusOK, playlists)
 }
 
-// AddSongToPlaylistHandler adds a song to a playlist.
-// @Summary      Add song to playlist
-// @Description  Add a song to a playlist
-// @Tags         playlists
-// @Accept       json
-// @Produce      json
-// @Param        id   path      string          true  "Playlist ID"
-// @Param        req  body      AddSongRequest  true  "Song Data"
-// @Success      201  {object}  models.PlaylistSong
-// @Failure      400  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
-// @Router       /api/v1/playlists/{id}/songs [post]
-func AddSongToPlaylistHandler(c echo.Context, db database.Service) error {
-	playlistID := c.Param("id")
-	req := new(AddSongRequest)
-
-	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
-	}
-
-	playlistSong := &models.PlaylistSong{
-		PlaylistID: playlistID,
-		SongID:     req.SongID,
-		Position:   req.Position,
+// validateRules checks the rules of a smart playlist
+func validateRules(p *models.Playlist) error {
+	if p.Rules == nil {
+		return nil
 	}
+	_, err := smart.Compile(p.Rules, "", time.Now())
+	return err
+}
 
-	_, err := db.Create(playlistSong)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+// refreshSmartPlaylist computes the entries of p if it is a smart playlist
+func refreshSmartPlaylist(c echo.Context, db database.Service, p *models.Playlist) error {
+	if p.Rules == nil {
+		return nil
 	}
-
-	return c.JSON(http.StatusCreated, playlistSong)
+	return playlist.NewStore(db).Refresh(c.Request().Context(), p)
 }
 
-// RemoveSongFromPlaylistHandler removes a song from a playlist.
-// @Summary      Remove song from playlist
-// @Description  Remove a song from a playlist
-// @Tags         playlists
-// @Accept       json
-// @Produce      json
-// @Param        id       path      string  true  "Playlist ID"
-// @Param        song_id  path      string  true  "Song ID"
-// @Success      200      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
-// @Router       /api/v1/playlists/{id}/songs/{song_id} [delete]
-func RemoveSongFromPlaylistHandler(c echo.Context, db database.Service) error {
-	playlistID := c.Param("id")
-	songID := c.Param("song_id")
-
-	_, err := db.Delete(&models.PlaylistSong{}, "playlist_id = ? AND song_id = ?", playlistID, songID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+// refreshStalePlaylist computes the entries of p again if it is a smart
+// playlist whose entries are out of date. Failures are logged and the old
+// entries served.
+func refreshStalePlaylist(c echo.Context, store *playlist.Store, p *models.Playlist) {
+	if err := store.RefreshStale(c.Request().Context(), p); err != nil {
+		log.Printf("Failed to refresh smart playlist %s: %v", p.ID, err)
 	}
-
-	return c.JSON(http.StatusOK, echo.Map{"message": "Song removed from playlist"})
 }
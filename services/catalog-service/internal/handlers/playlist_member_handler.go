@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/playlist"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultInviteExpiry is how long invite links stay valid by default
+	defaultInviteExpiry = 7 * 24 * time.Hour
+	// maxInviteExpiry is the longest an invite link can stay valid
+	maxInviteExpiry = 30 * 24 * time.Hour
+)
+
+// PlaylistMemberRequest sets the role of a playlist member
+type PlaylistMemberRequest struct {
+	Role string `json:"role" enums:"editor,viewer"`
+}
+
+// PlaylistInviteRequest creates an invite link granting role. It expires
+// after expires_in_hours (default 168, max 720).
+type PlaylistInviteRequest struct {
+	Role           string `json:"role" enums:"editor,viewer"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+// AcceptInviteRequest accepts the invite with the token from its link
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// FindPlaylistMembers lists the members of a playlist.
+// @Summary      Get playlist members
+// @Description  List the users given a role on a playlist, besides its owner
+// @Tags         playlists
+// @Produce      json
+// @Param        id   path      string  true  "Playlist ID"
+// @Success      200  {array}   models.PlaylistMember
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/members [get]
+func FindPlaylistMembers(c echo.Context, db database.Service) error {
+	if _, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.View); err != nil {
+		return playlistError(c, err)
+	}
+
+	var members []models.PlaylistMember
+	if err := db.GetDB().Where("playlist_id = ?", c.Param("id")).Order("created_at").Find(&members).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, members)
+}
+
+// SetPlaylistMemberHandler adds a user to a playlist or changes their role.
+// @Summary      Set playlist member
+// @Description  Give a user the editor or viewer role on a playlist; only the owner can
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                 true  "Playlist ID"
+// @Param        user_id  path      string                 true  "User ID"
+// @Param        req      body      PlaylistMemberRequest  true  "Role"
+// @Success      200      {object}  models.PlaylistMember
+// @Failure      400      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/members/{user_id} [put]
+func SetPlaylistMemberHandler(c echo.Context, db database.Service) error {
+	p, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.Manage)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	req := new(PlaylistMemberRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	role := models.PlaylistRole(req.Role)
+	if !role.Valid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "role must be editor or viewer"})
+	}
+
+	userID := c.Param("user_id")
+	if p.CreatorUserID != nil && *p.CreatorUserID == userID {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "The owner cannot be a member"})
+	}
+	var user models.User
+	result := db.GetDB().Select("id").Limit(1).Find(&user, "id = ?", userID)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "User not found"})
+	}
+
+	member, err := setPlaylistMember(db, p.ID, userID, role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, member)
+}
+
+// RemovePlaylistMemberHandler removes a member from a playlist. The owner
+// can remove anyone; members can remove themselves.
+// @Summary      Remove playlist member
+// @Description  Remove a user's role on a playlist
+// @Tags         playlists
+// @Produce      json
+// @Param        id       path      string  true  "Playlist ID"
+// @Param        user_id  path      string  true  "User ID"
+// @Success      200      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/members/{user_id} [delete]
+func RemovePlaylistMemberHandler(c echo.Context, db database.Service) error {
+	_, role, err := authorizePlaylist(c, playlist.NewStore(db), playlist.View)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	userID := c.Param("user_id")
+	if user, _ := currentUser(c); role != models.PlaylistOwner && user.ID != userID {
+		return playlistError(c, playlist.ErrForbidden)
+	}
+
+	_, err = db.Delete(&models.PlaylistMember{}, "playlist_id = ? AND user_id = ?", c.Param("id"), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Member removed from playlist"})
+}
+
+// CreatePlaylistInviteHandler creates an invite link for a playlist. The
+// token is only returned here.
+// @Summary      Create playlist invite
+// @Description  Create an expiring invite that makes whoever accepts it an editor or viewer; only the owner can
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string                 true  "Playlist ID"
+// @Param        req  body      PlaylistInviteRequest  true  "Invite"
+// @Success      201  {object}  models.PlaylistInvite
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/invites [post]
+func CreatePlaylistInviteHandler(c echo.Context, db database.Service) error {
+	p, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.Manage)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	req := new(PlaylistInviteRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	role := models.PlaylistRole(req.Role)
+	if !role.Valid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "role must be editor or viewer"})
+	}
+	expiry := defaultInviteExpiry
+	if req.ExpiresInHours != 0 {
+		expiry = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if expiry <= 0 || expiry > maxInviteExpiry {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "expires_in_hours must be between 1 and 720"})
+	}
+
+	token, hash, err := playlist.NewInviteToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	user, _ := currentUser(c)
+	invite := &models.PlaylistInvite{
+		PlaylistID:      p.ID,
+		TokenHash:       hash,
+		Role:            role,
+		CreatedByUserID: user.ID,
+		ExpiresAt:       time.Now().Add(expiry),
+	}
+	if _, err := db.Create(invite); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	invite.Token = token
+	return c.JSON(http.StatusCreated, invite)
+}
+
+// FindPlaylistInvites lists the unexpired invites of a playlist.
+// @Summary      Get playlist invites
+// @Description  List a playlist's unexpired invites, without their tokens; only the owner can
+// @Tags         playlists
+// @Produce      json
+// @Param        id   path      string  true  "Playlist ID"
+// @Success      200  {array}   models.PlaylistInvite
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/invites [get]
+func FindPlaylistInvites(c echo.Context, db database.Service) error {
+	if _, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.Manage); err != nil {
+		return playlistError(c, err)
+	}
+
+	var invites []models.PlaylistInvite
+	err := db.GetDB().Where("playlist_id = ? AND expires_at > ?", c.Param("id"), time.Now()).Order("created_at").Find(&invites).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, invites)
+}
+
+// DeletePlaylistInviteHandler revokes an invite.
+// @Summary      Revoke playlist invite
+// @Description  Revoke an invite so its link stops working; only the owner can
+// @Tags         playlists
+// @Produce      json
+// @Param        id         path      string  true  "Playlist ID"
+// @Param        invite_id  path      string  true  "Invite ID"
+// @Success      200        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/invites/{invite_id} [delete]
+func DeletePlaylistInviteHandler(c echo.Context, db database.Service) error {
+	if _, _, err := authorizePlaylist(c, playlist.NewStore(db), playlist.Manage); err != nil {
+		return playlistError(c, err)
+	}
+
+	_, err := db.Delete(&models.PlaylistInvite{}, "id = ? AND playlist_id = ?", c.Param("invite_id"), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Invite revoked"})
+}
+
+// AcceptPlaylistInviteHandler makes the caller a member of the invite's
+// playlist. A role the caller already has is kept if it grants more.
+// @Summary      Accept playlist invite
+// @Description  Join a playlist with the token of an invite link
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        req  body      AcceptInviteRequest  true  "Invite token"
+// @Success      200  {object}  models.PlaylistMember
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/invites/accept [post]
+func AcceptPlaylistInviteHandler(c echo.Context, db database.Service) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	req := new(AcceptInviteRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var invite models.PlaylistInvite
+	result := db.GetDB().Where("token_hash = ? AND expires_at > ?", playlist.HashInviteToken(req.Token), time.Now()).Limit(1).Find(&invite)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Invite not found or expired"})
+	}
+
+	_, role, err := playlist.NewStore(db).Role(c.Request().Context(), invite.PlaylistID, user.ID)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	if role.AtLeast(invite.Role) {
+		return c.JSON(http.StatusOK, models.PlaylistMember{PlaylistID: invite.PlaylistID, UserID: user.ID, Role: role})
+	}
+
+	member, err := setPlaylistMember(db, invite.PlaylistID, user.ID, invite.Role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, member)
+}
+
+// setPlaylistMember gives a user role on a playlist, adding them as a member
+// if needed
+func setPlaylistMember(db database.Service, playlistID, userID string, role models.PlaylistRole) (*models.PlaylistMember, error) {
+	member := &models.PlaylistMember{PlaylistID: playlistID, UserID: userID}
+	result := db.GetDB().Where(member).Limit(1).Find(member)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	member.Role = role
+	if result.RowsAffected == 0 {
+		_, err := db.Create(member)
+		return member, err
+	}
+	err := db.GetDB().Model(&models.PlaylistMember{}).Where("playlist_id = ? AND user_id = ?", playlistID, userID).Update("role", role).Error
+	return member, err
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/playlist"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// AddSongRequest adds song_id, or the songs in song_ids in that order,
+// before the entry at index position; without position they are appended
+type AddSongRequest struct {
+	SongID     string   `json:"song_id"`
+	SongIDs    []string `json:"song_ids"`
+	Position   *int     `json:"position"`
+	SnapshotID string   `json:"snapshot_id"`
+}
+
+// MoveSongsRequest moves range_length entries (default 1) starting at index
+// range_start before the entry at index insert_before, counted in the order
+// before the move
+type MoveSongsRequest struct {
+	RangeStart   int    `json:"range_start"`
+	RangeLength  *int   `json:"range_length"`
+	InsertBefore int    `json:"insert_before"`
+	SnapshotID   string `json:"snapshot_id"`
+}
+
+// ReorderSongsRequest lists every entry of a playlist in its new order
+type ReorderSongsRequest struct {
+	EntryIDs   []string `json:"entry_ids"`
+	SnapshotID string   `json:"snapshot_id"`
+}
+
+// PlaylistSongsResponse carries the playlist's snapshot ID after a read or
+// an edit, and the entries read or added
+type PlaylistSongsResponse struct {
+	SnapshotID string                `json:"snapshot_id"`
+	Items      []models.PlaylistSong `json:"items,omitempty"`
+}
+
+// FindPlaylistSongs lists the entries of a playlist in order. Entries of
+// smart playlists are computed again when they are out of date.
+// @Summary      Get playlist songs
+// @Description  Get the ordered entries of a playlist, each with its song and artists, and the playlist's snapshot ID
+// @Tags         playlists
+// @Produce      json
+// @Param        id   path      string  true  "Playlist ID"
+// @Success      200  {object}  PlaylistSongsResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/songs [get]
+func FindPlaylistSongs(c echo.Context, db database.Service) error {
+	store := playlist.NewStore(db)
+	p, _, err := authorizePlaylist(c, store, playlist.View)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	refreshStalePlaylist(c, store, p)
+	snapshotID, entries, err := store.Entries(c.Request().Context(), p.ID)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, PlaylistSongsResponse{SnapshotID: snapshotID, Items: entries})
+}
+
+// AddSongToPlaylistHandler adds songs to a playlist. A song can be added
+// more than once; each addition is a separate entry.
+// @Summary      Add songs to playlist
+// @Description  Insert songs at a position (or append them); with snapshot_id the edit fails with 409 if the playlist changed since
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string          true  "Playlist ID"
+// @Param        req  body      AddSongRequest  true  "Songs to add"
+// @Success      201  {object}  PlaylistSongsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/songs [post]
+func AddSongToPlaylistHandler(c echo.Context, db database.Service) error {
+	req := new(AddSongRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	songIDs := req.SongIDs
+	if req.SongID != "" {
+		songIDs = append([]string{req.SongID}, songIDs...)
+	}
+	for _, songID := range songIDs {
+		if _, err := uuid.Parse(songID); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID: " + songID})
+		}
+	}
+
+	store := playlist.NewStore(db)
+	if _, _, err := authorizePlaylist(c, store, playlist.Edit); err != nil {
+		return playlistError(c, err)
+	}
+	user, _ := currentUser(c)
+	snapshotID, entries, err := store.Insert(c.Request().Context(), c.Param("id"), req.SnapshotID, user.ID, songIDs, req.Position)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusCreated, PlaylistSongsResponse{SnapshotID: snapshotID, Items: entries})
+}
+
+// MovePlaylistSongsHandler moves a range of entries within a playlist.
+// @Summary      Move playlist songs
+// @Description  Move range_length entries starting at range_start before the entry at insert_before
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string            true  "Playlist ID"
+// @Param        req  body      MoveSongsRequest  true  "Range to move"
+// @Success      200  {object}  PlaylistSongsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/songs/move [post]
+func MovePlaylistSongsHandler(c echo.Context, db database.Service) error {
+	req := new(MoveSongsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	length := 1
+	if req.RangeLength != nil {
+		length = *req.RangeLength
+	}
+
+	store := playlist.NewStore(db)
+	if _, _, err := authorizePlaylist(c, store, playlist.Edit); err != nil {
+		return playlistError(c, err)
+	}
+	snapshotID, err := store.Move(c.Request().Context(), c.Param("id"), req.SnapshotID, req.RangeStart, length, req.InsertBefore)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, PlaylistSongsResponse{SnapshotID: snapshotID})
+}
+
+// ReorderPlaylistSongsHandler puts all entries of a playlist in a new order.
+// @Summary      Reorder playlist songs
+// @Description  Reorder a playlist by listing every entry ID in the new order
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string               true  "Playlist ID"
+// @Param        req  body      ReorderSongsRequest  true  "Entry IDs in order"
+// @Success      200  {object}  PlaylistSongsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/songs [put]
+func ReorderPlaylistSongsHandler(c echo.Context, db database.Service) error {
+	req := new(ReorderSongsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	store := playlist.NewStore(db)
+	if _, _, err := authorizePlaylist(c, store, playlist.Edit); err != nil {
+		return playlistError(c, err)
+	}
+	snapshotID, err := store.Reorder(c.Request().Context(), c.Param("id"), req.SnapshotID, req.EntryIDs)
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, PlaylistSongsResponse{SnapshotID: snapshotID})
+}
+
+// RemoveSongFromPlaylistHandler removes every entry of a song from a playlist.
+// @Summary      Remove song from playlist
+// @Description  Remove all entries of a song from a playlist
+// @Tags         playlists
+// @Produce      json
+// @Param        id           path      string  true   "Playlist ID"
+// @Param        song_id      path      string  true   "Song ID"
+// @Param        snapshot_id  query     string  false  "Expected snapshot ID"
+// @Success      200          {object}  PlaylistSongsResponse
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      409          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/songs/{song_id} [delete]
+func RemoveSongFromPlaylistHandler(c echo.Context, db database.Service) error {
+	store := playlist.NewStore(db)
+	if _, _, err := authorizePlaylist(c, store, playlist.Edit); err != nil {
+		return playlistError(c, err)
+	}
+	snapshotID, err := store.RemoveSong(c.Request().Context(), c.Param("id"), c.QueryParam("snapshot_id"), c.Param("song_id"))
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, PlaylistSongsResponse{SnapshotID: snapshotID})
+}
+
+// RemovePlaylistEntryHandler removes one entry from a playlist.
+// @Summary      Remove playlist entry
+// @Description  Remove a single entry, leaving other entries of the same song
+// @Tags         playlists
+// @Produce      json
+// @Param        id           path      string  true   "Playlist ID"
+// @Param        entry_id     path      string  true   "Entry ID"
+// @Param        snapshot_id  query     string  false  "Expected snapshot ID"
+// @Success      200          {object}  PlaylistSongsResponse
+// @Failure      400          {object}  map[string]string
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      409          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /api/v1/playlists/{id}/entries/{entry_id} [delete]
+func RemovePlaylistEntryHandler(c echo.Context, db database.Service) error {
+	store := playlist.NewStore(db)
+	if _, _, err := authorizePlaylist(c, store, playlist.Edit); err != nil {
+		return playlistError(c, err)
+	}
+	snapshotID, err := store.RemoveEntry(c.Request().Context(), c.Param("id"), c.QueryParam("snapshot_id"), c.Param("entry_id"))
+	if err != nil {
+		return playlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, PlaylistSongsResponse{SnapshotID: snapshotID})
+}
+
+// authorizePlaylist checks that the caller may perform action on the
+// playlist in the path and returns it with the caller's role
+func authorizePlaylist(c echo.Context, store *playlist.Store, action playlist.Action) (*models.Playlist, models.PlaylistRole, error) {
+	user, _ := currentUser(c)
+	return store.Authorize(c.Request().Context(), c.Param("id"), user.ID, action)
+}
+
+// playlistError responds to an error from the playlist store
+func playlistError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, playlist.ErrNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Playlist not found"})
+	case errors.Is(err, playlist.ErrForbidden):
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "Not allowed to change this playlist"})
+	case errors.Is(err, playlist.ErrSnapshotMismatch):
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Playlist has changed since snapshot_id"})
+	case errors.Is(err, playlist.ErrComputed):
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Smart playlist songs are computed from its rules"})
+	case errors.Is(err, playlist.ErrInvalid):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+}
+
+// numberPlaylistSongs sets the positions of the entries loaded with the
+// playlists
+func numberPlaylistSongs(playlists []models.Playlist) {
+	for _, p := range playlists {
+		playlist.Number(p.PlaylistSongs)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// PreviewHandler serves the short preview clips cut by the preview step and
+// lets editors choose where they start
+type PreviewHandler struct {
+	storage  storage.Backend
+	db       database.Service
+	pipeline *pipeline.Pipeline
+	streamer *UploadHandler
+}
+
+// NewPreviewHandler creates a preview handler; previewPipeline regenerates
+// clips after their start changes
+func NewPreviewHandler(storageClient storage.Backend, db database.Service, previewPipeline *pipeline.Pipeline) *PreviewHandler {
+	return &PreviewHandler{
+		storage:  storageClient,
+		db:       db,
+		pipeline: previewPipeline,
+		streamer: &UploadHandler{storage: storageClient},
+	}
+}
+
+// SetPreviewStartRequest sets the preview start; null returns to the
+// automatically chosen loudest stretch
+type SetPreviewStartRequest struct {
+	StartMS *int32 `json:"start_ms"`
+}
+
+// Stream serves a song's preview clip with byte-range support. It needs no
+// authentication and never falls back to the full track.
+// @Summary      Stream song preview
+// @Description  Stream the short public preview clip of a song
+// @Tags         songs
+// @Produce      audio/mpeg
+// @Param        id   path      string  true  "Song ID"
+// @Success      200  {file}    binary
+// @Success      206  {file}    binary
+// @Failure      404  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Router       /api/v1/preview/{id} [get]
+func (h *PreviewHandler) Stream(c echo.Context) error {
+	songID := c.Param("id")
+	if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID"})
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=3600")
+	return h.streamer.streamKey(c, pipeline.PreviewKey(songID))
+}
+
+// SetStart stores an editor-chosen preview start and regenerates the clip
+// @Summary      Set song preview start
+// @Description  Choose where the preview clip starts, or null for the loudest part
+// @Tags         songs
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                  true  "Song ID"
+// @Param        request  body      SetPreviewStartRequest  true  "Preview start"
+// @Success      202      {object}  map[string]interface{}
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Router       /api/v1/songs/{id}/preview [put]
+func (h *PreviewHandler) SetStart(c echo.Context) error {
+	songID := c.Param("id")
+	if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID"})
+	}
+
+	var req SetPreviewStartRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	var song models.Song
+	result, err := h.db.Find(&song, "id = ?", songID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Song not found"})
+	}
+	if req.StartMS != nil && (*req.StartMS < 0 || (song.Duration > 0 && int64(*req.StartMS) >= int64(song.Duration)*1000)) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "start_ms must be within the song"})
+	}
+
+	ctx := c.Request().Context()
+	err = h.db.GetDB().WithContext(ctx).Model(&models.Song{}).Where("id = ?", songID).
+		Update("preview_start_ms", req.StartMS).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	key, err := h.masterKey(c, songID)
+	if err != nil {
+		log.Printf("Failed to find master of song %s: %v", songID, err)
+	}
+	if key != "" && h.pipeline != nil {
+		h.pipeline.Enqueue(pipeline.Job{SongID: songID, Key: key})
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"preview_start_ms": req.StartMS,
+		"regenerating":     key != "",
+	})
+}
+
+// masterKey returns the storage key of the song's uploaded master, or "" when
+// none has been uploaded
+func (h *PreviewHandler) masterKey(c echo.Context, songID string) (string, error) {
+	var assets []models.SongAsset
+	if _, err := h.db.Find(&assets, "song_id = ? AND source_key <> ''", songID); err != nil {
+		return "", err
+	}
+	if len(assets) > 0 {
+		return assets[0].SourceKey, nil
+	}
+
+	// Songs uploaded before renditions existed only have the master file
+	files, err := h.storage.ListFiles(c.Request().Context(), "songs/"+songID+"/audio.")
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	return files[0].Key, nil
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/services/catalog-service/internal/device"
+	"go-audio-stream/services/catalog-service/internal/relay"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+// RelayOfferRequest is a device's WebRTC offer, with all its ICE candidates
+type RelayOfferRequest struct {
+	DeviceID string `json:"device_id"`
+	SDP      string `json:"sdp"`
+}
+
+type RelayAnswerResponse struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// ICEServersResponse lists the ICE servers the devices of a pairing may use,
+// in the format of RTCIceServer
+type ICEServersResponse struct {
+	ICEServers []webrtc.ICEServer `json:"ice_servers"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+}
+
+// RelayHandler serves the media relay and TURN credentials of pairings
+type RelayHandler struct {
+	relay *relay.Relay
+	turn  *relay.TURNServer
+	db    database.Service
+}
+
+// NewRelayHandler creates a relay handler. relay and turn are nil when not
+// enabled.
+func NewRelayHandler(mediaRelay *relay.Relay, turnServer *relay.TURNServer, db database.Service) *RelayHandler {
+	return &RelayHandler{
+		relay: mediaRelay,
+		turn:  turnServer,
+		db:    db,
+	}
+}
+
+// ICEServers mints TURN credentials for the devices of a pairing.
+// @Summary      Get ICE servers
+// @Description  Get the TURN server with credentials for the devices of a completed pairing; empty when TURN is not enabled
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Pairing ID"
+// @Success      200  {object}  ICEServersResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/rtc/pairings/{id}/ice-servers [get]
+func (h *RelayHandler) ICEServers(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	pairing, err := device.NewStore(h.db).Pairing(c.Request().Context(), user.ID, c.Param("id"))
+	if err != nil {
+		return deviceError(c, err)
+	}
+
+	response := ICEServersResponse{ICEServers: []webrtc.ICEServer{}}
+	if h.turn != nil {
+		server, expiresAt, err := h.turn.Credentials(pairing.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		response.ICEServers = append(response.ICEServers, server)
+		response.ExpiresAt = &expiresAt
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// Publish relays the audio of a pairing's phone.
+// @Summary      Publish audio to the relay
+// @Description  Answer the offer of a pairing's phone and relay the Opus audio it sends to the pairing's browser
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string             true  "Pairing ID"
+// @Param        offer  body      RelayOfferRequest  true  "The phone's device ID and offer"
+// @Success      200    {object}  RelayAnswerResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      403    {object}  map[string]string
+// @Failure      404    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/rtc/pairings/{id}/publish [post]
+func (h *RelayHandler) Publish(c echo.Context) error {
+	return h.connect(c, true)
+}
+
+// Subscribe sends the relayed audio of a pairing to its browser.
+// @Summary      Subscribe to relayed audio
+// @Description  Answer the offer of a pairing's browser with the audio its phone publishes
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string             true  "Pairing ID"
+// @Param        offer  body      RelayOfferRequest  true  "The browser's device ID and offer"
+// @Success      200    {object}  RelayAnswerResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      403    {object}  map[string]string
+// @Failure      404    {object}  map[string]string
+// @Failure      409    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/rtc/pairings/{id}/subscribe [post]
+func (h *RelayHandler) Subscribe(c echo.Context) error {
+	return h.connect(c, false)
+}
+
+// connect answers the offer of the pairing's phone when publishing, or of
+// its browser otherwise
+func (h *RelayHandler) connect(c echo.Context, publish bool) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	var req RelayOfferRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	ctx := c.Request().Context()
+	pairing, err := device.NewStore(h.db).Pairing(ctx, user.ID, c.Param("id"))
+	if err != nil {
+		return deviceError(c, err)
+	}
+
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: req.SDP}
+	var answer *webrtc.SessionDescription
+	if publish {
+		if req.DeviceID != pairing.MobileDeviceID {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "Only the pairing's phone can publish"})
+		}
+		answer, err = h.relay.Publish(ctx, pairing.ID, offer)
+	} else {
+		if req.DeviceID != *pairing.BrowserDeviceID {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "Only the pairing's browser can subscribe"})
+		}
+		answer, err = h.relay.Subscribe(ctx, pairing.ID, offer)
+	}
+	if err != nil {
+		return relayError(c, err)
+	}
+	return c.JSON(http.StatusOK, RelayAnswerResponse{Type: answer.Type.String(), SDP: answer.SDP})
+}
+
+// Close disconnects the devices of a pairing from the relay.
+// @Summary      Stop relaying
+// @Description  Disconnect the phone and browser of a pairing from the relay
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Pairing ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/rtc/pairings/{id}/relay [delete]
+func (h *RelayHandler) Close(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	pairing, err := device.NewStore(h.db).Pairing(c.Request().Context(), user.ID, c.Param("id"))
+	if err != nil {
+		return deviceError(c, err)
+	}
+	h.relay.Close(pairing.ID)
+	return c.JSON(http.StatusOK, echo.Map{"message": "Relay closed successfully"})
+}
+
+// relayError responds to an error from the relay
+func relayError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, relay.ErrInvalidOffer):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	case errors.Is(err, relay.ErrNoPublisher):
+		return c.JSON(http.StatusConflict, echo.Map{"error": "The phone is not publishing audio"})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/hls"
+	"go-audio-stream/pkg/models"
+)
+
+// ReplayGain values are exposed as response headers on streams and as
+// session data in HLS master playlists, in the units of ReplayGain tags
+const (
+	headerTrackGain      = "X-ReplayGain-Track-Gain"
+	headerTrackPeak      = "X-ReplayGain-Track-Peak"
+	sessionDataTrackGain = "org.hydrogenaudio.replaygain.track_gain"
+	sessionDataTrackPeak = "org.hydrogenaudio.replaygain.track_peak"
+)
+
+// replayGain loads the formatted track gain and peak of a song. ok is false
+// until the song's loudness has been measured.
+func replayGain(db database.Service, songID string) (gain, peak string, ok bool) {
+	if db == nil {
+		return "", "", false
+	}
+	var song models.Song
+	if _, err := db.Find(&song, "id = ?", songID); err != nil || song.TrackGain == nil || song.TrackPeak == nil {
+		return "", "", false
+	}
+	return fmt.Sprintf("%.2f dB", *song.TrackGain), fmt.Sprintf("%.6f", *song.TrackPeak), true
+}
+
+// setReplayGainHeaders adds the song's ReplayGain headers when known
+func setReplayGainHeaders(header http.Header, db database.Service, songID string) {
+	if gain, peak, ok := replayGain(db, songID); ok {
+		header.Set(headerTrackGain, gain)
+		header.Set(headerTrackPeak, peak)
+	}
+}
+
+// replayGainSessionData returns EXT-X-SESSION-DATA tags with the song's
+// ReplayGain values when known
+func replayGainSessionData(db database.Service, songID string) []string {
+	gain, peak, ok := replayGain(db, songID)
+	if !ok {
+		return nil
+	}
+	return []string{
+		hls.SessionDataTag(sessionDataTrackGain, gain),
+		hls.SessionDataTag(sessionDataTrackPeak, peak),
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/search"
+	"go-audio-stream/pkg/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchResponse holds the best matches of each requested type; types that
+// were not requested are omitted
+type SearchResponse struct {
+	Songs     *[]models.Song     `json:"songs,omitempty"`
+	Artists   *[]models.Artist   `json:"artists,omitempty"`
+	Albums    *[]models.Album    `json:"albums,omitempty"`
+	Playlists *[]models.Playlist `json:"playlists,omitempty"`
+}
+
+// SearchHandler searches the catalog. Every word of q must match, the last
+// one as a prefix for search-as-you-type; names similar to q are found too,
+// so small typos still match. Results are ranked by relevance and
+// popularity. Private playlists are never returned.
+// @Summary      Search the catalog
+// @Description  Full-text search over songs, artists, albums and playlists
+// @Tags         search
+// @Accept       json
+// @Produce      json
+// @Param        q      query     string  true   "Search text"
+// @Param        types  query     string  false  "Comma-separated types: song, artist, album, playlist (default all)"
+// @Param        limit  query     int     false  "Results per type (default 10, max 50)"
+// @Success      200    {object}  SearchResponse
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/search [get]
+func SearchHandler(c echo.Context, db database.Service) error {
+	q, err := search.Parse(c.QueryParam("q"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "q must contain at least one letter or digit"})
+	}
+	types, err := search.ParseTypes(c.QueryParam("types"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	limit := defaultSearchLimit
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSearchLimit {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "limit must be between 1 and 50"})
+		}
+	}
+
+	response := SearchResponse{}
+	for _, t := range types {
+		tx := db.GetDB()
+		var dest interface{}
+		switch t {
+		case search.Song:
+			response.Songs = &[]models.Song{}
+			tx, dest = tx.Preload("Artists"), response.Songs
+		case search.Artist:
+			response.Artists = &[]models.Artist{}
+			dest = response.Artists
+		case search.Album:
+			response.Albums = &[]models.Album{}
+			tx, dest = tx.Preload("Artists"), response.Albums
+		case search.Playlist:
+			response.Playlists = &[]models.Playlist{}
+			dest = response.Playlists
+		}
+		if err := search.Find(tx, t, q, limit, dest); err != nil {
+			if errors.Is(err, search.ErrUnknownType) {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
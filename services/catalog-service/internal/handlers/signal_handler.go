@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/services/catalog-service/internal/device"
+	"go-audio-stream/services/catalog-service/internal/signaling"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// signalWriteWait bounds each write to a signaling socket
+	signalWriteWait = 10 * time.Second
+	// signalPongWait is how long a socket may stay silent, pongs included
+	signalPongWait = 60 * time.Second
+	// signalPingPeriod keeps idle sockets alive, and must be below
+	// signalPongWait
+	signalPingPeriod = signalPongWait * 9 / 10
+	// signalMaxMessageSize fits an SDP with plenty of room
+	signalMaxMessageSize = 64 << 10
+)
+
+var signalUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// Sockets are authenticated by token rather than cookies, so pages of
+	// any origin the CORS policy allows may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// SignalHandler serves the WebRTC signaling socket
+type SignalHandler struct {
+	hub *signaling.Hub
+	db  database.Service
+}
+
+// NewSignalHandler creates a signaling handler connecting devices to hub
+func NewSignalHandler(hub *signaling.Hub, db database.Service) *SignalHandler {
+	return &SignalHandler{
+		hub: hub,
+		db:  db,
+	}
+}
+
+// Signal upgrades to a WebSocket relaying offers, answers and candidates
+// between the caller's paired devices.
+// @Summary      WebRTC signaling
+// @Description  WebSocket relaying offer, answer, candidate and sync messages to paired devices of the caller. Connecting again with the same device replaces the earlier socket; messages for a disconnected device are kept for 30 seconds.
+// @Tags         devices
+// @Param        device_id  query     string  true   "The connecting device"
+// @Param        token      query     string  false  "Access token, for clients that cannot set the Authorization header"
+// @Success      101
+// @Failure      401        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Router       /api/v1/rtc/signal [get]
+func (h *SignalHandler) Signal(c echo.Context) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+	ctx := c.Request().Context()
+	connected, err := device.NewStore(h.db).Touch(ctx, user.ID, c.QueryParam("device_id"))
+	if err != nil {
+		return deviceError(c, err)
+	}
+
+	conn, err := signalUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader has responded already
+		return nil
+	}
+	defer conn.Close()
+
+	session, err := h.hub.Connect(ctx, user.ID, connected.ID)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "signaling unavailable"),
+			time.Now().Add(signalWriteWait))
+		return nil
+	}
+	defer session.Close()
+
+	replies := make(chan signaling.ServerMessage, 8)
+	go writeSignals(conn, session, replies)
+	readSignals(ctx, conn, session, replies)
+	return nil
+}
+
+// readSignals passes the messages of the socket to the session until the
+// socket fails or closes, replying with an error message to those that
+// cannot be relayed
+func readSignals(ctx context.Context, conn *websocket.Conn, session *signaling.Session, replies chan<- signaling.ServerMessage) {
+	conn.SetReadLimit(signalMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(signalPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(signalPongWait))
+	})
+
+	for {
+		var msg signaling.ClientMessage
+		err := conn.ReadJSON(&msg)
+		if err == nil {
+			err = session.Send(ctx, msg)
+		} else if !isJSONError(err) {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, signaling.ErrClosed) {
+			return
+		}
+		select {
+		case replies <- signaling.ServerMessage{Type: signaling.TypeError, Error: err.Error()}:
+		default:
+		}
+	}
+}
+
+// writeSignals writes the messages for the device and replies to the socket
+// and keeps it alive with pings, until the session is closed or a write
+// fails
+func writeSignals(conn *websocket.Conn, session *signaling.Session, replies <-chan signaling.ServerMessage) {
+	ticker := time.NewTicker(signalPingPeriod)
+	defer ticker.Stop()
+	defer conn.Close()
+
+	for {
+		var msg signaling.ServerMessage
+		select {
+		case <-session.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session closed"),
+				time.Now().Add(signalWriteWait))
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(signalWriteWait)); err != nil {
+				return
+			}
+			continue
+		case msg = <-session.Messages():
+		case msg = <-replies:
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(signalWriteWait))
+		if err := conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+// isJSONError tells malformed messages, after which the socket can still be
+// read, from socket errors
+func isJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
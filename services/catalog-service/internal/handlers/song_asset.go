@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/transcode"
+	"go-audio-stream/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// queueRenditions replaces a song's renditions with pending ones encoded
+// from sourceKey, for the transcoder to pick up. Uploads for songs that do
+// not exist yet are skipped.
+func queueRenditions(db database.Service, songID, sourceKey string) error {
+	if db == nil {
+		return nil
+	}
+
+	var song models.Song
+	if _, err := db.Find(&song, "id = ?", songID); err != nil || song.ID == "" {
+		return err
+	}
+
+	assets := make([]models.SongAsset, len(transcode.DefaultRenditions))
+	for i, r := range transcode.DefaultRenditions {
+		assets[i] = models.SongAsset{
+			SongID:      songID,
+			Rendition:   r.Name,
+			Codec:       r.Codec,
+			Bitrate:     r.Bitrate,
+			ContentType: r.ContentType,
+			Status:      models.SongAssetPending,
+			SourceKey:   sourceKey,
+		}
+	}
+
+	tx := db.GetDB().Begin()
+	if err := tx.Unscoped().Where("song_id = ?", songID).Delete(&models.SongAsset{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&assets).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// songStreamKey resolves a bare songs/{id} stream key to the storage key of
+// the rendition that best matches the client's Accept header. The master is
+// streamed until a rendition is ready. ok is false for any other key.
+func (h *UploadHandler) songStreamKey(c echo.Context, key string) (resolved string, ok bool, err error) {
+	songID, found := strings.CutPrefix(key, "songs/")
+	if !found || uuid.Validate(songID) != nil {
+		return "", false, nil
+	}
+	if h.db == nil {
+		return "", true, c.JSON(http.StatusServiceUnavailable, echo.Map{"error": "Database is not available"})
+	}
+
+	var assets []models.SongAsset
+	if _, err := h.db.Find(&assets, "song_id = ?", songID); err != nil {
+		return "", true, c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(assets) == 0 {
+		return "", true, c.JSON(http.StatusNotFound, echo.Map{"error": "Song has no audio"})
+	}
+
+	// The chosen file depends on Accept, so caches must key on it
+	c.Response().Header().Add(echo.HeaderVary, "Accept")
+	setReplayGainHeaders(c.Response().Header(), h.db, songID)
+
+	ready := map[string]models.SongAsset{}
+	for _, asset := range assets {
+		if asset.Status == models.SongAssetReady {
+			ready[asset.Rendition] = asset
+		}
+	}
+	if len(ready) == 0 {
+		return assets[0].SourceKey, true, nil
+	}
+
+	available := make([]transcode.Rendition, 0, len(ready))
+	for _, r := range transcode.DefaultRenditions {
+		if _, exists := ready[r.Name]; exists {
+			available = append(available, r)
+		}
+	}
+	rendition, acceptable := transcode.Negotiate(c.Request().Header.Get(echo.HeaderAccept), available)
+	if !acceptable {
+		return "", true, c.JSON(http.StatusNotAcceptable, echo.Map{"error": "No rendition matches the Accept header"})
+	}
+
+	c.Response().Header().Set("X-Rendition", rendition.Name)
+	return ready[rendition.Name].Key, true, nil
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AddCreditRequest credits an artist on a song
+type AddCreditRequest struct {
+	ArtistID string `json:"artist_id"`
+	Role     string `json:"role" enums:"primary,featured,composer,lyricist,producer,music_director"`
+	// Position orders the credits; it defaults to after the last credit
+	Position *int `json:"position"`
+}
+
+// sortCredits orders credits for display
+func sortCredits(credits []models.SongCredit) {
+	slices.SortStableFunc(credits, func(a, b models.SongCredit) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.Role, b.Role))
+	})
+}
+
+// FindSongCredits lists a song's artist credits in display order.
+// @Summary      Get song credits
+// @Description  List the artists credited on a song with their roles
+// @Tags         songs
+// @Produce      json
+// @Param        id   path      string  true  "Song ID"
+// @Success      200  {array}   models.SongCredit
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/songs/{id}/credits [get]
+func FindSongCredits(c echo.Context, db database.Service) error {
+	songID := c.Param("id")
+	var credits []models.SongCredit
+
+	if err := db.GetDB().Preload("Artist").Where("song_id = ?", songID).Find(&credits).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	sortCredits(credits)
+
+	return c.JSON(http.StatusOK, credits)
+}
+
+// AddSongCreditHandler credits an artist on a song in a role.
+// @Summary      Add song credit
+// @Description  Credit an artist on a song as primary, featured, composer, lyricist, producer or music_director
+// @Tags         songs
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string            true  "Song ID"
+// @Param        req  body      AddCreditRequest  true  "Credit Data"
+// @Success      201  {object}  models.SongCredit
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/songs/{id}/credits [post]
+func AddSongCreditHandler(c echo.Context, db database.Service) error {
+	songID := c.Param("id")
+	req := new(AddCreditRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	role := models.CreditRole(req.Role)
+	if role == "" {
+		role = models.CreditPrimary
+	}
+	if !role.Valid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "role must be primary, featured, composer, lyricist, producer or music_director"})
+	}
+
+	var song models.Song
+	if result, err := db.Find(&song, "id = ?", songID); err != nil || result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Song not found"})
+	}
+	artist := new(models.Artist)
+	if result, err := db.Find(artist, "id = ?", req.ArtistID); err != nil || result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Artist not found"})
+	}
+
+	var existing []models.SongCredit
+	if _, err := db.Find(&existing, "song_id = ?", songID); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	position := 0
+	for _, credit := range existing {
+		if credit.ArtistID == artist.ID && credit.Role == role {
+			return c.JSON(http.StatusConflict, echo.Map{"error": "Artist is already credited in this role"})
+		}
+		position = max(position, credit.Position+1)
+	}
+	if req.Position != nil {
+		position = *req.Position
+	}
+
+	credit := &models.SongCredit{
+		SongID:   songID,
+		ArtistID: artist.ID,
+		Role:     role,
+		Position: position,
+	}
+	if _, err := db.Create(credit); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	credit.Artist = artist
+
+	return c.JSON(http.StatusCreated, credit)
+}
+
+// RemoveSongCreditHandler removes an artist's credits from a song, only in
+// the given role when role is set.
+// @Summary      Remove song credit
+// @Description  Remove an artist's credits from a song
+// @Tags         songs
+// @Produce      json
+// @Param        id         path      string  true   "Song ID"
+// @Param        artist_id  path      string  true   "Artist ID"
+// @Param        role       query     string  false  "Only remove this role"
+// @Success      200        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /api/v1/songs/{id}/credits/{artist_id} [delete]
+func RemoveSongCreditHandler(c echo.Context, db database.Service) error {
+	songID := c.Param("id")
+	artistID := c.Param("artist_id")
+
+	var err error
+	if role := c.QueryParam("role"); role != "" {
+		_, err = db.Delete(&models.SongCredit{}, "song_id = ? AND artist_id = ? AND role = ?", songID, artistID, role)
+	} else {
+		_, err = db.Delete(&models.SongCredit{}, "song_id = ? AND artist_id = ?", songID, artistID)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Credit removed from song"})
+}
+
+// FindArtistSongs lists the songs an artist is credited on, optionally only
+// in one role, e.g. ?role=composer.
+// @Summary      Get an artist's songs
+// @Description  Get the songs an artist is credited on, filtered by role
+// @Tags         artists
+// @Produce      json
+// @Param        id    path      string  true   "Artist ID"
+// @Param        role  query     string  false  "Credit role"
+// @Success      200   {array}   models.Song
+// @Failure      400   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/artists/{id}/songs [get]
+func FindArtistSongs(c echo.Context, db database.Service) error {
+	artistID := c.Param("id")
+
+	credited := db.GetDB().Model(&models.SongCredit{}).Select("song_id").Where("artist_id = ?", artistID)
+	if role := c.QueryParam("role"); role != "" {
+		if !models.CreditRole(role).Valid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid role"})
+		}
+		credited = credited.Where("role = ?", role)
+	}
+
+	var songs []models.Song
+	err := db.GetDB().Preload("Credits.Artist").Where("id IN (?)", credited).Order("name").Find(&songs).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for i := range songs {
+		sortCredits(songs[i].Credits)
+	}
+
+	return c.JSON(http.StatusOK, songs)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go-audio-stream/pkg/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSortCredits(t *testing.T) {
+	credits := []models.SongCredit{
+		{ArtistID: "producer", Role: models.CreditProducer, Position: 2},
+		{ArtistID: "featured", Role: models.CreditFeatured, Position: 1},
+		{ArtistID: "composer", Role: models.CreditComposer, Position: 0},
+		{ArtistID: "primary", Role: models.CreditPrimary, Position: 0},
+		{ArtistID: "lyricist", Role: models.CreditLyricist, Position: 2},
+	}
+
+	sortCredits(credits)
+
+	// By position, then role
+	want := []string{"composer", "primary", "featured", "lyricist", "producer"}
+	for i, credit := range credits {
+		if credit.ArtistID != want[i] {
+			t.Fatalf("sortCredits() position %d = %s, want order %v", i, credit.ArtistID, want)
+		}
+	}
+}
+
+func TestAddSongCredit(t *testing.T) {
+	db := newCatalogDB(t)
+	db.songs["s1"] = models.Song{BaseModel: models.BaseModel{ID: "s1"}, Name: "Nikes"}
+	for _, id := range []string{"a1", "a2"} {
+		db.artists[id] = models.Artist{BaseModel: models.BaseModel{ID: id}}
+	}
+
+	e := echo.New()
+	e.POST("/songs/:id/credits", func(c echo.Context) error {
+		return AddSongCreditHandler(c, db)
+	})
+	add := func(song, body string) (int, models.SongCredit) {
+		rec := serve(e, http.MethodPost, "/songs/"+song+"/credits", map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON}, body)
+		var credit models.SongCredit
+		if rec.Code == http.StatusCreated {
+			if err := json.Unmarshal(rec.Body.Bytes(), &credit); err != nil {
+				t.Fatalf("response is not a credit: %v", err)
+			}
+		}
+		return rec.Code, credit
+	}
+
+	// The role defaults to primary and credits go after the last
+	for i, body := range []string{`{"artist_id": "a1"}`, `{"artist_id": "a1", "role": "composer"}`} {
+		code, credit := add("s1", body)
+		if code != http.StatusCreated || credit.Position != i {
+			t.Fatalf("AddSongCreditHandler(%s) = %d at position %d, want 201 at %d", body, code, credit.Position, i)
+		}
+	}
+	if db.credits[0].Role != models.CreditPrimary {
+		t.Errorf("credit without a role has role %q", db.credits[0].Role)
+	}
+
+	// An explicit position is kept
+	if code, credit := add("s1", `{"artist_id": "a2", "role": "featured", "position": 0}`); code != http.StatusCreated || credit.Position != 0 {
+		t.Errorf("AddSongCreditHandler() with position 0 = %d at position %d", code, credit.Position)
+	}
+
+	for _, tt := range []struct {
+		song, body string
+		want       int
+	}{
+		{"s1", `{"artist_id": "a1", "role": "primary"}`, http.StatusConflict},
+		{"s1", `{"artist_id": "a1", "role": "drummer"}`, http.StatusBadRequest},
+		{"s1", `{"artist_id": "a3"}`, http.StatusNotFound},
+		{"s2", `{"artist_id": "a1"}`, http.StatusNotFound},
+	} {
+		if code, _ := add(tt.song, tt.body); code != tt.want {
+			t.Errorf("AddSongCreditHandler(%s, %s) status = %d, want %d", tt.song, tt.body, code, tt.want)
+		}
+	}
+	if len(db.credits) != 3 {
+		t.Errorf("AddSongCreditHandler() created %d credits, want 3", len(db.credits))
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/database/query"
 	"go-audio-stream/pkg/models"
 	"net/http"
 
@@ -26,7 +27,9 @@ func CreateSongHandler(c echo.Context, db database.Service) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
-	_, err := db.Create(song)
+	// Artists, tags and instruments are referenced by ID; only the links to
+	// them are created
+	err := db.GetDB().Omit("Artists.*", "Tags.*", "Instruments.*").Create(song).Error
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
@@ -89,43 +92,69 @@ func DeleteSongHandler(c echo.Context, db database.Service) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "Song deleted successfully"})
 }
 
-// FindOneSongById retrieves a song by ID.
+// FindOneSongById retrieves a song by ID with the relations named in
+// include.
 // @Summary      Get a song
 // @Description  Get a song by ID
 // @Tags         songs
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Song ID"
-// @Success      200  {object}  models.Song
-// @Failure      404  {object}  map[string]string
+// @Param        id       path      string  true   "Song ID"
+// @Param        include  query     string  false  "Comma-separated relations: artists, credits, album, features, tags, instruments"
+// @Param        fields   query     string  false  "Comma-separated JSON keys to return"
+// @Success      200      {object}  models.Song
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
 // @Router       /api/v1/songs/{id} [get]
 func FindOneSongById(c echo.Context, db database.Service) error {
 	id := c.Param("id")
 	var song models.Song
 
-	_, err := db.Find(&song, "id = ?", id)
+	tx, err := query.Preload(db.GetDB(), songIncludes, c.QueryParam("include"))
 	if err != nil {
+		return queryError(c, err)
+	}
+	result := tx.Limit(1).Find(&song, "id = ?", id)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
 		return c.JSON(http.StatusNotFound, echo.Map{"error": "Song not found"})
 	}
 
-	return c.JSON(http.StatusOK, song)
+	return jsonFields(c, http.StatusOK, song)
 }
 
-// FindAllSongs retrieves all songs.
+// FindAllSongs retrieves a page of songs.
 // @Summary      Get all songs
-// @Description  Get a list of all songs
+// @Description  Get a page of songs; the envelope's pagination holds the next cursor
 // @Tags         songs
 // @Accept       json
 // @Produce      json
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor  query     string  false  "next_cursor of the previous page"
+// @Param        sort       query     string  false  "name, created_at or duration, prefixed with - for descending"
+// @Param        language   query     string  false  "Language code"
+// @Param        explicit   query     bool    false  "Explicit songs only, or none"
+// @Param        artist_id  query     string  false  "Credited artist ID"
+// @Param        album_id   query     string  false  "Album ID"
+// @Param        include  query     string  false  "Comma-separated relations: artists, credits, album, features, tags, instruments"
+// @Param        fields   query     string  false  "Comma-separated JSON keys to return"
 // @Success      200  {array}   models.Song
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /api/v1/songs/ [get]
 func FindAllSongs(c echo.Context, db database.Service) error {
 	var songs []models.Song
-	_, err := db.Find(&songs)
+	tx, err := query.Preload(db.GetDB(), songIncludes, c.QueryParam("include"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return queryError(c, err)
+	}
+	page, err := query.Find(tx, &songs, songListSpec, c.QueryParams())
+	if err != nil {
+		return queryError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, songs)
+	setPagination(c, page)
+	return jsonFields(c, http.StatusOK, songs)
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+
+	"go-audio-stream/pkg/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LocalStorageHandler serves files written by the local storage backend, so
+// that its signed and public URLs resolve against the catalog service
+type LocalStorageHandler struct {
+	storage *storage.LocalClient
+}
+
+// NewLocalStorageHandler creates a new local storage handler
+func NewLocalStorageHandler(storageClient *storage.LocalClient) *LocalStorageHandler {
+	return &LocalStorageHandler{
+		storage: storageClient,
+	}
+}
+
+// ServeFile serves a stored file. Private files require the expires and
+// signature query parameters produced by GetPresignedURL.
+// GET /storage/*
+func (h *LocalStorageHandler) ServeFile(c echo.Context) error {
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil || key == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Key is required"})
+	}
+
+	signature := c.QueryParam("signature")
+	if signature != "" {
+		if err := h.storage.VerifySignature(key, c.QueryParam("expires"), signature); err != nil {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		}
+	} else if !h.storage.IsPublic(key) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "Signed URL required"})
+	}
+
+	file, info, err := h.storage.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "File not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to read file: " + err.Error()})
+	}
+	defer file.Close()
+
+	if info.ContentType != "" {
+		c.Response().Header().Set(echo.HeaderContentType, info.ContentType)
+	}
+	http.ServeContent(c.Response(), c.Request(), path.Base(key), info.LastModified, file)
+	return nil
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/httprange"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// StreamAudio streams an audio file from storage with byte-range support
+// (RFC 7233) so players can seek without a new presigned URL. A bare
+// songs/{id} key streams the song's transcoded rendition best matching the
+// Accept header. Only song masters and renditions can be streamed.
+// GET /api/v1/stream/*
+func (h *UploadHandler) StreamAudio(c echo.Context) error {
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil || key == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Key is required"})
+	}
+	if !streamable(key) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "File not found"})
+	}
+	if resolved, ok, err := h.songStreamKey(c, key); ok {
+		if err != nil || resolved == "" {
+			return err
+		}
+		key = resolved
+	}
+	return h.streamKey(c, key)
+}
+
+// streamable reports whether key is a song's audio: songs/{id}, resolved to
+// a rendition, songs/{id}/audio.{ext} or songs/{id}/renditions/{file}.
+// Other stored files, such as unfinished uploads, are never streamed.
+func streamable(key string) bool {
+	rest, ok := strings.CutPrefix(key, "songs/")
+	if !ok {
+		return false
+	}
+	songID, file, _ := strings.Cut(rest, "/")
+	if uuid.Validate(songID) != nil {
+		return false
+	}
+	if file == "" {
+		return true
+	}
+	if name, ok := strings.CutPrefix(file, "renditions/"); ok {
+		file = name
+	} else if !strings.HasPrefix(file, "audio.") {
+		return false
+	}
+	return file != "" && file != ".." && !strings.ContainsAny(file, "/\\")
+}
+
+// streamKey serves the file stored under key, honouring Range and
+// conditional request headers
+func (h *UploadHandler) streamKey(c echo.Context, key string) error {
+	ctx := c.Request().Context()
+	info, err := h.storage.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "File not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to stat file: " + err.Error()})
+	}
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	header := c.Response().Header()
+	header.Set("Accept-Ranges", "bytes")
+	if info.ETag != "" {
+		header.Set("ETag", info.ETag)
+	}
+	if !info.LastModified.IsZero() {
+		header.Set(echo.HeaderLastModified, info.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if info.ETag != "" && c.Request().Header.Get("If-None-Match") == info.ETag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// Long streams must not be cut off by the server's WriteTimeout
+	http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+
+	rangeHeader := c.Request().Header.Get("Range")
+	if rangeHeader == "" || !ifRangeMatches(c.Request().Header.Get("If-Range"), info) {
+		return h.streamFull(c, key, info, contentType)
+	}
+
+	ranges, err := httprange.Parse(rangeHeader, info.Size)
+	switch {
+	case errors.Is(err, httprange.ErrUnsatisfiable):
+		header.Set("Content-Range", httprange.Unsatisfied(info.Size))
+		return c.NoContent(http.StatusRequestedRangeNotSatisfiable)
+	case err != nil:
+		// Invalid Range headers are ignored, as RFC 7233 allows
+		return h.streamFull(c, key, info, contentType)
+	case len(ranges) == 1:
+		return h.streamRange(c, key, info, contentType, ranges[0])
+	default:
+		return h.streamMultipart(c, key, info, contentType, ranges)
+	}
+}
+
+func (h *UploadHandler) streamFull(c echo.Context, key string, info *storage.FileInfo, contentType string) error {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(info.Size, 10))
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(http.StatusOK)
+	}
+
+	reader, _, err := h.storage.Get(c.Request().Context(), key)
+	if err != nil {
+		header.Del(echo.HeaderContentLength)
+		return c.JSON(http.StatusBadGateway, echo.Map{"error": "Failed to read file: " + err.Error()})
+	}
+	defer reader.Close()
+
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = io.CopyN(c.Response(), reader, info.Size)
+	return ignoreClientGone(err)
+}
+
+func (h *UploadHandler) streamRange(c echo.Context, key string, info *storage.FileInfo, contentType string, r httprange.Range) error {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Content-Range", r.ContentRange(info.Size))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(r.Length, 10))
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(http.StatusPartialContent)
+	}
+
+	reader, err := h.storage.GetRange(c.Request().Context(), key, r.Start, r.Length)
+	if err != nil {
+		header.Del("Content-Range")
+		header.Del(echo.HeaderContentLength)
+		return c.JSON(http.StatusBadGateway, echo.Map{"error": "Failed to read file: " + err.Error()})
+	}
+	defer reader.Close()
+
+	c.Response().WriteHeader(http.StatusPartialContent)
+	_, err = io.CopyN(c.Response(), reader, r.Length)
+	return ignoreClientGone(err)
+}
+
+func (h *UploadHandler) streamMultipart(c echo.Context, key string, info *storage.FileInfo, contentType string, ranges []httprange.Range) error {
+	body := httprange.Multipart{
+		Ranges:      ranges,
+		Size:        info.Size,
+		ContentType: contentType,
+		Boundary:    randomBoundary(),
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, body.MediaType())
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(body.ContentLength(), 10))
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(http.StatusPartialContent)
+	}
+
+	ctx := c.Request().Context()
+	c.Response().WriteHeader(http.StatusPartialContent)
+	_, err := body.WriteBody(c.Response(), func(r httprange.Range) (io.ReadCloser, error) {
+		return h.storage.GetRange(ctx, key, r.Start, r.Length)
+	})
+	return ignoreClientGone(err)
+}
+
+// ifRangeMatches reports whether the Range header should be honoured given
+// the If-Range precondition. ETags are compared strongly, so weak validators
+// never match.
+func ifRangeMatches(ifRange string, info *storage.FileInfo) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return info.ETag != "" && ifRange == info.ETag
+	}
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+
+	t, err := http.ParseTime(ifRange)
+	if err != nil || info.LastModified.IsZero() {
+		return false
+	}
+	return info.LastModified.Truncate(time.Second).Equal(t)
+}
+
+// ignoreClientGone drops errors caused by the player closing the connection
+// mid-stream, which happens on every seek
+func ignoreClientGone(err error) error {
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+	if errors.Is(err, io.ErrClosedPipe) || strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "connection reset") {
+		return nil
+	}
+	return err
+}
+
+func randomBoundary() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
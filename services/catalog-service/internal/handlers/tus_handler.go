@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/sniff"
+	"go-audio-stream/pkg/middlewares"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+	"go-audio-stream/services/catalog-service/internal/tus"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// chunkReadTimeout replaces the server's ReadTimeout for requests carrying
+// upload data, whose bodies can take minutes on slow connections
+const chunkReadTimeout = 15 * time.Minute
+
+// TusHandler serves resumable uploads of audio masters using the tus 1.0
+// protocol. Finished uploads land under the same songs/{id}/audio.{ext} keys
+// as UploadAudio and are queued for processing.
+type TusHandler struct {
+	store    *tus.Store
+	db       database.Service
+	pipeline *pipeline.Pipeline
+	maxSize  int64
+}
+
+// NewTusHandler creates a tus handler accepting uploads up to maxSize bytes,
+// or the default audio upload limit when maxSize is not positive
+func NewTusHandler(store *tus.Store, db database.Service, audioPipeline *pipeline.Pipeline, maxSize int64) *TusHandler {
+	if maxSize <= 0 {
+		maxSize = DefaultUploadLimits.MaxAudioSize
+	}
+	return &TusHandler{
+		store:    store,
+		db:       db,
+		pipeline: audioPipeline,
+		maxSize:  maxSize,
+	}
+}
+
+// Options advertises the supported protocol version and extensions
+// OPTIONS /api/v1/uploads/tus
+func (h *TusHandler) Options(c echo.Context) error {
+	header := c.Response().Header()
+	header.Set(tus.HeaderResumable, tus.Version)
+	header.Set(tus.HeaderVersion, tus.Version)
+	header.Set(tus.HeaderExtension, tus.Extensions)
+	header.Set(tus.HeaderMaxSize, strconv.FormatInt(h.maxSize, 10))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Create starts an upload. The song is chosen with the song_id metadata
+// key; a new ID is generated when it is missing. Data sent with the request
+// is stored as the first chunk (creation-with-upload).
+// POST /api/v1/uploads/tus
+func (h *TusHandler) Create(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	req := c.Request()
+	if req.Header.Get(tus.HeaderDeferLength) != "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Upload-Defer-Length is not supported"})
+	}
+	length, err := strconv.ParseInt(req.Header.Get(tus.HeaderUploadLength), 10, 64)
+	if err != nil || length < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid Upload-Length"})
+	}
+	if length == 0 {
+		return rejectUpload(c, &sniff.Rejection{Reason: sniff.ReasonEmpty, Message: "File is empty"})
+	}
+	if length > h.maxSize {
+		return rejectUpload(c, sniff.TooLarge(h.maxSize))
+	}
+
+	rawMetadata := req.Header.Get(tus.HeaderUploadMetadata)
+	metadata, err := tus.ParseMetadata(rawMetadata)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	songID := metadata["song_id"]
+	if songID == "" {
+		songID = uuid.New().String()
+	} else if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song_id"})
+	}
+
+	upload := &models.Upload{
+		UserID:   user.ID,
+		SongID:   songID,
+		Filename: metadata["filename"],
+		Metadata: rawMetadata,
+		Length:   length,
+	}
+	if err := h.store.Create(req.Context(), upload); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to create upload: " + err.Error()})
+	}
+
+	header := c.Response().Header()
+	header.Set(tus.HeaderResumable, tus.Version)
+	header.Set(echo.HeaderLocation, c.Scheme()+"://"+req.Host+strings.TrimSuffix(req.URL.Path, "/")+"/"+upload.ID)
+	header.Set(tus.HeaderUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	if req.ContentLength != 0 && req.Header.Get(echo.HeaderContentType) == tus.OffsetContentType {
+		unlock := h.store.Lock(upload.ID)
+		defer unlock()
+		if err := h.append(c, upload, 0); err != nil {
+			return err
+		}
+		header.Set(tus.HeaderUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	}
+
+	return c.NoContent(http.StatusCreated)
+}
+
+// Head reports how much of an upload the server has
+// HEAD /api/v1/uploads/tus/:id
+func (h *TusHandler) Head(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+	upload, err := h.find(c)
+	if err != nil {
+		return err
+	}
+
+	header := c.Response().Header()
+	header.Set(tus.HeaderResumable, tus.Version)
+	header.Set(echo.HeaderCacheControl, "no-store")
+	header.Set(tus.HeaderUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	header.Set(tus.HeaderUploadLength, strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		header.Set(tus.HeaderUploadMetadata, upload.Metadata)
+	}
+	if upload.CompletedAt == nil {
+		header.Set(tus.HeaderUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// Patch appends a chunk at the offset given in Upload-Offset
+// PATCH /api/v1/uploads/tus/:id
+func (h *TusHandler) Patch(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+	req := c.Request()
+	if req.Header.Get(echo.HeaderContentType) != tus.OffsetContentType {
+		return c.JSON(http.StatusUnsupportedMediaType, echo.Map{"error": "Content-Type must be " + tus.OffsetContentType})
+	}
+	offset, err := strconv.ParseInt(req.Header.Get(tus.HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid Upload-Offset"})
+	}
+
+	unlock := h.store.Lock(c.Param("id"))
+	defer unlock()
+
+	// Load after locking so the offset reflects any request that just ended
+	upload, err := h.find(c)
+	if err != nil {
+		return err
+	}
+	if err := h.append(c, upload, offset); err != nil {
+		return err
+	}
+
+	header := c.Response().Header()
+	header.Set(tus.HeaderResumable, tus.Version)
+	header.Set(tus.HeaderUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	if upload.CompletedAt == nil {
+		header.Set(tus.HeaderUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Delete terminates an upload and discards its data
+// DELETE /api/v1/uploads/tus/:id
+func (h *TusHandler) Delete(c echo.Context) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	unlock := h.store.Lock(c.Param("id"))
+	defer unlock()
+
+	upload, err := h.find(c)
+	if err != nil {
+		return err
+	}
+	if upload.CompletedAt != nil {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Upload is already complete"})
+	}
+	if err := h.store.Terminate(c.Request().Context(), upload); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to terminate upload: " + err.Error()})
+	}
+
+	c.Response().Header().Set(tus.HeaderResumable, tus.Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// append streams the request body into the upload and queues the finished
+// file for processing
+func (h *TusHandler) append(c echo.Context, upload *models.Upload, offset int64) error {
+	req := c.Request()
+	if req.ContentLength > 0 && offset+req.ContentLength > upload.Length {
+		return tusError(http.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+	}
+
+	// Large chunks must not be cut off by the server's Read/WriteTimeout
+	rc := http.NewResponseController(c.Response())
+	rc.SetReadDeadline(time.Now().Add(chunkReadTimeout))
+	rc.SetWriteDeadline(time.Now().Add(chunkReadTimeout + time.Minute))
+
+	err := h.store.Append(req.Context(), upload, offset, req.Body)
+	var rejection *sniff.Rejection
+	switch {
+	case err == nil:
+	case errors.Is(err, tus.ErrOffsetMismatch):
+		c.Response().Header().Set(tus.HeaderUploadOffset, strconv.FormatInt(upload.Offset, 10))
+		return tusError(http.StatusConflict, "Upload-Offset does not match the stored offset")
+	case errors.Is(err, tus.ErrCompleted):
+		return tusError(http.StatusForbidden, "Upload is already complete")
+	case errors.As(err, &rejection):
+		if err := h.store.Terminate(req.Context(), upload); err != nil {
+			log.Printf("Failed to terminate rejected upload %s: %v", upload.ID, err)
+		}
+		status, body := rejectionResponse(rejection)
+		return echo.NewHTTPError(status, body)
+	default:
+		return tusError(http.StatusInternalServerError, "Failed to store chunk: "+err.Error())
+	}
+
+	if upload.CompletedAt == nil {
+		return nil
+	}
+	if err := queueRenditions(h.db, upload.SongID, upload.Key); err != nil {
+		log.Printf("Failed to queue renditions for song %s: %v", upload.SongID, err)
+	}
+	if h.pipeline != nil {
+		h.pipeline.Enqueue(pipeline.Job{
+			SongID:      upload.SongID,
+			Key:         upload.Key,
+			ContentType: upload.ContentType,
+		})
+	}
+	return nil
+}
+
+// find loads the upload named in the path, which must belong to the caller.
+// Expired and unknown uploads are reported as 404, as tus allows.
+func (h *TusHandler) find(c echo.Context) (*models.Upload, error) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return nil, tusError(http.StatusNotFound, "Upload not found")
+	}
+
+	upload, err := h.store.Get(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, tus.ErrNotFound) || (err == nil && upload.UserID != user.ID) {
+		return nil, tusError(http.StatusNotFound, "Upload not found")
+	}
+	if err != nil {
+		return nil, tusError(http.StatusInternalServerError, err.Error())
+	}
+	return upload, nil
+}
+
+// checkVersion rejects requests for protocol versions other than 1.0.0
+func (h *TusHandler) checkVersion(c echo.Context) error {
+	if c.Request().Header.Get(tus.HeaderResumable) == tus.Version {
+		return nil
+	}
+	c.Response().Header().Set(tus.HeaderVersion, tus.Version)
+	return tusError(http.StatusPreconditionFailed, "Unsupported Tus-Resumable version")
+}
+
+// tusError is the error response of a failed check. The helpers above
+// return it rather than writing the response, so their callers stop.
+func tusError(status int, message string) *echo.HTTPError {
+	return echo.NewHTTPError(status, echo.Map{"error": message})
+}
+
+// currentUser returns the user set by the auth middleware
+func currentUser(c echo.Context) (models.User, bool) {
+	user, ok := c.Get(middlewares.UserContextKey).(models.User)
+	return user, ok && user.ID != ""
+}
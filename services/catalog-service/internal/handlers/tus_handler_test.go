@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/middlewares"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/tus"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+const testUploadID = "6f1c1a4e-3c1b-4a53-9a43-0d5c3f0b8a11"
+
+// uploadDB runs queries dry, finds only its upload and counts creates
+type uploadDB struct {
+	database.Service
+	db      *gorm.DB
+	upload  *models.Upload
+	created int
+}
+
+func newUploadDB(t *testing.T, upload *models.Upload) *uploadDB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	u := &uploadDB{db: db, upload: upload}
+	db.Callback().Query().After("gorm:query").Register("test:find", func(tx *gorm.DB) {
+		if dest, ok := tx.Statement.Dest.(*models.Upload); ok && u.upload != nil {
+			*dest = *u.upload
+		}
+	})
+	db.Callback().Create().Register("test:create", func(tx *gorm.DB) {
+		u.created++
+	})
+	return u
+}
+
+func (u *uploadDB) GetDB() *gorm.DB {
+	return u.db
+}
+
+func newTusServer(t *testing.T, db *uploadDB) *echo.Echo {
+	t.Helper()
+	client, err := storage.NewLocalClient(storage.Config{LocalPath: t.TempDir(), LocalBaseURL: "http://localhost:4000"})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	h := NewTusHandler(tus.NewStore(client, db, 0, time.Hour), db, nil, 0)
+
+	e := echo.New()
+	g := e.Group("/tus", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(middlewares.UserContextKey, models.User{BaseModel: models.BaseModel{ID: "user-1"}})
+			return next(c)
+		}
+	})
+	g.POST("", h.Create)
+	g.HEAD("/:id", h.Head)
+	g.PATCH("/:id", h.Patch)
+	g.DELETE("/:id", h.Delete)
+	return e
+}
+
+func serve(e *echo.Echo, method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTusRequiresVersion(t *testing.T) {
+	db := newUploadDB(t, nil)
+	e := newTusServer(t, db)
+
+	for _, version := range []string{"", "0.2.2"} {
+		rec := serve(e, http.MethodPost, "/tus", map[string]string{
+			tus.HeaderResumable:    version,
+			tus.HeaderUploadLength: "100",
+		}, "")
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("Create() with Tus-Resumable %q status = %d, want 412", version, rec.Code)
+		}
+		if rec.Header().Get(tus.HeaderVersion) != tus.Version {
+			t.Errorf("Create() with Tus-Resumable %q did not advertise Tus-Version", version)
+		}
+	}
+	if db.created != 0 {
+		t.Errorf("Create() without a supported version created %d uploads", db.created)
+	}
+}
+
+func TestTusUnknownUpload(t *testing.T) {
+	e := newTusServer(t, newUploadDB(t, nil))
+	version := map[string]string{tus.HeaderResumable: tus.Version}
+
+	for _, id := range []string{testUploadID, "not-a-uuid"} {
+		if rec := serve(e, http.MethodHead, "/tus/"+id, version, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("Head(%s) status = %d, want 404", id, rec.Code)
+		}
+		if rec := serve(e, http.MethodDelete, "/tus/"+id, version, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("Delete(%s) status = %d, want 404", id, rec.Code)
+		}
+	}
+}
+
+func TestTusOffsetConflict(t *testing.T) {
+	upload := &models.Upload{
+		BaseModel: models.BaseModel{ID: testUploadID},
+		UserID:    "user-1",
+		SongID:    "song-1",
+		Length:    100,
+		Offset:    10,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	e := newTusServer(t, newUploadDB(t, upload))
+
+	rec := serve(e, http.MethodPatch, "/tus/"+testUploadID, map[string]string{
+		tus.HeaderResumable:    tus.Version,
+		tus.HeaderUploadOffset: "0",
+		echo.HeaderContentType: tus.OffsetContentType,
+	}, "chunk")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Patch() at wrong offset status = %d, want 409", rec.Code)
+	}
+	if got := rec.Header().Get(tus.HeaderUploadOffset); got != "10" {
+		t.Errorf("Patch() at wrong offset reported Upload-Offset %q, want 10", got)
+	}
+	if !strings.Contains(rec.Body.String(), "does not match") {
+		t.Errorf("Patch() at wrong offset body = %s", rec.Body.String())
+	}
+
+	// Another user's upload is not found
+	upload.UserID = "user-2"
+	rec = serve(e, http.MethodHead, "/tus/"+testUploadID, map[string]string{tus.HeaderResumable: tus.Version}, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Head() of another user's upload status = %d, want 404", rec.Code)
+	}
+}
@@ -1,34 +1,75 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
-	"strings"
 	"time"
 
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/artwork"
+	"go-audio-stream/pkg/media/metadata"
+	"go-audio-stream/pkg/media/sniff"
+	"go-audio-stream/pkg/models"
 	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+	"go-audio-stream/services/catalog-service/internal/playlist"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-// UploadHandler holds the storage client for upload operations
+// UploadHandler holds the storage backend for upload operations, the
+// database for applying parsed metadata and the pipeline that processes
+// uploaded audio
 type UploadHandler struct {
-	storage *storage.Client
+	storage  storage.Backend
+	db       database.Service
+	pipeline *pipeline.Pipeline
+	limits   UploadLimits
 }
 
-// NewUploadHandler creates a new upload handler
-func NewUploadHandler(storageClient *storage.Client) *UploadHandler {
+// UploadLimits caps the size of uploaded files in bytes
+type UploadLimits struct {
+	MaxAudioSize int64
+	MaxImageSize int64
+}
+
+// DefaultUploadLimits allows 500 MB audio masters and 10 MB images
+var DefaultUploadLimits = UploadLimits{
+	MaxAudioSize: 500 << 20,
+	MaxImageSize: 10 << 20,
+}
+
+// multipartOverhead allows for the multipart boundaries and form fields
+// around the file when limiting the request body
+const multipartOverhead = 1 << 20
+
+// NewUploadHandler creates a new upload handler. Zero limits fall back to
+// DefaultUploadLimits.
+func NewUploadHandler(storageClient storage.Backend, db database.Service, audioPipeline *pipeline.Pipeline, limits UploadLimits) *UploadHandler {
+	if limits.MaxAudioSize <= 0 {
+		limits.MaxAudioSize = DefaultUploadLimits.MaxAudioSize
+	}
+	if limits.MaxImageSize <= 0 {
+		limits.MaxImageSize = DefaultUploadLimits.MaxImageSize
+	}
 	return &UploadHandler{
-		storage: storageClient,
+		storage:  storageClient,
+		db:       db,
+		pipeline: audioPipeline,
+		limits:   limits,
 	}
 }
 
 // UploadAudioRequest contains metadata for audio upload
 type UploadAudioRequest struct {
-	SongID string `form:"song_id" json:"song_id"`
+	SongID        string `form:"song_id" json:"song_id"`
+	ApplyMetadata bool   `form:"apply_metadata" json:"apply_metadata"`
 }
 
 // UploadResponse is returned after successful upload
@@ -37,29 +78,51 @@ type UploadResponse struct {
 	URL         string `json:"url"`
 	ContentType string `json:"content_type"`
 	Size        int64  `json:"size"`
+
+	// Set for audio uploads whose tags could be parsed
+	Metadata *metadata.Metadata `json:"metadata,omitempty"`
+	CoverURL string             `json:"cover_url,omitempty"`
+
+	// Set for image uploads and audio with embedded cover art
+	Images *models.Images `json:"images,omitempty"`
 }
 
-// UploadAudio handles audio file uploads
+// UploadAudio handles audio file uploads. The format is detected from the
+// file content and its first frames are decoded before anything is stored.
+// Tags, duration and cover art are parsed from the file and returned; with
+// apply_metadata=true they are also written onto the song identified by
+// song_id.
 // POST /api/upload/audio
 func (h *UploadHandler) UploadAudio(c echo.Context) error {
+	// Enforce the size limit while the body is read, before form parsing
+	// buffers it to disk
+	limitRequestBody(c, h.limits.MaxAudioSize)
+
 	// Get song ID from form
 	songID := c.FormValue("song_id")
+	applyMetadata := c.FormValue("apply_metadata") == "true"
 	if songID == "" {
+		if applyMetadata {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "song_id is required with apply_metadata"})
+		}
 		songID = uuid.New().String()
 	}
 
-	// Get the file from the request
-	file, err := c.FormFile("file")
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "No file provided"})
+	var song *models.Song
+	if applyMetadata {
+		if h.db == nil {
+			return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": "Database is not available"})
+		}
+		song = new(models.Song)
+		if _, err := h.db.Find(song, "id = ?", songID); err != nil || song.ID == "" {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Song not found"})
+		}
 	}
 
-	// Validate audio content type
-	contentType := file.Header.Get("Content-Type")
-	if !isValidAudioType(contentType) {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": fmt.Sprintf("Invalid content type: %s. Allowed: audio/mpeg, audio/wav, audio/flac, audio/aac, audio/ogg", contentType),
-		})
+	// Get the file from the request
+	file, err := formFile(c, h.limits.MaxAudioSize)
+	if err != nil {
+		return rejectUpload(c, err)
 	}
 
 	// Open the file
@@ -69,32 +132,145 @@ func (h *UploadHandler) UploadAudio(c echo.Context) error {
 	}
 	defer src.Close()
 
-	// Generate storage key: songs/{song_id}/audio.{ext}
-	ext := filepath.Ext(file.Filename)
-	if ext == "" {
-		ext = getExtensionFromContentType(contentType)
+	// Detect the format from the content; the client's Content-Type is
+	// not trusted
+	detected, err := sniff.ValidateAudio(src)
+	if err != nil {
+		return rejectUpload(c, err)
+	}
+	contentType := detected.MIMEType
+
+	// Parse tags before uploading; formats without a parser still upload
+	meta, err := metadata.Read(src)
+	if err != nil {
+		if !errors.Is(err, metadata.ErrUnsupportedFormat) {
+			log.Printf("Failed to read metadata of %s: %v", file.Filename, err)
+		}
+		meta = nil
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to read file"})
 	}
-	key := fmt.Sprintf("songs/%s/audio%s", songID, ext)
 
-	// Upload to B2 (audio is private, use presigned URLs for access)
-	uploadedKey, err := h.storage.Upload(c.Request().Context(), key, src, contentType)
+	// Generate storage key: songs/{song_id}/audio.{ext}
+	key := fmt.Sprintf("songs/%s/audio%s", songID, detected.Extension)
+
+	// Upload to storage (audio is private, use presigned URLs for access)
+	uploadedKey, err := h.storage.Upload(c.Request().Context(), key, limitReader(src, h.limits.MaxAudioSize), contentType)
 	if err != nil {
+		if errors.Is(err, errFileTooLarge) {
+			return rejectUpload(c, sniff.TooLarge(h.limits.MaxAudioSize))
+		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Upload failed: " + err.Error()})
 	}
 
+	// Embedded cover art is public like other images
+	var coverURL string
+	var coverImages *models.Images
+	if meta != nil && meta.Picture != nil {
+		coverURL, coverImages = h.uploadCoverArt(c, songID, meta.Picture)
+	}
+
+	if song != nil && meta != nil {
+		if err := applySongMetadata(h.db, song, meta, coverURL, coverImages); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to apply metadata: " + err.Error()})
+		}
+	}
+
+	if err := queueRenditions(h.db, songID, uploadedKey); err != nil {
+		log.Printf("Failed to queue renditions for song %s: %v", songID, err)
+	}
+
+	// Package for streaming in the background
+	if h.pipeline != nil {
+		h.pipeline.Enqueue(pipeline.Job{
+			SongID:      songID,
+			Key:         uploadedKey,
+			ContentType: contentType,
+		})
+	}
+
 	return c.JSON(http.StatusCreated, UploadResponse{
 		Key:         uploadedKey,
 		URL:         "", // Audio files use presigned URLs, not direct access
 		ContentType: contentType,
 		Size:        file.Size,
+		Metadata:    meta,
+		CoverURL:    coverURL,
+		Images:      coverImages,
 	})
 }
 
-// UploadImage handles image file uploads (album art, artist images)
+// uploadCoverArt stores embedded cover art as the song's public cover
+// images and returns their primary URL. Pictures that do not decode are
+// skipped.
+func (h *UploadHandler) uploadCoverArt(c echo.Context, songID string, picture *metadata.Picture) (string, *models.Images) {
+	if _, err := sniff.ValidateImage(bytes.NewReader(picture.Data)); err != nil {
+		log.Printf("Skipping cover art for song %s: %v", songID, err)
+		return "", nil
+	}
+
+	images, primaryKey, err := h.storeArtwork(c.Request().Context(), "songs/"+songID, picture.Data)
+	if err != nil {
+		log.Printf("Failed to upload cover art for song %s: %v", songID, err)
+		return "", nil
+	}
+	return h.storage.GetPublicURL(primaryKey), images
+}
+
+// applySongMetadata fills song fields from parsed tags. The duration always
+// comes from the file; other fields are only set when empty so manual edits
+// are kept. Artists are matched by name and created when missing.
+func applySongMetadata(db database.Service, song *models.Song, meta *metadata.Metadata, coverURL string, coverImages *models.Images) error {
+	updates := map[string]interface{}{}
+	if meta.DurationMS > 0 {
+		updates["duration"] = int32((meta.DurationMS + 500) / 1000)
+	}
+	if song.Name == "" && meta.Title != "" {
+		updates["name"] = meta.Title
+	}
+	if song.Image == "" && coverURL != "" {
+		updates["image"] = coverURL
+		updates["images"] = coverImages
+	}
+	if song.Language == "" && meta.Language != "" {
+		updates["language"] = meta.Language
+	}
+	if song.TrackNumber == nil && meta.TrackNumber > 0 {
+		updates["track_number"] = int16(meta.TrackNumber)
+	}
+
+	gormDB := db.GetDB()
+	if len(updates) > 0 {
+		if err := gormDB.Model(song).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(meta.Artists) == 0 {
+		return nil
+	}
+	artists := make([]models.Artist, 0, len(meta.Artists))
+	for _, name := range meta.Artists {
+		var artist models.Artist
+		if err := gormDB.Where("LOWER(name) = LOWER(?)", name).Attrs(models.Artist{Name: name}).FirstOrCreate(&artist).Error; err != nil {
+			return err
+		}
+		artists = append(artists, artist)
+	}
+	return gormDB.Model(song).Omit("Artists.*").Association("Artists").Append(artists)
+}
+
+// UploadImage handles image file uploads (album art, artist images). The
+// format is detected from the file content, and the image is stored as
+// square JPEG and WebP variants with a dominant colour and BlurHash, which
+// are saved on the entity.
 // POST /api/upload/image
 func (h *UploadHandler) UploadImage(c echo.Context) error {
+	limitRequestBody(c, h.limits.MaxImageSize)
+
 	// Get entity info from form
-	entityType := c.FormValue("entity_type") // "song", "artist", "playlist"
+	entityType := c.FormValue("entity_type") // "song", "artist", "album", "playlist"
 	entityID := c.FormValue("entity_id")
 
 	if entityType == "" || entityID == "" {
@@ -102,22 +278,25 @@ func (h *UploadHandler) UploadImage(c echo.Context) error {
 	}
 
 	// Validate entity type
-	if entityType != "song" && entityType != "artist" && entityType != "playlist" {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid entity_type. Allowed: song, artist, playlist"})
+	if entityType != "song" && entityType != "artist" && entityType != "album" && entityType != "playlist" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid entity_type. Allowed: song, artist, album, playlist"})
 	}
 
-	// Get the file from the request
-	file, err := c.FormFile("file")
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "No file provided"})
+	// Only those who may manage a playlist change its cover
+	if entityType == "playlist" && h.db != nil {
+		user, ok := currentUser(c)
+		if !ok {
+			return echo.ErrUnauthorized
+		}
+		if _, _, err := playlist.NewStore(h.db).Authorize(c.Request().Context(), entityID, user.ID, playlist.Manage); err != nil {
+			return playlistError(c, err)
+		}
 	}
 
-	// Validate image content type
-	contentType := file.Header.Get("Content-Type")
-	if !isValidImageType(contentType) {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"error": fmt.Sprintf("Invalid content type: %s. Allowed: image/jpeg, image/png, image/webp, image/gif", contentType),
-		})
+	// Get the file from the request
+	file, err := formFile(c, h.limits.MaxImageSize)
+	if err != nil {
+		return rejectUpload(c, err)
 	}
 
 	// Open the file
@@ -127,27 +306,42 @@ func (h *UploadHandler) UploadImage(c echo.Context) error {
 	}
 	defer src.Close()
 
-	// Generate storage key based on entity: {entity_type}s/{entity_id}/cover.{ext}
-	ext := filepath.Ext(file.Filename)
-	if ext == "" {
-		ext = getImageExtensionFromContentType(contentType)
+	if _, err := sniff.ValidateImage(src); err != nil {
+		return rejectUpload(c, err)
+	}
+	data, err := io.ReadAll(limitReader(src, h.limits.MaxImageSize))
+	if err != nil {
+		if errors.Is(err, errFileTooLarge) {
+			return rejectUpload(c, sniff.TooLarge(h.limits.MaxImageSize))
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to read file"})
 	}
-	key := fmt.Sprintf("%ss/%s/cover%s", entityType, entityID, ext)
 
-	// Upload to B2 with public-read ACL (images are public)
-	uploadedKey, err := h.storage.UploadWithACL(c.Request().Context(), key, src, contentType, "public-read")
+	// Re-encoded at fixed sizes under {entity_type}s/{entity_id}/cover/,
+	// which also drops EXIF data; images are public
+	ctx := c.Request().Context()
+	images, primaryKey, err := h.storeArtwork(ctx, fmt.Sprintf("%ss/%s", entityType, entityID), data)
 	if err != nil {
+		var rejection *sniff.Rejection
+		if errors.As(err, &rejection) {
+			return rejectUpload(c, rejection)
+		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Upload failed: " + err.Error()})
 	}
+	publicURL := h.storage.GetPublicURL(primaryKey)
 
-	// Return public URL for images
-	publicURL := h.storage.GetPublicURL(uploadedKey)
+	if h.db != nil {
+		if err := h.saveEntityImages(ctx, entityType, entityID, publicURL, images); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to save images: " + err.Error()})
+		}
+	}
 
 	return c.JSON(http.StatusCreated, UploadResponse{
-		Key:         uploadedKey,
+		Key:         primaryKey,
 		URL:         publicURL,
-		ContentType: contentType,
+		ContentType: artwork.JPEG.ContentType,
 		Size:        file.Size,
+		Images:      images,
 	})
 }
 
@@ -190,98 +384,90 @@ func (h *UploadHandler) DeleteFile(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "File deleted successfully"})
 }
 
-// StreamAudio streams audio file with range support for seeking
-// GET /api/stream/:key
-func (h *UploadHandler) StreamAudio(c echo.Context) error {
-	key := c.Param("key")
-	if key == "" {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Key is required"})
-	}
+// Helper functions
+
+// limitRequestBody caps the request body at the file limit plus room for
+// the rest of the multipart form
+func limitRequestBody(c echo.Context, limit int64) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, limit+multipartOverhead)
+}
 
-	// Generate a short-lived presigned URL and redirect
-	presignedURL, err := h.storage.GetPresignedURL(c.Request().Context(), key, 5*time.Minute)
+// formFile returns the uploaded "file" field, reporting oversized bodies
+// and files as a size rejection
+func formFile(c echo.Context, limit int64) (*multipart.FileHeader, error) {
+	file, err := c.FormFile("file")
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to generate stream URL: " + err.Error()})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, sniff.TooLarge(limit)
+		}
+		return nil, &sniff.Rejection{Reason: reasonMissingFile, Message: "No file provided"}
 	}
-
-	return c.Redirect(http.StatusTemporaryRedirect, presignedURL)
+	if file.Size > limit {
+		return nil, sniff.TooLarge(limit)
+	}
+	return file, nil
 }
 
-// Helper functions
+// reasonMissingFile rejects requests without a "file" form field
+const reasonMissingFile sniff.Reason = "missing_file"
 
-func isValidAudioType(contentType string) bool {
-	validTypes := []string{
-		"audio/mpeg",
-		"audio/mp3",
-		"audio/wav",
-		"audio/wave",
-		"audio/x-wav",
-		"audio/flac",
-		"audio/x-flac",
-		"audio/aac",
-		"audio/ogg",
-		"audio/vorbis",
-		"audio/webm",
-	}
-	for _, t := range validTypes {
-		if strings.EqualFold(contentType, t) {
-			return true
-		}
-	}
-	return false
+// rejectUpload responds with why an upload was refused. Validation failures
+// carry a machine-readable reason and the detected type.
+func rejectUpload(c echo.Context, err error) error {
+	return c.JSON(rejectionResponse(err))
 }
 
-func isValidImageType(contentType string) bool {
-	validTypes := []string{
-		"image/jpeg",
-		"image/jpg",
-		"image/png",
-		"image/webp",
-		"image/gif",
-	}
-	for _, t := range validTypes {
-		if strings.EqualFold(contentType, t) {
-			return true
-		}
+// rejectionResponse returns the status and body of rejectUpload's response
+func rejectionResponse(err error) (int, echo.Map) {
+	var rejection *sniff.Rejection
+	if !errors.As(err, &rejection) {
+		return http.StatusInternalServerError, echo.Map{"error": "Failed to read file: " + err.Error()}
 	}
-	return false
-}
 
-func getExtensionFromContentType(contentType string) string {
-	switch strings.ToLower(contentType) {
-	case "audio/mpeg", "audio/mp3":
-		return ".mp3"
-	case "audio/wav", "audio/wave", "audio/x-wav":
-		return ".wav"
-	case "audio/flac", "audio/x-flac":
-		return ".flac"
-	case "audio/aac":
-		return ".aac"
-	case "audio/ogg", "audio/vorbis":
-		return ".ogg"
-	case "audio/webm":
-		return ".webm"
-	default:
-		return ".mp3"
+	status := http.StatusUnprocessableEntity
+	switch rejection.Reason {
+	case sniff.ReasonEmpty, reasonMissingFile:
+		status = http.StatusBadRequest
+	case sniff.ReasonTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case sniff.ReasonUnrecognized, sniff.ReasonWrongKind:
+		status = http.StatusUnsupportedMediaType
 	}
-}
 
-func getImageExtensionFromContentType(contentType string) string {
-	switch strings.ToLower(contentType) {
-	case "image/jpeg", "image/jpg":
-		return ".jpg"
-	case "image/png":
-		return ".png"
-	case "image/webp":
-		return ".webp"
-	case "image/gif":
-		return ".gif"
-	default:
-		return ".jpg"
+	body := echo.Map{"error": rejection.Message, "reason": rejection.Reason}
+	if rejection.Detected != "" {
+		body["detected_type"] = rejection.Detected
 	}
+	return status, body
 }
 
-// Utility to read up to a limit (for optional use)
+// errFileTooLarge is returned by readers from limitReader past their limit
+var errFileTooLarge = errors.New("file too large")
+
+// limitReader fails with errFileTooLarge once more than limit bytes have
+// been read, instead of silently truncating like io.LimitReader
 func limitReader(r io.Reader, limit int64) io.Reader {
-	return io.LimitReader(r, limit)
+	return &maxSizeReader{r: r, remaining: limit}
+}
+
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, errFileTooLarge
+	}
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n - int(-m.remaining), errFileTooLarge
+	}
+	return n, err
 }
@@ -42,6 +42,9 @@ func CreateUserHandler(c echo.Context, db database.Service) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "first_name is required"})
 	}
 
+	// Follows are changed through the follow endpoints, which keep the
+	// artists' follower counts in sync
+	user.Follows = nil
 	user.Username = calculateUserName(user.Email)
 	_, err := db.Create(user)
 	if err != nil {
@@ -100,6 +103,7 @@ func UpdateUserHandler(c echo.Context, db database.Service) error {
 	if err := c.Bind(user); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
+	user.Follows = nil
 
 	_, err = db.Update(&models.User{}, user, "id = ?", id)
 
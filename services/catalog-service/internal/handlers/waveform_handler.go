@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go-audio-stream/pkg/media/waveform"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultPixelsPerSecond is the zoom level served when none is requested
+const defaultPixelsPerSecond = 20
+
+// WaveformHandler serves the peak data produced by the waveform step
+type WaveformHandler struct {
+	storage storage.Backend
+}
+
+// NewWaveformHandler creates a new waveform handler
+func NewWaveformHandler(storageClient storage.Backend) *WaveformHandler {
+	return &WaveformHandler{
+		storage: storageClient,
+	}
+}
+
+// Waveform serves a song's waveform in audiowaveform format. Zoom levels
+// between the generated ones are merged from the next finer level.
+// @Summary      Get song waveform
+// @Description  Get min/max peak data of a song for drawing a waveform seek bar
+// @Tags         songs
+// @Produce      json
+// @Produce      application/octet-stream
+// @Param        id                 path      string  true   "Song ID"
+// @Param        pixels_per_second  query     int     false  "Zoom level (default 20)"
+// @Param        format             query     string  false  "json (default) or dat"
+// @Param        bits               query     int     false  "8 (default for json) or 16 (default for dat)"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /api/v1/songs/{id}/waveform [get]
+func (h *WaveformHandler) Waveform(c echo.Context) error {
+	songID := c.Param("id")
+	if _, err := uuid.Parse(songID); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid song ID"})
+	}
+
+	finest := waveform.DefaultPixelsPerSecond[0]
+	pps := defaultPixelsPerSecond
+	if value := c.QueryParam("pixels_per_second"); value != "" {
+		var err error
+		if pps, err = strconv.Atoi(value); err != nil || pps < 1 || pps > finest {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": fmt.Sprintf("pixels_per_second must be between 1 and %d", finest)})
+		}
+	}
+
+	format := c.QueryParam("format")
+	bits := 8
+	switch format {
+	case "", "json":
+		format = "json"
+	case "dat":
+		bits = 16
+	default:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format must be json or dat"})
+	}
+	if value := c.QueryParam("bits"); value != "" {
+		if bits, _ = strconv.Atoi(value); bits != 8 && bits != 16 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "bits must be 8 or 16"})
+		}
+	}
+
+	// The coarsest generated level that is at least as fine as requested
+	level := finest
+	for _, generated := range waveform.DefaultPixelsPerSecond {
+		if generated >= pps {
+			level = generated
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, max-age=300")
+
+	// Stored files are served as they are when they match the request
+	if level == pps && (format == "json" && bits == 8 || format == "dat" && bits == 16) {
+		return h.serveStored(c, pipeline.WaveformKey(songID, level, "."+format))
+	}
+
+	w, err := h.load(c, pipeline.WaveformKey(songID, level, ".dat"))
+	if err != nil {
+		return err
+	}
+	w = w.Resample(waveform.SamplesPerPixel(w.SampleRate, pps))
+	if bits == 8 {
+		w = w.To8Bit()
+	}
+
+	if format == "json" {
+		return c.JSON(http.StatusOK, w)
+	}
+	var dat bytes.Buffer
+	if err := w.WriteDat(&dat); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to encode waveform: " + err.Error()})
+	}
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, dat.Bytes())
+}
+
+func (h *WaveformHandler) serveStored(c echo.Context, key string) error {
+	reader, info, err := h.storage.Get(c.Request().Context(), key)
+	if err != nil {
+		return waveformError(err)
+	}
+	defer reader.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+	return c.Stream(http.StatusOK, contentType, reader)
+}
+
+func (h *WaveformHandler) load(c echo.Context, key string) (*waveform.Waveform, error) {
+	reader, _, err := h.storage.Get(c.Request().Context(), key)
+	if err != nil {
+		return nil, waveformError(err)
+	}
+	defer reader.Close()
+
+	w, err := waveform.ReadDat(io.LimitReader(reader, 64<<20))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, echo.Map{"error": "Failed to read waveform: " + err.Error()})
+	}
+	return w, nil
+}
+
+// waveformError is the error response for a waveform that cannot be read.
+// It is returned rather than written, so callers stop, and is not written
+// over a response that has already started.
+func waveformError(err error) *echo.HTTPError {
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, echo.Map{"error": "Waveform not available yet"})
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, echo.Map{"error": "Failed to read waveform: " + err.Error()})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-audio-stream/pkg/media/waveform"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestWaveform(t *testing.T) {
+	const songID = "0b3f5a9e-9d8e-4c49-8b1f-2f0e6c1d7a42"
+	client, err := storage.NewLocalClient(storage.Config{LocalPath: t.TempDir(), LocalBaseURL: "http://localhost:4000"})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	e := echo.New()
+	e.GET("/songs/:id/waveform", NewWaveformHandler(client).Waveform)
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/songs/"+songID+"/waveform"+query, nil))
+		return rec
+	}
+
+	// Not generated yet, whether served as stored or resampled
+	for _, query := range []string{"", "?pixels_per_second=30"} {
+		if rec := get(query); rec.Code != http.StatusNotFound {
+			t.Errorf("GET waveform%s before generation status = %d, want 404", query, rec.Code)
+		}
+	}
+
+	// One second at 50 pixels per second, resampled to 25
+	w := &waveform.Waveform{SampleRate: 1000, SamplesPerPixel: 20, Bits: 16, Data: make([]int16, 100)}
+	var dat bytes.Buffer
+	if err := w.WriteDat(&dat); err != nil {
+		t.Fatalf("WriteDat() error = %v", err)
+	}
+	if _, err := client.Upload(context.Background(), pipeline.WaveformKey(songID, 50, ".dat"), &dat, echo.MIMEOctetStream); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	rec := get("?pixels_per_second=25")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET waveform?pixels_per_second=25 status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		SamplesPerPixel int `json:"samples_per_pixel"`
+		Bits            int `json:"bits"`
+		Length          int `json:"length"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if body.SamplesPerPixel != 40 || body.Bits != 8 || body.Length != 25 {
+		t.Errorf("resampled waveform = %+v, want 40 samples per pixel, 8 bits, 25 pixels", body)
+	}
+}
@@ -0,0 +1,226 @@
+// Package httprange parses HTTP Range headers (RFC 7233) and writes
+// multipart/byteranges bodies.
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxRanges is the number of ranges accepted in one request after
+// coalescing. Requests with more ranges are treated as invalid so the caller
+// can fall back to a full response.
+const MaxRanges = 16
+
+var (
+	// ErrInvalid is returned for a syntactically invalid Range header. Per
+	// RFC 7233 the server should ignore the header and send the full body.
+	ErrInvalid = errors.New("invalid range")
+	// ErrUnsatisfiable is returned when no range overlaps the content. The
+	// server should answer 416 Range Not Satisfiable.
+	ErrUnsatisfiable = errors.New("range not satisfiable")
+)
+
+// Range is a byte range resolved against a known content length
+type Range struct {
+	Start  int64
+	Length int64
+}
+
+// End returns the offset of the last byte in the range
+func (r Range) End() int64 {
+	return r.Start + r.Length - 1
+}
+
+// ContentRange returns the Content-Range header value for the range
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End(), size)
+}
+
+// Unsatisfied returns the Content-Range header value for a 416 response
+func Unsatisfied(size int64) string {
+	return fmt.Sprintf("bytes */%d", size)
+}
+
+// Parse parses a Range header value against content of the given size.
+// Overlapping and adjacent ranges are coalesced, so the result is sorted.
+func Parse(header string, size int64) ([]Range, error) {
+	const prefix = "bytes="
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, ErrInvalid
+	}
+
+	var ranges []Range
+	specs := 0
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		specs++
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, ErrInvalid
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var r Range
+		if first == "" {
+			// Suffix range: the last N bytes
+			suffix, err := parseOffset(last)
+			if err != nil {
+				return nil, ErrInvalid
+			}
+			if suffix == 0 || size == 0 {
+				continue
+			}
+			if suffix > size {
+				suffix = size
+			}
+			r = Range{Start: size - suffix, Length: suffix}
+		} else {
+			start, err := parseOffset(first)
+			if err != nil {
+				return nil, ErrInvalid
+			}
+			end := size - 1
+			if last != "" {
+				end, err = parseOffset(last)
+				if err != nil || end < start {
+					return nil, ErrInvalid
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				continue
+			}
+			r = Range{Start: start, Length: end - start + 1}
+		}
+		ranges = append(ranges, r)
+	}
+
+	if specs == 0 {
+		return nil, ErrInvalid
+	}
+	if len(ranges) == 0 {
+		return nil, ErrUnsatisfiable
+	}
+
+	ranges = coalesce(ranges)
+	if len(ranges) > MaxRanges {
+		return nil, ErrInvalid
+	}
+
+	return ranges, nil
+}
+
+// coalesce merges overlapping and adjacent ranges
+func coalesce(ranges []Range) []Range {
+	if len(ranges) < 2 {
+		return ranges
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End()+1 {
+			if r.End() > last.End() {
+				last.Length = r.End() - last.Start + 1
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+func parseOffset(s string) (int64, error) {
+	if s == "" || strings.ContainsAny(s, "+-") {
+		return 0, ErrInvalid
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Multipart describes a multipart/byteranges body for several ranges of the
+// same content
+type Multipart struct {
+	Ranges      []Range
+	Size        int64
+	ContentType string
+	Boundary    string
+}
+
+// MediaType returns the Content-Type header value for the multipart body
+func (m Multipart) MediaType() string {
+	return "multipart/byteranges; boundary=" + m.Boundary
+}
+
+// ContentLength returns the exact length of the body written by WriteBody
+func (m Multipart) ContentLength() int64 {
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	mw.SetBoundary(m.Boundary)
+	for _, r := range m.Ranges {
+		mw.CreatePart(m.partHeader(r))
+		counter += countingWriter(r.Length)
+	}
+	mw.Close()
+	return int64(counter)
+}
+
+// WriteBody writes the multipart body to w, opening each range with open
+func (m Multipart) WriteBody(w io.Writer, open func(Range) (io.ReadCloser, error)) (int64, error) {
+	counter := new(countingWriter)
+	mw := multipart.NewWriter(io.MultiWriter(w, counter))
+	if err := mw.SetBoundary(m.Boundary); err != nil {
+		return 0, err
+	}
+
+	for _, r := range m.Ranges {
+		part, err := mw.CreatePart(m.partHeader(r))
+		if err != nil {
+			return int64(*counter), err
+		}
+
+		reader, err := open(r)
+		if err != nil {
+			return int64(*counter), err
+		}
+		_, err = io.CopyN(part, reader, r.Length)
+		reader.Close()
+		if err != nil {
+			return int64(*counter), err
+		}
+	}
+
+	err := mw.Close()
+	return int64(*counter), err
+}
+
+func (m Multipart) partHeader(r Range) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {r.ContentRange(m.Size)},
+		"Content-Type":  {m.ContentType},
+	}
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
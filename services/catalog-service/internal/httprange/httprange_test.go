@@ -0,0 +1,109 @@
+package httprange
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		want   []Range
+		err    error
+	}{
+		{"bytes=0-499", 1000, []Range{{0, 500}}, nil},
+		{"bytes=500-", 1000, []Range{{500, 500}}, nil},
+		{"bytes=-200", 1000, []Range{{800, 200}}, nil},
+		{"bytes=-2000", 1000, []Range{{0, 1000}}, nil},
+		{"bytes=900-1999", 1000, []Range{{900, 100}}, nil},
+		{"bytes=0-0,-1", 1000, []Range{{0, 1}, {999, 1}}, nil},
+		{"bytes=500-599, 0-99", 1000, []Range{{0, 100}, {500, 100}}, nil},
+		{"bytes=0-99,50-149,150-199", 1000, []Range{{0, 200}}, nil},
+		{"BYTES=0-1", 10, []Range{{0, 2}}, nil},
+		{"bytes=1000-", 1000, nil, ErrUnsatisfiable},
+		{"bytes=-0", 1000, nil, ErrUnsatisfiable},
+		{"bytes=0-", 0, nil, ErrUnsatisfiable},
+		{"bytes=5-1", 1000, nil, ErrInvalid},
+		{"bytes=abc", 1000, nil, ErrInvalid},
+		{"bytes=", 1000, nil, ErrInvalid},
+		{"bytes=-", 1000, nil, ErrInvalid},
+		{"bytes=--5", 1000, nil, ErrInvalid},
+		{"items=0-5", 1000, nil, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.header, tt.size)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %d) error = %v, want %v", tt.header, tt.size, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q, %d) = %v, want %v", tt.header, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestParseTooManyRanges(t *testing.T) {
+	header := "bytes=" + strings.Join([]string{
+		"0-0", "2-2", "4-4", "6-6", "8-8", "10-10", "12-12", "14-14", "16-16",
+		"18-18", "20-20", "22-22", "24-24", "26-26", "28-28", "30-30", "32-32",
+	}, ",")
+	if _, err := Parse(header, 1000); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Parse() with %d ranges error = %v, want ErrInvalid", MaxRanges+1, err)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	body := Multipart{
+		Ranges:      []Range{{0, 3}, {10, 5}},
+		Size:        int64(len(content)),
+		ContentType: "audio/mpeg",
+		Boundary:    "testboundary",
+	}
+
+	var buf bytes.Buffer
+	n, err := body.WriteBody(&buf, func(r Range) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content[r.Start : r.Start+r.Length])), nil
+	})
+	if err != nil {
+		t.Fatalf("WriteBody() error = %v", err)
+	}
+	if n != int64(buf.Len()) || n != body.ContentLength() {
+		t.Errorf("WriteBody() wrote %d bytes, buffer %d, ContentLength() %d", n, buf.Len(), body.ContentLength())
+	}
+
+	_, params, err := mime.ParseMediaType(body.MediaType())
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	reader := multipart.NewReader(&buf, params["boundary"])
+
+	wantParts := []struct{ contentRange, data string }{
+		{"bytes 0-2/20", "012"},
+		{"bytes 10-14/20", "abcde"},
+	}
+	for _, want := range wantParts {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Range"); got != want.contentRange {
+			t.Errorf("part Content-Range = %q, want %q", got, want.contentRange)
+		}
+		if string(data) != want.data {
+			t.Errorf("part data = %q, want %q", data, want.data)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("NextPart() after last part error = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package lexorank generates sort keys that order lexicographically, so an
+// item can be placed between two others by giving it a key between theirs
+// without renumbering the rest of the list.
+//
+// Keys are base-36 fractions written with the digits 0-9 and a-z, which
+// sort the same bytewise and under the usual database collations. Keys
+// never end in 0, so there is always room below every key.
+package lexorank
+
+import (
+	"errors"
+	"strings"
+)
+
+// Digits are the key digits in ascending order
+const Digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(Digits)
+
+// ErrInvalidKey is returned for keys outside the alphabet, keys ending in 0
+// and bounds that are not in ascending order
+var ErrInvalidKey = errors.New("invalid lexorank key")
+
+// Between returns a key sorting strictly between a and b. An empty a is the
+// start of the list and an empty b its end.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) || (b != "" && a >= b) {
+		return "", ErrInvalidKey
+	}
+	return midpoint(a, b), nil
+}
+
+// Spread returns n ascending keys between a and b, spaced so that the keys
+// stay short
+func Spread(a, b string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	mid, err := Between(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := Spread(a, mid, n/2)
+	if err != nil {
+		return nil, err
+	}
+	right, err := Spread(mid, b, n-n/2-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(left, mid), right...), nil
+}
+
+func valid(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(Digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(key, "0")
+}
+
+// midpoint returns a key between a and b, where a < b, b == "" means the end
+// and neither ends in 0
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix, reading a as padded with zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(Digits, a[0])
+	}
+	high := base
+	if b != "" {
+		high = strings.IndexByte(Digits, b[0])
+	}
+	if high-low > 1 {
+		return string(Digits[(low+high+1)/2])
+	}
+	// The first digits are adjacent. A longer b is above its first digit.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(Digits[low]) + midpoint(tail(a, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return Digits[0]
+}
+
+func tail(key string, i int) string {
+	if i < len(key) {
+		return key[i:]
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package lexorank
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "i"},
+		{"i", ""},
+		{"a", "b"},
+		{"a", "a1"},
+		{"az", "b"},
+		{"z", ""},
+		{"zz", ""},
+		{"", "01"},
+		{"00000001i", "00000002i"},
+		{"1", "11"},
+	}
+
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got <= tt.a || (tt.b != "" && got >= tt.b) || !valid(got) {
+			t.Errorf("Between(%q, %q) = %q", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestBetweenRejectsInvalidKeys(t *testing.T) {
+	for _, tt := range [][2]string{{"b", "a"}, {"a", "a"}, {"A", ""}, {"a0", ""}, {"", "-"}} {
+		if _, err := Between(tt[0], tt[1]); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Between(%q, %q) error = %v, want ErrInvalidKey", tt[0], tt[1], err)
+		}
+	}
+}
+
+func TestRepeatedInsertsStayOrdered(t *testing.T) {
+	keys := []string{}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		at := r.Intn(len(keys) + 1)
+		if i%3 == 0 {
+			at = len(keys) // appends are the common case
+		}
+		var a, b string
+		if at > 0 {
+			a = keys[at-1]
+		}
+		if at < len(keys) {
+			b = keys[at]
+		}
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", a, b, err)
+		}
+		keys = slices.Insert(keys, at, key)
+	}
+	if !slices.IsSorted(keys) {
+		t.Fatal("keys are not sorted")
+	}
+	if len(slices.Compact(slices.Clone(keys))) != len(keys) {
+		t.Fatal("keys are not unique")
+	}
+}
+
+func TestSpread(t *testing.T) {
+	keys, err := Spread("", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1000 || !slices.IsSorted(keys) {
+		t.Fatalf("Spread returned %d keys, sorted %v", len(keys), slices.IsSorted(keys))
+	}
+	for _, key := range keys {
+		if len(key) > 3 {
+			t.Errorf("key %q is longer than needed", key)
+		}
+	}
+
+	keys, err = Spread("a", "b", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[0] <= "a" || keys[4] >= "b" || !slices.IsSorted(keys) {
+		t.Errorf("Spread(a, b, 5) = %v", keys)
+	}
+}
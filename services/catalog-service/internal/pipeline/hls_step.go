@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-audio-stream/pkg/media/hls"
+	"go-audio-stream/pkg/storage"
+)
+
+// HLSKey returns the storage key of a file in a song's HLS package
+func HLSKey(songID, name string) string {
+	return fmt.Sprintf("songs/%s/hls/%s", songID, name)
+}
+
+// HLSStep packages the master into HLS variants and uploads them under
+// songs/{song_id}/hls/
+type HLSStep struct {
+	storage  storage.Backend
+	packager hls.Packager
+	variants []hls.Variant
+}
+
+// NewHLSStep creates an HLS packaging step
+func NewHLSStep(storageClient storage.Backend, packager hls.Packager, variants []hls.Variant) *HLSStep {
+	return &HLSStep{
+		storage:  storageClient,
+		packager: packager,
+		variants: variants,
+	}
+}
+
+func (s *HLSStep) Name() string {
+	return "hls"
+}
+
+func (s *HLSStep) Run(ctx context.Context, job Job) error {
+	outputDir, err := os.MkdirTemp("", "hls-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := s.packager.Package(ctx, job.Path, outputDir, s.variants); err != nil {
+		return err
+	}
+
+	var files []string
+	err = filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list packaged files: %w", err)
+	}
+
+	// Upload segments first and playlists last, the master playlist at the
+	// very end, so a visible playlist never references missing files
+	sort.SliceStable(files, func(i, j int) bool {
+		return uploadOrder(files[i]) < uploadOrder(files[j])
+	})
+
+	for _, name := range files {
+		if err := s.upload(ctx, job.SongID, outputDir, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *HLSStep) upload(ctx context.Context, songID, outputDir, name string) error {
+	file, err := os.Open(filepath.Join(outputDir, filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if _, err := s.storage.Upload(ctx, HLSKey(songID, name), file, hlsContentType(name)); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+func uploadOrder(name string) int {
+	switch {
+	case name == hls.MasterPlaylistName:
+		return 2
+	case strings.HasSuffix(name, ".m3u8"):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func hlsContentType(name string) string {
+	switch filepath.Ext(name) {
+	case ".m3u8":
+		return hls.PlaylistContentType
+	case ".ts":
+		return "video/mp2t"
+	default:
+		return "audio/mp4"
+	}
+}
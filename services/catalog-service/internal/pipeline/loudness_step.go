@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/loudness"
+	"go-audio-stream/pkg/models"
+)
+
+// LoudnessStep measures the master per EBU R128 and stores the loudness and
+// ReplayGain values on the song and its features
+type LoudnessStep struct {
+	analyzer loudness.Analyzer
+	db       database.Service
+}
+
+// NewLoudnessStep creates a loudness analysis step
+func NewLoudnessStep(analyzer loudness.Analyzer, db database.Service) *LoudnessStep {
+	return &LoudnessStep{
+		analyzer: analyzer,
+		db:       db,
+	}
+}
+
+func (s *LoudnessStep) Name() string {
+	return "loudness"
+}
+
+func (s *LoudnessStep) Run(ctx context.Context, job Job) error {
+	result, err := s.analyzer.Analyze(ctx, job.Path)
+	if errors.Is(err, loudness.ErrSilent) {
+		log.Printf("Song %s is silent, skipping loudness", job.SongID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	gormDB := s.db.GetDB().WithContext(ctx)
+	err = gormDB.Model(&models.Song{}).Where("id = ?", job.SongID).Updates(map[string]interface{}{
+		"integrated_loudness": float32(result.Integrated),
+		"loudness_range":      float32(result.Range),
+		"true_peak":           float32(result.TruePeak),
+		"track_gain":          float32(result.TrackGain()),
+		"track_peak":          float32(result.TrackPeak()),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return gormDB.Model(&models.SongFeatures{}).Where("song_id = ?", job.SongID).
+		Update("loudness", float32(result.Integrated)).Error
+}
@@ -0,0 +1,133 @@
+// Package pipeline runs background processing steps on uploaded audio.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-audio-stream/pkg/storage"
+)
+
+// Job describes an uploaded audio master
+type Job struct {
+	SongID      string
+	Key         string
+	ContentType string
+
+	// Path is a local copy of the master, valid while the steps run
+	Path string
+}
+
+// Step is one processing step run after an audio upload
+type Step interface {
+	Name() string
+	Run(ctx context.Context, job Job) error
+}
+
+// Pipeline downloads each uploaded master once and runs the steps on it in
+// order. Jobs run in the background with bounded concurrency.
+type Pipeline struct {
+	storage storage.Backend
+	steps   []Step
+	timeout time.Duration
+	slots   chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a pipeline running at most concurrency jobs at a time, each
+// limited to timeout
+func New(storageClient storage.Backend, concurrency int, timeout time.Duration, steps ...Step) *Pipeline {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pipeline{
+		storage: storageClient,
+		steps:   steps,
+		timeout: timeout,
+		slots:   make(chan struct{}, concurrency),
+	}
+}
+
+// Enqueue schedules job to run in the background. Failures are logged.
+func (p *Pipeline) Enqueue(job Job) {
+	if len(p.steps) == 0 {
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		p.slots <- struct{}{}
+		defer func() { <-p.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		defer cancel()
+
+		if err := p.Run(ctx, job); err != nil {
+			log.Printf("Pipeline failed for song %s: %v", job.SongID, err)
+		}
+	}()
+}
+
+// Wait blocks until all enqueued jobs have finished
+func (p *Pipeline) Wait() {
+	p.wg.Wait()
+}
+
+// Run downloads the master for job and runs every step on it. A failing step
+// is logged and does not stop the remaining steps.
+func (p *Pipeline) Run(ctx context.Context, job Job) error {
+	dir, err := os.MkdirTemp("", "pipeline-*")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	job.Path = filepath.Join(dir, "master"+path.Ext(job.Key))
+	if err := p.download(ctx, job.Key, job.Path); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, step := range p.steps {
+		started := time.Now()
+		if err := step.Run(ctx, job); err != nil {
+			log.Printf("Pipeline step %s failed for song %s: %v", step.Name(), job.SongID, err)
+			failed++
+			continue
+		}
+		log.Printf("Pipeline step %s completed for song %s in %s", step.Name(), job.SongID, time.Since(started).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(p.steps))
+	}
+	return nil
+}
+
+func (p *Pipeline) download(ctx context.Context, key, dest string) error {
+	reader, _, err := p.storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download master: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create local copy: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to download master: %w", err)
+	}
+	return file.Close()
+}
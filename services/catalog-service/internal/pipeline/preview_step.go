@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/preview"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+)
+
+// PreviewKey returns the storage key of a song's preview clip
+func PreviewKey(songID string) string {
+	return "songs/" + songID + "/preview" + preview.Extension
+}
+
+// PreviewStep cuts the preview clip served to unauthenticated listeners. It
+// starts at the song's editor-set preview_start_ms, or at the loudest stretch
+// of the track when none is set.
+type PreviewStep struct {
+	storage storage.Backend
+	db      database.Service
+	finder  preview.StartFinder
+	clipper preview.Clipper
+}
+
+// NewPreviewStep creates a preview clip step
+func NewPreviewStep(storageClient storage.Backend, db database.Service, finder preview.StartFinder, clipper preview.Clipper) *PreviewStep {
+	return &PreviewStep{
+		storage: storageClient,
+		db:      db,
+		finder:  finder,
+		clipper: clipper,
+	}
+}
+
+func (s *PreviewStep) Name() string {
+	return "preview"
+}
+
+func (s *PreviewStep) Run(ctx context.Context, job Job) error {
+	start, err := s.start(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	output := filepath.Join(filepath.Dir(job.Path), "preview"+preview.Extension)
+	if err := s.clipper.Clip(ctx, job.Path, output, start); err != nil {
+		return err
+	}
+	defer os.Remove(output)
+
+	file, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := s.storage.Upload(ctx, PreviewKey(job.SongID), file, preview.ContentType); err != nil {
+		return fmt.Errorf("failed to upload preview: %w", err)
+	}
+	return nil
+}
+
+// start returns where the clip begins, keeping it within the song when its
+// duration is known
+func (s *PreviewStep) start(ctx context.Context, job Job) (time.Duration, error) {
+	length := s.clipper.Duration()
+
+	// Masters may be uploaded before their song exists
+	var song models.Song
+	if err := s.db.GetDB().WithContext(ctx).Select("id", "duration", "preview_start_ms").Where("id = ?", job.SongID).Limit(1).Find(&song).Error; err != nil {
+		return 0, fmt.Errorf("failed to load song: %w", err)
+	}
+	if song.PreviewStartMS == nil {
+		return s.finder.FindStart(ctx, job.Path, length)
+	}
+
+	start := time.Duration(*song.PreviewStartMS) * time.Millisecond
+	if song.Duration > 0 {
+		start = min(start, time.Duration(song.Duration)*time.Second-length)
+	}
+	return max(start, 0), nil
+}
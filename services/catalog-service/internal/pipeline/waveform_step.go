@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-audio-stream/pkg/media/waveform"
+	"go-audio-stream/pkg/storage"
+)
+
+// WaveformKey returns the storage key of a song's waveform at a zoom level,
+// with ext ".dat" or ".json"
+func WaveformKey(songID string, pixelsPerSecond int, ext string) string {
+	return fmt.Sprintf("songs/%s/waveform/%d%s", songID, pixelsPerSecond, ext)
+}
+
+// WaveformStep generates peak data at several zoom levels and uploads each
+// as a 16-bit .dat file and an 8-bit .json file under
+// songs/{song_id}/waveform/
+type WaveformStep struct {
+	storage         storage.Backend
+	generator       waveform.Generator
+	pixelsPerSecond []int
+}
+
+// NewWaveformStep creates a waveform generation step
+func NewWaveformStep(storageClient storage.Backend, generator waveform.Generator, pixelsPerSecond []int) *WaveformStep {
+	return &WaveformStep{
+		storage:         storageClient,
+		generator:       generator,
+		pixelsPerSecond: pixelsPerSecond,
+	}
+}
+
+func (s *WaveformStep) Name() string {
+	return "waveform"
+}
+
+func (s *WaveformStep) Run(ctx context.Context, job Job) error {
+	waveforms, err := s.generator.Generate(ctx, job.Path, s.pixelsPerSecond)
+	if err != nil {
+		return err
+	}
+
+	for i, w := range waveforms {
+		pps := s.pixelsPerSecond[i]
+
+		var dat bytes.Buffer
+		if err := w.WriteDat(&dat); err != nil {
+			return fmt.Errorf("failed to encode waveform: %w", err)
+		}
+		if _, err := s.storage.Upload(ctx, WaveformKey(job.SongID, pps, ".dat"), &dat, "application/octet-stream"); err != nil {
+			return fmt.Errorf("failed to upload waveform: %w", err)
+		}
+
+		data, err := json.Marshal(w.To8Bit())
+		if err != nil {
+			return fmt.Errorf("failed to encode waveform: %w", err)
+		}
+		if _, err := s.storage.Upload(ctx, WaveformKey(job.SongID, pps, ".json"), bytes.NewReader(data), "application/json"); err != nil {
+			return fmt.Errorf("failed to upload waveform: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package playlist
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"go-audio-stream/pkg/models"
+)
+
+// ErrForbidden is returned when a user may see a playlist but not perform
+// the requested action on it
+var ErrForbidden = errors.New("not allowed to change this playlist")
+
+// Action is something a user wants to do with a playlist
+type Action int
+
+const (
+	// View reads the playlist and its entries
+	View Action = iota
+	// Edit adds, moves and removes entries
+	Edit
+	// Manage changes or deletes the playlist and its members
+	Manage
+)
+
+// Allowed reports whether a user with role may perform action on p. Public
+// playlists can be viewed by anyone, private ones only by their owner and
+// members. Editors can edit the entries while the playlist is collaborative.
+func Allowed(p *models.Playlist, role models.PlaylistRole, action Action) bool {
+	switch action {
+	case View:
+		return !p.Private || role.AtLeast(models.PlaylistViewer)
+	case Edit:
+		return role == models.PlaylistOwner || (role == models.PlaylistEditor && p.IsCollaborative)
+	}
+	return role == models.PlaylistOwner
+}
+
+// Authorize loads a playlist and the role userID has on it, and checks that
+// the user may perform action. Playlists the user cannot view are reported
+// as ErrNotFound so private playlists stay hidden, and editing the entries
+// of a smart playlist as ErrComputed.
+func (s *Store) Authorize(ctx context.Context, playlistID, userID string, action Action) (*models.Playlist, models.PlaylistRole, error) {
+	playlist, role, err := s.Role(ctx, playlistID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !Allowed(playlist, role, View) {
+		return nil, "", ErrNotFound
+	}
+	if !Allowed(playlist, role, action) {
+		return nil, "", ErrForbidden
+	}
+	if action == Edit && playlist.Rules != nil {
+		return nil, "", ErrComputed
+	}
+	return playlist, role, nil
+}
+
+// Role loads a playlist and the role userID has on it: owner for its
+// creator, the member role for members and none otherwise
+func (s *Store) Role(ctx context.Context, playlistID, userID string) (*models.Playlist, models.PlaylistRole, error) {
+	db := s.db.GetDB().WithContext(ctx)
+	var playlist models.Playlist
+	result := db.Limit(1).Find(&playlist, "id = ?", playlistID)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", ErrNotFound
+	}
+
+	if playlist.CreatorUserID != nil && *playlist.CreatorUserID == userID {
+		return &playlist, models.PlaylistOwner, nil
+	}
+	if userID == "" {
+		return &playlist, "", nil
+	}
+	var member models.PlaylistMember
+	err := db.Where("playlist_id = ? AND user_id = ?", playlistID, userID).Limit(1).Find(&member).Error
+	if err != nil {
+		return nil, "", err
+	}
+	return &playlist, member.Role, nil
+}
+
+// NewInviteToken returns a random invite token and the hash to store
+func NewInviteToken() (token, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashInviteToken(token), nil
+}
+
+// HashInviteToken returns the stored form of an invite token
+func HashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package playlist
+
+import (
+	"testing"
+
+	"go-audio-stream/pkg/models"
+)
+
+func TestAllowed(t *testing.T) {
+	public := &models.Playlist{}
+	private := &models.Playlist{Private: true}
+	collaborative := &models.Playlist{Private: true, IsCollaborative: true}
+
+	tests := []struct {
+		playlist *models.Playlist
+		role     models.PlaylistRole
+		action   Action
+		want     bool
+	}{
+		{public, "", View, true},
+		{public, "", Edit, false},
+		{private, "", View, false},
+		{private, models.PlaylistViewer, View, true},
+		{private, models.PlaylistViewer, Edit, false},
+		{private, models.PlaylistEditor, Edit, false},
+		{collaborative, models.PlaylistEditor, Edit, true},
+		{collaborative, models.PlaylistEditor, Manage, false},
+		{collaborative, models.PlaylistViewer, Edit, false},
+		{private, models.PlaylistOwner, Edit, true},
+		{private, models.PlaylistOwner, Manage, true},
+	}
+
+	for _, tt := range tests {
+		if got := Allowed(tt.playlist, tt.role, tt.action); got != tt.want {
+			t.Errorf("Allowed(private=%v collaborative=%v, %q, %d) = %v, want %v",
+				tt.playlist.Private, tt.playlist.IsCollaborative, tt.role, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestInviteToken(t *testing.T) {
+	token, hash, err := NewInviteToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 32 || hash != HashInviteToken(token) || hash == token {
+		t.Errorf("token %q, hash %q", token, hash)
+	}
+	other, _, _ := NewInviteToken()
+	if other == token {
+		t.Error("tokens repeat")
+	}
+}
@@ -0,0 +1,191 @@
+package playlist
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go-audio-stream/pkg/database/search"
+	"go-audio-stream/pkg/database/search/translit"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/playlistfile"
+)
+
+// MinScore is the lowest score accepted as a match. A track with the right
+// title but another artist stays below it.
+const MinScore = 0.75
+
+// candidateLimit is how many catalog songs found by search are compared
+// with each track
+const candidateLimit = 10
+
+// Score weights of the compared fields
+const (
+	titleWeight    = 0.6
+	artistWeight   = 0.3
+	durationWeight = 0.1
+)
+
+// Candidate is a song an imported track may refer to
+type Candidate struct {
+	Title      string
+	Artists    []string
+	DurationMS int
+	// Location is the file path of local songs
+	Location string
+}
+
+// Match is the song found for an imported track, if any
+type Match struct {
+	Track       playlistfile.Track `json:"track"`
+	SongID      string             `json:"song_id,omitempty"`
+	LocalSongID string             `json:"local_song_id,omitempty"`
+	Score       float64            `json:"score,omitempty"`
+}
+
+// MatchTracks finds the catalog song best matching each track or, failing
+// that, the best matching local song of userID. Catalog candidates come
+// from search, so typos and other scripts are found as well.
+func (s *Store) MatchTracks(ctx context.Context, userID string, tracks []playlistfile.Track) ([]Match, error) {
+	db := s.db.GetDB().WithContext(ctx)
+	var locals []models.UserLocalSong
+	if userID != "" {
+		if err := db.Where("user_id = ?", userID).Find(&locals).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	matches := make([]Match, len(tracks))
+	for i, track := range tracks {
+		match := &matches[i]
+		match.Track = track
+
+		if q, err := search.Parse(cleanTitle(track.Title)); err == nil {
+			var songs []models.Song
+			if err := search.Find(db.Preload("Artists"), search.Song, q, candidateLimit, &songs); err != nil {
+				return nil, err
+			}
+			for _, song := range songs {
+				if score := Score(track, songCandidate(song)); score >= MinScore && score > match.Score {
+					match.SongID, match.Score = song.ID, score
+				}
+			}
+		}
+		if match.SongID != "" {
+			continue
+		}
+		for _, local := range locals {
+			candidate := Candidate{Title: local.Title, DurationMS: local.DurationMS, Location: local.FilePath}
+			if local.Artist != "" {
+				candidate.Artists = []string{local.Artist}
+			}
+			if score := Score(track, candidate); score >= MinScore && score > match.Score {
+				match.LocalSongID, match.Score = local.ID, score
+			}
+		}
+	}
+	return matches, nil
+}
+
+func songCandidate(song models.Song) Candidate {
+	c := Candidate{Title: song.Name, DurationMS: int(song.Duration) * 1000}
+	for _, artist := range song.Artists {
+		c.Artists = append(c.Artists, artist.Name)
+	}
+	return c
+}
+
+// Score rates from 0 to 1 how well c matches track by title, artist and
+// duration. Names are compared by their phonetic keys, so other spellings
+// and scripts of a name match too. Fields missing on either side are left
+// out, and a file name shared with a local song is a full match.
+func Score(track playlistfile.Track, c Candidate) float64 {
+	if track.Location != "" && c.Location != "" && fileName(track.Location) == fileName(c.Location) {
+		return 1
+	}
+
+	title := similarity(cleanTitle(track.Title), cleanTitle(c.Title))
+	if title < 0.5 {
+		return 0
+	}
+	score, weight := titleWeight*title, titleWeight
+
+	if track.Artist != "" && len(c.Artists) > 0 {
+		artist := similarity(track.Artist, strings.Join(c.Artists, " "))
+		for _, name := range c.Artists {
+			artist = max(artist, similarity(track.Artist, name))
+		}
+		score += artistWeight * artist
+		weight += artistWeight
+	}
+
+	if track.DurationMS > 0 && c.DurationMS > 0 {
+		// Durations within 2 seconds match fully, 15 seconds apart not at all
+		diff := track.DurationMS - c.DurationMS
+		if diff < 0 {
+			diff = -diff
+		}
+		score += durationWeight * min(1, max(0, float64(15000-diff)/13000))
+		weight += durationWeight
+	}
+	return score / weight
+}
+
+// bracketed matches (...) and [...] parts of titles such as "(Remastered)"
+var bracketed = regexp.MustCompile(`\s*[(\[][^)\]]*[)\]]`)
+
+// cleanTitle drops bracketed parts of a title unless nothing else is left
+func cleanTitle(title string) string {
+	if cleaned := strings.TrimSpace(bracketed.ReplaceAllString(title, "")); cleaned != "" {
+		return cleaned
+	}
+	return title
+}
+
+// similarity is the Dice coefficient of the letter pairs of the phonetic
+// keys of a and b
+func similarity(a, b string) float64 {
+	ka, kb := translit.Phonetic(a), translit.Phonetic(b)
+	if ka == "" || kb == "" {
+		return 0
+	}
+	if ka == kb {
+		return 1
+	}
+	pa, pb := pairs(ka), pairs(kb)
+	counts := make(map[string]int, len(pa))
+	for _, p := range pa {
+		counts[p]++
+	}
+	common := 0
+	for _, p := range pb {
+		if counts[p] > 0 {
+			counts[p]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(pa)+len(pb))
+}
+
+// pairs returns the adjacent letter pairs of each word, padded with spaces
+func pairs(s string) []string {
+	var out []string
+	for _, word := range strings.Fields(s) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+1 < len(runes); i++ {
+			out = append(out, string(runes[i:i+2]))
+		}
+	}
+	return out
+}
+
+// fileName returns the last element of a path or URL, without extension
+func fileName(location string) string {
+	if i := strings.LastIndexAny(location, `/\`); i >= 0 {
+		location = location[i+1:]
+	}
+	if i := strings.LastIndexByte(location, '.'); i > 0 {
+		location = location[:i]
+	}
+	return strings.ToLower(location)
+}
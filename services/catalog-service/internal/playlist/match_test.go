@@ -0,0 +1,46 @@
+package playlist
+
+import (
+	"testing"
+
+	"go-audio-stream/services/catalog-service/internal/playlistfile"
+)
+
+func TestScore(t *testing.T) {
+	showkali := Candidate{Title: "Showkali", Artists: []string{"A. R. Rahman", "Shashaa Tirupati"}, DurationMS: 270000}
+
+	tests := []struct {
+		name  string
+		track playlistfile.Track
+		c     Candidate
+		match bool
+	}{
+		{"exact", playlistfile.Track{Title: "Showkali", Artist: "A. R. Rahman", DurationMS: 270000}, showkali, true},
+		{"spelling", playlistfile.Track{Title: "Shaukali", Artist: "AR Rahman"}, showkali, true},
+		{"script", playlistfile.Track{Title: "ஷௌக்காளி"}, showkali, true},
+		{"suffix", playlistfile.Track{Title: "Showkali (From \"Acham Yenbadhu Madamaiyada\")", Artist: "Shashaa Tirupati"}, showkali, true},
+		{"duration off", playlistfile.Track{Title: "Showkali", Artist: "A. R. Rahman", DurationMS: 200000}, showkali, true},
+		{"other artist", playlistfile.Track{Title: "Showkali", Artist: "Ilaiyaraaja", DurationMS: 200000}, showkali, false},
+		{"other title", playlistfile.Track{Title: "Thalli Pogathey", Artist: "A. R. Rahman"}, showkali, false},
+		{"file name", playlistfile.Track{Title: "Track 1", Location: "/sdcard/Music/01 showkali.MP3"}, Candidate{Title: "x", Location: "C:/rips/01 Showkali.flac"}, true},
+	}
+
+	for _, tt := range tests {
+		score := Score(tt.track, tt.c)
+		if (score >= MinScore) != tt.match {
+			t.Errorf("%s: Score() = %.2f, want match %v", tt.name, score, tt.match)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := similarity("Ilaya Nila", "Ilaiya Nilaa"); s < 0.8 {
+		t.Errorf("similarity of spellings = %.2f", s)
+	}
+	if s := similarity("Ilaya Nila", "Roja"); s > 0.3 {
+		t.Errorf("similarity of different names = %.2f", s)
+	}
+	if s := similarity("", "Roja"); s != 0 {
+		t.Errorf("similarity with empty name = %.2f", s)
+	}
+}
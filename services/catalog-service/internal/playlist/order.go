@@ -0,0 +1,170 @@
+package playlist
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/lexorank"
+)
+
+// maxRankLength is the rank length beyond which all entries of a playlist
+// are ranked afresh. Repeated inserts at one spot grow ranks by about a digit
+// every five inserts.
+const maxRankLength = 24
+
+// insert returns entries with added placed before index position, or
+// appended when position is nil
+func insert(entries, added []models.PlaylistSong, position *int) ([]models.PlaylistSong, error) {
+	at := len(entries)
+	if position != nil {
+		at = *position
+	}
+	if at < 0 || at > len(entries) {
+		return nil, fmt.Errorf("%w: position must be between 0 and %d", ErrInvalid, len(entries))
+	}
+	return slices.Insert(slices.Clone(entries), at, added...), nil
+}
+
+// move returns entries with the length entries starting at start moved
+// before the entry at index before, which indexes the order before the move
+func move(entries []models.PlaylistSong, start, length, before int) ([]models.PlaylistSong, error) {
+	if length < 1 || start < 0 || start+length > len(entries) {
+		return nil, fmt.Errorf("%w: range must lie within the %d entries", ErrInvalid, len(entries))
+	}
+	if before < 0 || before > len(entries) {
+		return nil, fmt.Errorf("%w: insert_before must be between 0 and %d", ErrInvalid, len(entries))
+	}
+	if before >= start && before <= start+length {
+		return entries, nil
+	}
+
+	moved := slices.Clone(entries[start : start+length])
+	rest := slices.Delete(slices.Clone(entries), start, start+length)
+	if before > start {
+		before -= length
+	}
+	return slices.Insert(rest, before, moved...), nil
+}
+
+// reorder returns entries in the order of ids, which must name every entry
+// exactly once
+func reorder(entries []models.PlaylistSong, ids []string) ([]models.PlaylistSong, error) {
+	if len(ids) != len(entries) {
+		return nil, fmt.Errorf("%w: entry_ids must list all %d entries", ErrInvalid, len(entries))
+	}
+	byID := make(map[string]models.PlaylistSong, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	ordered := make([]models.PlaylistSong, 0, len(ids))
+	for _, id := range ids {
+		e, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %s is not in the playlist or listed twice", ErrInvalid, id)
+		}
+		delete(byID, id)
+		ordered = append(ordered, e)
+	}
+	return ordered, nil
+}
+
+// remove returns entries without those matching drop
+func remove(entries []models.PlaylistSong, drop func(models.PlaylistSong) bool) []models.PlaylistSong {
+	return slices.DeleteFunc(slices.Clone(entries), drop)
+}
+
+// rank gives the entries ascending ranks in their current order and
+// returns the indexes of the entries whose rank changed. Entries without a
+// rank are new. The longest run of existing ranks that is already ascending
+// is kept, so a move only rewrites the moved entries.
+func rank(entries []models.PlaylistSong) ([]int, error) {
+	kept := ascendingRun(entries)
+
+	var changed []int
+	for i := 0; i < len(entries); {
+		if kept[i] {
+			i++
+			continue
+		}
+		end := i
+		for end < len(entries) && !kept[end] {
+			end++
+		}
+		var low, high string
+		if i > 0 {
+			low = entries[i-1].Rank
+		}
+		if end < len(entries) {
+			high = entries[end].Rank
+		}
+		ranks, err := lexorank.Spread(low, high, end-i)
+		if err != nil {
+			return nil, err
+		}
+		for j, r := range ranks {
+			if len(r) > maxRankLength {
+				return rebalance(entries)
+			}
+			entries[i+j].Rank = r
+			changed = append(changed, i+j)
+		}
+		i = end
+	}
+	return changed, nil
+}
+
+// rebalance ranks all entries afresh with evenly spaced short ranks
+func rebalance(entries []models.PlaylistSong) ([]int, error) {
+	ranks, err := lexorank.Spread("", "", len(entries))
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]int, len(entries))
+	for i := range entries {
+		entries[i].Rank = ranks[i]
+		changed[i] = i
+	}
+	return changed, nil
+}
+
+// ascendingRun marks the entries of a longest subsequence whose existing
+// ranks strictly ascend
+func ascendingRun(entries []models.PlaylistSong) []bool {
+	// tails[k] is the index ending the best subsequence of length k+1
+	var tails []int
+	prev := make([]int, len(entries))
+	for i, e := range entries {
+		prev[i] = -1
+		if e.Rank == "" {
+			continue
+		}
+		k, _ := slices.BinarySearchFunc(tails, e.Rank, func(t int, r string) int {
+			return strings.Compare(entries[t].Rank, r)
+		})
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	kept := make([]bool, len(entries))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			kept[i] = true
+		}
+	}
+	return kept
+}
+
+// Number sets each entry's Position to its index
+func Number(entries []models.PlaylistSong) {
+	for i := range entries {
+		entries[i].Position = i
+	}
+}
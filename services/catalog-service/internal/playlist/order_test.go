@@ -0,0 +1,149 @@
+package playlist
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-audio-stream/pkg/models"
+)
+
+// entries returns a playlist with one entry per letter of ids, ranked in
+// that order
+func entries(ids string) []models.PlaylistSong {
+	list := make([]models.PlaylistSong, len(ids))
+	for i, id := range ids {
+		list[i] = models.PlaylistSong{ID: string(id), Rank: string(rune('b' + i))}
+	}
+	return list
+}
+
+func order(list []models.PlaylistSong) string {
+	var b strings.Builder
+	for _, e := range list {
+		b.WriteString(e.ID)
+	}
+	return b.String()
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		position *int
+		want     string
+	}{
+		{nil, "abcXY"},
+		{intPtr(0), "XYabc"},
+		{intPtr(2), "abXYc"},
+		{intPtr(3), "abcXY"},
+	}
+
+	for _, tt := range tests {
+		got, err := insert(entries("abc"), entries("XY"), tt.position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order(got) != tt.want {
+			t.Errorf("insert at %v = %s, want %s", tt.position, order(got), tt.want)
+		}
+	}
+
+	if _, err := insert(entries("abc"), entries("X"), intPtr(4)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("insert past the end error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		start, length, before int
+		want                  string
+	}{
+		{0, 1, 3, "bcad"},
+		{0, 1, 4, "bcda"},
+		{3, 1, 0, "dabc"},
+		{1, 2, 4, "adbc"},
+		{2, 2, 0, "cdab"},
+		{1, 2, 1, "abcd"},
+		{1, 2, 3, "abcd"},
+	}
+
+	for _, tt := range tests {
+		got, err := move(entries("abcd"), tt.start, tt.length, tt.before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order(got) != tt.want {
+			t.Errorf("move(%d, %d, %d) = %s, want %s", tt.start, tt.length, tt.before, order(got), tt.want)
+		}
+	}
+
+	for _, args := range [][3]int{{3, 2, 0}, {0, 0, 1}, {0, 1, 5}, {-1, 1, 0}} {
+		if _, err := move(entries("abcd"), args[0], args[1], args[2]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("move%v error = %v, want ErrInvalid", args, err)
+		}
+	}
+}
+
+func TestReorder(t *testing.T) {
+	got, err := reorder(entries("abc"), []string{"c", "a", "b"})
+	if err != nil || order(got) != "cab" {
+		t.Errorf("reorder = %s, %v", order(got), err)
+	}
+	for _, ids := range [][]string{{"a", "b"}, {"a", "a", "b"}, {"a", "b", "x"}} {
+		if _, err := reorder(entries("abc"), ids); !errors.Is(err, ErrInvalid) {
+			t.Errorf("reorder(%v) error = %v, want ErrInvalid", ids, err)
+		}
+	}
+}
+
+func TestRankRewritesOnlyMovedEntries(t *testing.T) {
+	list, err := move(entries("abcdef"), 4, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = append(list, models.PlaylistSong{ID: "X"})
+
+	changed, err := rank(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, i := range changed {
+		ids = append(ids, list[i].ID)
+	}
+	if !slices.Equal(ids, []string{"e", "X"}) {
+		t.Errorf("changed entries = %v, want [e X]", ids)
+	}
+	assertRanked(t, list)
+}
+
+func TestRankRebalancesLongRanks(t *testing.T) {
+	list := entries("ab")
+	for i := 0; i < 200; i++ {
+		var err error
+		list, err = insert(list, []models.PlaylistSong{{ID: "x"}}, intPtr(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rank(list); err != nil {
+			t.Fatal(err)
+		}
+		assertRanked(t, list)
+	}
+	for _, e := range list {
+		if len(e.Rank) > maxRankLength {
+			t.Fatalf("rank %q is longer than %d", e.Rank, maxRankLength)
+		}
+	}
+}
+
+func assertRanked(t *testing.T, list []models.PlaylistSong) {
+	t.Helper()
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Rank >= list[i].Rank {
+			t.Fatalf("ranks %q and %q are not ascending", list[i-1].Rank, list[i].Rank)
+		}
+	}
+}
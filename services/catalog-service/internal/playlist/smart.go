@@ -0,0 +1,140 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/services/catalog-service/internal/smart"
+
+	"github.com/google/uuid"
+)
+
+// RefreshInterval is how long the computed entries of a smart playlist are
+// served before they are computed again
+const RefreshInterval = time.Hour
+
+// ErrComputed is returned when entries of a smart playlist are edited; they
+// are computed from its rules
+var ErrComputed = errors.New("smart playlist entries are computed from its rules")
+
+// Refresh computes the entries of smart playlist p from its rules, with
+// listens counted for its owner, and sets p's snapshot ID and refresh time.
+// The snapshot ID only changes when the songs or their order do.
+func (s *Store) Refresh(ctx context.Context, p *models.Playlist) error {
+	var owner string
+	if p.CreatorUserID != nil {
+		owner = *p.CreatorUserID
+	}
+	now := time.Now()
+	q, err := smart.Compile(p.Rules, owner, now)
+	if err != nil {
+		return err
+	}
+
+	db := s.db.GetDB().WithContext(ctx)
+	var songIDs, current []string
+	err = db.Model(&models.Song{}).Joins(smart.Join).Where(q.Where, q.Args...).
+		Order(q.Order).Limit(q.Limit).Pluck("songs.id", &songIDs).Error
+	if err != nil {
+		return err
+	}
+	err = db.Model(&models.PlaylistSong{}).Where("playlist_id = ?", p.ID).Order("rank, id").Pluck("song_id", &current).Error
+	if err != nil {
+		return err
+	}
+
+	if !slices.Equal(current, songIDs) {
+		snapshot, err := s.edit(ctx, p.ID, "", func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+			return computed(entries, p.ID, songIDs), nil
+		})
+		if err != nil {
+			return err
+		}
+		p.SnapshotID = snapshot
+	}
+
+	// The model does not write refreshed_at, so clients cannot set it
+	if err := db.Exec("UPDATE playlists SET refreshed_at = ? WHERE id = ?", now, p.ID).Error; err != nil {
+		return err
+	}
+	p.RefreshedAt = &now
+	return nil
+}
+
+// RefreshStale refreshes p when it is a smart playlist whose entries are
+// older than RefreshInterval
+func (s *Store) RefreshStale(ctx context.Context, p *models.Playlist) error {
+	if p.Rules == nil || (p.RefreshedAt != nil && time.Since(*p.RefreshedAt) < RefreshInterval) {
+		return nil
+	}
+	return s.Refresh(ctx, p)
+}
+
+// RefreshSmartPlaylists refreshes every smart playlist whose entries are
+// older than RefreshInterval and returns how many were refreshed
+func (s *Store) RefreshSmartPlaylists(ctx context.Context) (int, error) {
+	var playlists []models.Playlist
+	err := s.db.GetDB().WithContext(ctx).
+		Where("rules IS NOT NULL AND (refreshed_at IS NULL OR refreshed_at < ?)", time.Now().Add(-RefreshInterval)).
+		Find(&playlists).Error
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for i := range playlists {
+		if err := s.Refresh(ctx, &playlists[i]); err != nil {
+			log.Printf("Failed to refresh smart playlist %s: %v", playlists[i].ID, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// RunRefresh calls RefreshSmartPlaylists every interval until ctx is
+// cancelled
+func (s *Store) RunRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshed, err := s.RefreshSmartPlaylists(ctx)
+			if err != nil {
+				log.Printf("Failed to refresh smart playlists: %v", err)
+			} else if refreshed > 0 {
+				log.Printf("Refreshed %d smart playlists", refreshed)
+			}
+		}
+	}
+}
+
+// computed orders the entries of a playlist as songIDs. Entries of songs
+// already in the playlist are kept, so their IDs survive a refresh and only
+// moved entries are ranked again.
+func computed(entries []models.PlaylistSong, playlistID string, songIDs []string) []models.PlaylistSong {
+	bySong := make(map[string]models.PlaylistSong, len(entries))
+	for _, e := range entries {
+		if _, ok := bySong[e.SongID]; !ok {
+			bySong[e.SongID] = e
+		}
+	}
+
+	ordered := make([]models.PlaylistSong, 0, len(songIDs))
+	for _, songID := range songIDs {
+		e, ok := bySong[songID]
+		if !ok {
+			e = models.PlaylistSong{ID: uuid.New().String(), PlaylistID: playlistID, SongID: songID}
+		}
+		ordered = append(ordered, e)
+	}
+	return ordered
+}
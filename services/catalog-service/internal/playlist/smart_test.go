@@ -0,0 +1,37 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+
+	"go-audio-stream/pkg/models"
+)
+
+func TestComputedKeepsEntriesOfRemainingSongs(t *testing.T) {
+	list := entries("abc")
+	for i := range list {
+		list[i].SongID = "song-" + list[i].ID
+	}
+	// A repeated song keeps its first entry
+	list = append(list, models.PlaylistSong{ID: "d", SongID: "song-a", Rank: "x"})
+
+	ordered := computed(list, "playlist-1", []string{"song-c", "song-new", "song-a"})
+	if len(ordered) != 3 || ordered[0].ID != "c" || ordered[2].ID != "a" {
+		t.Fatalf("computed = %+v", ordered)
+	}
+	added := ordered[1]
+	if added.ID == "" || added.SongID != "song-new" || added.PlaylistID != "playlist-1" || added.Rank != "" {
+		t.Errorf("new entry = %+v", added)
+	}
+
+	changed, err := rank(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c and a cannot both keep their ranks; one of them moves with the new
+	// entry
+	if len(changed) != 2 || !slices.Contains(changed, 1) {
+		t.Errorf("changed entries = %v", changed)
+	}
+	assertRanked(t, ordered)
+}
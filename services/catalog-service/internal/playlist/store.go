@@ -0,0 +1,242 @@
+// Package playlist decides who may read and change playlists and edits their
+// ordered entries. Every edit runs in a transaction that first replaces the
+// playlist's snapshot ID, which locks the playlist row until the edit
+// commits, so concurrent edits apply one after the other and a client
+// holding an outdated snapshot ID is told so instead of editing a list it
+// has not seen.
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// MaxInsert is the most songs added in one request
+const MaxInsert = 100
+
+var (
+	ErrNotFound         = errors.New("playlist not found")
+	ErrSnapshotMismatch = errors.New("playlist snapshot has changed")
+	ErrInvalid          = errors.New("invalid playlist edit")
+)
+
+// Store reads and edits playlist entries
+type Store struct {
+	db database.Service
+}
+
+// NewStore creates a playlist store
+func NewStore(db database.Service) *Store {
+	return &Store{db: db}
+}
+
+// detailColumns are the columns of a playlist's details. They are written
+// even when zero, so flags can be turned off and rules cleared.
+var detailColumns = []string{"name", "image", "private", "description", "is_collaborative", "rules", "creator_artist_id"}
+
+// Update saves the details of playlist id from p; its entries, snapshot,
+// creator and artwork are left alone
+func (s *Store) Update(ctx context.Context, id string, p *models.Playlist) error {
+	return s.db.GetDB().WithContext(ctx).Model(&models.Playlist{}).Where("id = ?", id).
+		Select(detailColumns).Updates(p).Error
+}
+
+// Entries returns the snapshot ID and the ordered entries of a playlist,
+// each with its song and the song's artists
+func (s *Store) Entries(ctx context.Context, playlistID string) (string, []models.PlaylistSong, error) {
+	var playlist models.Playlist
+	db := s.db.GetDB().WithContext(ctx)
+	result := db.Select("id", "snapshot_id").Limit(1).Find(&playlist, "id = ?", playlistID)
+	if result.Error != nil {
+		return "", nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", nil, ErrNotFound
+	}
+
+	var entries []models.PlaylistSong
+	err := db.Preload("Song.Artists").Where("playlist_id = ?", playlistID).Order("rank, id").Find(&entries).Error
+	if err != nil {
+		return "", nil, err
+	}
+	Number(entries)
+	return playlist.SnapshotID, entries, nil
+}
+
+// Insert adds songs on behalf of userID before the entry at index position,
+// or at the end when position is nil, and returns the new snapshot ID and
+// entries
+func (s *Store) Insert(ctx context.Context, playlistID, snapshotID, userID string, songIDs []string, position *int) (string, []models.PlaylistSong, error) {
+	if len(songIDs) == 0 || len(songIDs) > MaxInsert {
+		return "", nil, fmt.Errorf("%w: add between 1 and %d songs", ErrInvalid, MaxInsert)
+	}
+	var count int64
+	err := s.db.GetDB().WithContext(ctx).Model(&models.Song{}).Where("id IN ?", songIDs).Count(&count).Error
+	if err != nil {
+		return "", nil, err
+	}
+	if int(count) != len(distinct(songIDs)) {
+		return "", nil, fmt.Errorf("%w: song not found", ErrInvalid)
+	}
+
+	added := make([]models.PlaylistSong, len(songIDs))
+	for i, songID := range songIDs {
+		added[i] = models.PlaylistSong{ID: uuid.New().String(), PlaylistID: playlistID, SongID: songID, AddedBy: &userID}
+	}
+	var ordered []models.PlaylistSong
+	snapshot, err := s.edit(ctx, playlistID, snapshotID, func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+		var err error
+		ordered, err = insert(entries, added, position)
+		return ordered, err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	Number(ordered)
+	index := make(map[string]int, len(ordered))
+	for i, e := range ordered {
+		index[e.ID] = i
+	}
+	for i := range added {
+		added[i] = ordered[index[added[i].ID]]
+	}
+	return snapshot, added, nil
+}
+
+// Move moves length entries starting at index start before the entry at
+// index before, counted in the order before the move
+func (s *Store) Move(ctx context.Context, playlistID, snapshotID string, start, length, before int) (string, error) {
+	return s.edit(ctx, playlistID, snapshotID, func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+		return move(entries, start, length, before)
+	})
+}
+
+// Reorder puts the entries in the order of entryIDs, which must list every
+// entry of the playlist once
+func (s *Store) Reorder(ctx context.Context, playlistID, snapshotID string, entryIDs []string) (string, error) {
+	return s.edit(ctx, playlistID, snapshotID, func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+		return reorder(entries, entryIDs)
+	})
+}
+
+// RemoveEntry removes one entry
+func (s *Store) RemoveEntry(ctx context.Context, playlistID, snapshotID, entryID string) (string, error) {
+	return s.edit(ctx, playlistID, snapshotID, func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+		kept := remove(entries, func(e models.PlaylistSong) bool { return e.ID == entryID })
+		if len(kept) == len(entries) {
+			return nil, fmt.Errorf("%w: entry %s is not in the playlist", ErrInvalid, entryID)
+		}
+		return kept, nil
+	})
+}
+
+// RemoveSong removes every entry of a song
+func (s *Store) RemoveSong(ctx context.Context, playlistID, snapshotID, songID string) (string, error) {
+	return s.edit(ctx, playlistID, snapshotID, func(entries []models.PlaylistSong) ([]models.PlaylistSong, error) {
+		return remove(entries, func(e models.PlaylistSong) bool { return e.SongID == songID }), nil
+	})
+}
+
+// edit replaces the entries of a playlist with the order returned by apply
+// and returns the new snapshot ID. New entries in the order are created,
+// missing ones deleted and only entries whose rank changed are updated. An
+// empty snapshotID skips the concurrency check.
+func (s *Store) edit(ctx context.Context, playlistID, snapshotID string, apply func([]models.PlaylistSong) ([]models.PlaylistSong, error)) (string, error) {
+	tx := s.db.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return "", tx.Error
+	}
+	defer tx.Rollback()
+
+	// The model only writes snapshot_id on create, so clients cannot set it
+	next := uuid.New().String()
+	sql := "UPDATE playlists SET snapshot_id = ? WHERE id = ? AND deleted_at IS NULL"
+	args := []interface{}{next, playlistID}
+	if snapshotID != "" {
+		sql += " AND snapshot_id = ?"
+		args = append(args, snapshotID)
+	}
+	result := tx.Exec(sql, args...)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(&models.Playlist{}).Where("id = ?", playlistID).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "", ErrNotFound
+		}
+		return "", ErrSnapshotMismatch
+	}
+
+	var entries []models.PlaylistSong
+	if err := tx.Where("playlist_id = ?", playlistID).Order("rank, id").Find(&entries).Error; err != nil {
+		return "", err
+	}
+	ordered, err := apply(entries)
+	if err != nil {
+		return "", err
+	}
+
+	existing := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		existing[e.ID] = true
+	}
+	for i, e := range ordered {
+		if !existing[e.ID] {
+			ordered[i].Rank = ""
+		}
+	}
+	changed, err := rank(ordered)
+	if err != nil {
+		return "", err
+	}
+
+	for _, i := range changed {
+		e := ordered[i]
+		if !existing[e.ID] {
+			if err := tx.Omit("Song").Create(&ordered[i]).Error; err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := tx.Model(&models.PlaylistSong{}).Where("id = ?", e.ID).UpdateColumn("rank", e.Rank).Error; err != nil {
+			return "", err
+		}
+	}
+	for _, e := range ordered {
+		delete(existing, e.ID)
+	}
+	if len(existing) > 0 {
+		removed := make([]string, 0, len(existing))
+		for id := range existing {
+			removed = append(removed, id)
+		}
+		if err := tx.Where("id IN ?", removed).Delete(&models.PlaylistSong{}).Error; err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// distinct returns ids without repeats
+func distinct(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
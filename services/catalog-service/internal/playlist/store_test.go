@@ -0,0 +1,62 @@
+package playlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// dryDB builds queries without running them and records their SQL
+type dryDB struct {
+	database.Service
+	db  *gorm.DB
+	sql []string
+}
+
+func newDryDB(t *testing.T) *dryDB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	d := &dryDB{db: db}
+	db.Callback().Update().After("gorm:update").Register("test:record", func(tx *gorm.DB) {
+		d.sql = append(d.sql, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	return d
+}
+
+func (d *dryDB) GetDB() *gorm.DB {
+	return d.db
+}
+
+func TestUpdateWritesZeroValues(t *testing.T) {
+	db := newDryDB(t)
+	// Makes the playlist public and non-collaborative, clears its rules and
+	// tries to change its ID
+	p := &models.Playlist{BaseModel: models.BaseModel{ID: "other"}, Name: "Mix"}
+
+	if err := NewStore(db).Update(context.Background(), "p1", p); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(db.sql) != 1 {
+		t.Fatalf("Update() ran %d statements", len(db.sql))
+	}
+	sql := db.sql[0]
+	for _, want := range []string{"`private`=false", "`is_collaborative`=false", "`rules`=NULL", "`creator_artist_id`=NULL", "WHERE id = \"p1\""} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Update() SQL %s lacks %s", sql, want)
+		}
+	}
+	for _, unwanted := range []string{"snapshot_id", "creator_user_id", "images", "other"} {
+		if strings.Contains(sql, unwanted) {
+			t.Errorf("Update() SQL %s writes %s", sql, unwanted)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package playlistfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseM3U reads plain and extended M3U. #EXTINF gives the duration and
+// "Artist - Title" of the next location; #EXTALB and #EXTART its album and
+// artist. Tracks without #EXTINF are named after their file.
+func parseM3U(data []byte) (*Playlist, error) {
+	p := &Playlist{}
+	var next Track
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info, title, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			// Attributes such as tvg-id="..." may follow the duration
+			seconds, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+			if s, err := strconv.ParseFloat(seconds, 64); err == nil && s > 0 {
+				next.DurationMS = int(s * 1000)
+			}
+			artist, name := splitArtistTitle(title)
+			next.Title = name
+			if artist != "" {
+				next.Artist = artist
+			}
+		case strings.HasPrefix(line, "#EXTALB:"):
+			next.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTART:"):
+			next.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			p.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			next.Location = line
+			if next.Title == "" {
+				artist, title := titleFromLocation(line)
+				next.Title = title
+				if next.Artist == "" {
+					next.Artist = artist
+				}
+			}
+			p.Tracks = append(p.Tracks, next)
+			next = Track{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return p, nil
+}
+
+// writeM3U writes extended M3U with one #EXTINF per track
+func writeM3U(w io.Writer, p *Playlist) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	if p.Title != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", oneLine(p.Title))
+	}
+	for _, t := range p.Tracks {
+		seconds := -1
+		if t.DurationMS > 0 {
+			seconds = (t.DurationMS + 500) / 1000
+		}
+		name := oneLine(t.Title)
+		if t.Artist != "" {
+			name = oneLine(t.Artist) + " - " + name
+		}
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", seconds, name)
+		if t.Album != "" {
+			fmt.Fprintf(bw, "#EXTALB:%s\n", oneLine(t.Album))
+		}
+		bw.WriteString(oneLine(t.Location) + "\n")
+	}
+	return bw.Flush()
+}
+
+// oneLine keeps s on a single line
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,133 @@
+// Package playlistfile reads and writes playlists in the M3U8 (extended M3U
+// in UTF-8), XSPF and JSPF formats used by other players.
+package playlistfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format is a playlist file format
+type Format string
+
+const (
+	M3U8 Format = "m3u8"
+	XSPF Format = "xspf"
+	JSPF Format = "jspf"
+)
+
+// bom is the byte order mark some editors put before UTF-8 text
+const bom = "\uFEFF"
+
+// ErrUnknownFormat is returned for formats other than M3U8, XSPF and JSPF
+var ErrUnknownFormat = errors.New("unknown playlist format")
+
+// ErrInvalid is returned for files that cannot be parsed
+var ErrInvalid = errors.New("invalid playlist file")
+
+// Playlist is the content of a playlist file
+type Playlist struct {
+	Title       string
+	Description string
+	Tracks      []Track
+}
+
+// Track is one playlist item. Fields missing from the file are empty.
+type Track struct {
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	DurationMS int    `json:"duration_ms,omitempty"`
+	Location   string `json:"location,omitempty"`
+}
+
+// ParseFormat returns the format named by value, also accepting m3u
+func ParseFormat(value string) (Format, error) {
+	switch f := Format(strings.ToLower(value)); f {
+	case M3U8, XSPF, JSPF:
+		return f, nil
+	case "m3u":
+		return M3U8, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, value)
+}
+
+// Detect guesses the format of a file from its name and content
+func Detect(filename string, data []byte) (Format, error) {
+	if ext := strings.TrimPrefix(path.Ext(filename), "."); ext != "" {
+		if f, err := ParseFormat(ext); err == nil {
+			return f, nil
+		}
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte(bom)))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return M3U8, nil
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return XSPF, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return JSPF, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Parse reads a playlist file
+func Parse(data []byte, format Format) (*Playlist, error) {
+	data = bytes.TrimPrefix(data, []byte(bom))
+	switch format {
+	case M3U8:
+		return parseM3U(data)
+	case XSPF:
+		return parseXSPF(data)
+	case JSPF:
+		return parseJSPF(data)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// Write writes p in format
+func Write(w io.Writer, p *Playlist, format Format) error {
+	switch format {
+	case M3U8:
+		return writeM3U(w, p)
+	case XSPF:
+		return writeXSPF(w, p)
+	case JSPF:
+		return writeJSPF(w, p)
+	}
+	return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// ContentType returns the MIME type of format
+func ContentType(format Format) string {
+	switch format {
+	case XSPF:
+		return "application/xspf+xml"
+	case JSPF:
+		return "application/jspf+json"
+	}
+	return "audio/x-mpegurl"
+}
+
+// titleFromLocation derives a track's artist and title from a file name of
+// the form "Artist - Title.ext"
+func titleFromLocation(location string) (artist, title string) {
+	name := location
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return splitArtistTitle(name)
+}
+
+// splitArtistTitle splits "Artist - Title"
+func splitArtistTitle(s string) (artist, title string) {
+	if artist, title, ok := strings.Cut(s, " - "); ok {
+		return strings.TrimSpace(artist), strings.TrimSpace(title)
+	}
+	return "", strings.TrimSpace(s)
+}
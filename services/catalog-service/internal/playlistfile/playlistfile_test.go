@@ -0,0 +1,123 @@
+package playlistfile
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const m3uSample = `#EXTM3U
+#PLAYLIST:Road trip
+#EXTINF:270,A. R. Rahman - Showkali
+#EXTALB:Acham Yenbadhu Madamaiyada
+https://example.com/showkali.mp3
+
+#EXTINF:-1 tvg-id="x",Untitled stream
+http://radio.example.com/live
+C:\Music\Ilaiyaraaja - Ilaya Nila.flac
+`
+
+func TestParseM3U(t *testing.T) {
+	p, err := Parse([]byte(m3uSample), M3U8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Playlist{
+		Title: "Road trip",
+		Tracks: []Track{
+			{Title: "Showkali", Artist: "A. R. Rahman", Album: "Acham Yenbadhu Madamaiyada", DurationMS: 270000, Location: "https://example.com/showkali.mp3"},
+			{Title: "Untitled stream", Location: "http://radio.example.com/live"},
+			{Title: "Ilaya Nila", Artist: "Ilaiyaraaja", Location: `C:\Music\Ilaiyaraaja - Ilaya Nila.flac`},
+		},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Parse() = %+v\nwant %+v", p, want)
+	}
+}
+
+func TestParseXSPFAndJSPF(t *testing.T) {
+	xspf := `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Mix</title>
+  <trackList>
+    <track><location>file:///music/a.mp3</location><title>Song A</title><creator>Artist A</creator><duration>181000</duration></track>
+    <track><location>file:///music/Artist B - Song B.ogg</location></track>
+  </trackList>
+</playlist>`
+	jspf := `{"playlist": {"title": "Mix", "track": [
+		{"location": ["file:///music/a.mp3"], "title": "Song A", "creator": "Artist A", "duration": 181000},
+		{"location": "file:///music/Artist B - Song B.ogg"}
+	]}}`
+	want := &Playlist{
+		Title: "Mix",
+		Tracks: []Track{
+			{Title: "Song A", Artist: "Artist A", DurationMS: 181000, Location: "file:///music/a.mp3"},
+			{Title: "Song B", Artist: "Artist B", Location: "file:///music/Artist B - Song B.ogg"},
+		},
+	}
+
+	for format, data := range map[Format]string{XSPF: xspf, JSPF: jspf} {
+		p, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("%s: Parse() = %+v\nwant %+v", format, p, want)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	p := &Playlist{
+		Title:       "Favourites",
+		Description: "Songs & more",
+		Tracks: []Track{
+			{Title: "Showkali", Artist: "A. R. Rahman", Album: "Acham Yenbadhu Madamaiyada", DurationMS: 270000, Location: "https://example.com/a?sig=1&exp=2"},
+			{Title: "Ilaya Nila", Artist: "Ilaiyaraaja", Location: "https://example.com/b"},
+		},
+	}
+
+	for _, format := range []Format{M3U8, XSPF, JSPF} {
+		var buf bytes.Buffer
+		if err := Write(&buf, p, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		detected, err := Detect("", buf.Bytes())
+		if err != nil || detected != format {
+			t.Errorf("%s: Detect() = %q, %v", format, detected, err)
+		}
+		got, err := Parse(buf.Bytes(), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		want := *p
+		if format == M3U8 {
+			want.Description = "" // M3U has no description
+		}
+		if !reflect.DeepEqual(got, &want) {
+			t.Errorf("%s round trip = %+v\nwant %+v\n%s", format, got, &want, buf.String())
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     Format
+	}{
+		{"list.m3u", "a.mp3", M3U8},
+		{"list.XSPF", "", XSPF},
+		{"", "\uFEFF#EXTM3U\n", M3U8},
+		{"export.txt", `{"playlist": {}}`, JSPF},
+	}
+	for _, tt := range tests {
+		if got, err := Detect(tt.filename, []byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("Detect(%q) = %q, %v; want %q", tt.filename, got, err, tt.want)
+		}
+	}
+	if _, err := Detect("notes.txt", []byte("hello")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Detect(notes.txt) error = %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package playlistfile
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xspfNamespace is the XML namespace of XSPF version 1
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Namespace  string      `xml:"xmlns,attr,omitempty"`
+	Version    string      `xml:"version,attr"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations []string `xml:"location"`
+	Title     string   `xml:"title,omitempty"`
+	Creator   string   `xml:"creator,omitempty"`
+	Album     string   `xml:"album,omitempty"`
+	Duration  int      `xml:"duration,omitempty"`
+}
+
+// jspfPlaylist is XSPF in JSON, as described at https://xspf.org/jspf
+type jspfPlaylist struct {
+	Playlist struct {
+		Title      string      `json:"title,omitempty"`
+		Annotation string      `json:"annotation,omitempty"`
+		Tracks     []jspfTrack `json:"track"`
+	} `json:"playlist"`
+}
+
+type jspfTrack struct {
+	Locations jspfLocations `json:"location,omitempty"`
+	Title     string        `json:"title,omitempty"`
+	Creator   string        `json:"creator,omitempty"`
+	Album     string        `json:"album,omitempty"`
+	Duration  int           `json:"duration,omitempty"`
+}
+
+// jspfLocations is an array of URIs, though some writers use a plain string
+type jspfLocations []string
+
+func (l *jspfLocations) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*l = jspfLocations{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+func parseXSPF(data []byte) (*Playlist, error) {
+	var doc xspfPlaylist
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	p := &Playlist{Title: strings.TrimSpace(doc.Title), Description: strings.TrimSpace(doc.Annotation)}
+	for _, t := range doc.Tracks {
+		p.Tracks = append(p.Tracks, newTrack(t.Title, t.Creator, t.Album, t.Duration, t.Locations))
+	}
+	return p, nil
+}
+
+func parseJSPF(data []byte) (*Playlist, error) {
+	var doc jspfPlaylist
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	p := &Playlist{Title: strings.TrimSpace(doc.Playlist.Title), Description: strings.TrimSpace(doc.Playlist.Annotation)}
+	for _, t := range doc.Playlist.Tracks {
+		p.Tracks = append(p.Tracks, newTrack(t.Title, t.Creator, t.Album, t.Duration, t.Locations))
+	}
+	return p, nil
+}
+
+// newTrack builds a track from XSPF fields, naming untitled tracks after
+// their first location
+func newTrack(title, creator, album string, durationMS int, locations []string) Track {
+	t := Track{
+		Title:      strings.TrimSpace(title),
+		Artist:     strings.TrimSpace(creator),
+		Album:      strings.TrimSpace(album),
+		DurationMS: max(durationMS, 0),
+	}
+	if len(locations) > 0 {
+		t.Location = strings.TrimSpace(locations[0])
+	}
+	if t.Title == "" && t.Location != "" {
+		artist, title := titleFromLocation(t.Location)
+		t.Title = title
+		if t.Artist == "" {
+			t.Artist = artist
+		}
+	}
+	return t
+}
+
+func writeXSPF(w io.Writer, p *Playlist) error {
+	doc := xspfPlaylist{Namespace: xspfNamespace, Version: "1", Title: p.Title, Annotation: p.Description}
+	for _, t := range p.Tracks {
+		track := xspfTrack{Title: t.Title, Creator: t.Artist, Album: t.Album, Duration: t.DurationMS}
+		if t.Location != "" {
+			track.Locations = []string{t.Location}
+		}
+		doc.Tracks = append(doc.Tracks, track)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeJSPF(w io.Writer, p *Playlist) error {
+	var doc jspfPlaylist
+	doc.Playlist.Title = p.Title
+	doc.Playlist.Annotation = p.Description
+	doc.Playlist.Tracks = []jspfTrack{}
+	for _, t := range p.Tracks {
+		track := jspfTrack{Title: t.Title, Creator: t.Artist, Album: t.Album, Duration: t.DurationMS}
+		if t.Location != "" {
+			track.Locations = jspfLocations{t.Location}
+		}
+		doc.Playlist.Tracks = append(doc.Playlist.Tracks, track)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,275 @@
+// Package relay forwards a phone's audio to its paired browser through the
+// server, for devices that cannot connect to each other directly, e.g.
+// behind symmetric NATs. The phone publishes its Opus track for a pairing
+// and the browser subscribes to the pairing; the relay copies the RTP
+// packets from one to the other without decoding them. Devices that cannot
+// reach the relay either can go through the embedded TURN server.
+//
+// Offers and answers carry all ICE candidates: the relay waits for its
+// candidates to be gathered before answering, and expects the same of the
+// devices.
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/pion/webrtc/v4"
+)
+
+var (
+	ErrInvalidOffer = errors.New("invalid offer")
+	ErrNoPublisher  = errors.New("pairing has no published audio")
+)
+
+// opus is the only codec relayed
+var opus = webrtc.RTPCodecCapability{
+	MimeType:    webrtc.MimeTypeOpus,
+	ClockRate:   48000,
+	Channels:    2,
+	SDPFmtpLine: "minptime=10;useinbandfec=1",
+}
+
+// Config configures the relay's peer connections
+type Config struct {
+	// PublicIP is announced in place of the host's addresses when the relay
+	// is behind 1:1 NAT
+	PublicIP string
+	// PortMin and PortMax bound the UDP ports of peer connections; 0 allows
+	// any
+	PortMin uint16
+	PortMax uint16
+}
+
+// Relay forwards the audio of each pairing from its phone to its browser
+type Relay struct {
+	api *webrtc.API
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session is the relayed audio of one pairing. The track outlives its
+// publisher, so a browser stays subscribed while the phone reconnects.
+type session struct {
+	track      *webrtc.TrackLocalStaticRTP
+	publisher  *webrtc.PeerConnection
+	subscriber *webrtc.PeerConnection
+}
+
+// New creates a relay
+func New(config Config) (*Relay, error) {
+	settings := webrtc.SettingEngine{}
+	if config.PublicIP != "" {
+		settings.SetNAT1To1IPs([]string{config.PublicIP}, webrtc.ICECandidateTypeHost)
+	}
+	if config.PortMin > 0 || config.PortMax > 0 {
+		if err := settings.SetEphemeralUDPPortRange(config.PortMin, config.PortMax); err != nil {
+			return nil, err
+		}
+	}
+
+	media := &webrtc.MediaEngine{}
+	err := media.RegisterCodec(webrtc.RTPCodecParameters{RTPCodecCapability: opus, PayloadType: 111}, webrtc.RTPCodecTypeAudio)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Relay{
+		api:      webrtc.NewAPI(webrtc.WithMediaEngine(media), webrtc.WithSettingEngine(settings)),
+		sessions: make(map[string]*session),
+	}, nil
+}
+
+// Publish answers the phone's offer for pairingID and forwards the audio it
+// sends to the pairing's browser. An earlier publisher of the pairing is
+// disconnected.
+func (r *Relay) Publish(ctx context.Context, pairingID string, offer webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	r.mu.Lock()
+	s := r.sessions[pairingID]
+	if s == nil {
+		track, err := webrtc.NewTrackLocalStaticRTP(opus, "audio", "pairing-"+pairingID)
+		if err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		s = &session{track: track}
+		r.sessions[pairingID] = s
+	}
+	r.mu.Unlock()
+
+	pc, err := r.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, err
+	}
+	pc.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if remote.Kind() == webrtc.RTPCodecTypeAudio {
+			forward(remote, s.track)
+		}
+	})
+
+	answer, err := r.answer(ctx, pairingID, pc, offer, func() error {
+		for _, t := range pc.GetTransceivers() {
+			if t.Kind() == webrtc.RTPCodecTypeAudio && t.Direction() == webrtc.RTPTransceiverDirectionRecvonly {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: no Opus audio is sent", ErrInvalidOffer)
+	})
+	if err != nil {
+		r.drop(pairingID, pc)
+		return nil, err
+	}
+
+	r.mu.Lock()
+	old := s.publisher
+	s.publisher = pc
+	// The pairing may have been removed while answering, when its browser
+	// dropped
+	if r.sessions[pairingID] == nil {
+		r.sessions[pairingID] = s
+	}
+	r.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return answer, nil
+}
+
+// Subscribe answers the browser's offer for pairingID, sending it the audio
+// of the pairing's phone. An earlier subscriber of the pairing is
+// disconnected.
+func (r *Relay) Subscribe(ctx context.Context, pairingID string, offer webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	r.mu.Lock()
+	s := r.sessions[pairingID]
+	r.mu.Unlock()
+	if s == nil {
+		return nil, ErrNoPublisher
+	}
+
+	pc, err := r.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, err
+	}
+	answer, err := r.answer(ctx, pairingID, pc, offer, func() error {
+		sender, err := pc.AddTrack(s.track)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOffer, err)
+		}
+		// Read the browser's RTCP so the sender keeps going
+		go func() {
+			buf := make([]byte, 1500)
+			for {
+				if _, _, err := sender.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+		return nil
+	})
+	if err != nil {
+		r.drop(pairingID, pc)
+		return nil, err
+	}
+
+	r.mu.Lock()
+	old := s.subscriber
+	s.subscriber = pc
+	r.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return answer, nil
+}
+
+// Close disconnects the phone and browser of pairingID
+func (r *Relay) Close(pairingID string) {
+	r.mu.Lock()
+	s := r.sessions[pairingID]
+	delete(r.sessions, pairingID)
+	r.mu.Unlock()
+
+	if s == nil {
+		return
+	}
+	for _, pc := range []*webrtc.PeerConnection{s.publisher, s.subscriber} {
+		if pc != nil {
+			pc.Close()
+		}
+	}
+}
+
+// answer applies offer to pc, calls prepare and returns the answer with all
+// of the relay's candidates. pc is dropped from the pairing once its
+// connection fails or closes.
+func (r *Relay) answer(ctx context.Context, pairingID string, pc *webrtc.PeerConnection, offer webrtc.SessionDescription, prepare func() error) (*webrtc.SessionDescription, error) {
+	if offer.Type != webrtc.SDPTypeOffer {
+		return nil, fmt.Errorf("%w: not an offer", ErrInvalidOffer)
+	}
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOffer, err)
+	}
+	if err := prepare(); err != nil {
+		return nil, err
+	}
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			r.drop(pairingID, pc)
+		}
+	})
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return nil, err
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return nil, err
+	}
+	select {
+	case <-gathered:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return pc.LocalDescription(), nil
+}
+
+// drop closes pc and removes it from the pairing, removing the pairing once
+// neither device is connected
+func (r *Relay) drop(pairingID string, pc *webrtc.PeerConnection) {
+	r.mu.Lock()
+	if s := r.sessions[pairingID]; s != nil {
+		if s.publisher == pc {
+			s.publisher = nil
+		}
+		if s.subscriber == pc {
+			s.subscriber = nil
+		}
+		if s.publisher == nil && s.subscriber == nil {
+			delete(r.sessions, pairingID)
+		}
+	}
+	r.mu.Unlock()
+
+	if err := pc.Close(); err != nil {
+		log.Printf("Failed to close relay connection of pairing %s: %v", pairingID, err)
+	}
+}
+
+// forward copies the RTP packets of remote to track until remote ends
+func forward(remote *webrtc.TrackRemote, track *webrtc.TrackLocalStaticRTP) {
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			return
+		}
+		if err := track.WriteRTP(packet); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			log.Printf("Failed to relay audio: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+func newPeer(t *testing.T, config webrtc.Configuration) *webrtc.PeerConnection {
+	t.Helper()
+	pc, err := webrtc.NewPeerConnection(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// offer returns pc's offer with all its candidates
+func offer(t *testing.T, pc *webrtc.PeerConnection) webrtc.SessionDescription {
+	t.Helper()
+	o, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(o); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	return *pc.LocalDescription()
+}
+
+func TestRelayForwardsAudioThroughTURN(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	turnServer, err := NewTURNServer(TURNConfig{ListenAddress: "127.0.0.1:0", PublicIP: "127.0.0.1", Realm: "test", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer turnServer.Close()
+	turnServer.config.URLs = []string{"turn:" + turnServer.Addr().String()}
+
+	relay, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close("pairing-1")
+
+	// The phone may only use the TURN server, as behind a symmetric NAT
+	iceServer, _, err := turnServer.Credentials("pairing-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := newPeer(t, webrtc.Configuration{
+		ICEServers:         []webrtc.ICEServer{iceServer},
+		ICETransportPolicy: webrtc.ICETransportPolicyRelay,
+	})
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = phone.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := relay.Publish(ctx, "pairing-1", offer(t, phone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.SetRemoteDescription(*answer); err != nil {
+		t.Fatal(err)
+	}
+
+	browser := newPeer(t, webrtc.Configuration{})
+	_, err = browser.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+	browser.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if _, _, err := remote.ReadRTP(); err == nil {
+			received <- remote.Codec().MimeType
+		}
+	})
+	answer, err = relay.Subscribe(ctx, "pairing-1", offer(t, browser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := browser.SetRemoteDescription(*answer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Send 20ms Opus frames until the browser receives one
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				track.WriteSample(media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond})
+			}
+		}
+	}()
+
+	select {
+	case mimeType := <-received:
+		if mimeType != webrtc.MimeTypeOpus {
+			t.Errorf("browser received %s", mimeType)
+		}
+	case <-ctx.Done():
+		t.Fatal("browser received no audio")
+	}
+	if turnServer.server.AllocationCount() == 0 {
+		t.Error("phone did not connect through TURN")
+	}
+}
+
+func TestRelayRejects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	relay, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser := newPeer(t, webrtc.Configuration{})
+	if _, err := browser.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relay.Subscribe(ctx, "pairing-1", offer(t, browser)); !errors.Is(err, ErrNoPublisher) {
+		t.Errorf("Subscribe() without publisher error = %v", err)
+	}
+
+	// A phone offering no audio has nothing to publish
+	phone := newPeer(t, webrtc.Configuration{})
+	if _, err := phone.CreateDataChannel("data", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relay.Publish(ctx, "pairing-1", offer(t, phone)); !errors.Is(err, ErrInvalidOffer) {
+		t.Errorf("Publish() without audio error = %v", err)
+	}
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if len(relay.sessions) != 0 {
+		t.Errorf("failed publish left %d sessions", len(relay.sessions))
+	}
+}
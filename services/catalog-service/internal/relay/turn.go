@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/pion/turn/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+// DefaultCredentialTTL is how long minted TURN credentials are valid when
+// TURNConfig leaves it unset
+const DefaultCredentialTTL = time.Hour
+
+// TURNConfig configures the embedded TURN server
+type TURNConfig struct {
+	// ListenAddress is the UDP address to serve on, e.g. 0.0.0.0:3478
+	ListenAddress string
+	// PublicIP is the address of relayed candidates; peers must reach it
+	PublicIP string
+	// URLs are the TURN URLs given to peers, e.g. turn:turn.example.com:3478
+	URLs []string
+	// Realm is the TURN authentication realm
+	Realm string
+	// Secret signs the credentials
+	Secret string
+	// CredentialTTL is how long minted credentials are valid
+	CredentialTTL time.Duration
+}
+
+// TURNServer relays the media of peers that cannot reach each other or the
+// relay directly. It accepts the time-limited credentials of the TURN REST
+// API, username "<expiry>:<pairing ID>" and an HMAC of it as password, so
+// credentials need no storage and stop working on their own.
+type TURNServer struct {
+	server *turn.Server
+	conn   net.PacketConn
+	config TURNConfig
+}
+
+// NewTURNServer starts a TURN server
+func NewTURNServer(config TURNConfig) (*TURNServer, error) {
+	if config.Secret == "" {
+		return nil, errors.New("TURN secret is required")
+	}
+	relayIP := net.ParseIP(config.PublicIP)
+	if relayIP == nil {
+		return nil, errors.New("TURN public IP is invalid")
+	}
+	if config.CredentialTTL <= 0 {
+		config.CredentialTTL = DefaultCredentialTTL
+	}
+
+	conn, err := net.ListenPacket("udp4", config.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:       config.Realm,
+		AuthHandler: turn.LongTermTURNRESTAuthHandler(config.Secret, nil),
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn: conn,
+			RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+				RelayAddress: relayIP,
+				Address:      "0.0.0.0",
+			},
+		}},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &TURNServer{server: server, conn: conn, config: config}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *TURNServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Credentials mints credentials for the devices of pairingID, returning the
+// ICE server to use and when the credentials expire
+func (s *TURNServer) Credentials(pairingID string) (webrtc.ICEServer, time.Time, error) {
+	expiresAt := time.Now().Add(s.config.CredentialTTL)
+	username, password, err := turn.GenerateLongTermTURNRESTCredentials(s.config.Secret, pairingID, s.config.CredentialTTL)
+	if err != nil {
+		return webrtc.ICEServer{}, time.Time{}, err
+	}
+	return webrtc.ICEServer{
+		URLs:       s.config.URLs,
+		Username:   username,
+		Credential: password,
+	}, expiresAt, nil
+}
+
+// Close stops the server
+func (s *TURNServer) Close() error {
+	return s.server.Close()
+}
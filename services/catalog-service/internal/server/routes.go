@@ -1,17 +1,24 @@
 package server
 
 import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
 	"go-audio-stream/pkg/database"
 	common_handlers "go-audio-stream/pkg/handlers"
 	"go-audio-stream/pkg/middlewares"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
 	"go-audio-stream/services/catalog-service/internal/handlers"
-	"net/http"
 
 	_ "go-audio-stream/services/catalog-service/docs"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"golang.org/x/time/rate"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
@@ -21,14 +28,26 @@ func (s *Server) RegisterRoutes() http.Handler {
 	e.Use(middleware.Recover())
 
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"https://*", "http://*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowOrigins: []string{"https://*", "http://*"},
+		AllowMethods: []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Defer-Length"},
+		ExposeHeaders: []string{"Location", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Metadata",
+			"Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"X-Rendition", "X-ReplayGain-Track-Gain", "X-ReplayGain-Track-Peak"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	e.Use(middlewares.CustomResponseMiddleware)
+	// Streamed audio and stored files are passed through without buffering,
+	// exported playlists (JSPF is JSON) are served as-is and signaling
+	// sockets take over the connection
+	skipPrefixes := middlewares.SkipPathPrefixes("/swagger", "/api/v1/stream/", "/api/v1/preview/", "/api/v1/uploads/tus", storage.LocalURLPrefix)
+	e.Use(middlewares.CustomResponseMiddlewareWithConfig(middlewares.ResponseConfig{
+		Skipper: func(c echo.Context) bool {
+			return skipPrefixes(c) || c.Path() == "/api/v1/playlists/:id/export" || c.Path() == "/api/v1/rtc/signal"
+		},
+	}))
 
 	e.GET("/health", s.withClient(common_handlers.HealthHandler))
 	e.GET("/hello", s.withClient(common_handlers.HelloWorldHandler))
@@ -47,6 +66,48 @@ func (s *Server) RegisterRoutes() http.Handler {
 	artistGroup.GET("/:id", s.withClient(handlers.FindOneArtistById))
 	artistGroup.PUT("/:id", s.withClient(handlers.UpdateArtistHandler))
 	artistGroup.DELETE("/:id", s.withClient(handlers.DeleteArtistHandler))
+	artistGroup.GET("/:id/albums", s.withClient(handlers.FindArtistAlbums))
+	artistGroup.GET("/:id/songs", s.withClient(handlers.FindArtistSongs))
+	artistGroup.POST("/:id/follow", s.withClient(handlers.FollowArtistHandler))
+	artistGroup.DELETE("/:id/follow", s.withClient(handlers.UnfollowArtistHandler))
+
+	meGroup := protectedGroup.Group("/me")
+	meGroup.GET("/following", s.withClient(handlers.FindFollowedArtists))
+
+	deviceHandler := handlers.NewDeviceHandler(s.db, os.Getenv("DEVICE_PAIR_URL"))
+	deviceGroup := protectedGroup.Group("/devices")
+	deviceGroup.POST("", deviceHandler.Register)
+	deviceGroup.GET("", deviceHandler.List)
+	deviceGroup.PUT("/:id", deviceHandler.Rename)
+	deviceGroup.DELETE("/:id", deviceHandler.Revoke)
+	deviceGroup.POST("/:id/heartbeat", deviceHandler.Heartbeat)
+	deviceGroup.POST("/pair/initiate", deviceHandler.InitiatePairing)
+	// Pair codes are short, so guessing them is rate limited per user
+	deviceGroup.POST("/pair/verify", deviceHandler.VerifyPairing, pairVerifyRateLimiter())
+
+	// Browsers cannot set headers on WebSockets, so the token may be passed
+	// as ?token= instead
+	rtcGroup := e.Group("/api/v1/rtc", middlewares.TokenFromQuery("token"), middlewares.NewAuthMiddleware(s.identityClient))
+	rtcGroup.GET("/signal", handlers.NewSignalHandler(s.signalHub, s.db).Signal)
+
+	// TURN credentials also help devices streaming directly; the media
+	// relay is optional
+	relayHandler := handlers.NewRelayHandler(s.mediaRelay, s.turnServer, s.db)
+	rtcGroup.GET("/pairings/:id/ice-servers", relayHandler.ICEServers)
+	if s.mediaRelay != nil {
+		rtcGroup.POST("/pairings/:id/publish", relayHandler.Publish)
+		rtcGroup.POST("/pairings/:id/subscribe", relayHandler.Subscribe)
+		rtcGroup.DELETE("/pairings/:id/relay", relayHandler.Close)
+	}
+
+	albumGroup := protectedGroup.Group("/albums")
+	albumGroup.POST("/", s.withClient(handlers.CreateAlbumHandler))
+	albumGroup.GET("/", s.withClient(handlers.FindAllAlbums))
+	albumGroup.GET("/:id", s.withClient(handlers.FindOneAlbumById))
+	albumGroup.PUT("/:id", s.withClient(handlers.UpdateAlbumHandler))
+	albumGroup.DELETE("/:id", s.withClient(handlers.DeleteAlbumHandler))
+	albumGroup.POST("/:id/songs", s.withClient(handlers.AddSongToAlbumHandler))
+	albumGroup.DELETE("/:id/songs/:song_id", s.withClient(handlers.RemoveSongFromAlbumHandler))
 
 	songGroup := protectedGroup.Group("/songs")
 	songGroup.POST("/", s.withClient(handlers.CreateSongHandler))
@@ -54,34 +115,142 @@ func (s *Server) RegisterRoutes() http.Handler {
 	songGroup.GET("/:id", s.withClient(handlers.FindOneSongById))
 	songGroup.PUT("/:id", s.withClient(handlers.UpdateSongHandler))
 	songGroup.DELETE("/:id", s.withClient(handlers.DeleteSongHandler))
+	songGroup.GET("/:id/credits", s.withClient(handlers.FindSongCredits))
+	songGroup.POST("/:id/credits", s.withClient(handlers.AddSongCreditHandler))
+	songGroup.DELETE("/:id/credits/:artist_id", s.withClient(handlers.RemoveSongCreditHandler))
+
+	protectedGroup.GET("/search", s.withClient(handlers.SearchHandler))
 
 	playlistGroup := protectedGroup.Group("/playlists")
 	playlistGroup.POST("/", s.withClient(handlers.CreatePlaylistHandler))
 	playlistGroup.GET("/", s.withClient(handlers.FindAllPlaylists))
+	playlistFileHandler := handlers.NewPlaylistFileHandler(s.storageClient, s.db)
+	playlistGroup.POST("/import", playlistFileHandler.Import)
+	playlistGroup.GET("/:id/export", playlistFileHandler.Export)
 	playlistGroup.GET("/:id", s.withClient(handlers.FindOnePlaylistById))
 	playlistGroup.PUT("/:id", s.withClient(handlers.UpdatePlaylistHandler))
 	playlistGroup.DELETE("/:id", s.withClient(handlers.DeletePlaylistHandler))
+	playlistGroup.GET("/:id/songs", s.withClient(handlers.FindPlaylistSongs))
 	playlistGroup.POST("/:id/songs", s.withClient(handlers.AddSongToPlaylistHandler))
+	playlistGroup.PUT("/:id/songs", s.withClient(handlers.ReorderPlaylistSongsHandler))
+	playlistGroup.POST("/:id/songs/move", s.withClient(handlers.MovePlaylistSongsHandler))
 	playlistGroup.DELETE("/:id/songs/:song_id", s.withClient(handlers.RemoveSongFromPlaylistHandler))
+	playlistGroup.DELETE("/:id/entries/:entry_id", s.withClient(handlers.RemovePlaylistEntryHandler))
+	playlistGroup.GET("/:id/members", s.withClient(handlers.FindPlaylistMembers))
+	playlistGroup.PUT("/:id/members/:user_id", s.withClient(handlers.SetPlaylistMemberHandler))
+	playlistGroup.DELETE("/:id/members/:user_id", s.withClient(handlers.RemovePlaylistMemberHandler))
+	playlistGroup.GET("/:id/invites", s.withClient(handlers.FindPlaylistInvites))
+	playlistGroup.POST("/:id/invites", s.withClient(handlers.CreatePlaylistInviteHandler))
+	playlistGroup.DELETE("/:id/invites/:invite_id", s.withClient(handlers.DeletePlaylistInviteHandler))
+	playlistGroup.POST("/invites/accept", s.withClient(handlers.AcceptPlaylistInviteHandler))
 
 	// Upload routes (requires storage client)
 	if s.storageClient != nil {
-		uploadHandler := handlers.NewUploadHandler(s.storageClient)
+		uploadHandler := handlers.NewUploadHandler(s.storageClient, s.db, s.audioPipeline, s.uploadLimits)
 		uploadGroup := protectedGroup.Group("/upload")
 		uploadGroup.POST("/audio", uploadHandler.UploadAudio)
 		uploadGroup.POST("/image", uploadHandler.UploadImage)
 
+		// Resumable uploads (tus 1.0); OPTIONS is unauthenticated for discovery
+		tusHandler := handlers.NewTusHandler(s.tusStore, s.db, s.audioPipeline, s.uploadLimits.MaxAudioSize)
+		e.OPTIONS("/api/v1/uploads/tus", tusHandler.Options)
+		e.OPTIONS("/api/v1/uploads/tus/*", tusHandler.Options)
+		tusGroup := protectedGroup.Group("/uploads/tus")
+		tusGroup.POST("", tusHandler.Create)
+		tusGroup.POST("/", tusHandler.Create)
+		tusGroup.HEAD("/:id", tusHandler.Head)
+		tusGroup.PATCH("/:id", tusHandler.Patch)
+		tusGroup.DELETE("/:id", tusHandler.Delete)
+
 		filesGroup := protectedGroup.Group("/files")
 		filesGroup.GET("/*", uploadHandler.GetPresignedURL)
 		filesGroup.DELETE("/*", uploadHandler.DeleteFile)
 
-		// Public streaming endpoint (still protected by presigned URL expiry)
-		e.GET("/api/v1/stream/*", uploadHandler.StreamAudio)
+		// Full tracks are for signed-in listeners; anyone else gets previews
+		streamGroup := protectedGroup.Group("/stream")
+		streamGroup.GET("/*", uploadHandler.StreamAudio)
+		streamGroup.HEAD("/*", uploadHandler.StreamAudio)
+
+		hlsHandler := handlers.NewHLSHandler(s.storageClient, s.db)
+		songGroup.GET("/:id/stream.m3u8", hlsHandler.MasterPlaylist)
+		songGroup.GET("/:id/hls/:variant/playlist.m3u8", hlsHandler.MediaPlaylist)
+
+		waveformHandler := handlers.NewWaveformHandler(s.storageClient)
+		songGroup.GET("/:id/waveform", waveformHandler.Waveform)
+
+		// Public preview clips, rate limited per client IP
+		previewHandler := handlers.NewPreviewHandler(s.storageClient, s.db, s.previewPipeline)
+		previewLimiter := previewRateLimiter()
+		e.GET("/api/v1/preview/:id", previewHandler.Stream, previewLimiter)
+		e.HEAD("/api/v1/preview/:id", previewHandler.Stream, previewLimiter)
+		songGroup.PUT("/:id/preview", previewHandler.SetStart)
+	}
+
+	// Local storage serves its own signed URLs
+	if localClient, ok := s.storageClient.(*storage.LocalClient); ok {
+		e.GET(storage.LocalURLPrefix+"*", handlers.NewLocalStorageHandler(localClient).ServeFile)
 	}
 
 	return e
 }
 
+// previewRateLimiter allows each client IP PREVIEW_RATE_LIMIT preview
+// requests per minute (60 by default), with bursts for range requests
+func previewRateLimiter() echo.MiddlewareFunc {
+	perMinute, _ := strconv.Atoi(os.Getenv("PREVIEW_RATE_LIMIT"))
+	if perMinute <= 0 {
+		perMinute = 60
+	}
+
+	store := middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+		Rate:      rate.Limit(float64(perMinute) / 60),
+		Burst:     max(perMinute/4, 1),
+		ExpiresIn: 3 * time.Minute,
+	})
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: store,
+		ErrorHandler: func(c echo.Context, err error) error {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "Could not identify client"})
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", "60")
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": "Too many preview requests"})
+		},
+	})
+}
+
+// pairVerifyRateLimiter allows each user PAIR_VERIFY_RATE_LIMIT pairing
+// attempts per minute (5 by default)
+func pairVerifyRateLimiter() echo.MiddlewareFunc {
+	perMinute, _ := strconv.Atoi(os.Getenv("PAIR_VERIFY_RATE_LIMIT"))
+	if perMinute <= 0 {
+		perMinute = 5
+	}
+
+	store := middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+		Rate:      rate.Limit(float64(perMinute) / 60),
+		Burst:     perMinute,
+		ExpiresIn: 3 * time.Minute,
+	})
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			user, ok := c.Get(middlewares.UserContextKey).(models.User)
+			if !ok || user.ID == "" {
+				return "", echo.ErrUnauthorized
+			}
+			return user.ID, nil
+		},
+		ErrorHandler: func(c echo.Context, err error) error {
+			return echo.ErrUnauthorized
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", "60")
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": "Too many pairing attempts"})
+		},
+	})
+}
+
 func (s *Server) withClient(handler func(echo.Context, database.Service) error) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		return handler(c, s.db)
@@ -1,26 +1,49 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 
 	"go-audio-stream/pkg/clients"
 	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/ffmpeg"
+	"go-audio-stream/pkg/media/hls"
+	"go-audio-stream/pkg/media/loudness"
+	"go-audio-stream/pkg/media/preview"
+	"go-audio-stream/pkg/media/waveform"
 	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/catalog-service/internal/device"
+	"go-audio-stream/services/catalog-service/internal/follow"
+	"go-audio-stream/services/catalog-service/internal/handlers"
+	"go-audio-stream/services/catalog-service/internal/pipeline"
+	"go-audio-stream/services/catalog-service/internal/playlist"
+	"go-audio-stream/services/catalog-service/internal/relay"
+	"go-audio-stream/services/catalog-service/internal/signaling"
+	"go-audio-stream/services/catalog-service/internal/tus"
 )
 
 type Server struct {
 	port int
 
-	db             database.Service
-	identityClient *clients.IdentityClient
-	storageClient  *storage.Client
+	db              database.Service
+	identityClient  *clients.IdentityClient
+	storageClient   storage.Backend
+	audioPipeline   *pipeline.Pipeline
+	previewPipeline *pipeline.Pipeline
+	uploadLimits    handlers.UploadLimits
+	tusStore        *tus.Store
+	signalHub       *signaling.Hub
+	mediaRelay      *relay.Relay
+	turnServer      *relay.TURNServer
 }
 
 func NewServer() *http.Server {
@@ -32,19 +55,53 @@ func NewServer() *http.Server {
 		log.Fatalf("Failed to create identity client: %v", err)
 	}
 
-	// Initialize B2 storage client
+	// Initialize storage backend (B2 by default, local disk with STORAGE_BACKEND=local)
 	storageConfig := storage.LoadConfig()
-	storageClient, err := storage.NewClient(storageConfig)
+	storageClient, err := storage.NewBackend(storageConfig)
 	if err != nil {
-		log.Printf("Warning: Failed to create storage client: %v", err)
+		log.Printf("Warning: Failed to create storage backend, upload and stream routes are disabled: %v", err)
 		// Don't fatal - allow service to run without storage
 	}
 
+	db := database.New()
+	// Smart playlists are also refreshed when read; this keeps unread ones
+	// from going stale
+	go playlist.NewStore(db).RunRefresh(context.Background(), playlist.RefreshInterval)
+	// Follower counts are kept in sync by the follow endpoints; this repairs
+	// counts changed any other way
+	go follow.NewStore(db).RunReconcile(context.Background(), follow.ReconcileInterval)
+	go device.NewStore(db).RunExpiry(context.Background(), time.Minute)
+
+	// Running several instances needs a PubSub shared between them in place
+	// of the in-memory one
+	signalHub := signaling.NewHub(signaling.NewMemoryPubSub(), device.NewStore(db).Paired)
+	if err := signalHub.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start signaling hub: %v", err)
+	}
+
+	var audioPipeline, previewPipeline *pipeline.Pipeline
+	var tusStore *tus.Store
+	if storageClient != nil {
+		audioPipeline = newAudioPipeline(storageClient, db)
+		previewPipeline = newPreviewPipeline(storageClient, db)
+		tusStore = newTusStore(storageClient, db)
+	}
+
 	NewServer := &Server{
-		port:           port,
-		db:             database.New(),
-		identityClient: identityClient,
-		storageClient:  storageClient,
+		port:            port,
+		db:              db,
+		identityClient:  identityClient,
+		storageClient:   storageClient,
+		audioPipeline:   audioPipeline,
+		previewPipeline: previewPipeline,
+		uploadLimits: handlers.UploadLimits{
+			MaxAudioSize: envMegabytes("UPLOAD_MAX_AUDIO_MB"),
+			MaxImageSize: envMegabytes("UPLOAD_MAX_IMAGE_MB"),
+		},
+		tusStore:   tusStore,
+		signalHub:  signalHub,
+		mediaRelay: newMediaRelay(),
+		turnServer: newTURNServer(),
 	}
 
 	// Declare Server config
@@ -57,9 +114,137 @@ func NewServer() *http.Server {
 	}
 
 	fmt.Printf("Server running on port %d\n", port)
-	if storageClient != nil {
-		fmt.Printf("Storage connected to bucket: %s\n", storageClient.GetBucketName())
+	switch client := storageClient.(type) {
+	case *storage.Client:
+		fmt.Printf("Storage connected to bucket: %s\n", client.GetBucketName())
+	case *storage.LocalClient:
+		fmt.Printf("Storage using local directory: %s\n", storageConfig.LocalPath)
 	}
 
 	return server
 }
+
+// newAudioPipeline assembles the post-upload processing steps. Steps that
+// need ffmpeg are skipped when it is not installed.
+func newAudioPipeline(storageClient storage.Backend, db database.Service) *pipeline.Pipeline {
+	var steps []pipeline.Step
+
+	runner := ffmpeg.NewRunner(os.Getenv("FFMPEG_PATH"))
+	if err := runner.Available(); err != nil {
+		log.Printf("Warning: %v, loudness, waveform, preview and HLS steps are disabled", err)
+	} else {
+		steps = append(steps, pipeline.NewLoudnessStep(loudness.NewFFmpegAnalyzer(runner), db))
+		steps = append(steps, pipeline.NewWaveformStep(storageClient, waveform.NewFFmpegGenerator(runner), waveform.DefaultPixelsPerSecond))
+		steps = append(steps, newPreviewStep(storageClient, db, runner))
+
+		segmentSeconds, _ := strconv.Atoi(os.Getenv("HLS_SEGMENT_SECONDS"))
+		packager := hls.NewFFmpegPackager(runner, time.Duration(segmentSeconds)*time.Second, os.Getenv("HLS_SEGMENT_TYPE"))
+		steps = append(steps, pipeline.NewHLSStep(storageClient, packager, hls.DefaultVariants))
+	}
+
+	return pipeline.New(storageClient, 2, 30*time.Minute, steps...)
+}
+
+// newPreviewPipeline assembles the pipeline that only recuts preview clips,
+// run when an editor moves a preview's start
+func newPreviewPipeline(storageClient storage.Backend, db database.Service) *pipeline.Pipeline {
+	runner := ffmpeg.NewRunner(os.Getenv("FFMPEG_PATH"))
+	if runner.Available() != nil {
+		return nil
+	}
+	return pipeline.New(storageClient, 1, 10*time.Minute, newPreviewStep(storageClient, db, runner))
+}
+
+// newPreviewStep creates the preview clip step, with the clip length taken
+// from PREVIEW_SECONDS (30 by default)
+func newPreviewStep(storageClient storage.Backend, db database.Service, runner *ffmpeg.Runner) *pipeline.PreviewStep {
+	seconds, _ := strconv.Atoi(os.Getenv("PREVIEW_SECONDS"))
+	clipper := preview.NewFFmpegClipper(runner, preview.Options{Duration: time.Duration(seconds) * time.Second})
+	return pipeline.NewPreviewStep(storageClient, db, preview.NewLoudestFinder(runner), clipper)
+}
+
+// newTusStore creates the resumable upload store and starts removing
+// abandoned uploads in the background
+func newTusStore(storageClient storage.Backend, db database.Service) *tus.Store {
+	partSize := envMegabytes("TUS_PART_SIZE_MB")
+	if partSize == 0 {
+		partSize = 8 << 20
+	}
+	expiryHours, _ := strconv.Atoi(os.Getenv("TUS_EXPIRY_HOURS"))
+	if expiryHours <= 0 {
+		expiryHours = 24
+	}
+
+	store := tus.NewStore(storageClient, db, partSize, time.Duration(expiryHours)*time.Hour)
+	go store.RunExpiry(context.Background(), time.Hour)
+	return store
+}
+
+// newMediaRelay creates the WebRTC media relay when RTC_RELAY_ENABLED is
+// set. RTC_PUBLIC_IP is announced when the host is behind 1:1 NAT and
+// RTC_UDP_PORT_MIN and RTC_UDP_PORT_MAX bound its ports.
+func newMediaRelay() *relay.Relay {
+	if enabled, _ := strconv.ParseBool(os.Getenv("RTC_RELAY_ENABLED")); !enabled {
+		return nil
+	}
+	portMin, _ := strconv.ParseUint(os.Getenv("RTC_UDP_PORT_MIN"), 10, 16)
+	portMax, _ := strconv.ParseUint(os.Getenv("RTC_UDP_PORT_MAX"), 10, 16)
+
+	mediaRelay, err := relay.New(relay.Config{
+		PublicIP: os.Getenv("RTC_PUBLIC_IP"),
+		PortMin:  uint16(portMin),
+		PortMax:  uint16(portMax),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create media relay, relay routes are disabled: %v", err)
+		return nil
+	}
+	return mediaRelay
+}
+
+// newTURNServer starts the embedded TURN server when TURN_SECRET is set.
+// TURN_PUBLIC_IP is required; TURN_LISTEN_ADDRESS defaults to
+// 0.0.0.0:3478 and TURN_URLS, comma separated, to the public IP and port.
+func newTURNServer() *relay.TURNServer {
+	secret := os.Getenv("TURN_SECRET")
+	if secret == "" {
+		return nil
+	}
+	listenAddress := os.Getenv("TURN_LISTEN_ADDRESS")
+	if listenAddress == "" {
+		listenAddress = "0.0.0.0:3478"
+	}
+	publicIP := os.Getenv("TURN_PUBLIC_IP")
+	urls := strings.Split(os.Getenv("TURN_URLS"), ",")
+	if urls[0] == "" {
+		_, port, _ := net.SplitHostPort(listenAddress)
+		urls = []string{"turn:" + net.JoinHostPort(publicIP, port)}
+	}
+	realm := os.Getenv("TURN_REALM")
+	if realm == "" {
+		realm = "audiostream"
+	}
+	ttlMinutes, _ := strconv.Atoi(os.Getenv("TURN_CREDENTIAL_TTL_MINUTES"))
+
+	turnServer, err := relay.NewTURNServer(relay.TURNConfig{
+		ListenAddress: listenAddress,
+		PublicIP:      publicIP,
+		URLs:          urls,
+		Realm:         realm,
+		Secret:        secret,
+		CredentialTTL: time.Duration(ttlMinutes) * time.Minute,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to start TURN server, TURN is disabled: %v", err)
+		return nil
+	}
+	fmt.Printf("TURN server listening on %s\n", turnServer.Addr())
+	return turnServer
+}
+
+// envMegabytes reads a size in megabytes from the environment, returning
+// bytes or 0 when unset
+func envMegabytes(name string) int64 {
+	mb, _ := strconv.ParseInt(os.Getenv(name), 10, 64)
+	return mb << 20
+}
@@ -0,0 +1,401 @@
+// Package signaling relays WebRTC offers, answers and ICE candidates between
+// a user's paired devices. Every connected device has a Session on the hub
+// of the instance it is connected to; a message for a device is published
+// to that device's topic on a PubSub shared by all instances, so the sender
+// and recipient may be connected to different instances.
+//
+// When a device connects, its hub announces it on the presence topic. Other
+// hubs close any older session of the same device, so a reconnecting
+// device replaces its dropped connection, and publish again the messages
+// they buffered while nobody was subscribed to the device's topic.
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message types
+const (
+	TypeOffer     = "offer"
+	TypeAnswer    = "answer"
+	TypeCandidate = "candidate"
+	TypeSync      = "sync"
+	TypeError     = "error"
+)
+
+const (
+	// presenceTopic carries the announcements of connecting devices
+	presenceTopic = "signal.presence"
+	// bufferTTL is how long a message waits for an offline recipient; later
+	// the offer or candidate it carries is stale anyway
+	bufferTTL = 30 * time.Second
+	// bufferSize bounds the messages buffered per recipient, keeping the
+	// newest
+	bufferSize = 32
+	// sessionBuffer is how many messages a session holds for its socket
+	sessionBuffer = 64
+	// pairingCacheTTL is how long a session trusts a successful pairing
+	// check, so trickled candidates don't each query the database
+	pairingCacheTTL = 30 * time.Second
+)
+
+var (
+	ErrInvalid   = errors.New("invalid message")
+	ErrNotPaired = errors.New("devices are not paired")
+	ErrClosed    = errors.New("session closed")
+)
+
+// ClientMessage is a message sent by a device. SDP and Candidate are relayed
+// as they are.
+type ClientMessage struct {
+	Type      string          `json:"type"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	SDP       json.RawMessage `json:"sdp,omitempty"`
+	Candidate json.RawMessage `json:"candidate,omitempty"`
+}
+
+// ServerMessage is a message to a device, relayed from From, or an error
+// about a message the device sent
+type ServerMessage struct {
+	Type    string   `json:"type"`
+	From    string   `json:"from,omitempty"`
+	Payload *Payload `json:"payload,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type Payload struct {
+	SDP       json.RawMessage `json:"sdp,omitempty"`
+	Candidate json.RawMessage `json:"candidate,omitempty"`
+}
+
+// PairedFunc reports whether devices a and b of userID are paired
+type PairedFunc func(ctx context.Context, userID, a, b string) (bool, error)
+
+// presence announces that a device connected
+type presence struct {
+	HubID       string    `json:"hub_id"`
+	DeviceID    string    `json:"device_id"`
+	SessionID   string    `json:"session_id"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+type buffered struct {
+	data []byte
+	at   time.Time
+}
+
+// Hub holds the signaling sessions of the devices connected to this
+// instance
+type Hub struct {
+	id     string
+	pubsub PubSub
+	paired PairedFunc
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	buffers  map[string][]buffered
+}
+
+// NewHub creates a hub relaying through pubsub between devices that paired
+// reports as paired
+func NewHub(pubsub PubSub, paired PairedFunc) *Hub {
+	return &Hub{
+		id:       uuid.NewString(),
+		pubsub:   pubsub,
+		paired:   paired,
+		sessions: make(map[string]*Session),
+		buffers:  make(map[string][]buffered),
+	}
+}
+
+// Start subscribes to the presence announcements of other instances and
+// handles them in the background, also dropping stale buffered messages,
+// until ctx is cancelled
+func (h *Hub) Start(ctx context.Context) error {
+	sub, err := h.pubsub.Subscribe(ctx, presenceTopic)
+	if err != nil {
+		return err
+	}
+	go h.run(ctx, sub)
+	return nil
+}
+
+func (h *Hub) run(ctx context.Context, sub Subscription) {
+	defer sub.Close()
+	ticker := time.NewTicker(bufferTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-sub.Messages():
+			if !ok {
+				return
+			}
+			var p presence
+			if err := json.Unmarshal(data, &p); err != nil {
+				log.Printf("Invalid signaling presence message: %v", err)
+				continue
+			}
+			if p.HubID != h.id {
+				h.arrived(ctx, p)
+			}
+		case now := <-ticker.C:
+			h.expire(now)
+		}
+	}
+}
+
+// Connect starts a session for deviceID of userID, replacing any earlier
+// session of the device
+func (h *Hub) Connect(ctx context.Context, userID, deviceID string) (*Session, error) {
+	sub, err := h.pubsub.Subscribe(ctx, deviceTopic(deviceID))
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		hub:         h,
+		id:          uuid.NewString(),
+		userID:      userID,
+		deviceID:    deviceID,
+		connectedAt: time.Now(),
+		sub:         sub,
+		out:         make(chan ServerMessage, sessionBuffer),
+		done:        make(chan struct{}),
+		peers:       make(map[string]time.Time),
+	}
+	go s.forward()
+
+	h.mu.Lock()
+	old := h.sessions[deviceID]
+	h.sessions[deviceID] = s
+	h.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	data, err := json.Marshal(presence{HubID: h.id, DeviceID: deviceID, SessionID: s.id, ConnectedAt: s.connectedAt})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if _, err := h.pubsub.Publish(ctx, presenceTopic, data); err != nil {
+		s.Close()
+		return nil, err
+	}
+	h.flush(ctx, deviceID)
+	return s, nil
+}
+
+// arrived handles a device connecting to another instance: a session of the
+// device connected here before it is closed and the messages buffered for
+// the device are published again
+func (h *Hub) arrived(ctx context.Context, p presence) {
+	h.mu.Lock()
+	old := h.sessions[p.DeviceID]
+	if old != nil && old.connectedAt.Before(p.ConnectedAt) {
+		delete(h.sessions, p.DeviceID)
+	} else {
+		old = nil
+	}
+	h.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	h.flush(ctx, p.DeviceID)
+}
+
+// flush publishes the messages buffered for deviceID again
+func (h *Hub) flush(ctx context.Context, deviceID string) {
+	h.mu.Lock()
+	pending := h.buffers[deviceID]
+	delete(h.buffers, deviceID)
+	h.mu.Unlock()
+
+	now := time.Now()
+	for _, m := range pending {
+		if now.Sub(m.at) > bufferTTL {
+			continue
+		}
+		if err := h.deliver(ctx, deviceID, m); err != nil {
+			log.Printf("Failed to deliver buffered signaling message to device %s: %v", deviceID, err)
+		}
+	}
+}
+
+// deliver publishes a message to deviceID, buffering it when no instance
+// has the device connected
+func (h *Hub) deliver(ctx context.Context, deviceID string, m buffered) error {
+	received, err := h.pubsub.Publish(ctx, deviceTopic(deviceID), m.data)
+	if err != nil {
+		return err
+	}
+	if received > 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pending := append(h.buffers[deviceID], m)
+	if len(pending) > bufferSize {
+		pending = pending[len(pending)-bufferSize:]
+	}
+	h.buffers[deviceID] = pending
+	return nil
+}
+
+// expire drops the buffered messages older than bufferTTL
+func (h *Hub) expire(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for deviceID, pending := range h.buffers {
+		fresh := pending[:0]
+		for _, m := range pending {
+			if now.Sub(m.at) <= bufferTTL {
+				fresh = append(fresh, m)
+			}
+		}
+		if len(fresh) == 0 {
+			delete(h.buffers, deviceID)
+		} else {
+			h.buffers[deviceID] = fresh
+		}
+	}
+}
+
+func deviceTopic(deviceID string) string {
+	return "signal.device." + deviceID
+}
+
+// Session is the connection of one device to the hub
+type Session struct {
+	hub         *Hub
+	id          string
+	userID      string
+	deviceID    string
+	connectedAt time.Time
+	sub         Subscription
+	out         chan ServerMessage
+	done        chan struct{}
+	closeOnce   sync.Once
+
+	mu    sync.Mutex
+	peers map[string]time.Time
+}
+
+// DeviceID returns the connected device
+func (s *Session) DeviceID() string {
+	return s.deviceID
+}
+
+// Messages returns the messages relayed to the device
+func (s *Session) Messages() <-chan ServerMessage {
+	return s.out
+}
+
+// Done is closed when the session is closed, e.g. because the device
+// connected again
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send relays a message from the device to msg.To, which must be paired
+// with it. Messages for devices that are not connected are buffered
+// briefly.
+func (s *Session) Send(ctx context.Context, msg ClientMessage) error {
+	switch msg.Type {
+	case TypeOffer, TypeAnswer, TypeCandidate, TypeSync:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalid, msg.Type)
+	}
+	if msg.From != "" && msg.From != s.deviceID {
+		return fmt.Errorf("%w: from must be the connected device", ErrInvalid)
+	}
+	if msg.To == "" || msg.To == s.deviceID {
+		return fmt.Errorf("%w: to must be another device", ErrInvalid)
+	}
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+	if err := s.checkPaired(ctx, msg.To); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ServerMessage{
+		Type:    msg.Type,
+		From:    s.deviceID,
+		Payload: &Payload{SDP: msg.SDP, Candidate: msg.Candidate},
+	})
+	if err != nil {
+		return err
+	}
+	return s.hub.deliver(ctx, msg.To, buffered{data: data, at: time.Now()})
+}
+
+// checkPaired returns ErrNotPaired unless the device is paired with peer
+func (s *Session) checkPaired(ctx context.Context, peer string) error {
+	s.mu.Lock()
+	checked, ok := s.peers[peer]
+	s.mu.Unlock()
+	if ok && time.Since(checked) < pairingCacheTTL {
+		return nil
+	}
+
+	paired, err := s.hub.paired(ctx, s.userID, s.deviceID, peer)
+	if err != nil {
+		return err
+	}
+	if !paired {
+		return ErrNotPaired
+	}
+	s.mu.Lock()
+	s.peers[peer] = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// forward passes the messages published for the device to Messages until
+// the session is closed. A device that does not keep up is disconnected.
+func (s *Session) forward() {
+	for data := range s.sub.Messages() {
+		var msg ServerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("Invalid signaling message for device %s: %v", s.deviceID, err)
+			continue
+		}
+		select {
+		case s.out <- msg:
+		default:
+			log.Printf("Signaling session of device %s is not keeping up, closing it", s.deviceID)
+			s.Close()
+		}
+	}
+}
+
+// Close ends the session
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.sub.Close()
+
+		h := s.hub
+		h.mu.Lock()
+		if h.sessions[s.deviceID] == s {
+			delete(h.sessions, s.deviceID)
+		}
+		h.mu.Unlock()
+	})
+}
@@ -0,0 +1,147 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pairedWith pairs phone and browser of user-1 only
+func pairedWith(ctx context.Context, userID, a, b string) (bool, error) {
+	return userID == "user-1" && (a == "phone" && b == "browser" || a == "browser" && b == "phone"), nil
+}
+
+func startHubs(t *testing.T, n int) []*Hub {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	pubsub := NewMemoryPubSub()
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		hubs[i] = NewHub(pubsub, pairedWith)
+		if err := hubs[i].Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hubs
+}
+
+func connect(t *testing.T, hub *Hub, userID, deviceID string) *Session {
+	t.Helper()
+	s, err := hub.Connect(context.Background(), userID, deviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func receive(t *testing.T, s *Session) ServerMessage {
+	t.Helper()
+	select {
+	case msg := <-s.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("device %s received nothing", s.DeviceID())
+		return ServerMessage{}
+	}
+}
+
+func TestSendAcrossHubs(t *testing.T) {
+	hubs := startHubs(t, 2)
+	phone := connect(t, hubs[0], "user-1", "phone")
+	browser := connect(t, hubs[1], "user-1", "browser")
+
+	err := phone.Send(context.Background(), ClientMessage{Type: TypeOffer, To: "browser", SDP: []byte(`"v=0"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := receive(t, browser)
+	if msg.Type != TypeOffer || msg.From != "phone" || msg.Payload == nil || string(msg.Payload.SDP) != `"v=0"` {
+		t.Errorf("browser received %+v", msg)
+	}
+}
+
+func TestSendRejects(t *testing.T) {
+	hubs := startHubs(t, 1)
+	phone := connect(t, hubs[0], "user-1", "phone")
+	other := connect(t, hubs[0], "user-2", "phone-2")
+
+	tests := []struct {
+		name    string
+		session *Session
+		msg     ClientMessage
+		want    error
+	}{
+		{"unknown type", phone, ClientMessage{Type: "hello", To: "browser"}, ErrInvalid},
+		{"spoofed sender", phone, ClientMessage{Type: TypeOffer, From: "tv", To: "browser"}, ErrInvalid},
+		{"no recipient", phone, ClientMessage{Type: TypeOffer}, ErrInvalid},
+		{"to itself", phone, ClientMessage{Type: TypeOffer, To: "phone"}, ErrInvalid},
+		{"unpaired device", phone, ClientMessage{Type: TypeOffer, To: "tv"}, ErrNotPaired},
+		{"other user", other, ClientMessage{Type: TypeOffer, To: "browser"}, ErrNotPaired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.session.Send(context.Background(), tt.msg); !errors.Is(err, tt.want) {
+				t.Errorf("Send() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferedUntilReconnect(t *testing.T) {
+	hubs := startHubs(t, 2)
+	phone := connect(t, hubs[0], "user-1", "phone")
+
+	for _, typ := range []string{TypeOffer, TypeCandidate} {
+		if err := phone.Send(context.Background(), ClientMessage{Type: typ, To: "browser"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The browser comes back on the other instance
+	browser := connect(t, hubs[1], "user-1", "browser")
+	if msg := receive(t, browser); msg.Type != TypeOffer {
+		t.Errorf("first message = %+v", msg)
+	}
+	if msg := receive(t, browser); msg.Type != TypeCandidate {
+		t.Errorf("second message = %+v", msg)
+	}
+}
+
+func TestReconnectReplacesSession(t *testing.T) {
+	hubs := startHubs(t, 2)
+	first := connect(t, hubs[0], "user-1", "browser")
+	second := connect(t, hubs[1], "user-1", "browser")
+
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Fatal("earlier session was not closed")
+	}
+	select {
+	case <-second.Done():
+		t.Fatal("new session was closed")
+	default:
+	}
+	if err := first.Send(context.Background(), ClientMessage{Type: TypeOffer, To: "phone"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() on replaced session error = %v", err)
+	}
+}
+
+func TestExpireDropsStaleMessages(t *testing.T) {
+	hub := NewHub(NewMemoryPubSub(), pairedWith)
+	now := time.Now()
+	hub.buffers["browser"] = []buffered{{at: now.Add(-time.Minute)}, {at: now}}
+	hub.buffers["tv"] = []buffered{{at: now.Add(-time.Minute)}}
+
+	hub.expire(now)
+	if len(hub.buffers["browser"]) != 1 || hub.buffers["browser"][0].at != now {
+		t.Errorf("browser buffer = %+v", hub.buffers["browser"])
+	}
+	if _, ok := hub.buffers["tv"]; ok {
+		t.Error("empty buffer was kept")
+	}
+}
@@ -0,0 +1,92 @@
+package signaling
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriptionBuffer is how many messages a memory subscription holds
+// before further messages to it are dropped
+const subscriptionBuffer = 64
+
+// PubSub carries messages between the hubs of all catalog service
+// instances. Publish returns how many subscribers received the message, as
+// Redis PUBLISH does, so a hub can tell that nobody is listening.
+type PubSub interface {
+	Publish(ctx context.Context, topic string, data []byte) (int, error)
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+}
+
+// Subscription receives the messages published to a topic until closed
+type Subscription interface {
+	Messages() <-chan []byte
+	Close() error
+}
+
+// MemoryPubSub is a PubSub within one process, for running a single
+// instance
+type MemoryPubSub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*memorySubscription]struct{}
+}
+
+// NewMemoryPubSub creates an in-process PubSub
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{topics: make(map[string]map[*memorySubscription]struct{})}
+}
+
+// Publish hands data to the subscribers of topic. Subscribers that are not
+// keeping up miss the message and are not counted.
+func (p *MemoryPubSub) Publish(ctx context.Context, topic string, data []byte) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	received := 0
+	for sub := range p.topics[topic] {
+		select {
+		case sub.messages <- data:
+			received++
+		default:
+		}
+	}
+	return received, nil
+}
+
+// Subscribe starts receiving the messages published to topic
+func (p *MemoryPubSub) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	sub := &memorySubscription{pubsub: p, topic: topic, messages: make(chan []byte, subscriptionBuffer)}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.topics[topic] == nil {
+		p.topics[topic] = make(map[*memorySubscription]struct{})
+	}
+	p.topics[topic][sub] = struct{}{}
+	return sub, nil
+}
+
+type memorySubscription struct {
+	pubsub    *MemoryPubSub
+	topic     string
+	messages  chan []byte
+	closeOnce sync.Once
+}
+
+func (s *memorySubscription) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Close stops the subscription and closes its channel
+func (s *memorySubscription) Close() error {
+	s.closeOnce.Do(func() {
+		p := s.pubsub
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.topics[s.topic], s)
+		if len(p.topics[s.topic]) == 0 {
+			delete(p.topics, s.topic)
+		}
+		close(s.messages)
+	})
+	return nil
+}
@@ -0,0 +1,330 @@
+// Package smart compiles the rules of smart playlists to SQL selecting the
+// matching songs. Fields, operators and sorts come from allow-lists and
+// every value is passed as a query argument, so rules sent by users cannot
+// inject SQL.
+//
+// Rules can test the song itself (name, language, duration, ...), its
+// audio features (tempo, energy, valence, ...), its artists, album and tags,
+// and the listen history of the playlist's owner (plays, last_played).
+package smart
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"go-audio-stream/pkg/models"
+)
+
+const (
+	// DefaultLimit is the number of songs of rules without a limit
+	DefaultLimit = 100
+	// MaxLimit caps the limit of rules
+	MaxLimit = 500
+	// MaxConditions caps the conditions of rules
+	MaxConditions = 20
+	// DefaultSort orders the songs of rules without a sort, most played
+	// first
+	DefaultSort = "-play_count"
+	// maxDays caps the periods of day conditions
+	maxDays = 3650
+)
+
+// Join adds the audio features of songs to the query, which conditions and
+// sorts on feature fields refer to. Songs without features never match
+// them.
+const Join = "LEFT JOIN song_features ON song_features.song_id = songs.id AND song_features.deleted_at IS NULL"
+
+// ErrInvalid is wrapped by the errors for malformed rules
+var ErrInvalid = errors.New("invalid smart playlist rules")
+
+// Query is compiled rules. Where and Order are SQL over the songs table
+// joined with Join, Where taking Args.
+type Query struct {
+	Where string
+	Args  []interface{}
+	Order string
+	Limit int
+}
+
+// kind is the type of value a field is compared with
+type kind int
+
+const (
+	// text fields take strings and are compared ignoring case
+	text kind = iota
+	number
+	boolean
+	// days fields are timestamps compared with a number of days before now
+	days
+)
+
+var operators = map[kind][]string{
+	text:    {"eq", "neq", "contains", "in", "not_in"},
+	number:  {"eq", "neq", "gt", "gte", "lt", "lte", "between"},
+	boolean: {"eq"},
+	days:    {"in_last", "not_in_last"},
+}
+
+// field describes how a rule field is matched. Fields of songs compare
+// column directly; fields of related rows wrap the comparison of column in
+// related, a condition selecting the songs with matching rows. Owner fields
+// take the owner's user ID as their first argument.
+type field struct {
+	kind    kind
+	column  string
+	related string
+	owner   bool
+}
+
+const (
+	artistSongs = `songs.id IN (SELECT artist_song.song_id FROM artist_song
+		JOIN artists ON artists.id = artist_song.artist_id
+		WHERE artists.deleted_at IS NULL AND %s)`
+	albumSongs = `songs.album_id IN (SELECT albums.id FROM albums
+		WHERE albums.deleted_at IS NULL AND %s)`
+	tagSongs = `songs.id IN (SELECT song_tag_map.song_id FROM song_tag_map
+		JOIN song_tags ON song_tags.id = song_tag_map.song_tag_id
+		WHERE song_tags.deleted_at IS NULL AND %s)`
+	// user_listen_histories.song_id is compared as text, as in the play count
+	// trigger, since it is not typed uuid in every database
+	playedSongs = `songs.id::text IN (SELECT user_listen_histories.song_id::text FROM user_listen_histories
+		WHERE user_listen_histories.user_id = ? AND user_listen_histories.deleted_at IS NULL AND %s)`
+	userPlays = `(SELECT count(*) FROM user_listen_histories
+		WHERE user_listen_histories.user_id = ? AND user_listen_histories.deleted_at IS NULL
+		AND user_listen_histories.song_id::text = songs.id::text)`
+)
+
+var fields = map[string]field{
+	"name":     {kind: text, column: "songs.name"},
+	"language": {kind: text, column: "songs.language"},
+	"artist":   {kind: text, column: "artists.name", related: artistSongs},
+	"album":    {kind: text, column: "albums.title", related: albumSongs},
+	"tag":      {kind: text, column: "song_tags.name", related: tagSongs},
+
+	"duration":   {kind: number, column: "songs.duration"},
+	"play_count": {kind: number, column: "songs.play_count"},
+	"explicit":   {kind: boolean, column: "songs.explicit"},
+	"added":      {kind: days, column: "songs.created_at"},
+
+	"tempo":            {kind: number, column: "song_features.tempo"},
+	"energy":           {kind: number, column: "song_features.energy"},
+	"valence":          {kind: number, column: "song_features.valence"},
+	"danceability":     {kind: number, column: "song_features.danceability"},
+	"loudness":         {kind: number, column: "song_features.loudness"},
+	"acousticness":     {kind: number, column: "song_features.acousticness"},
+	"speechiness":      {kind: number, column: "song_features.speechiness"},
+	"instrumentalness": {kind: number, column: "song_features.instrumentalness"},
+
+	// The owner's listens: how often and how recently they played a song
+	"plays":       {kind: number, column: userPlays, owner: true},
+	"last_played": {kind: days, column: "user_listen_histories.played_at", related: playedSongs, owner: true},
+}
+
+// sorts maps the sort names of rules to their columns
+var sorts = map[string]string{
+	"name":         "songs.name",
+	"added":        "songs.created_at",
+	"duration":     "songs.duration",
+	"play_count":   "songs.play_count",
+	"tempo":        "song_features.tempo",
+	"energy":       "song_features.energy",
+	"valence":      "song_features.valence",
+	"danceability": "song_features.danceability",
+}
+
+// Compile validates rules and compiles them for the playlist owned by
+// userID, with day conditions counted back from now. Errors wrap ErrInvalid
+// and name the offending part of the rules.
+func Compile(rules *models.SmartRules, userID string, now time.Time) (*Query, error) {
+	if rules == nil {
+		return nil, fmt.Errorf("%w: rules are required", ErrInvalid)
+	}
+
+	join := " AND "
+	switch rules.Match {
+	case "", "all":
+	case "any":
+		join = " OR "
+	default:
+		return nil, fmt.Errorf("%w: match must be all or any", ErrInvalid)
+	}
+	if len(rules.Conditions) == 0 || len(rules.Conditions) > MaxConditions {
+		return nil, fmt.Errorf("%w: conditions must hold between 1 and %d conditions", ErrInvalid, MaxConditions)
+	}
+
+	q := &Query{Limit: DefaultLimit}
+	conditions := make([]string, len(rules.Conditions))
+	for i, condition := range rules.Conditions {
+		sql, args, err := compileCondition(condition, userID, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: conditions[%d]: %s", ErrInvalid, i, err)
+		}
+		conditions[i] = "(" + sql + ")"
+		q.Args = append(q.Args, args...)
+	}
+	q.Where = "(" + strings.Join(conditions, join) + ")"
+
+	order, err := compileSort(rules.Sort)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	q.Order = order
+
+	if rules.Limit < 0 || rules.Limit > MaxLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalid, MaxLimit)
+	}
+	if rules.Limit > 0 {
+		q.Limit = rules.Limit
+	}
+	return q, nil
+}
+
+// compileCondition compiles one condition to SQL and its arguments
+func compileCondition(condition models.SmartCondition, userID string, now time.Time) (string, []interface{}, error) {
+	f, ok := fields[condition.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown field %q", condition.Field)
+	}
+	if !slices.Contains(operators[f.kind], condition.Op) {
+		return "", nil, fmt.Errorf("%s takes the operators %s", condition.Field, strings.Join(operators[f.kind], ", "))
+	}
+
+	op := condition.Op
+	negated := false
+	if f.related != "" {
+		// Related rows are tested for the positive comparison, which is
+		// negated as a whole: a song without the tag matches neq too
+		switch op {
+		case "neq":
+			op, negated = "eq", true
+		case "not_in":
+			op, negated = "in", true
+		case "not_in_last":
+			op, negated = "in_last", true
+		}
+	}
+
+	var sql string
+	var args []interface{}
+	var err error
+	switch f.kind {
+	case text:
+		sql, args, err = compileText(f.column, op, condition.Value)
+	case number:
+		sql, args, err = compileNumber(f.column, op, condition.Value)
+	case boolean:
+		var value bool
+		if json.Unmarshal(condition.Value, &value) != nil {
+			return "", nil, fmt.Errorf("%s takes true or false", condition.Field)
+		}
+		sql, args = f.column+" = ?", []interface{}{value}
+	case days:
+		sql, args, err = compileDays(f.column, op, condition.Value, now)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%s %s", condition.Field, err)
+	}
+
+	if f.related != "" {
+		sql = fmt.Sprintf(f.related, sql)
+	}
+	if f.owner {
+		args = append([]interface{}{userID}, args...)
+	}
+	if negated {
+		sql = "NOT " + sql
+	}
+	return sql, args, nil
+}
+
+// compileText compares lower(column) with lowercased strings
+func compileText(column, op string, raw json.RawMessage) (string, []interface{}, error) {
+	expr := "lower(" + column + ")"
+	if op == "in" || op == "not_in" {
+		var values []string
+		if json.Unmarshal(raw, &values) != nil || len(values) == 0 {
+			return "", nil, errors.New("takes a list of strings")
+		}
+		for i := range values {
+			values[i] = strings.ToLower(values[i])
+		}
+		if op == "in" {
+			return expr + " IN ?", []interface{}{values}, nil
+		}
+		return expr + " NOT IN ?", []interface{}{values}, nil
+	}
+
+	var value string
+	if json.Unmarshal(raw, &value) != nil || value == "" {
+		return "", nil, errors.New("takes a string")
+	}
+	value = strings.ToLower(value)
+	switch op {
+	case "eq":
+		return expr + " = ?", []interface{}{value}, nil
+	case "neq":
+		return expr + " <> ?", []interface{}{value}, nil
+	}
+	return expr + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(value) + "%"}, nil
+}
+
+// compileNumber compares column with a number, or two for between
+func compileNumber(column, op string, raw json.RawMessage) (string, []interface{}, error) {
+	if op == "between" {
+		var bounds []float64
+		if json.Unmarshal(raw, &bounds) != nil || len(bounds) != 2 || bounds[0] > bounds[1] {
+			return "", nil, errors.New("takes [low, high]")
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{bounds[0], bounds[1]}, nil
+	}
+
+	var value float64
+	if json.Unmarshal(raw, &value) != nil {
+		return "", nil, errors.New("takes a number")
+	}
+	comparisons := map[string]string{"eq": "=", "neq": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+	return column + " " + comparisons[op] + " ?", []interface{}{value}, nil
+}
+
+// compileDays compares column with the time a number of days before now
+func compileDays(column, op string, raw json.RawMessage, now time.Time) (string, []interface{}, error) {
+	var n int
+	if json.Unmarshal(raw, &n) != nil || n < 1 || n > maxDays {
+		return "", nil, fmt.Errorf("takes a number of days between 1 and %d", maxDays)
+	}
+	since := now.AddDate(0, 0, -n)
+	if op == "in_last" {
+		return column + " >= ?", []interface{}{since}, nil
+	}
+	return "(" + column + " IS NULL OR " + column + " < ?)", []interface{}{since}, nil
+}
+
+// compileSort compiles the sort of rules to an ORDER BY clause. Songs
+// missing the sorted value come last, and ties are broken by ID.
+func compileSort(sort string) (string, error) {
+	if sort == "" {
+		sort = DefaultSort
+	}
+	if sort == "random" {
+		return "random()", nil
+	}
+	name, direction := strings.TrimPrefix(sort, "-"), "ASC"
+	if name != sort {
+		direction = "DESC"
+	}
+	column, ok := sorts[name]
+	if !ok {
+		return "", fmt.Errorf("sort must be random or one of %s", strings.Join(slices.Sorted(maps.Keys(sorts)), ", "))
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, songs.id", column, direction), nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,142 @@
+package smart
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/models"
+)
+
+func rules(t *testing.T, value string) *models.SmartRules {
+	t.Helper()
+	var r models.SmartRules
+	if err := json.Unmarshal([]byte(value), &r); err != nil {
+		t.Fatalf("unmarshal %s: %v", value, err)
+	}
+	return &r
+}
+
+func TestCompile(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	monthAgo := now.AddDate(0, 0, -30)
+
+	// Tamil songs with energy above 0.7 not played in 30 days, at most 50
+	q, err := Compile(rules(t, `{"conditions": [
+		{"field": "language", "op": "eq", "value": "TA"},
+		{"field": "energy", "op": "gt", "value": 0.7},
+		{"field": "last_played", "op": "not_in_last", "value": 30}
+	], "limit": 50}`), "user-1", now)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	conditions := strings.Split(q.Where, " AND (")
+	if len(conditions) != 3 {
+		t.Fatalf("Where = %s", q.Where)
+	}
+	if conditions[0] != "((lower(songs.language) = ?)" || conditions[1] != "song_features.energy > ?)" {
+		t.Errorf("Where = %s", q.Where)
+	}
+	if !strings.HasPrefix(conditions[2], "NOT songs.id::text IN (SELECT user_listen_histories.song_id::text") ||
+		!strings.Contains(conditions[2], "user_listen_histories.played_at >= ?") {
+		t.Errorf("last_played condition = %s", conditions[2])
+	}
+	if want := []interface{}{"ta", 0.7, "user-1", monthAgo}; !reflect.DeepEqual(q.Args, want) {
+		t.Errorf("Args = %#v, want %#v", q.Args, want)
+	}
+	if q.Order != "songs.play_count DESC NULLS LAST, songs.id" || q.Limit != 50 {
+		t.Errorf("Order = %q, Limit = %d", q.Order, q.Limit)
+	}
+	if got := strings.Count(q.Where, "?"); got != len(q.Args) {
+		t.Errorf("%d placeholders for %d args", got, len(q.Args))
+	}
+}
+
+func TestCompileConditions(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		condition string
+		where     string
+		args      []interface{}
+	}{
+		{`{"field": "name", "op": "contains", "value": "100%_"}`, `lower(songs.name) LIKE ? ESCAPE '\'`, []interface{}{`%100\%\_%`}},
+		{`{"field": "language", "op": "in", "value": ["TA", "hi"]}`, "lower(songs.language) IN ?", []interface{}{[]string{"ta", "hi"}}},
+		{`{"field": "tempo", "op": "between", "value": [90, 120]}`, "song_features.tempo BETWEEN ? AND ?", []interface{}{90.0, 120.0}},
+		{`{"field": "explicit", "op": "eq", "value": false}`, "songs.explicit = ?", []interface{}{false}},
+		{`{"field": "added", "op": "not_in_last", "value": 7}`, "(songs.created_at IS NULL OR songs.created_at < ?)", []interface{}{now.AddDate(0, 0, -7)}},
+		{`{"field": "tag", "op": "neq", "value": "Live"}`, "NOT songs.id IN (SELECT song_tag_map.song_id", []interface{}{"live"}},
+		{`{"field": "plays", "op": "gte", "value": 3}`, "(SELECT count(*) FROM user_listen_histories", []interface{}{"user-1", 3.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			q, err := Compile(rules(t, `{"conditions": [`+tt.condition+`]}`), "user-1", now)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if !strings.HasPrefix(q.Where, "(("+tt.where) {
+				t.Errorf("Where = %s, want prefix %s", q.Where, tt.where)
+			}
+			if !reflect.DeepEqual(q.Args, tt.args) {
+				t.Errorf("Args = %#v, want %#v", q.Args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCompileMatchAnyAndSort(t *testing.T) {
+	q, err := Compile(rules(t, `{"match": "any", "sort": "name", "conditions": [
+		{"field": "artist", "op": "eq", "value": "Anirudh"},
+		{"field": "album", "op": "contains", "value": "Jailer"}
+	]}`), "", time.Now())
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !strings.Contains(q.Where, ") OR (songs.album_id IN") {
+		t.Errorf("Where = %s", q.Where)
+	}
+	if q.Order != "songs.name ASC NULLS LAST, songs.id" || q.Limit != DefaultLimit {
+		t.Errorf("Order = %q, Limit = %d", q.Order, q.Limit)
+	}
+
+	q, err = Compile(rules(t, `{"sort": "random", "conditions": [{"field": "energy", "op": "lt", "value": 0.3}]}`), "", time.Now())
+	if err != nil || q.Order != "random()" {
+		t.Errorf("random sort = %+v, %v", q, err)
+	}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  string
+	}{
+		{`{"conditions": []}`, "conditions must hold"},
+		{`{"match": "most", "conditions": [{"field": "name", "op": "eq", "value": "x"}]}`, "match must be"},
+		{`{"conditions": [{"field": "password", "op": "eq", "value": "x"}]}`, `conditions[0]: unknown field "password"`},
+		{`{"conditions": [{"field": "songs.name; DROP TABLE songs", "op": "eq", "value": "x"}]}`, "unknown field"},
+		{`{"conditions": [{"field": "energy", "op": "contains", "value": "x"}]}`, "energy takes the operators"},
+		{`{"conditions": [{"field": "energy", "op": "gt", "value": "high"}]}`, "energy takes a number"},
+		{`{"conditions": [{"field": "tempo", "op": "between", "value": [120, 90]}]}`, "tempo takes [low, high]"},
+		{`{"conditions": [{"field": "language", "op": "in", "value": []}]}`, "language takes a list"},
+		{`{"conditions": [{"field": "last_played", "op": "in_last", "value": 0}]}`, "last_played takes a number of days"},
+		{`{"conditions": [{"field": "explicit", "op": "eq", "value": "yes"}]}`, "explicit takes true or false"},
+		{`{"sort": "id; --", "conditions": [{"field": "name", "op": "eq", "value": "x"}]}`, "sort must be random or one of"},
+		{`{"limit": 501, "conditions": [{"field": "name", "op": "eq", "value": "x"}]}`, "limit must be between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			_, err := Compile(rules(t, tt.rules), "", time.Now())
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := Compile(nil, "", time.Now()); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Compile(nil) error = %v", err)
+	}
+}
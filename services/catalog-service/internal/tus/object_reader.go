@@ -0,0 +1,64 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go-audio-stream/pkg/storage"
+)
+
+// objectReader is an io.ReadSeeker over a stored file that fetches each
+// read with a ranged request, so an assembled upload can be validated
+// without downloading it
+type objectReader struct {
+	ctx     context.Context
+	storage storage.Backend
+	key     string
+	size    int64
+	offset  int64
+}
+
+func newObjectReader(ctx context.Context, storageClient storage.Backend, key string, size int64) *objectReader {
+	return &objectReader{ctx: ctx, storage: storageClient, key: key, size: size}
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	length := int64(len(p))
+	if remaining := r.size - r.offset; length > remaining {
+		length = remaining
+	}
+
+	body, err := r.storage.GetRange(r.ctx, r.key, r.offset, length)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:length])
+	r.offset += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,320 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/sniff"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+)
+
+var (
+	ErrNotFound       = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	ErrCompleted      = errors.New("upload already completed")
+)
+
+// Store persists upload progress in Postgres and upload data in the storage
+// backend. Data is sent to storage in parts of partSize bytes; whatever
+// remains below that after a request is kept in a separate incomplete-part
+// object and prepended to the next request's data, so no request has to
+// deliver a full part.
+type Store struct {
+	storage  storage.Backend
+	db       database.Service
+	partSize int64
+	expiry   time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewStore creates an upload store. partSize is raised to the storage
+// minimum part size if needed; uploads expire after expiry without
+// progress.
+func NewStore(storageClient storage.Backend, db database.Service, partSize int64, expiry time.Duration) *Store {
+	if partSize < storage.MinPartSize {
+		partSize = storage.MinPartSize
+	}
+	return &Store{
+		storage:  storageClient,
+		db:       db,
+		partSize: partSize,
+		expiry:   expiry,
+		locks:    make(map[string]*sync.Mutex),
+	}
+}
+
+// Create records a new upload
+func (s *Store) Create(ctx context.Context, upload *models.Upload) error {
+	upload.ExpiresAt = time.Now().Add(s.expiry)
+	return s.db.GetDB().WithContext(ctx).Create(upload).Error
+}
+
+// Get loads an unexpired upload
+func (s *Store) Get(ctx context.Context, id string) (*models.Upload, error) {
+	var upload models.Upload
+	err := s.db.GetDB().WithContext(ctx).Where("id = ?", id).Limit(1).Find(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	if upload.ID == "" || (upload.CompletedAt == nil && time.Now().After(upload.ExpiresAt)) {
+		return nil, ErrNotFound
+	}
+	return &upload, nil
+}
+
+// Lock serialises requests for one upload within this process and returns
+// the unlock function
+func (s *Store) Lock(id string) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[id] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+func (s *Store) forget(id string) {
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+}
+
+// Append writes body at the upload's current offset. Progress is saved after
+// every part so an interrupted request can be resumed from what was stored.
+// When the last byte arrives the parts are assembled and the file is
+// validated; a *sniff.Rejection is returned if it is not playable audio.
+func (s *Store) Append(ctx context.Context, upload *models.Upload, offset int64, body io.Reader) error {
+	if upload.CompletedAt != nil {
+		return ErrCompleted
+	}
+	if offset != upload.Offset {
+		return ErrOffsetMismatch
+	}
+
+	// Keep saving progress after the client disconnects
+	ctx = context.WithoutCancel(ctx)
+	upload.ExpiresAt = time.Now().Add(s.expiry)
+
+	reader := io.LimitReader(body, upload.Length-upload.Offset)
+	hadTail := upload.IncompleteSize > 0
+	if hadTail {
+		tail, err := s.readIncomplete(ctx, upload)
+		if err != nil {
+			return err
+		}
+		reader = io.MultiReader(bytes.NewReader(tail), reader)
+	}
+
+	stored := upload.Offset - upload.IncompleteSize // bytes in finished parts
+	buf := make([]byte, s.partSize)
+	for {
+		n, readErr := io.ReadFull(reader, buf)
+		data := buf[:n]
+		last := stored+int64(n) == upload.Length
+
+		switch {
+		case int64(n) == s.partSize || (last && n > 0):
+			if err := s.uploadPart(ctx, upload, data); err != nil {
+				return err
+			}
+			stored += int64(n)
+			upload.IncompleteSize = 0
+		case n > 0 && (!hadTail || int64(n) > upload.IncompleteSize):
+			// The request ended below a full part; keep the rest for the
+			// next request
+			if _, err := s.storage.Upload(ctx, incompleteKey(upload.ID), bytes.NewReader(data), "application/octet-stream"); err != nil {
+				return err
+			}
+			upload.IncompleteSize = int64(n)
+		}
+
+		if hadTail && upload.IncompleteSize == 0 {
+			if err := s.storage.Delete(ctx, incompleteKey(upload.ID)); err != nil {
+				log.Printf("Failed to delete incomplete part of upload %s: %v", upload.ID, err)
+			}
+			hadTail = false
+		}
+
+		upload.Offset = stored + upload.IncompleteSize
+		if err := s.save(ctx, upload); err != nil {
+			return err
+		}
+
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+				break
+			}
+			return readErr
+		}
+	}
+
+	if upload.Offset == upload.Length {
+		return s.finish(ctx, upload)
+	}
+	return nil
+}
+
+// readIncomplete loads the data held back from earlier requests
+func (s *Store) readIncomplete(ctx context.Context, upload *models.Upload) ([]byte, error) {
+	tail, _, err := s.storage.Get(ctx, incompleteKey(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incomplete part: %w", err)
+	}
+	defer tail.Close()
+
+	data, err := io.ReadAll(io.LimitReader(tail, upload.IncompleteSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incomplete part: %w", err)
+	}
+	if int64(len(data)) != upload.IncompleteSize {
+		return nil, fmt.Errorf("incomplete part is %d bytes, expected %d", len(data), upload.IncompleteSize)
+	}
+	return data, nil
+}
+
+// uploadPart stores data as the next part, starting the multipart upload on
+// the first part once the format can be detected
+func (s *Store) uploadPart(ctx context.Context, upload *models.Upload, data []byte) error {
+	if upload.MultipartID == "" {
+		detected, ok := sniff.Detect(data)
+		if !ok {
+			return &sniff.Rejection{Reason: sniff.ReasonUnrecognized, Message: "Unrecognized file format"}
+		}
+		if detected.Kind != sniff.KindAudio {
+			return &sniff.Rejection{Reason: sniff.ReasonWrongKind, Message: "Expected an audio file, got " + detected.MIMEType, Detected: detected.MIMEType}
+		}
+
+		upload.Key = fmt.Sprintf("songs/%s/audio%s", upload.SongID, detected.Extension)
+		upload.ContentType = detected.MIMEType
+		multipartID, err := s.storage.CreateMultipartUpload(ctx, upload.Key, upload.ContentType)
+		if err != nil {
+			return err
+		}
+		upload.MultipartID = multipartID
+	}
+
+	part, err := s.storage.UploadPart(ctx, upload.Key, upload.MultipartID, int32(len(upload.Parts)+1), bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	upload.Parts = append(upload.Parts, models.UploadPart{Number: part.Number, ETag: part.ETag, Size: part.Size})
+	return nil
+}
+
+// finish assembles the parts into the final key and checks that the result
+// is playable. Invalid files are removed along with the upload.
+func (s *Store) finish(ctx context.Context, upload *models.Upload) error {
+	parts := make([]storage.Part, len(upload.Parts))
+	for i, part := range upload.Parts {
+		parts[i] = storage.Part{Number: part.Number, ETag: part.ETag, Size: part.Size}
+	}
+	if err := s.storage.CompleteMultipartUpload(ctx, upload.Key, upload.MultipartID, parts); err != nil {
+		return err
+	}
+
+	if _, err := sniff.ValidateAudio(newObjectReader(ctx, s.storage, upload.Key, upload.Length)); err != nil {
+		if deleteErr := s.storage.Delete(ctx, upload.Key); deleteErr != nil {
+			log.Printf("Failed to delete invalid upload %s: %v", upload.Key, deleteErr)
+		}
+		if deleteErr := s.delete(ctx, upload); deleteErr != nil {
+			log.Printf("Failed to delete invalid upload %s: %v", upload.ID, deleteErr)
+		}
+		return err
+	}
+
+	now := time.Now()
+	upload.CompletedAt = &now
+	s.forget(upload.ID)
+	return s.save(ctx, upload)
+}
+
+// Terminate discards an upload and everything stored for it
+func (s *Store) Terminate(ctx context.Context, upload *models.Upload) error {
+	ctx = context.WithoutCancel(ctx)
+	if upload.MultipartID != "" && upload.CompletedAt == nil {
+		err := s.storage.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID)
+		if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			return err
+		}
+	}
+	if upload.IncompleteSize > 0 {
+		if err := s.storage.Delete(ctx, incompleteKey(upload.ID)); err != nil {
+			return err
+		}
+	}
+	return s.delete(ctx, upload)
+}
+
+// ExpireUploads terminates unfinished uploads past their expiry and
+// returns how many were removed
+func (s *Store) ExpireUploads(ctx context.Context) (int, error) {
+	var expired []models.Upload
+	err := s.db.GetDB().WithContext(ctx).
+		Where("completed_at IS NULL AND expires_at < ?", time.Now()).
+		Find(&expired).Error
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range expired {
+		unlock := s.Lock(expired[i].ID)
+		err := s.Terminate(ctx, &expired[i])
+		unlock()
+		if err != nil {
+			log.Printf("Failed to expire upload %s: %v", expired[i].ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunExpiry calls ExpireUploads every interval until ctx is cancelled
+func (s *Store) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.ExpireUploads(ctx)
+			if err != nil {
+				log.Printf("Failed to expire uploads: %v", err)
+			} else if removed > 0 {
+				log.Printf("Expired %d abandoned uploads", removed)
+			}
+		}
+	}
+}
+
+func (s *Store) save(ctx context.Context, upload *models.Upload) error {
+	return s.db.GetDB().WithContext(ctx).Save(upload).Error
+}
+
+func (s *Store) delete(ctx context.Context, upload *models.Upload) error {
+	s.forget(upload.ID)
+	return s.db.GetDB().WithContext(ctx).Unscoped().Delete(upload).Error
+}
+
+// incompleteKey is where data below the minimum part size waits for the
+// next request
+func incompleteKey(uploadID string) string {
+	return "uploads/" + uploadID + "/incomplete"
+}
@@ -0,0 +1,205 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/sniff"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// memoryDB runs queries dry and records the uploads saved and deleted
+type memoryDB struct {
+	database.Service
+	db      *gorm.DB
+	saved   map[string]models.Upload
+	deleted map[string]bool
+}
+
+func newMemoryDB(t *testing.T) *memoryDB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	m := &memoryDB{db: db, saved: map[string]models.Upload{}, deleted: map[string]bool{}}
+	db.Callback().Update().Register("test:save", func(tx *gorm.DB) {
+		if upload, ok := tx.Statement.Dest.(*models.Upload); ok {
+			m.saved[upload.ID] = *upload
+		}
+	})
+	db.Callback().Delete().Register("test:delete", func(tx *gorm.DB) {
+		if upload, ok := tx.Statement.Dest.(*models.Upload); ok {
+			m.deleted[upload.ID] = true
+		}
+	})
+	return m
+}
+
+func (m *memoryDB) GetDB() *gorm.DB {
+	return m.db
+}
+
+// cutReader returns its data and then fails, like a dropped connection
+type cutReader struct {
+	data []byte
+}
+
+func (r *cutReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// mp3Frames returns n frames of MPEG 1 Layer III, 128 kbps, 44.1 kHz
+func mp3Frames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	return bytes.Repeat(frame, n)
+}
+
+func newTestStore(t *testing.T) (*Store, *storage.LocalClient, *memoryDB) {
+	t.Helper()
+	client, err := storage.NewLocalClient(storage.Config{LocalPath: t.TempDir(), LocalBaseURL: "http://localhost:4000"})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	db := newMemoryDB(t)
+	return NewStore(client, db, 0, time.Hour), client, db
+}
+
+func readObject(t *testing.T, client *storage.LocalClient, key string) []byte {
+	t.Helper()
+	reader, _, err := client.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q) error = %v", key, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll(%q) error = %v", key, err)
+	}
+	return data
+}
+
+func TestStoreAppend(t *testing.T) {
+	ctx := context.Background()
+	store, client, db := newTestStore(t)
+
+	// Two parts: a full one and the rest as the last
+	data := mp3Frames(int(storage.MinPartSize/417) + 100)
+	upload := &models.Upload{BaseModel: models.BaseModel{ID: "u1"}, SongID: "s1", Length: int64(len(data))}
+
+	// A chunk below the part size is held back
+	if err := store.Append(ctx, upload, 0, bytes.NewReader(data[:1000])); err != nil {
+		t.Fatalf("Append() below part size error = %v", err)
+	}
+	if upload.Offset != 1000 || upload.IncompleteSize != 1000 || len(upload.Parts) != 0 {
+		t.Fatalf("after small chunk offset = %d, incomplete = %d, parts = %d", upload.Offset, upload.IncompleteSize, len(upload.Parts))
+	}
+	if tail := readObject(t, client, incompleteKey("u1")); !bytes.Equal(tail, data[:1000]) {
+		t.Errorf("incomplete part is %d bytes, want the first 1000", len(tail))
+	}
+
+	if err := store.Append(ctx, upload, 0, bytes.NewReader(data)); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("Append() at wrong offset error = %v, want ErrOffsetMismatch", err)
+	}
+
+	// A body cut off past the part size stores the full part and keeps the
+	// rest for the resume
+	cut := int64(storage.MinPartSize + 500)
+	err := store.Append(ctx, upload, 1000, &cutReader{data: data[1000:cut]})
+	if err == nil {
+		t.Fatal("Append() with cut off body succeeded")
+	}
+	if upload.Offset != cut || len(upload.Parts) != 1 || upload.IncompleteSize != cut-storage.MinPartSize {
+		t.Fatalf("after cut off body offset = %d, parts = %d, incomplete = %d", upload.Offset, len(upload.Parts), upload.IncompleteSize)
+	}
+	if upload.Key != "songs/s1/audio.mp3" || upload.ContentType != "audio/mpeg" {
+		t.Errorf("upload key = %q (%s)", upload.Key, upload.ContentType)
+	}
+	if saved := db.saved["u1"]; saved.Offset != cut {
+		t.Errorf("saved offset = %d, want %d", saved.Offset, cut)
+	}
+
+	// Resuming from the saved offset finishes the upload
+	if err := store.Append(ctx, upload, upload.Offset, bytes.NewReader(data[cut:])); err != nil {
+		t.Fatalf("Append() resume error = %v", err)
+	}
+	if upload.CompletedAt == nil || upload.Offset != upload.Length || len(upload.Parts) != 2 {
+		t.Fatalf("after resume completed = %v, offset = %d, parts = %d", upload.CompletedAt, upload.Offset, len(upload.Parts))
+	}
+	if stored := readObject(t, client, upload.Key); !bytes.Equal(stored, data) {
+		t.Errorf("stored file is %d bytes, want the %d uploaded", len(stored), len(data))
+	}
+	if _, err := client.Stat(ctx, incompleteKey("u1")); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("incomplete part left behind: %v", err)
+	}
+	if db.saved["u1"].CompletedAt == nil {
+		t.Error("completion was not saved")
+	}
+
+	if err := store.Append(ctx, upload, upload.Offset, bytes.NewReader(nil)); !errors.Is(err, ErrCompleted) {
+		t.Errorf("Append() after completion error = %v, want ErrCompleted", err)
+	}
+}
+
+func TestStoreAppendRejectsInvalidAudio(t *testing.T) {
+	ctx := context.Background()
+	store, client, db := newTestStore(t)
+
+	// An MP3 header followed by no further frames
+	data := append(mp3Frames(1), make([]byte, 2000)...)
+	upload := &models.Upload{BaseModel: models.BaseModel{ID: "u1"}, SongID: "s1", Length: int64(len(data))}
+
+	err := store.Append(ctx, upload, 0, bytes.NewReader(data))
+	var rejection *sniff.Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("Append() error = %v, want a rejection", err)
+	}
+	if _, err := client.Stat(ctx, upload.Key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("invalid file left behind: %v", err)
+	}
+	if !db.deleted["u1"] {
+		t.Error("invalid upload was not deleted")
+	}
+}
+
+func TestStoreTerminate(t *testing.T) {
+	ctx := context.Background()
+	store, client, db := newTestStore(t)
+
+	data := mp3Frames(int(storage.MinPartSize/417) + 100)
+	upload := &models.Upload{BaseModel: models.BaseModel{ID: "u1"}, SongID: "s1", Length: int64(len(data))}
+	if err := store.Append(ctx, upload, 0, bytes.NewReader(data[:storage.MinPartSize+10])); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if upload.MultipartID == "" || upload.IncompleteSize != 10 {
+		t.Fatalf("after Append() multipart = %q, incomplete = %d", upload.MultipartID, upload.IncompleteSize)
+	}
+
+	if err := store.Terminate(ctx, upload); err != nil {
+		t.Fatalf("Terminate() error = %v", err)
+	}
+	if err := client.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID); !errors.Is(err, storage.ErrUploadNotFound) {
+		t.Errorf("multipart upload was not aborted: %v", err)
+	}
+	if _, err := client.Stat(ctx, incompleteKey("u1")); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("incomplete part left behind: %v", err)
+	}
+	if !db.deleted["u1"] {
+		t.Error("upload was not deleted")
+	}
+}
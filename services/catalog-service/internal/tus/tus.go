@@ -0,0 +1,62 @@
+// Package tus implements the server side of the tus 1.0 resumable upload
+// protocol (https://tus.io/protocols/resumable-upload) on top of the
+// storage backend's multipart uploads.
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Protocol constants
+const (
+	Version    = "1.0.0"
+	Extensions = "creation,creation-with-upload,expiration,termination"
+
+	// OffsetContentType is the required Content-Type of PATCH requests
+	OffsetContentType = "application/offset+octet-stream"
+)
+
+// Request and response headers
+const (
+	HeaderResumable      = "Tus-Resumable"
+	HeaderVersion        = "Tus-Version"
+	HeaderExtension      = "Tus-Extension"
+	HeaderMaxSize        = "Tus-Max-Size"
+	HeaderUploadLength   = "Upload-Length"
+	HeaderUploadOffset   = "Upload-Offset"
+	HeaderUploadMetadata = "Upload-Metadata"
+	HeaderUploadExpires  = "Upload-Expires"
+	HeaderDeferLength    = "Upload-Defer-Length"
+)
+
+// ErrInvalidMetadata is returned for malformed Upload-Metadata headers
+var ErrInvalidMetadata = errors.New("invalid Upload-Metadata header")
+
+// ParseMetadata decodes an Upload-Metadata header: comma-separated pairs of
+// a key and an optional base64-encoded value
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, ErrInvalidMetadata
+		}
+		if _, exists := metadata[key]; exists {
+			return nil, ErrInvalidMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
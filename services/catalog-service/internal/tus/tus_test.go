@@ -0,0 +1,41 @@
+package tus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"pairs", "filename dHJhY2subXAz,song_id MTIz", map[string]string{"filename": "track.mp3", "song_id": "123"}, false},
+		{"key without value", "is_master, filename YS5tcDM=", map[string]string{"is_master": "", "filename": "a.mp3"}, false},
+		{"bad base64", "filename not*base64", nil, true},
+		{"duplicate key", "a YQ==,a Yg==", nil, true},
+		{"empty key", "a YQ==,,b Yg==", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetadata(tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMetadata) {
+					t.Fatalf("ParseMetadata() error = %v, want ErrInvalidMetadata", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMetadata() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
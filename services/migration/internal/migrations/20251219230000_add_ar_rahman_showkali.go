@@ -26,8 +26,8 @@ func (m *AddARRahmanShowkali) Name() string {
 func (m *AddARRahmanShowkali) Up(db *gorm.DB) error {
 	ctx := context.Background()
 
-	// Initialize storage client for Backblaze B2
-	storageClient, err := storage.NewClient(storage.LoadConfig())
+	// Initialize storage backend (Backblaze B2 unless STORAGE_BACKEND=local)
+	storageClient, err := storage.NewBackend(storage.LoadConfig())
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage client: %w", err)
 	}
@@ -94,7 +94,7 @@ func (m *AddARRahmanShowkali) Up(db *gorm.DB) error {
 		Artists:     []models.Artist{artist},
 	}
 
-	if err := db.Create(&song).Error; err != nil {
+	if err := db.Omit("Artists.*").Create(&song).Error; err != nil {
 		return fmt.Errorf("failed to create song Showkali: %w", err)
 	}
 	log.Println("Created song: Showkali from Acham Yenbadhu Madamaiyada (2016)")
@@ -105,8 +105,8 @@ func (m *AddARRahmanShowkali) Up(db *gorm.DB) error {
 func (m *AddARRahmanShowkali) Down(db *gorm.DB) error {
 	ctx := context.Background()
 
-	// Initialize storage client for Backblaze B2
-	storageClient, err := storage.NewClient(storage.LoadConfig())
+	// Initialize storage backend (Backblaze B2 unless STORAGE_BACKEND=local)
+	storageClient, err := storage.NewBackend(storage.LoadConfig())
 	if err != nil {
 		log.Printf("Warning: failed to initialize storage client for cleanup: %v", err)
 	} else {
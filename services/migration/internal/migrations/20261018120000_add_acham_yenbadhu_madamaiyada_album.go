@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"go-audio-stream/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// AddAchamYenbadhuMadamaiyadaAlbum creates the album the seeded Showkali song
+// belongs to and places the song on its tracklist
+type AddAchamYenbadhuMadamaiyadaAlbum struct{}
+
+func (m *AddAchamYenbadhuMadamaiyadaAlbum) Version() string {
+	return "20261018120000"
+}
+
+func (m *AddAchamYenbadhuMadamaiyadaAlbum) Name() string {
+	return "add_acham_yenbadhu_madamaiyada_album"
+}
+
+func (m *AddAchamYenbadhuMadamaiyadaAlbum) Up(db *gorm.DB) error {
+	var artist models.Artist
+	if err := db.Where("name = ?", "A.R. Rahman").First(&artist).Error; err != nil {
+		return fmt.Errorf("failed to find artist A.R. Rahman: %w", err)
+	}
+
+	album := models.Album{
+		Title:     "Acham Yenbadhu Madamaiyada",
+		Type:      models.AlbumTypeAlbum,
+		Image:     fmt.Sprintf("https://%s.s3.%s.backblazeb2.com/albums/acham-yenbadhu-madamaiyada.jpg", os.Getenv("B2_BUCKET_NAME"), os.Getenv("B2_REGION")),
+		DiscCount: 1,
+		Artists:   []models.Artist{artist},
+	}
+	if err := db.Omit("Artists.*").Create(&album).Error; err != nil {
+		return fmt.Errorf("failed to create album Acham Yenbadhu Madamaiyada: %w", err)
+	}
+	log.Println("Created album: Acham Yenbadhu Madamaiyada")
+
+	err := db.Model(&models.Song{}).Where("name = ?", "Showkali").Updates(map[string]interface{}{
+		"album_id":     album.ID,
+		"disc_number":  1,
+		"track_number": 1,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to add Showkali to album: %w", err)
+	}
+
+	return nil
+}
+
+func (m *AddAchamYenbadhuMadamaiyadaAlbum) Down(db *gorm.DB) error {
+	var album models.Album
+	if err := db.Where("title = ?", "Acham Yenbadhu Madamaiyada").First(&album).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to find album: %w", err)
+	}
+
+	err := db.Model(&models.Song{}).Where("album_id = ?", album.ID).Updates(map[string]interface{}{
+		"album_id":    nil,
+		"disc_number": nil,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to detach songs from album: %w", err)
+	}
+	if err := db.Model(&album).Association("Artists").Clear(); err != nil {
+		return fmt.Errorf("failed to remove album artists: %w", err)
+	}
+	if err := db.Delete(&album).Error; err != nil {
+		return fmt.Errorf("failed to delete album: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// AddRolesToSongCredits lets an artist be credited on a song in several
+// roles by adding role to the primary key of artist_song. Existing credits
+// become primary artist credits.
+type AddRolesToSongCredits struct{}
+
+func (m *AddRolesToSongCredits) Version() string {
+	return "20261018130000"
+}
+
+func (m *AddRolesToSongCredits) Name() string {
+	return "add_roles_to_song_credits"
+}
+
+func (m *AddRolesToSongCredits) Up(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			"ALTER TABLE artist_song ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'primary'",
+			"ALTER TABLE artist_song ADD COLUMN IF NOT EXISTS position bigint NOT NULL DEFAULT 0",
+			"ALTER TABLE artist_song ADD COLUMN IF NOT EXISTS created_at timestamptz",
+			"ALTER TABLE artist_song DROP CONSTRAINT IF EXISTS artist_song_pkey",
+			"ALTER TABLE artist_song ADD PRIMARY KEY (song_id, artist_id, role)",
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to migrate artist_song: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AddRolesToSongCredits) Down(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			// Keep one credit per artist and song, preferring primary
+			`DELETE FROM artist_song a USING artist_song b
+				WHERE a.song_id = b.song_id AND a.artist_id = b.artist_id AND a.role <> b.role
+				AND (b.role = 'primary' OR (a.role <> 'primary' AND a.role > b.role))`,
+			"ALTER TABLE artist_song DROP CONSTRAINT IF EXISTS artist_song_pkey",
+			"ALTER TABLE artist_song ADD PRIMARY KEY (song_id, artist_id)",
+			"ALTER TABLE artist_song DROP COLUMN IF EXISTS role",
+			"ALTER TABLE artist_song DROP COLUMN IF EXISTS position",
+			"ALTER TABLE artist_song DROP COLUMN IF EXISTS created_at",
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to revert artist_song: %w", err)
+			}
+		}
+		return nil
+	})
+}
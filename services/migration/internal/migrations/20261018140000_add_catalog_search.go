@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// searchVectors are the weighted tsvector expressions searched per table.
+// The 'simple' configuration neither stems nor drops stop words, which suits
+// names in many languages.
+var searchVectors = []struct {
+	table, name, vector string
+}{
+	{"songs", "name", `setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(language, '')), 'D')`},
+	{"artists", "name", `setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(bio, '')), 'C')`},
+	{"albums", "title", `setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(label, '')), 'C')`},
+	{"playlists", "name", `setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(description, '')), 'C')`},
+}
+
+// AddCatalogSearch adds the search_vector columns and trigram indexes used by
+// catalog search, and keeps songs.play_count up to date from the listen
+// history to rank popular songs first
+type AddCatalogSearch struct{}
+
+func (m *AddCatalogSearch) Version() string {
+	return "20261018140000"
+}
+
+func (m *AddCatalogSearch) Name() string {
+	return "add_catalog_search"
+}
+
+func (m *AddCatalogSearch) Up(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{"CREATE EXTENSION IF NOT EXISTS pg_trgm"}
+		for _, v := range searchVectors {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (%s) STORED", v.table, v.vector),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_search_vector ON %[1]s USING gin (search_vector)", v.table),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_%[2]s_trgm ON %[1]s USING gin (lower(%[2]s) gin_trgm_ops)", v.table, v.name),
+			)
+		}
+		statements = append(statements,
+			"ALTER TABLE songs ADD COLUMN IF NOT EXISTS play_count bigint NOT NULL DEFAULT 0",
+			`UPDATE songs SET play_count = plays.count
+				FROM (SELECT song_id, count(*) FROM user_listen_histories GROUP BY song_id) plays
+				WHERE songs.id::text = plays.song_id`,
+			`CREATE OR REPLACE FUNCTION count_song_play() RETURNS trigger AS $$
+			BEGIN
+				UPDATE songs SET play_count = play_count + 1 WHERE id = NEW.song_id::uuid;
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`,
+			"DROP TRIGGER IF EXISTS count_song_play ON user_listen_histories",
+			"CREATE TRIGGER count_song_play AFTER INSERT ON user_listen_histories FOR EACH ROW EXECUTE FUNCTION count_song_play()",
+		)
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to add catalog search: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AddCatalogSearch) Down(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"DROP TRIGGER IF EXISTS count_song_play ON user_listen_histories",
+			"DROP FUNCTION IF EXISTS count_song_play()",
+		}
+		for _, v := range searchVectors {
+			statements = append(statements,
+				fmt.Sprintf("DROP INDEX IF EXISTS idx_%s_%s_trgm", v.table, v.name),
+				fmt.Sprintf("DROP INDEX IF EXISTS idx_%s_search_vector", v.table),
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS search_vector", v.table),
+			)
+		}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to remove catalog search: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"fmt"
+
+	"go-audio-stream/pkg/database/search/translit"
+
+	"gorm.io/gorm"
+)
+
+// keyedTables are the tables whose names get transliterated search keys
+var keyedTables = map[string]bool{"songs": true, "artists": true, "albums": true}
+
+// AddTransliteratedSearchKeys fills search_keys with the native-script,
+// romanized and phonetic forms of each song, artist and album name, and adds
+// them to search_vector with a trigram index so search matches across
+// scripts. The catalog keeps the keys current on every save.
+type AddTransliteratedSearchKeys struct{}
+
+func (m *AddTransliteratedSearchKeys) Version() string {
+	return "20261018150000"
+}
+
+func (m *AddTransliteratedSearchKeys) Name() string {
+	return "add_transliterated_search_keys"
+}
+
+func (m *AddTransliteratedSearchKeys) Up(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, v := range searchVectors {
+			if !keyedTables[v.table] {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_keys text", v.table)).Error; err != nil {
+				return fmt.Errorf("failed to add search keys to %s: %w", v.table, err)
+			}
+			if err := backfillSearchKeys(tx, v.table, v.name); err != nil {
+				return err
+			}
+
+			vector := v.vector + " ||\n\t\tsetweight(to_tsvector('simple', coalesce(search_keys, '')), 'B')"
+			if err := replaceSearchVector(tx, v.table, vector); err != nil {
+				return err
+			}
+			err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_search_keys_trgm ON %[1]s USING gin (search_keys gin_trgm_ops)", v.table)).Error
+			if err != nil {
+				return fmt.Errorf("failed to index search keys of %s: %w", v.table, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AddTransliteratedSearchKeys) Down(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, v := range searchVectors {
+			if !keyedTables[v.table] {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS idx_%s_search_keys_trgm", v.table)).Error; err != nil {
+				return fmt.Errorf("failed to drop search keys index of %s: %w", v.table, err)
+			}
+			if err := replaceSearchVector(tx, v.table, v.vector); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillSearchKeys computes the search keys of every row of table from its
+// name column
+func backfillSearchKeys(tx *gorm.DB, table, column string) error {
+	var rows []struct {
+		ID   string
+		Name string
+	}
+	err := tx.Table(table).Select(fmt.Sprintf("id, %s AS name", column)).FindInBatches(&rows, 500, func(batch *gorm.DB, _ int) error {
+		for _, row := range rows {
+			err := tx.Exec(fmt.Sprintf("UPDATE %s SET search_keys = ? WHERE id = ?", table), translit.Keys(row.Name), row.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill search keys of %s: %w", table, err)
+	}
+	return nil
+}
+
+// replaceSearchVector redefines the generated search_vector column of table,
+// which Postgres cannot alter in place, and recreates its index
+func replaceSearchVector(tx *gorm.DB, table, vector string) error {
+	for _, statement := range []string{
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS search_vector", table),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (%s) STORED", table, vector),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_search_vector ON %[1]s USING gin (search_vector)", table),
+	} {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to replace search vector of %s: %w", table, err)
+		}
+	}
+	return nil
+}
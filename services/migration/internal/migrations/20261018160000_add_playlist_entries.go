@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// AddPlaylistEntries turns playlist_songs into entries with their own ID, so
+// a song can appear in a playlist more than once, and replaces the
+// client-supplied position with a server-maintained lexicographic rank.
+// Existing entries keep their order. Playlists get a snapshot_id that
+// changes with every edit of their entries.
+type AddPlaylistEntries struct{}
+
+func (m *AddPlaylistEntries) Version() string {
+	return "20261018160000"
+}
+
+func (m *AddPlaylistEntries) Name() string {
+	return "add_playlist_entries"
+}
+
+func (m *AddPlaylistEntries) Up(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE playlist_songs ADD COLUMN IF NOT EXISTS id uuid",
+			"UPDATE playlist_songs SET id = gen_random_uuid() WHERE id IS NULL",
+			"ALTER TABLE playlist_songs ALTER COLUMN id SET NOT NULL",
+			"ALTER TABLE playlist_songs ADD COLUMN IF NOT EXISTS rank text NOT NULL DEFAULT ''",
+		}
+		if tx.Migrator().HasColumn("playlist_songs", "position") {
+			statements = append(statements,
+				// Fixed-width ranks in the old order, ending in a non-zero digit
+				`UPDATE playlist_songs p SET rank = r.rank FROM (
+					SELECT playlist_id, song_id,
+						lpad(row_number() OVER (PARTITION BY playlist_id ORDER BY position, created_at)::text, 8, '0') || 'i' AS rank
+					FROM playlist_songs
+				) r WHERE p.playlist_id = r.playlist_id AND p.song_id = r.song_id`,
+				"ALTER TABLE playlist_songs DROP COLUMN position",
+			)
+		}
+		statements = append(statements,
+			"ALTER TABLE playlist_songs DROP CONSTRAINT IF EXISTS playlist_songs_pkey",
+			"ALTER TABLE playlist_songs ADD PRIMARY KEY (id)",
+			"CREATE INDEX IF NOT EXISTS idx_playlist_songs_rank ON playlist_songs (playlist_id, rank)",
+			"CREATE INDEX IF NOT EXISTS idx_playlist_songs_song_id ON playlist_songs (song_id)",
+			"ALTER TABLE playlists ADD COLUMN IF NOT EXISTS snapshot_id text",
+			"UPDATE playlists SET snapshot_id = gen_random_uuid()::text WHERE snapshot_id IS NULL OR snapshot_id = ''",
+		)
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to migrate playlist entries: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AddPlaylistEntries) Down(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			"ALTER TABLE playlist_songs ADD COLUMN IF NOT EXISTS position bigint NOT NULL DEFAULT 0",
+			`UPDATE playlist_songs p SET position = r.position FROM (
+				SELECT id, row_number() OVER (PARTITION BY playlist_id ORDER BY rank, id) - 1 AS position
+				FROM playlist_songs
+			) r WHERE p.id = r.id`,
+			// Keep the first entry of each song
+			`DELETE FROM playlist_songs a USING playlist_songs b
+				WHERE a.playlist_id = b.playlist_id AND a.song_id = b.song_id AND a.position > b.position`,
+			"ALTER TABLE playlist_songs DROP CONSTRAINT IF EXISTS playlist_songs_pkey",
+			"ALTER TABLE playlist_songs ADD PRIMARY KEY (playlist_id, song_id)",
+			"DROP INDEX IF EXISTS idx_playlist_songs_rank",
+			"ALTER TABLE playlist_songs DROP COLUMN IF EXISTS rank",
+			"ALTER TABLE playlist_songs DROP COLUMN IF EXISTS id",
+			"ALTER TABLE playlists DROP COLUMN IF EXISTS snapshot_id",
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to revert playlist entries: %w", err)
+			}
+		}
+		return nil
+	})
+}
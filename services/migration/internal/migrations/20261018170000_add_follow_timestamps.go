@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// AddFollowTimestamps records when users followed artists, indexes follows
+// by artist for counting and recomputes every artist's follower count from
+// the follows, which nothing kept in sync before
+type AddFollowTimestamps struct{}
+
+func (m *AddFollowTimestamps) Version() string {
+	return "20261018170000"
+}
+
+func (m *AddFollowTimestamps) Name() string {
+	return "add_follow_timestamps"
+}
+
+func (m *AddFollowTimestamps) Up(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			"ALTER TABLE user_follows_artist ADD COLUMN IF NOT EXISTS created_at timestamptz",
+			"CREATE INDEX IF NOT EXISTS idx_user_follows_artist_artist_id ON user_follows_artist (artist_id)",
+			"UPDATE artists SET followers = 0 WHERE followers IS NULL",
+			"ALTER TABLE artists ALTER COLUMN followers SET DEFAULT 0",
+			"ALTER TABLE artists ALTER COLUMN followers SET NOT NULL",
+			`UPDATE artists SET followers = counts.followers
+				FROM (SELECT artists.id, count(user_follows_artist.user_id) AS followers
+					FROM artists LEFT JOIN user_follows_artist ON user_follows_artist.artist_id = artists.id
+					GROUP BY artists.id) counts
+				WHERE artists.id = counts.id AND artists.followers <> counts.followers`,
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to migrate follows: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AddFollowTimestamps) Down(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			"DROP INDEX IF EXISTS idx_user_follows_artist_artist_id",
+			"ALTER TABLE user_follows_artist DROP COLUMN IF EXISTS created_at",
+			"ALTER TABLE artists ALTER COLUMN followers DROP NOT NULL",
+			"ALTER TABLE artists ALTER COLUMN followers DROP DEFAULT",
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to revert follows: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -6,5 +6,11 @@ import "go-audio-stream/services/migration/internal/runner"
 func GetMigrations() []runner.Migration {
 	return []runner.Migration{
 		&AddARRahmanShowkali{},
+		&AddAchamYenbadhuMadamaiyadaAlbum{},
+		&AddRolesToSongCredits{},
+		&AddCatalogSearch{},
+		&AddTransliteratedSearchKeys{},
+		&AddPlaylistEntries{},
+		&AddFollowTimestamps{},
 	}
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"go-audio-stream/pkg/database"
+	"go-audio-stream/pkg/media/ffmpeg"
+	"go-audio-stream/pkg/media/transcode"
+	"go-audio-stream/pkg/storage"
+	"go-audio-stream/services/transcoder/internal/worker"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	runner := ffmpeg.NewRunner(os.Getenv("FFMPEG_PATH"))
+	if err := runner.Available(); err != nil {
+		log.Fatalf("Transcoder requires ffmpeg: %v", err)
+	}
+
+	storageClient, err := storage.NewBackend(storage.LoadConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage backend: %v", err)
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	workers := envInt("TRANSCODE_WORKERS", 1)
+	pollInterval := time.Duration(envInt("TRANSCODE_POLL_SECONDS", 5)) * time.Second
+	lease := time.Duration(envInt("TRANSCODE_LEASE_MINUTES", 30)) * time.Minute
+
+	queue := worker.NewDBQueue(db.GetDB(), lease, envInt("TRANSCODE_MAX_ATTEMPTS", 3))
+	encoder := transcode.NewFFmpegEncoder(runner)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.New(storageClient, queue, encoder, pollInterval).Run(ctx)
+		}()
+	}
+	log.Printf("Transcoder running with %d workers", workers)
+
+	<-ctx.Done()
+	log.Println("shutting down, waiting for running encodes")
+	wg.Wait()
+	log.Println("Transcoder exiting")
+}
+
+// envInt reads a positive integer from the environment, returning fallback
+// when it is unset or invalid
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go-audio-stream/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// claimable matches pending assets and assets whose lease has run out with
+// attempts left. Claims count as attempts, so a master that kills its worker
+// is not retried forever.
+const claimable = "(status = ? OR (status = ? AND locked_until < ? AND attempts < ?))"
+
+// abandoned matches assets whose lease has run out without attempts left
+const abandoned = "status = ? AND locked_until < ? AND attempts >= ?"
+
+// Queue hands out pending renditions to workers
+type Queue interface {
+	// Claim leases the pending renditions of the oldest waiting master. It
+	// returns no assets when there is nothing to do.
+	Claim(ctx context.Context) ([]models.SongAsset, error)
+	// Complete marks an asset ready with its stored key and size
+	Complete(ctx context.Context, asset *models.SongAsset) error
+	// Fail records cause and requeues the asset until it runs out of attempts
+	Fail(ctx context.Context, asset *models.SongAsset, cause error) error
+}
+
+// DBQueue is a Queue over the song_assets table. Rows are claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED so several workers can share it, and a
+// lease returns assets to the queue if their worker dies.
+type DBQueue struct {
+	db          *gorm.DB
+	lease       time.Duration
+	maxAttempts int
+}
+
+// NewDBQueue creates a queue leasing assets for lease and giving up on an
+// asset after maxAttempts failures
+func NewDBQueue(db *gorm.DB, lease time.Duration, maxAttempts int) *DBQueue {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &DBQueue{
+		db:          db,
+		lease:       lease,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (q *DBQueue) Claim(ctx context.Context) ([]models.SongAsset, error) {
+	var assets []models.SongAsset
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		locking := clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}
+
+		err := tx.Model(&models.SongAsset{}).Where(abandoned, models.SongAssetProcessing, now, q.maxAttempts).
+			Updates(map[string]interface{}{
+				"status":       models.SongAssetFailed,
+				"error":        "Lease expired on the last attempt",
+				"locked_until": nil,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		var first models.SongAsset
+		err = tx.Clauses(locking).Where(claimable, models.SongAssetPending, models.SongAssetProcessing, now, q.maxAttempts).
+			Order("created_at").Limit(1).Find(&first).Error
+		if err != nil || first.ID == "" {
+			return err
+		}
+
+		// Encode every rendition of the master from one download
+		err = tx.Clauses(locking).Where(claimable, models.SongAssetPending, models.SongAssetProcessing, now, q.maxAttempts).
+			Where("song_id = ? AND source_key = ?", first.SongID, first.SourceKey).
+			Order("created_at").Find(&assets).Error
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, len(assets))
+		lockedUntil := now.Add(q.lease)
+		for i := range assets {
+			ids[i] = assets[i].ID
+			assets[i].Status = models.SongAssetProcessing
+			assets[i].LockedUntil = &lockedUntil
+			assets[i].Attempts++
+		}
+		return tx.Model(&models.SongAsset{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       models.SongAssetProcessing,
+			"locked_until": lockedUntil,
+			"attempts":     gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func (q *DBQueue) Complete(ctx context.Context, asset *models.SongAsset) error {
+	asset.Status = models.SongAssetReady
+	asset.Error = ""
+	asset.LockedUntil = nil
+	return q.db.WithContext(ctx).Model(asset).Updates(map[string]interface{}{
+		"status":       asset.Status,
+		"key":          asset.Key,
+		"size":         asset.Size,
+		"error":        "",
+		"locked_until": nil,
+	}).Error
+}
+
+func (q *DBQueue) Fail(ctx context.Context, asset *models.SongAsset, cause error) error {
+	asset.Status = models.SongAssetPending
+	if asset.Attempts >= q.maxAttempts {
+		asset.Status = models.SongAssetFailed
+	}
+	asset.Error = cause.Error()
+	asset.LockedUntil = nil
+	return q.db.WithContext(ctx).Model(asset).Updates(map[string]interface{}{
+		"status":       asset.Status,
+		"error":        asset.Error,
+		"locked_until": nil,
+	}).Error
+}
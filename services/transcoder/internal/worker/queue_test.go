@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// dryPool lets dry-run transactions begin and commit without a database
+type dryPool struct{}
+
+// dryTx is a transaction begun on a dryPool
+type dryTx struct {
+	*dryPool
+}
+
+var errDryRun = errors.New("dry run")
+
+func (*dryPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errDryRun
+}
+
+func (*dryPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errDryRun
+}
+
+func (*dryPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errDryRun
+}
+
+func (*dryPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func (p *dryPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return &dryTx{p}, nil
+}
+
+func (*dryTx) Commit() error   { return nil }
+func (*dryTx) Rollback() error { return nil }
+
+func TestClaimGivesUpOnExpiredLeases(t *testing.T) {
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true, ConnPool: &dryPool{}})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	var statements []string
+	record := func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	db.Callback().Query().After("gorm:query").Register("test:record", record)
+	db.Callback().Update().After("gorm:update").Register("test:record", record)
+
+	assets, err := NewDBQueue(db, time.Minute, 3).Claim(context.Background())
+	if err != nil || len(assets) != 0 {
+		t.Fatalf("Claim() = %v, %v", assets, err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("Claim() ran %d statements: %v", len(statements), statements)
+	}
+
+	// Leases that ran out on the last attempt fail instead of being retried
+	fail := statements[0]
+	if !strings.HasPrefix(fail, "UPDATE `song_assets` SET") || !strings.Contains(fail, `"failed"`) || !strings.Contains(fail, "attempts >= 3") {
+		t.Errorf("Claim() did not fail abandoned assets: %s", fail)
+	}
+	if claim := statements[1]; !strings.Contains(claim, "locked_until <") || !strings.Contains(claim, "attempts < 3") {
+		t.Errorf("Claim() reclaims expired leases without attempts left: %s", claim)
+	}
+}
@@ -0,0 +1,171 @@
+// Package worker encodes pending song renditions from the queue.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"go-audio-stream/pkg/media/transcode"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+)
+
+// RenditionKey returns the storage key of an encoded rendition
+func RenditionKey(songID string, r transcode.Rendition) string {
+	return fmt.Sprintf("songs/%s/renditions/%s%s", songID, r.Name, r.Extension)
+}
+
+// Worker claims pending renditions, encodes them from the master and
+// uploads the results next to it
+type Worker struct {
+	storage      storage.Backend
+	queue        Queue
+	encoder      transcode.Encoder
+	pollInterval time.Duration
+}
+
+// New creates a worker checking the queue every pollInterval while idle
+func New(storageClient storage.Backend, queue Queue, encoder transcode.Encoder, pollInterval time.Duration) *Worker {
+	return &Worker{
+		storage:      storageClient,
+		queue:        queue,
+		encoder:      encoder,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run processes the queue until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		processed, err := w.ProcessNext(ctx)
+		if err != nil {
+			log.Printf("Transcode failed: %v", err)
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// ProcessNext encodes the next claimed batch of renditions. It reports
+// whether there was anything to do. Each rendition is completed or failed
+// on its own; the returned error covers the batch as a whole.
+func (w *Worker) ProcessNext(ctx context.Context) (bool, error) {
+	assets, err := w.queue.Claim(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim renditions: %w", err)
+	}
+	if len(assets) == 0 {
+		return false, nil
+	}
+
+	dir, err := os.MkdirTemp("", "transcode-*")
+	if err != nil {
+		return true, w.failAll(ctx, assets, fmt.Errorf("failed to create work directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+
+	source := assets[0].SourceKey
+	master := filepath.Join(dir, "master"+path.Ext(source))
+	if err := w.download(ctx, source, master); err != nil {
+		return true, w.failAll(ctx, assets, err)
+	}
+
+	var failed int
+	for i := range assets {
+		asset := &assets[i]
+		started := time.Now()
+		if err := w.encode(ctx, asset, master, dir); err != nil {
+			failed++
+			log.Printf("Rendition %s failed for song %s: %v", asset.Rendition, asset.SongID, err)
+			if err := w.queue.Fail(ctx, asset, err); err != nil {
+				log.Printf("Failed to record failure of rendition %s: %v", asset.ID, err)
+			}
+			continue
+		}
+		if err := w.queue.Complete(ctx, asset); err != nil {
+			return true, fmt.Errorf("failed to complete rendition %s: %w", asset.ID, err)
+		}
+		log.Printf("Rendition %s completed for song %s in %s", asset.Rendition, asset.SongID, time.Since(started).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		return true, fmt.Errorf("%d of %d renditions failed for song %s", failed, len(assets), assets[0].SongID)
+	}
+	return true, nil
+}
+
+// encode produces one rendition and uploads it, filling in its key and size
+func (w *Worker) encode(ctx context.Context, asset *models.SongAsset, master, dir string) error {
+	rendition, ok := transcode.Lookup(asset.Rendition)
+	if !ok {
+		return fmt.Errorf("unknown rendition %q", asset.Rendition)
+	}
+
+	output := filepath.Join(dir, rendition.Name+rendition.Extension)
+	if err := w.encoder.Encode(ctx, master, output, rendition); err != nil {
+		return err
+	}
+	defer os.Remove(output)
+
+	file, err := os.Open(output)
+	if err != nil {
+		return fmt.Errorf("failed to open encoded file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat encoded file: %w", err)
+	}
+
+	key, err := w.storage.Upload(ctx, RenditionKey(asset.SongID, rendition), file, rendition.ContentType)
+	if err != nil {
+		return fmt.Errorf("failed to upload rendition: %w", err)
+	}
+
+	asset.Key = key
+	asset.Size = info.Size()
+	return nil
+}
+
+func (w *Worker) download(ctx context.Context, key, dest string) error {
+	reader, _, err := w.storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download master: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create local copy: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to download master: %w", err)
+	}
+	return file.Close()
+}
+
+// failAll records err on every asset of a batch that could not start
+func (w *Worker) failAll(ctx context.Context, assets []models.SongAsset, err error) error {
+	for i := range assets {
+		if failErr := w.queue.Fail(ctx, &assets[i], err); failErr != nil {
+			log.Printf("Failed to record failure of rendition %s: %v", assets[i].ID, failErr)
+		}
+	}
+	return err
+}
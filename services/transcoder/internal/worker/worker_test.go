@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go-audio-stream/pkg/media/transcode"
+	"go-audio-stream/pkg/models"
+	"go-audio-stream/pkg/storage"
+)
+
+// fakeEncoder writes the rendition name followed by the input instead of
+// running ffmpeg
+type fakeEncoder struct {
+	fail map[string]bool
+}
+
+func (e *fakeEncoder) Encode(ctx context.Context, input, output string, r transcode.Rendition) error {
+	if e.fail[r.Name] {
+		return errors.New("encoder exploded")
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append([]byte(r.Name+":"), data...), 0o644)
+}
+
+// memoryQueue hands out its assets once
+type memoryQueue struct {
+	pending   []models.SongAsset
+	completed map[string]models.SongAsset
+	failed    map[string]string
+}
+
+func (q *memoryQueue) Claim(ctx context.Context) ([]models.SongAsset, error) {
+	assets := q.pending
+	q.pending = nil
+	return assets, nil
+}
+
+func (q *memoryQueue) Complete(ctx context.Context, asset *models.SongAsset) error {
+	q.completed[asset.Rendition] = *asset
+	return nil
+}
+
+func (q *memoryQueue) Fail(ctx context.Context, asset *models.SongAsset, cause error) error {
+	q.failed[asset.Rendition] = cause.Error()
+	return nil
+}
+
+func TestWorkerProcessNext(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalClient(storage.Config{
+		LocalPath:    t.TempDir(),
+		LocalBaseURL: "http://localhost:4000",
+		SigningKey:   "test-key",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	if _, err := store.Upload(ctx, "songs/s1/audio.flac", strings.NewReader("master"), "audio/flac"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	queue := &memoryQueue{completed: map[string]models.SongAsset{}, failed: map[string]string{}}
+	for _, name := range []string{"opus_96", "aac_128", "mp3_320"} {
+		queue.pending = append(queue.pending, models.SongAsset{SongID: "s1", Rendition: name, SourceKey: "songs/s1/audio.flac"})
+	}
+	w := New(store, queue, &fakeEncoder{fail: map[string]bool{"mp3_320": true}}, 0)
+
+	processed, err := w.ProcessNext(ctx)
+	if !processed || err == nil {
+		t.Fatalf("ProcessNext() = %v, %v, want processed with a failure", processed, err)
+	}
+
+	opus := queue.completed["opus_96"]
+	if opus.Key != "songs/s1/renditions/opus_96.opus" || opus.Size != int64(len("opus_96:master")) {
+		t.Errorf("opus_96 completed as key %q size %d", opus.Key, opus.Size)
+	}
+	reader, info, err := store.Get(ctx, opus.Key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer reader.Close()
+	body, _ := io.ReadAll(reader)
+	if string(body) != "opus_96:master" || info.ContentType != "audio/ogg" {
+		t.Errorf("stored rendition = %q (%s)", body, info.ContentType)
+	}
+
+	if _, ok := queue.completed["aac_128"]; !ok {
+		t.Error("aac_128 was not completed")
+	}
+	if queue.failed["mp3_320"] != "encoder exploded" {
+		t.Errorf("mp3_320 failure = %q", queue.failed["mp3_320"])
+	}
+
+	processed, err = w.ProcessNext(ctx)
+	if processed || err != nil {
+		t.Errorf("ProcessNext() on empty queue = %v, %v", processed, err)
+	}
+}
+
+func TestWorkerMissingMaster(t *testing.T) {
+	store, err := storage.NewLocalClient(storage.Config{LocalPath: t.TempDir(), LocalBaseURL: "http://localhost:4000"})
+	if err != nil {
+		t.Fatalf("NewLocalClient() error = %v", err)
+	}
+	queue := &memoryQueue{completed: map[string]models.SongAsset{}, failed: map[string]string{}}
+	queue.pending = []models.SongAsset{{SongID: "s1", Rendition: "opus_96", SourceKey: "songs/s1/audio.mp3"}}
+
+	processed, err := New(store, queue, &fakeEncoder{}, 0).ProcessNext(context.Background())
+	if !processed || err == nil {
+		t.Fatalf("ProcessNext() = %v, %v, want download failure", processed, err)
+	}
+	if queue.failed["opus_96"] == "" {
+		t.Error("opus_96 was not failed")
+	}
+}